@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RemoteAPIKeyExchanger trades API keys for access tokens at the user service.
+// Tokens are cached until shortly before they expire, so a revoked key may keep working for as long as a token lives.
+type RemoteAPIKeyExchanger struct {
+	url string
+
+	mu     sync.Mutex
+	tokens map[[sha256.Size]byte]exchangedToken
+}
+
+type exchangedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// exchangeMargin keeps cached tokens from expiring between the exchange and the request they are used for
+const exchangeMargin = 30 * time.Second
+
+func NewRemoteAPIKeyExchanger(url string) *RemoteAPIKeyExchanger {
+	return &RemoteAPIKeyExchanger{url: url, tokens: make(map[[sha256.Size]byte]exchangedToken)}
+}
+
+func (e *RemoteAPIKeyExchanger) ExchangeAPIKey(ctx context.Context, key string) (string, error) {
+	// keys are kept by their hash, so a dump of the cache doesn't leak them
+	sum := sha256.Sum256([]byte(key))
+	now := time.Now()
+
+	e.mu.Lock()
+	cached, ok := e.tokens[sum]
+	e.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	exchanged, err := e.exchange(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for k, t := range e.tokens {
+		if !now.Before(t.expiresAt) {
+			delete(e.tokens, k)
+		}
+	}
+	e.tokens[sum] = exchanged
+	return exchanged.token, nil
+}
+
+func (e *RemoteAPIKeyExchanger) exchange(ctx context.Context, key string) (exchangedToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, nil)
+	if err != nil {
+		return exchangedToken{}, err
+	}
+	req.Header.Add("Authorization", "ApiKey "+key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return exchangedToken{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return exchangedToken{}, fmt.Errorf("%w: api key exchange returned status code %d", ErrInvalidToken, resp.StatusCode)
+	}
+
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return exchangedToken{}, err
+	}
+	return exchangedToken{token: body.Token, expiresAt: body.ExpiresAt.Add(-exchangeMargin)}, nil
+}
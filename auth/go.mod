@@ -2,7 +2,10 @@ module github.com/gabrielseibel1/gaef/auth
 
 go 1.19
 
-require github.com/gin-gonic/gin v1.9.0
+require (
+	github.com/gin-gonic/gin v1.9.0
+	github.com/golang-jwt/jwt v3.2.2+incompatible
+)
 
 require (
 	github.com/bytedance/sonic v1.8.0 // indirect
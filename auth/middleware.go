@@ -4,10 +4,12 @@ import (
 	"context"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strings"
 )
 
 type MiddlewareGenerator struct {
 	reader           tokenReader
+	apiKeys          apiKeyExchanger
 	contextUserIDKey string
 	contextTokenKey  string
 }
@@ -16,30 +18,118 @@ type tokenReader interface {
 	ReadToken(ctx context.Context, token string) (string, error)
 }
 
-func NewMiddlewareGenerator(reader tokenReader, contextUserIDKey, contextTokenKey string) MiddlewareGenerator {
-	return MiddlewareGenerator{reader: reader, contextUserIDKey: contextUserIDKey, contextTokenKey: contextTokenKey}
+// apiKeyExchanger trades API keys for access tokens, which are then read like any other
+type apiKeyExchanger interface {
+	ExchangeAPIKey(ctx context.Context, key string) (string, error)
+}
+
+// claimsReader is implemented by token readers that know more about the user than its ID
+type claimsReader interface {
+	ReadClaims(ctx context.Context, token string) (Claims, error)
+}
+
+// Claims are the facts about the authenticated user carried by its token
+type Claims struct {
+	UserID   string
+	Verified bool
+	Scopes   []string // nil for tokens of a session, which may do anything
+}
+
+// scopes of tokens issued for API keys
+const (
+	ScopeRead  = "read"  // safe methods only
+	ScopeWrite = "write" // any method, as writes often need reads
+)
+
+// Allows tells if the token may be used for a request with the given method
+func (c Claims) Allows(method string) bool {
+	if c.Scopes == nil {
+		return true
+	}
+	for _, s := range c.Scopes {
+		switch {
+		case s == ScopeWrite:
+			return true
+		case s == ScopeRead && (method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions):
+			return true
+		}
+	}
+	return false
+}
+
+const contextClaimsKey = "auth.claims"
+
+// NewMiddlewareGenerator creates a MiddlewareGenerator that reads Bearer tokens with reader.
+// API keys are exchanged for tokens with apiKeys, or rejected if it is nil.
+func NewMiddlewareGenerator(reader tokenReader, apiKeys apiKeyExchanger, contextUserIDKey, contextTokenKey string) MiddlewareGenerator {
+	return MiddlewareGenerator{reader: reader, apiKeys: apiKeys, contextUserIDKey: contextUserIDKey, contextTokenKey: contextTokenKey}
 }
 
 func (g MiddlewareGenerator) AuthMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		authHeader := ctx.GetHeader("Authorization")
-		if authHeader == "" || len(authHeader) <= len("Bearer ") {
+		token, err := g.token(ctx)
+		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorUnauthorized)
 			return
 		}
-		token := authHeader[len("Bearer "):]
 
-		userID, err := g.reader.ReadToken(ctx, token)
+		var claims Claims
+		if cr, ok := g.reader.(claimsReader); ok {
+			claims, err = cr.ReadClaims(ctx, token)
+		} else {
+			claims.UserID, err = g.reader.ReadToken(ctx, token)
+		}
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorUnauthorized)
 			return
 		}
+		if !claims.Allows(ctx.Request.Method) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorInsufficientScope)
+			return
+		}
 
 		ctx.Set(g.contextTokenKey, token)
-		ctx.Set(g.contextUserIDKey, userID)
+		ctx.Set(g.contextUserIDKey, claims.UserID)
+		ctx.Set(contextClaimsKey, claims)
 
 		ctx.Next()
 	}
 }
 
-var errorUnauthorized = gin.H{"error": "unauthorized"}
+// token returns the access token of the request, given as a Bearer token or in exchange for an API key
+func (g MiddlewareGenerator) token(ctx *gin.Context) (string, error) {
+	authHeader := ctx.GetHeader("Authorization")
+	if token, ok := cutScheme(authHeader, "Bearer "); ok {
+		return token, nil
+	}
+	if key, ok := cutScheme(authHeader, "ApiKey "); ok && g.apiKeys != nil {
+		return g.apiKeys.ExchangeAPIKey(ctx, key)
+	}
+	return "", ErrInvalidToken
+}
+
+func cutScheme(authHeader, scheme string) (string, bool) {
+	if len(authHeader) <= len(scheme) || !strings.EqualFold(authHeader[:len(scheme)], scheme) {
+		return "", false
+	}
+	return authHeader[len(scheme):], true
+}
+
+// VerifiedMiddleware only lets users with a verified email through. It must come after AuthMiddleware.
+func (g MiddlewareGenerator) VerifiedMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := ctx.Value(contextClaimsKey).(Claims)
+		if !ok || !claims.Verified {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorUnverified)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+var (
+	errorUnauthorized      = gin.H{"error": "unauthorized"}
+	errorUnverified        = gin.H{"error": "email not verified"}
+	errorInsufficientScope = gin.H{"error": "insufficient scope"}
+)
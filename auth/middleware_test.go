@@ -2,13 +2,20 @@ package auth_test
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gabrielseibel1/gaef/auth"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestAPI_AuthMiddleware_OK(t *testing.T) {
@@ -33,6 +40,7 @@ func TestAPI_AuthMiddleware_OK(t *testing.T) {
 
 	auth.NewMiddlewareGenerator(
 		&mockAuthenticator,
+		nil,
 		"userID",
 		"token",
 	).AuthMiddleware()(c)
@@ -88,6 +96,7 @@ func TestAPI_AuthMiddleware_AuthenticatorError(t *testing.T) {
 
 	auth.NewMiddlewareGenerator(
 		&mockAuthenticator,
+		nil,
 		"userID",
 		"token",
 	).AuthMiddleware()(c)
@@ -146,6 +155,7 @@ func TestAPI_AuthMiddleware_MissingHeader(t *testing.T) {
 
 	auth.NewMiddlewareGenerator(
 		&mockAuthenticator,
+		nil,
 		"userID",
 		"token",
 	).AuthMiddleware()(c)
@@ -194,3 +204,545 @@ func (ma *mockAuthenticatedUserIDGetter) ReadToken(ctx context.Context, token st
 	ma.token = token
 	return ma.id, ma.err
 }
+
+func TestAPI_AuthMiddleware_JWTReader(t *testing.T) {
+	key, otherKey := newKey(t), newKey(t)
+	keys := newKeySet(t, key)
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		wantUserID string
+	}{
+		{
+			name:       "valid token",
+			token:      signToken(t, jwt.SigningMethodRS256, key, "test-kid", "test-user-id", time.Hour),
+			wantStatus: http.StatusOK,
+			wantUserID: "test-user-id",
+		},
+		{
+			name:       "expired token",
+			token:      signToken(t, jwt.SigningMethodRS256, key, "test-kid", "test-user-id", -time.Minute),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong key",
+			token:      signToken(t, jwt.SigningMethodRS256, otherKey, "test-kid", "test-user-id", time.Hour),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unexpected signing method",
+			token:      signToken(t, jwt.SigningMethodHS256, []byte("test-secret"), "test-kid", "test-user-id", time.Hour),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "missing subject",
+			token:      signToken(t, jwt.SigningMethodRS256, key, "test-kid", "", time.Hour),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "malformed token",
+			token:      "test-token",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "token for another purpose",
+			token: signClaims(t, key, jwt.MapClaims{
+				"sub":     "test-user-id",
+				"purpose": "email-verification",
+				"exp":     time.Now().Add(time.Hour).Unix(),
+			}),
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// run code under test
+			w, c := runAuthMiddleware(auth.NewJWTReader(keys), tt.token)
+
+			// assertions
+			if got, want := w.Result().StatusCode, tt.wantStatus; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := c.GetString("userID"), tt.wantUserID; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := c.IsAborted(), tt.wantStatus != http.StatusOK; got != want {
+				t.Fatalf("got aborted %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestAPI_AuthMiddleware_RemoteKeySet_UserServiceUnreachable(t *testing.T) {
+	// prepare test setup
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(gin.H{"keys": []auth.JWK{auth.NewJWK("test-kid", &key.PublicKey)}})
+	}))
+	url := userService.URL
+	token := signToken(t, jwt.SigningMethodRS256, key, "test-kid", "test-user-id", time.Hour)
+
+	// a zero ttl and refetch interval make every request try to refresh the keys
+	cachingReader := auth.NewJWTReader(auth.NewRemoteKeySet(url, 0, 0))
+
+	// run code under test and assertions
+
+	// keys are fetched while the user service is up
+	w, c := runAuthMiddleware(cachingReader, token)
+	if got, want := w.Result().StatusCode, http.StatusOK; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := c.GetString("userID"), "test-user-id"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	userService.Close()
+
+	// cached keys are still used when the user service is unreachable
+	w, c = runAuthMiddleware(cachingReader, token)
+	if got, want := w.Result().StatusCode, http.StatusOK; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := c.GetString("userID"), "test-user-id"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// expired tokens are rejected even with cached keys
+	expired := signToken(t, jwt.SigningMethodRS256, key, "test-kid", "test-user-id", -time.Minute)
+	w, _ = runAuthMiddleware(cachingReader, expired)
+	if got, want := w.Result().StatusCode, http.StatusUnauthorized; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// with no cached keys, tokens can't be verified
+	w, c = runAuthMiddleware(auth.NewJWTReader(auth.NewRemoteKeySet(url, time.Hour, 0)), token)
+	if got, want := w.Result().StatusCode, http.StatusUnauthorized; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !c.IsAborted() {
+		t.Fatalf("context was not aborted")
+	}
+}
+
+func TestAPI_AuthMiddleware_RemoteKeySet_UnknownKey(t *testing.T) {
+	// prepare test setup
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetches := 0
+	userService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		_ = json.NewEncoder(w).Encode(gin.H{"keys": []auth.JWK{auth.NewJWK("test-kid", &key.PublicKey)}})
+	}))
+	defer userService.Close()
+	reader := auth.NewJWTReader(auth.NewRemoteKeySet(userService.URL, time.Hour, 0))
+
+	// run code under test
+	w1, _ := runAuthMiddleware(reader, signToken(t, jwt.SigningMethodRS256, key, "test-kid", "test-user-id", time.Hour))
+	w2, _ := runAuthMiddleware(reader, signToken(t, jwt.SigningMethodRS256, otherKey, "other-kid", "test-user-id", time.Hour))
+	w3, _ := runAuthMiddleware(reader, signToken(t, jwt.SigningMethodRS256, otherKey, "test-kid", "test-user-id", time.Hour))
+
+	// assertions
+	if got, want := w1.Result().StatusCode, http.StatusOK; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := w2.Result().StatusCode, http.StatusUnauthorized; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := w3.Result().StatusCode, http.StatusUnauthorized; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// an unknown kid triggers a refresh, a known one within ttl doesn't
+	if got, want := fetches, 2; got != want {
+		t.Fatalf("got %v fetches, want %v", got, want)
+	}
+}
+
+func TestAPI_AuthMiddleware_RemoteKeySet_MinRefetchInterval(t *testing.T) {
+	// prepare test setup
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	userService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(10 * time.Millisecond) // long enough for concurrent requests to wait on the fetch
+		_ = json.NewEncoder(w).Encode(gin.H{"keys": []auth.JWK{auth.NewJWK("test-kid", &key.PublicKey)}})
+	}))
+	defer userService.Close()
+	reader := auth.NewJWTReader(auth.NewRemoteKeySet(userService.URL, time.Hour, time.Hour))
+	token := signToken(t, jwt.SigningMethodRS256, key, "test-kid", "test-user-id", time.Hour)
+
+	// run code under test
+	var wg sync.WaitGroup
+	codes := make([]int, 10)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w, _ := runAuthMiddleware(reader, token)
+			codes[i] = w.Result().StatusCode
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 10; i++ {
+		w, _ := runAuthMiddleware(reader, signToken(t, jwt.SigningMethodRS256, otherKey, fmt.Sprintf("other-kid-%d", i), "test-user-id", time.Hour))
+		if got, want := w.Result().StatusCode, http.StatusUnauthorized; got != want {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	// assertions
+	for _, code := range codes {
+		if got, want := code, http.StatusOK; got != want {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	// concurrent requests share a fetch, and unknown kids don't refetch within the interval
+	if got, want := atomic.LoadInt32(&fetches), int32(1); got != want {
+		t.Fatalf("got %v fetches, want %v", got, want)
+	}
+}
+
+func TestRemoteKeySet_FetchOutlivesCanceledRequest(t *testing.T) {
+	// prepare test setup
+	key := newKey(t)
+	var fetches int32
+	fetchStarted := make(chan struct{})
+	userService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			close(fetchStarted)
+		}
+		time.Sleep(50 * time.Millisecond) // long enough for the request that started the fetch to be canceled
+		_ = json.NewEncoder(w).Encode(gin.H{"keys": []auth.JWK{auth.NewJWK("test-kid", &key.PublicKey)}})
+	}))
+	defer userService.Close()
+	keySet := auth.NewRemoteKeySet(userService.URL, time.Hour, time.Hour)
+	token := &jwt.Token{Method: jwt.SigningMethodRS256, Header: map[string]interface{}{"kid": "test-kid"}}
+
+	// run code under test
+	ctx, cancel := context.WithCancel(context.Background())
+	canceledErr := make(chan error)
+	go func() {
+		_, err := keySet.VerificationKey(ctx, token)
+		canceledErr <- err
+	}()
+	<-fetchStarted
+	cancel()
+	got, err := keySet.VerificationKey(context.Background(), token)
+
+	// assertions
+	if err := <-canceledErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+	if err != nil {
+		t.Fatalf("got %v, want no error", err)
+	}
+	if got, want := got.(*rsa.PublicKey), &key.PublicKey; !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := atomic.LoadInt32(&fetches), int32(1); got != want {
+		t.Fatalf("got %v fetches, want %v", got, want)
+	}
+}
+
+func TestRemoteKeySet_SkipsMalformedKeys(t *testing.T) {
+	// prepare test setup
+	key := newKey(t)
+	malformed := auth.NewJWK("malformed-kid", &key.PublicKey)
+	malformed.N = "not base64url!"
+	userService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(gin.H{"keys": []auth.JWK{malformed, auth.NewJWK("test-kid", &key.PublicKey)}})
+	}))
+	defer userService.Close()
+	reader := auth.NewJWTReader(auth.NewRemoteKeySet(userService.URL, time.Hour, time.Minute))
+
+	// run code under test
+	w, c := runAuthMiddleware(reader, signToken(t, jwt.SigningMethodRS256, key, "test-kid", "test-user-id", time.Hour))
+
+	// assertions
+	if got, want := w.Result().StatusCode, http.StatusOK; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := c.GetString("userID"), "test-user-id"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid, sub string, ttl time.Duration) string {
+	token := jwt.NewWithClaims(method, jwt.MapClaims{
+		"sub": sub,
+		"exp": time.Now().Add(ttl).Unix(),
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// signClaims signs claims with the key that newKeySet publishes
+func signClaims(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-kid"
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// newKeySet publishes the public key as the one of kid test-kid, the way the user service does
+func newKeySet(t *testing.T, key *rsa.PrivateKey) auth.KeySet {
+	userService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(gin.H{"keys": []auth.JWK{auth.NewJWK("test-kid", &key.PublicKey)}})
+	}))
+	t.Cleanup(userService.Close)
+	return auth.NewRemoteKeySet(userService.URL, time.Hour, time.Minute)
+}
+
+func runAuthMiddleware(reader auth.JWTReader, token string) (*httptest.ResponseRecorder, *gin.Context) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = &http.Request{Header: make(http.Header)}
+	c.Request.Header.Add("Authorization", "Bearer "+token)
+	auth.NewMiddlewareGenerator(reader, nil, "userID", "token").AuthMiddleware()(c)
+	return w, c
+}
+
+func TestAPI_VerifiedMiddleware(t *testing.T) {
+	key := newKey(t)
+	keys := newKeySet(t, key)
+	tests := []struct {
+		name       string
+		claims     jwt.MapClaims
+		wantStatus int
+	}{
+		{
+			name:       "verified user",
+			claims:     jwt.MapClaims{"sub": "test-user-id", "verified": true, "exp": time.Now().Add(time.Hour).Unix()},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unverified user",
+			claims:     jwt.MapClaims{"sub": "test-user-id", "verified": false, "exp": time.Now().Add(time.Hour).Unix()},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "token without verified claim",
+			claims:     jwt.MapClaims{"sub": "test-user-id", "exp": time.Now().Add(time.Hour).Unix()},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// prepare test setup
+			token := signClaims(t, key, tt.claims)
+			g := auth.NewMiddlewareGenerator(auth.NewJWTReader(keys), nil, "userID", "token")
+			w, c := runAuthMiddleware(auth.NewJWTReader(keys), token)
+
+			// run code under test
+			g.VerifiedMiddleware()(c)
+
+			// assertions
+			if got, want := w.Result().StatusCode, tt.wantStatus; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := c.IsAborted(), tt.wantStatus != http.StatusOK; got != want {
+				t.Fatalf("got aborted %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestAPI_VerifiedMiddleware_ReaderWithoutClaims(t *testing.T) {
+	// prepare test setup
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = &http.Request{Header: make(http.Header)}
+	c.Request.Header.Add("Authorization", "Bearer test-token")
+	g := auth.NewMiddlewareGenerator(&mockAuthenticatedUserIDGetter{id: "test-user-id"}, nil, "userID", "token")
+
+	// run code under test
+	g.AuthMiddleware()(c)
+	g.VerifiedMiddleware()(c)
+
+	// assertions
+	if got, want := w.Result().StatusCode, http.StatusForbidden; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := c.GetString("userID"), "test-user-id"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_AuthMiddleware_APIKey(t *testing.T) {
+	key := newKey(t)
+	keys := newKeySet(t, key)
+	scopedToken := func(scope string) string {
+		return signClaims(t, key, jwt.MapClaims{
+			"sub":   "test-user-id",
+			"scope": scope,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		})
+	}
+	tokens := map[string]string{
+		"gaef_read-key":  scopedToken("read"),
+		"gaef_write-key": scopedToken("write"),
+	}
+	userService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := tokens[r.Header.Get("Authorization")[len("ApiKey "):]]
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(gin.H{"token": token, "expiresAt": time.Now().Add(time.Hour)})
+	}))
+	defer userService.Close()
+	exchanger := auth.NewRemoteAPIKeyExchanger(userService.URL)
+
+	tests := []struct {
+		name       string
+		exchanger  *auth.RemoteAPIKeyExchanger
+		header     string
+		method     string
+		wantStatus int
+		wantToken  string
+	}{
+		{
+			name:       "read key reads",
+			exchanger:  exchanger,
+			header:     "ApiKey gaef_read-key",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+			wantToken:  tokens["gaef_read-key"],
+		},
+		{
+			name:       "read key can't write",
+			exchanger:  exchanger,
+			header:     "ApiKey gaef_read-key",
+			method:     http.MethodPost,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "write key writes",
+			exchanger:  exchanger,
+			header:     "ApiKey gaef_write-key",
+			method:     http.MethodDelete,
+			wantStatus: http.StatusOK,
+			wantToken:  tokens["gaef_write-key"],
+		},
+		{
+			name:       "unknown key",
+			exchanger:  exchanger,
+			header:     "ApiKey gaef_unknown-key",
+			method:     http.MethodGet,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "api keys not accepted",
+			header:     "ApiKey gaef_read-key",
+			method:     http.MethodGet,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unknown scheme",
+			exchanger:  exchanger,
+			header:     "Basic dGVzdDp0ZXN0",
+			method:     http.MethodGet,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// prepare test setup
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = &http.Request{Method: tt.method, Header: make(http.Header)}
+			c.Request.Header.Add("Authorization", tt.header)
+			g := auth.NewMiddlewareGenerator(auth.NewJWTReader(keys), nil, "userID", "token")
+			if tt.exchanger != nil {
+				g = auth.NewMiddlewareGenerator(auth.NewJWTReader(keys), tt.exchanger, "userID", "token")
+			}
+
+			// run code under test
+			g.AuthMiddleware()(c)
+
+			// assertions
+			if got, want := w.Result().StatusCode, tt.wantStatus; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := c.GetString("token"), tt.wantToken; got != want {
+				t.Fatalf("got token %v, want %v", got, want)
+			}
+			if got, want := c.IsAborted(), tt.wantStatus != http.StatusOK; got != want {
+				t.Fatalf("got aborted %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRemoteAPIKeyExchanger_Cache(t *testing.T) {
+	// prepare test setup
+	exchanges := 0
+	expiresAt := time.Now().Add(time.Hour)
+	userService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges++
+		_ = json.NewEncoder(w).Encode(gin.H{"token": "token-for-" + r.Header.Get("Authorization"), "expiresAt": expiresAt})
+	}))
+	defer userService.Close()
+	exchanger := auth.NewRemoteAPIKeyExchanger(userService.URL)
+
+	// run code under test
+	first, err1 := exchanger.ExchangeAPIKey(context.Background(), "gaef_key")
+	second, err2 := exchanger.ExchangeAPIKey(context.Background(), "gaef_key")
+	expiresAt = time.Now().Add(time.Second)
+	other, err3 := exchanger.ExchangeAPIKey(context.Background(), "gaef_other-key")
+	_, err4 := exchanger.ExchangeAPIKey(context.Background(), "gaef_other-key")
+
+	// assertions
+	for _, err := range []error{err1, err2, err3, err4} {
+		if err != nil {
+			t.Fatalf("got error %v, want nil", err)
+		}
+	}
+	if got, want := first, "token-for-ApiKey gaef_key"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := second, first; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := other, "token-for-ApiKey gaef_other-key"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// tokens about to expire are exchanged again
+	if got, want := exchanges, 3; got != want {
+		t.Fatalf("got %v exchanges, want %v", got, want)
+	}
+}
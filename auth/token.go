@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWTReader is a tokenReader that verifies tokens locally, without calling the user service for each request
+type JWTReader struct {
+	keys KeySet
+}
+
+// KeySet provides the key to verify the signature of a token
+type KeySet interface {
+	VerificationKey(ctx context.Context, token *jwt.Token) (interface{}, error)
+}
+
+func NewJWTReader(keys KeySet) JWTReader {
+	return JWTReader{keys: keys}
+}
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrUnknownKey   = errors.New("unknown key")
+)
+
+// ReadToken verifies the signature and expiration of the token and returns its subject
+func (r JWTReader) ReadToken(ctx context.Context, tokenString string) (string, error) {
+	claims, err := r.ReadClaims(ctx, tokenString)
+	return claims.UserID, err
+}
+
+// ReadClaims verifies the signature and expiration of the token and returns its claims
+func (r JWTReader) ReadClaims(ctx context.Context, tokenString string) (Claims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return r.keys.VerificationKey(ctx, token)
+	})
+	if err != nil || !token.Valid {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return Claims{}, ErrInvalidToken
+	}
+	if _, ok := claims["exp"]; !ok {
+		return Claims{}, fmt.Errorf("%w: missing expiration", ErrInvalidToken)
+	}
+	// tokens signed for other purposes, such as email verification codes, don't grant access
+	if _, ok := claims["purpose"]; ok {
+		return Claims{}, fmt.Errorf("%w: not an access token", ErrInvalidToken)
+	}
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return Claims{}, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	verified, _ := claims["verified"].(bool)
+	c := Claims{UserID: sub, Verified: verified}
+	// tokens issued for API keys are limited to the scopes of the key
+	if scope, ok := claims["scope"].(string); ok {
+		c.Scopes = strings.Fields(scope)
+	}
+	return c, nil
+}
+
+// RemoteKeySet verifies RSA signed tokens with the public keys published by the user service as a JWK set.
+// Keys are cached for ttl, and cached keys keep being used while the user service is unreachable.
+// Keys are fetched at most once every minRefetchInterval, even for tokens with unknown kids, so that
+// made up kids can't flood the user service, and a single fetch at a time serves all the requests waiting on it.
+type RemoteKeySet struct {
+	url                string
+	ttl                time.Duration
+	minRefetchInterval time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	triedAt   time.Time
+	fetching  chan struct{} // closed once the fetch in flight is done, nil if there is none
+}
+
+// keySetFetchTimeout bounds fetching the key set, which doesn't end with the request that started it
+const keySetFetchTimeout = 10 * time.Second
+
+func NewRemoteKeySet(url string, ttl, minRefetchInterval time.Duration) *RemoteKeySet {
+	return &RemoteKeySet{url: url, ttl: ttl, minRefetchInterval: minRefetchInterval}
+}
+
+func (s *RemoteKeySet) VerificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+
+	for {
+		s.mu.Lock()
+		key, cached := s.keys[kid]
+		if cached && time.Since(s.fetchedAt) < s.ttl {
+			s.mu.Unlock()
+			return key, nil
+		}
+
+		// wait for the fetch in flight, then look again
+		if fetching := s.fetching; fetching != nil {
+			s.mu.Unlock()
+			select {
+			case <-fetching:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		// refresh when the cache is stale or the key is new to us, e.g. after a rotation, unless it was tried just now
+		if time.Since(s.triedAt) < s.minRefetchInterval {
+			s.mu.Unlock()
+			if cached {
+				return key, nil
+			}
+			return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+		}
+		fetching := make(chan struct{})
+		s.fetching = fetching
+		s.triedAt = time.Now()
+		s.mu.Unlock()
+
+		// the fetch runs on a context of its own, so that the requests waiting on it don't fail when the one
+		// that started it is canceled
+		var err error
+		go func() {
+			fetchCtx, cancel := context.WithTimeout(context.Background(), keySetFetchTimeout)
+			defer cancel()
+			var keys map[string]*rsa.PublicKey
+			keys, err = s.fetch(fetchCtx)
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if err == nil {
+				s.keys = keys
+				s.fetchedAt = time.Now()
+			}
+			s.fetching = nil
+			close(fetching)
+		}()
+
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		s.mu.Lock()
+		key, ok := s.keys[kid]
+		s.mu.Unlock()
+
+		if ok {
+			return key, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+}
+
+func (s *RemoteKeySet) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("key set request returned status code %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// one malformed key doesn't keep the others from being used
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// JWK is an RSA public key in the JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func NewJWK(kid string, key *rsa.PublicKey) JWK {
+	return JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: jwt.SigningMethodRS256.Alg(),
+		Kid: kid,
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func (k JWK) PublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
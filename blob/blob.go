@@ -0,0 +1,25 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Store keeps named blobs, such as uploaded pictures. Putting a name that exists replaces its blob.
+type Store interface {
+	Put(ctx context.Context, name, contentType string, r io.Reader) error
+	Get(ctx context.Context, name string) (io.ReadCloser, string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+var (
+	ErrNotFound    = errors.New("blob not found")
+	ErrInvalidName = errors.New("invalid blob name")
+)
+
+// validName keeps names flat, so that they can't escape the directory of a LocalStore
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
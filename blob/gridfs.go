@@ -0,0 +1,122 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GridFSStore keeps blobs in a GridFS bucket of MongoDB, with their content type in the metadata of the files
+type GridFSStore struct {
+	db         *mongo.Database
+	bucketName string
+}
+
+func NewGridFSStore(db *mongo.Database, bucketName string) GridFSStore {
+	return GridFSStore{db: db, bucketName: bucketName}
+}
+
+type metadata struct {
+	ContentType string `bson:"contentType"`
+}
+
+// bucket is opened per operation, as GridFS takes deadlines rather than contexts and they are set on the bucket
+func (gs GridFSStore) bucket(ctx context.Context) (*gridfs.Bucket, error) {
+	b, err := gridfs.NewBucket(gs.db, options.GridFSBucket().SetName(gs.bucketName))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := b.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		if err := b.SetWriteDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Put uploads a new revision of the file, and then removes the older ones
+func (gs GridFSStore) Put(ctx context.Context, name, contentType string, r io.Reader) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+	b, err := gs.bucket(ctx)
+	if err != nil {
+		return err
+	}
+	id, err := b.UploadFromStream(name, r, options.GridFSUpload().SetMetadata(metadata{ContentType: contentType}))
+	if err != nil {
+		return err
+	}
+	_, err = gs.deleteFiles(ctx, b, bson.M{"filename": name, "_id": bson.M{"$ne": id}})
+	return err
+}
+
+// Get opens the latest revision of the file
+func (gs GridFSStore) Get(ctx context.Context, name string) (io.ReadCloser, string, error) {
+	b, err := gs.bucket(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	stream, err := b.OpenDownloadStreamByName(name)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var m metadata
+	if raw := stream.GetFile().Metadata; raw != nil {
+		if err := bson.Unmarshal(raw, &m); err != nil {
+			stream.Close()
+			return nil, "", err
+		}
+	}
+	if m.ContentType == "" {
+		m.ContentType = "application/octet-stream"
+	}
+	return stream, m.ContentType, nil
+}
+
+func (gs GridFSStore) Delete(ctx context.Context, name string) error {
+	b, err := gs.bucket(ctx)
+	if err != nil {
+		return err
+	}
+	n, err := gs.deleteFiles(ctx, b, bson.M{"filename": name})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// deleteFiles deletes the files matching the filter, returning how many there were
+func (gs GridFSStore) deleteFiles(ctx context.Context, b *gridfs.Bucket, filter bson.M) (int, error) {
+	cursor, err := b.Find(filter)
+	if err != nil {
+		return 0, err
+	}
+	var files []struct {
+		ID interface{} `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &files); err != nil {
+		return 0, err
+	}
+	for _, f := range files {
+		if err := b.Delete(f.ID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
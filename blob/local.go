@@ -0,0 +1,76 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore keeps blobs as files of a directory, telling their content type by the extension of their names
+type LocalStore struct {
+	dir string
+}
+
+func NewLocalStore(dir string) (LocalStore, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return LocalStore{}, err
+	}
+	return LocalStore{dir: dir}, nil
+}
+
+// Put writes the blob to a temporary file first, so that readers never see it partially written
+func (ls LocalStore) Put(ctx context.Context, name, contentType string, r io.Reader) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+	f, err := os.CreateTemp(ls.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(ls.dir, name))
+}
+
+func (ls LocalStore) Get(ctx context.Context, name string) (io.ReadCloser, string, error) {
+	if !validName(name) {
+		return nil, "", ErrNotFound
+	}
+	f, err := os.Open(filepath.Join(ls.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return f, contentType, nil
+}
+
+func (ls LocalStore) Delete(ctx context.Context, name string) error {
+	if !validName(name) {
+		return ErrNotFound
+	}
+	err := os.Remove(filepath.Join(ls.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
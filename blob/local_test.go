@@ -0,0 +1,72 @@
+package blob_test
+
+import (
+	"context"
+	"errors"
+	"github.com/gabrielseibel1/gaef/blob"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	// prepare test setup
+	s, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+
+	// run code under test and assertions
+	if err := s.Put(context.TODO(), "dummy.png", "image/png", strings.NewReader("dummy-data")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := s.Put(context.TODO(), "dummy.png", "image/png", strings.NewReader("other-data")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	rc, contentType, err := s.Get(context.TODO(), "dummy.png")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if got, want := string(data), "other-data"; got != want {
+		t.Errorf("got data %s, want %s", got, want)
+	}
+	if got, want := contentType, "image/png"; got != want {
+		t.Errorf("got content type %s, want %s", got, want)
+	}
+
+	if err := s.Delete(context.TODO(), "dummy.png"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, _, err := s.Get(context.TODO(), "dummy.png"); !errors.Is(err, blob.ErrNotFound) {
+		t.Errorf("Get() after delete error = %v, want %v", err, blob.ErrNotFound)
+	}
+	if err := s.Delete(context.TODO(), "dummy.png"); !errors.Is(err, blob.ErrNotFound) {
+		t.Errorf("Delete() after delete error = %v, want %v", err, blob.ErrNotFound)
+	}
+}
+
+func TestLocalStore_InvalidName(t *testing.T) {
+	// prepare test setup
+	s, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+
+	for _, name := range []string{"", "..", "../dummy.png", "dummy/dummy.png"} {
+		// run code under test
+		err := s.Put(context.TODO(), name, "image/png", strings.NewReader("dummy-data"))
+
+		// assertions
+		if !errors.Is(err, blob.ErrInvalidName) {
+			t.Errorf("Put(%q) error = %v, want %v", name, err, blob.ErrInvalidName)
+		}
+		if _, _, err := s.Get(context.TODO(), name); !errors.Is(err, blob.ErrNotFound) {
+			t.Errorf("Get(%q) error = %v, want %v", name, err, blob.ErrNotFound)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package picture
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gabrielseibel1/gaef/blob"
+)
+
+// Picture is where an uploaded picture and its thumbnail are served from
+type Picture struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+}
+
+var (
+	ErrTooLarge        = errors.New("picture is too large")
+	ErrUnsupportedType = errors.New("picture must be a JPEG, PNG or GIF image")
+	ErrInvalidImage    = errors.New("picture is not a valid image")
+)
+
+// maxPixels bounds the size of decoded pictures, which may be much larger than their files
+const maxPixels = 40_000_000
+
+const thumbnailSuffix = "-thumb"
+
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Pictures validates uploaded pictures and keeps them in a blob store along with square thumbnails.
+// Blobs are named after the owner and the contents, so their URLs change when they do and can be cached forever.
+type Pictures struct {
+	store         blob.Store
+	baseURL       string
+	maxSize       int64
+	thumbnailSize int
+}
+
+// New creates Pictures that accepts files of up to maxSize bytes, served under baseURL followed by their names
+func New(store blob.Store, baseURL string, maxSize int64, thumbnailSize int) Pictures {
+	return Pictures{
+		store:         store,
+		baseURL:       baseURL,
+		maxSize:       maxSize,
+		thumbnailSize: thumbnailSize,
+	}
+}
+
+func (p Pictures) SavePicture(ctx context.Context, owner string, r io.Reader) (Picture, error) {
+	data, err := io.ReadAll(io.LimitReader(r, p.maxSize+1))
+	if err != nil {
+		return Picture{}, err
+	}
+	if int64(len(data)) > p.maxSize {
+		return Picture{}, ErrTooLarge
+	}
+
+	// tell the type by the contents, as the one declared by the client can't be trusted
+	contentType := http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return Picture{}, ErrUnsupportedType
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		return Picture{}, ErrInvalidImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return Picture{}, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Picture{}, ErrInvalidImage
+	}
+
+	// JPEG thumbnails for JPEG pictures, PNG ones otherwise to keep transparency
+	var thumb bytes.Buffer
+	thumbType, thumbExt := "image/png", ".png"
+	if contentType == "image/jpeg" {
+		thumbType, thumbExt = "image/jpeg", ".jpg"
+		err = jpeg.Encode(&thumb, Thumbnail(img, p.thumbnailSize), nil)
+	} else {
+		err = png.Encode(&thumb, Thumbnail(img, p.thumbnailSize))
+	}
+	if err != nil {
+		return Picture{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	base := owner + "-" + hex.EncodeToString(sum[:8])
+	name, thumbName := base+ext, base+thumbnailSuffix+thumbExt
+	if err := p.store.Put(ctx, thumbName, thumbType, &thumb); err != nil {
+		return Picture{}, err
+	}
+	if err := p.store.Put(ctx, name, contentType, bytes.NewReader(data)); err != nil {
+		return Picture{}, err
+	}
+	return Picture{URL: p.baseURL + name, ThumbnailURL: p.baseURL + thumbName}, nil
+}
+
+func (p Pictures) OpenPicture(ctx context.Context, name string) (io.ReadCloser, string, error) {
+	return p.store.Get(ctx, name)
+}
+
+// DeletePicture deletes a picture saved before for the given owner and its thumbnail, given its URL.
+// URLs that weren't given by SavePicture for that owner, such as ones of other owners, are left alone.
+func (p Pictures) DeletePicture(ctx context.Context, owner, url string) error {
+	name := strings.TrimPrefix(url, p.baseURL)
+	if url == "" || name == url || !strings.HasPrefix(name, owner+"-") {
+		return nil
+	}
+	base := strings.TrimSuffix(name, path.Ext(name))
+	for _, n := range []string{name, base + thumbnailSuffix + ".jpg", base + thumbnailSuffix + ".png"} {
+		if err := p.store.Delete(ctx, n); err != nil && !errors.Is(err, blob.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Thumbnail crops the center square of img and scales it down to size, averaging the pixels of each area
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	if side < size {
+		size = side
+	}
+	x0, y0 := bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2
+
+	thumb := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0, sy1 := y0+y*side/size, y0+(y+1)*side/size
+		for x := 0; x < size; x++ {
+			sx0, sx1 := x0+x*side/size, x0+(x+1)*side/size
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			// averaging alpha-premultiplied colors, as RGBA returns them, doesn't bleed transparent pixels
+			thumb.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return thumb
+}
@@ -0,0 +1,201 @@
+package picture_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gabrielseibel1/gaef/blob"
+	"github.com/gabrielseibel1/gaef/blob/picture"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+)
+
+const (
+	dummyBaseURL       = "https://dummy.io/pictures/"
+	dummyMaxSize       = 1 << 20
+	dummyThumbnailSize = 16
+)
+
+type mockStore struct {
+	blobs map[string][]byte
+	types map[string]string
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{blobs: make(map[string][]byte), types: make(map[string]string)}
+}
+
+func (m *mockStore) Put(ctx context.Context, name, contentType string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	m.blobs[name], m.types[name] = data, contentType
+	return err
+}
+
+func (m *mockStore) Get(ctx context.Context, name string) (io.ReadCloser, string, error) {
+	data, ok := m.blobs[name]
+	if !ok {
+		return nil, "", blob.ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), m.types[name], nil
+}
+
+func (m *mockStore) Delete(ctx context.Context, name string) error {
+	if _, ok := m.blobs[name]; !ok {
+		return blob.ErrNotFound
+	}
+	delete(m.blobs, name)
+	return nil
+}
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, 0, color.NRGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestPictures_SavePicture(t *testing.T) {
+	// prepare test setup
+	s := newMockStore()
+	p := picture.New(s, dummyBaseURL, dummyMaxSize, dummyThumbnailSize)
+	data := encodePNG(t, 64, 32)
+
+	// run code under test
+	pic, err := p.SavePicture(context.TODO(), "dummy-id", bytes.NewReader(data))
+
+	// assertions
+	if err != nil {
+		t.Fatalf("SavePicture() error = %v", err)
+	}
+	if !strings.HasPrefix(pic.URL, dummyBaseURL+"dummy-id-") || !strings.HasSuffix(pic.URL, ".png") {
+		t.Errorf("got url %s, want one of dummy-id under %s", pic.URL, dummyBaseURL)
+	}
+	name := strings.TrimPrefix(pic.URL, dummyBaseURL)
+	if got, want := s.blobs[name], data; !bytes.Equal(got, want) {
+		t.Errorf("got stored picture of %d bytes, want the %d uploaded", len(got), len(want))
+	}
+	if got, want := s.types[name], "image/png"; got != want {
+		t.Errorf("got content type %s, want %s", got, want)
+	}
+
+	thumbName := strings.TrimPrefix(pic.ThumbnailURL, dummyBaseURL)
+	thumb, err := png.Decode(bytes.NewReader(s.blobs[thumbName]))
+	if err != nil {
+		t.Fatalf("png.Decode() thumbnail error = %v", err)
+	}
+	if got, want := thumb.Bounds().Size(), image.Pt(dummyThumbnailSize, dummyThumbnailSize); got != want {
+		t.Errorf("got thumbnail of size %v, want %v", got, want)
+	}
+
+	// the same picture keeps its url, a different one doesn't
+	again, err := p.SavePicture(context.TODO(), "dummy-id", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("SavePicture() error = %v", err)
+	}
+	if again != pic {
+		t.Errorf("got %v saving the same picture, want %v", again, pic)
+	}
+	other, err := p.SavePicture(context.TODO(), "dummy-id", bytes.NewReader(encodePNG(t, 8, 8)))
+	if err != nil {
+		t.Fatalf("SavePicture() error = %v", err)
+	}
+	if other.URL == pic.URL {
+		t.Errorf("got url %s for a different picture, want another one", other.URL)
+	}
+}
+
+func TestPictures_SavePicture_JPEG(t *testing.T) {
+	// prepare test setup
+	s := newMockStore()
+	p := picture.New(s, dummyBaseURL, dummyMaxSize, dummyThumbnailSize)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 80)), nil); err != nil {
+		t.Fatalf("jpeg.Encode() error = %v", err)
+	}
+
+	// run code under test
+	pic, err := p.SavePicture(context.TODO(), "dummy-id", &buf)
+
+	// assertions
+	if err != nil {
+		t.Fatalf("SavePicture() error = %v", err)
+	}
+	thumbName := strings.TrimPrefix(pic.ThumbnailURL, dummyBaseURL)
+	if got, want := s.types[thumbName], "image/jpeg"; got != want {
+		t.Errorf("got thumbnail content type %s, want %s", got, want)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(s.blobs[thumbName])); err != nil {
+		t.Errorf("jpeg.Decode() thumbnail error = %v", err)
+	}
+}
+
+func TestPictures_SavePicture_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int64
+		wantErr error
+	}{
+		{name: "too large", data: encodePNG(t, 64, 64), maxSize: 64, wantErr: picture.ErrTooLarge},
+		{name: "not an image", data: []byte("<html>dummy</html>"), maxSize: dummyMaxSize, wantErr: picture.ErrUnsupportedType},
+		{name: "truncated image", data: encodePNG(t, 64, 64)[:40], maxSize: dummyMaxSize, wantErr: picture.ErrInvalidImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// prepare test setup
+			s := newMockStore()
+			p := picture.New(s, dummyBaseURL, tt.maxSize, dummyThumbnailSize)
+
+			// run code under test
+			_, err := p.SavePicture(context.TODO(), "dummy-id", bytes.NewReader(tt.data))
+
+			// assertions
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SavePicture() error = %v, want %v", err, tt.wantErr)
+			}
+			if got, want := len(s.blobs), 0; got != want {
+				t.Errorf("got %d stored blobs, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestPictures_DeletePicture(t *testing.T) {
+	// prepare test setup
+	s := newMockStore()
+	p := picture.New(s, dummyBaseURL, dummyMaxSize, dummyThumbnailSize)
+	pic, err := p.SavePicture(context.TODO(), "dummy-id", bytes.NewReader(encodePNG(t, 8, 8)))
+	if err != nil {
+		t.Fatalf("SavePicture() error = %v", err)
+	}
+
+	// run code under test and assertions
+	if err := p.DeletePicture(context.TODO(), "dummy-id", "https://elsewhere.io/dummy.png"); err != nil {
+		t.Fatalf("DeletePicture() of a foreign url error = %v", err)
+	}
+	if got, want := len(s.blobs), 2; got != want {
+		t.Errorf("got %d stored blobs after deleting a foreign url, want %d", got, want)
+	}
+	if err := p.DeletePicture(context.TODO(), "other-id", pic.URL); err != nil {
+		t.Fatalf("DeletePicture() of another owner's url error = %v", err)
+	}
+	if got, want := len(s.blobs), 2; got != want {
+		t.Errorf("got %d stored blobs after deleting another owner's url, want %d", got, want)
+	}
+	if err := p.DeletePicture(context.TODO(), "dummy-id", pic.URL); err != nil {
+		t.Fatalf("DeletePicture() error = %v", err)
+	}
+	if got, want := len(s.blobs), 0; got != want {
+		t.Errorf("got %d stored blobs, want %d", got, want)
+	}
+}
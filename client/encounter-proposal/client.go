@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
+	"github.com/gabrielseibel1/gaef/types/mergepatch"
 	"io"
 	"net/http"
 	"strconv"
@@ -116,6 +118,8 @@ func (c Client) ReadEP(ctx context.Context, token string, id string) (types.Enco
 	return respBody.EncounterProposal, err
 }
 
+// UpdateEP replaces the encounter proposal, only if it is still at the version of ep, when it has one,
+// failing with an *etag.ConflictError otherwise
 func (c Client) UpdateEP(ctx context.Context, token string, ep types.EncounterProposal) (types.EncounterProposal, error) {
 	reqBodyBytes, err := json.Marshal(ep)
 	if err != nil {
@@ -127,11 +131,17 @@ func (c Client) UpdateEP(ctx context.Context, token string, ep types.EncounterPr
 		return types.EncounterProposal{}, err
 	}
 	req.Header.Add("Authorization", "Bearer "+token)
+	if ep.Version != 0 {
+		req.Header.Add(etag.HeaderIfMatch, etag.Format(ep.Version))
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return types.EncounterProposal{}, err
 	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return types.EncounterProposal{}, &etag.ConflictError{Resource: "encounter proposal", ID: ep.ID, Version: ep.Version}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return types.EncounterProposal{}, fmt.Errorf("update EP request returned status code %d", resp.StatusCode)
 	}
@@ -141,17 +151,59 @@ func (c Client) UpdateEP(ctx context.Context, token string, ep types.EncounterPr
 	return respBody.EncounterProposal, err
 }
 
-func (c Client) DeleteEP(ctx context.Context, token string, id string) (string, error) {
+// PatchEP changes only the fields of the encounter proposal that are in the patch, removing those set to nil,
+// only if it is still at the given version, any if zero, failing with an *etag.ConflictError otherwise
+func (c Client) PatchEP(ctx context.Context, token string, id string, patch map[string]any, version int64) (types.EncounterProposal, error) {
+	reqBodyBytes, err := json.Marshal(patch)
+	if err != nil {
+		return types.EncounterProposal{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, c.URL+id, io.NopCloser(bytes.NewBuffer(reqBodyBytes)))
+	if err != nil {
+		return types.EncounterProposal{}, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+	req.Header.Add("Content-Type", mergepatch.ContentType)
+	if version != 0 {
+		req.Header.Add(etag.HeaderIfMatch, etag.Format(version))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.EncounterProposal{}, err
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return types.EncounterProposal{}, &etag.ConflictError{Resource: "encounter proposal", ID: id, Version: version}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return types.EncounterProposal{}, fmt.Errorf("patch EP request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct{ EncounterProposal types.EncounterProposal }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.EncounterProposal, err
+}
+
+// DeleteEP deletes the encounter proposal, only if it is still at the given version, any if zero,
+// failing with an *etag.ConflictError otherwise
+func (c Client) DeleteEP(ctx context.Context, token string, id string, version int64) (string, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.URL+id, nil)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Add("Authorization", "Bearer "+token)
+	if version != 0 {
+		req.Header.Add(etag.HeaderIfMatch, etag.Format(version))
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", err
 	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return "", &etag.ConflictError{Resource: "encounter proposal", ID: id, Version: version}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("delete EP request returned status code %d", resp.StatusCode)
 	}
@@ -2,18 +2,21 @@ package encounterProposal_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/gabrielseibel1/gaef/client/encounter-proposal"
 	"github.com/gabrielseibel1/gaef/client/group"
+	"github.com/gabrielseibel1/gaef/client/inbox"
 	"github.com/gabrielseibel1/gaef/client/user"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
 	"github.com/stretchr/testify/assert"
 	"reflect"
 	"testing"
 	"time"
 )
 
-func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterProposalServiceURL string) {
+func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterProposalServiceURL, inboxURL string) {
 	ctx := context.TODO()
 
 	usersClient := user.Client{URL: userServiceURL}
@@ -45,6 +48,17 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterPropos
 	if err != nil {
 		t.Fatalf("usersClient.SignUp = err: %s", err.Error())
 	}
+	// verify users, since only verified users may create groups and apply to encounter proposals
+	inboxClient := inbox.Client{URL: inboxURL}
+	for _, email := range []string{"eptest_1@gmail.com", "eptest_2@gmail.com"} {
+		code, err := inboxClient.VerificationCode(ctx, email)
+		if err != nil {
+			t.Fatalf("inboxClient.VerificationCode = err: %s", err.Error())
+		}
+		if err := usersClient.Verify(ctx, code); err != nil {
+			t.Fatalf("usersClient.Verify = err: %s", err.Error())
+		}
+	}
 	token1, err := usersClient.Login(ctx, "eptest_1@gmail.com", "test1231")
 	if err != nil {
 		t.Fatalf("usersClient.Login = err: %s", err.Error())
@@ -57,8 +71,6 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterPropos
 		Name:        "G",
 		PictureURL:  "example.com",
 		Description: "Gg",
-		Members:     []types.User{{ID: user1ID, Name: "1"}},
-		Leaders:     []types.User{{ID: user1ID, Name: "1"}},
 	})
 	if err != nil {
 		t.Fatalf("groupsClient.CreateGroup = err: %s", err.Error())
@@ -67,8 +79,6 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterPropos
 		Name:        "H",
 		PictureURL:  "example.com",
 		Description: "Hh",
-		Members:     []types.User{{ID: user2ID, Name: "2"}},
-		Leaders:     []types.User{{ID: user2ID, Name: "2"}},
 	})
 	if err != nil {
 		t.Fatalf("groupsClient.CreateGroup = err: %s", err.Error())
@@ -77,8 +87,6 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterPropos
 		Name:        "I",
 		PictureURL:  "example.com",
 		Description: "Ii",
-		Members:     []types.User{{ID: user2ID, Name: "2"}},
-		Leaders:     []types.User{{ID: user2ID, Name: "2"}},
 	})
 	if err != nil {
 		t.Fatalf("groupsClient.CreateGroup = err: %s", err.Error())
@@ -88,11 +96,11 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterPropos
 		assert.Nil(t, err)
 		_, err = usersClient.DeleteUser(ctx, token2, user2ID)
 		assert.Nil(t, err)
-		_, err = groupsClient.DeleteGroup(ctx, token1, g1.ID)
+		_, err = groupsClient.DeleteGroup(ctx, token1, g1.ID, 0)
 		assert.Nil(t, err)
-		_, err = groupsClient.DeleteGroup(ctx, token2, g2.ID)
+		_, err = groupsClient.DeleteGroup(ctx, token2, g2.ID, 0)
 		assert.Nil(t, err)
-		_, err = groupsClient.DeleteGroup(ctx, token2, g3.ID)
+		_, err = groupsClient.DeleteGroup(ctx, token2, g3.ID, 0)
 		assert.Nil(t, err)
 	}(usersClient, groupsClient, ctx, token1, user1ID)
 
@@ -205,10 +213,30 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterPropos
 	if err != nil {
 		t.Fatalf("encounterProposalClient.UpdateEP() = err: %s", err.Error())
 	}
+	readEP1.Version++
 	if got, want := updatedEP1, readEP1; !reflect.DeepEqual(got, want) {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 
+	// patch an encounter proposal and assert only the patched field changed
+	patchedEP1, err := encounterProposalClient.PatchEP(ctx, token2, readEP1.ID, map[string]any{
+		"encounterSpecification": map[string]any{"description": "Patched Description"},
+	}, readEP1.Version)
+	if err != nil {
+		t.Fatalf("encounterProposalClient.PatchEP() = err: %s", err.Error())
+	}
+	readEP1.Description = "Patched Description"
+	readEP1.Version++
+	if got, want := patchedEP1, readEP1; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// update an encounter proposal at a version it is no longer at and assert it conflicts
+	_, err = encounterProposalClient.UpdateEP(ctx, token2, updatedEP1)
+	if !errors.Is(err, etag.ErrVersionMismatch) {
+		t.Fatalf("encounterProposalClient.UpdateEP() = err: %v, want a conflict", err)
+	}
+
 	// append an application to an encounter proposal (use token 1, user 1, leader of g1)
 	appliedMessage, err := encounterProposalClient.ApplyToEP(ctx, token1, readEP2.ID, types.Application{
 		Description: "application1",
@@ -231,21 +259,21 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterPropos
 	}
 
 	// delete all encounter proposals
-	deletedMessage, err := encounterProposalClient.DeleteEP(ctx, token2, createdEP1ID)
+	deletedMessage, err := encounterProposalClient.DeleteEP(ctx, token2, createdEP1ID, readEP1.Version)
 	if err != nil {
 		t.Fatalf("encounterProposalClient.DeleteEP() = err: %s", err.Error())
 	}
 	if got, want := deletedMessage, "deleted encounter proposal "+createdEP1ID; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
-	deletedMessage, err = encounterProposalClient.DeleteEP(ctx, token2, createdEP2ID)
+	deletedMessage, err = encounterProposalClient.DeleteEP(ctx, token2, createdEP2ID, 0)
 	if err != nil {
 		t.Fatalf("encounterProposalClient.DeleteEP() = err: %s", err.Error())
 	}
 	if got, want := deletedMessage, "deleted encounter proposal "+createdEP2ID; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
-	deletedMessage, err = encounterProposalClient.DeleteEP(ctx, token1, createdEP3ID)
+	deletedMessage, err = encounterProposalClient.DeleteEP(ctx, token1, createdEP3ID, 0)
 	if err != nil {
 		t.Fatalf("encounterProposalClient.DeleteEP() = err: %s", err.Error())
 	}
@@ -260,6 +288,7 @@ func TestClient_Localhost8082(t *testing.T) {
 		"http://localhost:8080/api/v0/users/",
 		"http://localhost:8081/api/v0/groups/",
 		"http://localhost:8082/api/v0/encounter-proposals/",
+		"http://localhost:8025/",
 	)
 }
 
@@ -269,5 +298,6 @@ func TestClient_Production(t *testing.T) {
 		"https://gaef-user-service.onrender.com/api/v0/users/",
 		"https://gaef-group-service.onrender.com/api/v0/groups/",
 		"https://gaef-encounter-proposal-service.onrender.com/api/v0/encounter-proposals/",
+		"",
 	)
 }
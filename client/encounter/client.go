@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
+	"github.com/gabrielseibel1/gaef/types/mergepatch"
 	"io"
 	"net/http"
 )
@@ -37,15 +39,30 @@ func (c Client) GetEncounter(ctx context.Context, token string, id string) (type
 	return respBody.Encounter, err
 }
 
+// UpdateEncounter replaces the encounter, only if it is still at the version of e, when it has one,
+// failing with an *etag.ConflictError otherwise
 func (c Client) UpdateEncounter(ctx context.Context, token string, e types.Encounter) (types.Encounter, error) {
 	var respBody struct{ Encounter types.Encounter }
-	err := request(ctx, http.MethodPut, c.URL+e.ID, e, token, &respBody)
+	conflict := etag.ConflictError{Resource: "encounter", ID: e.ID, Version: e.Version}
+	err := requestIfMatch(ctx, http.MethodPut, c.URL+e.ID, e, token, &respBody, conflict)
 	return respBody.Encounter, err
 }
 
-func (c Client) DeleteEncounter(ctx context.Context, token string, id string) (string, error) {
+// PatchEncounter changes only the fields of the encounter that are in the patch, removing those set to nil,
+// only if it is still at the given version, any if zero, failing with an *etag.ConflictError otherwise
+func (c Client) PatchEncounter(ctx context.Context, token string, id string, patch map[string]any, version int64) (types.Encounter, error) {
+	var respBody struct{ Encounter types.Encounter }
+	conflict := etag.ConflictError{Resource: "encounter", ID: id, Version: version}
+	err := requestIfMatch(ctx, http.MethodPatch, c.URL+id, patch, token, &respBody, conflict)
+	return respBody.Encounter, err
+}
+
+// DeleteEncounter deletes the encounter, only if it is still at the given version, any if zero,
+// failing with an *etag.ConflictError otherwise
+func (c Client) DeleteEncounter(ctx context.Context, token string, id string, version int64) (string, error) {
 	var respBody struct{ ID string }
-	err := request(ctx, http.MethodDelete, c.URL+id, nil, token, &respBody)
+	conflict := etag.ConflictError{Resource: "encounter", ID: id, Version: version}
+	err := requestIfMatch(ctx, http.MethodDelete, c.URL+id, nil, token, &respBody, conflict)
 	return respBody.ID, err
 }
 
@@ -62,6 +79,12 @@ func (c Client) DeclineEncounter(ctx context.Context, token string, id string) (
 }
 
 func request(ctx context.Context, method string, url string, bodyObj any, token string, respBody any) error {
+	return requestIfMatch(ctx, method, url, bodyObj, token, respBody, etag.ConflictError{})
+}
+
+// requestIfMatch is a request that only changes the encounter if it is at the version of the conflict, when it has one,
+// returning the conflict otherwise
+func requestIfMatch(ctx context.Context, method string, url string, bodyObj any, token string, respBody any, conflict etag.ConflictError) error {
 	// build request body
 	var body io.Reader
 	if bodyObj == nil {
@@ -80,6 +103,12 @@ func request(ctx context.Context, method string, url string, bodyObj any, token
 		return err
 	}
 	req.Header.Add("Authorization", "Bearer "+token)
+	if method == http.MethodPatch {
+		req.Header.Add("Content-Type", mergepatch.ContentType)
+	}
+	if conflict.Version != 0 {
+		req.Header.Add(etag.HeaderIfMatch, etag.Format(conflict.Version))
+	}
 
 	// do request
 	resp, err := http.DefaultClient.Do(req)
@@ -88,6 +117,9 @@ func request(ctx context.Context, method string, url string, bodyObj any, token
 	}
 
 	// parse response
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return &conflict
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("request(%v, %v, %v) returned status code %d", method, url, body, resp.StatusCode)
 	}
@@ -4,14 +4,16 @@ import (
 	"context"
 	"github.com/gabrielseibel1/gaef/client/encounter"
 	"github.com/gabrielseibel1/gaef/client/group"
+	"github.com/gabrielseibel1/gaef/client/inbox"
 	"github.com/gabrielseibel1/gaef/client/user"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
 )
 
-func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterServiceURL string) {
+func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterServiceURL, inboxURL string) {
 	ctx := context.TODO()
 
 	usersClient := user.Client{URL: userServiceURL}
@@ -29,6 +31,11 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterServic
 	// create user and group
 	user1ID, err := usersClient.SignUp(ctx, types.User{Name: "1", Email: "enctest_1@gmail.com"}, "test1231")
 	assert.Nil(t, err)
+	// verify the user, since only verified users may create groups
+	code, err := inbox.Client{URL: inboxURL}.VerificationCode(ctx, "enctest_1@gmail.com")
+	assert.Nil(t, err)
+	err = usersClient.Verify(ctx, code)
+	assert.Nil(t, err)
 	token1, err := usersClient.Login(ctx, "enctest_1@gmail.com", "test1231")
 	assert.Nil(t, err)
 	user1, err := usersClient.ReadUser(ctx, token1, user1ID)
@@ -37,15 +44,13 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterServic
 		Name:        "G",
 		PictureURL:  "example.com",
 		Description: "Gg",
-		Members:     []types.User{{ID: user1ID, Name: "1"}},
-		Leaders:     []types.User{{ID: user1ID, Name: "1"}},
 	})
 	assert.Nil(t, err)
 	// cleanup afterward
 	defer func(usersClient user.Client, ctx context.Context, token, id string) {
 		_, err := usersClient.DeleteUser(ctx, token, id)
 		assert.Nil(t, err)
-		_, err = groupsClient.DeleteGroup(ctx, token1, g1.ID)
+		_, err = groupsClient.DeleteGroup(ctx, token1, g1.ID, 0)
 		assert.Nil(t, err)
 	}(usersClient, ctx, token1, user1ID)
 
@@ -69,6 +74,7 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterServic
 	// create encounter
 	enc1.ID, err = encountersClient.CreateEncounter(ctx, token1, enc1)
 	assert.Nil(t, err)
+	enc1.Version = 1
 
 	// query user encounters and verify first is the created one
 	userEncounters, err := encountersClient.GetUserEncounters(ctx, token1)
@@ -84,8 +90,24 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterServic
 	enc1.Name = "test-encounter-name-2"
 	updatedEncounter, err := encountersClient.UpdateEncounter(ctx, token1, enc1)
 	assert.Nil(t, err)
+	enc1.Version++
 	assert.Equal(t, enc1, updatedEncounter)
 
+	// patch encounter
+	enc1.Description = "test-encounter-description-2"
+	patchedEncounter, err := encountersClient.PatchEncounter(ctx, token1, enc1.ID, map[string]any{
+		"encounterSpecification": map[string]any{"description": enc1.Description},
+	}, enc1.Version)
+	assert.Nil(t, err)
+	enc1.Version++
+	assert.Equal(t, enc1, patchedEncounter)
+
+	// patch encounter at a version it is no longer at
+	_, err = encountersClient.PatchEncounter(ctx, token1, enc1.ID, map[string]any{
+		"encounterSpecification": map[string]any{"description": "test-encounter-description-stale"},
+	}, updatedEncounter.Version)
+	assert.ErrorIs(t, err, etag.ErrVersionMismatch)
+
 	// confirm encounter
 	confirmedID, err := encountersClient.ConfirmEncounter(ctx, token1, enc1.ID)
 	assert.Nil(t, err)
@@ -97,7 +119,7 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, encounterServic
 	assert.Equal(t, enc1.ID, declinedID)
 
 	// delete encounter
-	deletedID, err := encountersClient.DeleteEncounter(ctx, token1, enc1.ID)
+	deletedID, err := encountersClient.DeleteEncounter(ctx, token1, enc1.ID, 0)
 	assert.Nil(t, err)
 	assert.Equal(t, enc1.ID, deletedID)
 }
@@ -108,6 +130,7 @@ func TestClient_Localhost8083(t *testing.T) {
 		"http://localhost:8080/api/v0/users/",
 		"http://localhost:8081/api/v0/groups/",
 		"http://localhost:8083/api/v0/encounters/",
+		"http://localhost:8025/",
 	)
 }
 
@@ -117,5 +140,6 @@ func TestClient_Production(t *testing.T) {
 		"https://gaef-user-service.onrender.com/api/v0/users/",
 		"https://gaef-group-service.onrender.com/api/v0/groups/",
 		"https://gaef-encounter-service.onrender.com/api/v0/encounters/",
+		"",
 	)
 }
@@ -6,8 +6,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
+	"github.com/gabrielseibel1/gaef/types/mergepatch"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
 type Client struct {
@@ -55,6 +61,70 @@ func (c Client) CreateGroup(ctx context.Context, token string, g types.Group) (t
 	return respBody.Group, err
 }
 
+// SearchGroups finds public groups by words in their name, description or tags, having all the given tags.
+// Pass the returned cursor to read the next page, there are no more pages when it is empty.
+func (c Client) SearchGroups(ctx context.Context, token, q string, tags []string, cursor string) ([]types.GroupSummary, string, error) {
+	query := url.Values{}
+	if q != "" {
+		query.Set("q", q)
+	}
+	if len(tags) > 0 {
+		query.Set("tags", strings.Join(tags, ","))
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("search groups request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct {
+		Groups     []types.GroupSummary
+		NextCursor string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.Groups, respBody.NextCursor, err
+}
+
+// NearbyGroups finds public groups with a home base within radiusKm of a point, nearest first, zero meaning the default radius
+func (c Client) NearbyGroups(ctx context.Context, token string, latitude, longitude, radiusKm float64) ([]types.NearbyGroup, error) {
+	query := url.Values{}
+	query.Set("lat", strconv.FormatFloat(latitude, 'f', -1, 64))
+	query.Set("lng", strconv.FormatFloat(longitude, 'f', -1, 64))
+	if radiusKm != 0 {
+		query.Set("radiusKm", strconv.FormatFloat(radiusKm, 'f', -1, 64))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+"nearby?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("nearby groups request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct {
+		Groups []types.NearbyGroup
+	}
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.Groups, err
+}
+
 func (c Client) ParticipatingGroups(ctx context.Context, token string) ([]types.Group, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+"participating", nil)
 	if err != nil {
@@ -135,6 +205,8 @@ func (c Client) ReadLeadingGroup(ctx context.Context, token, id string) (types.G
 	return respBody.Group, err
 }
 
+// UpdateGroup replaces the group, only if it is still at the version of g, when it has one,
+// failing with an *etag.ConflictError otherwise
 func (c Client) UpdateGroup(ctx context.Context, token string, g types.Group) (types.Group, error) {
 	reqBodyBytes, err := json.Marshal(g)
 	if err != nil {
@@ -146,11 +218,17 @@ func (c Client) UpdateGroup(ctx context.Context, token string, g types.Group) (t
 		return types.Group{}, err
 	}
 	req.Header.Add("Authorization", "Bearer "+token)
+	if g.Version != 0 {
+		req.Header.Add(etag.HeaderIfMatch, etag.Format(g.Version))
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return types.Group{}, err
 	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return types.Group{}, &etag.ConflictError{Resource: "group", ID: g.ID, Version: g.Version}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return types.Group{}, fmt.Errorf("update group request returned status code %d", resp.StatusCode)
 	}
@@ -160,17 +238,97 @@ func (c Client) UpdateGroup(ctx context.Context, token string, g types.Group) (t
 	return respBody.Group, err
 }
 
-func (c Client) DeleteGroup(ctx context.Context, token, id string) (string, error) {
+// PatchGroup changes only the fields of the group that are in the patch, removing those set to nil,
+// only if it is still at the given version, any if zero, failing with an *etag.ConflictError otherwise
+func (c Client) PatchGroup(ctx context.Context, token, id string, patch map[string]any, version int64) (types.Group, error) {
+	reqBodyBytes, err := json.Marshal(patch)
+	if err != nil {
+		return types.Group{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, c.URL+id, io.NopCloser(bytes.NewBuffer(reqBodyBytes)))
+	if err != nil {
+		return types.Group{}, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+	req.Header.Add("Content-Type", mergepatch.ContentType)
+	if version != 0 {
+		req.Header.Add(etag.HeaderIfMatch, etag.Format(version))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.Group{}, err
+	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return types.Group{}, &etag.ConflictError{Resource: "group", ID: id, Version: version}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return types.Group{}, fmt.Errorf("patch group request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct{ Group types.Group }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.Group, err
+}
+
+// UploadPicture sets the picture of the group, returning it with the new picture URL along with the URL of its thumbnail
+func (c Client) UploadPicture(ctx context.Context, token, id, filename string, picture io.Reader) (types.Group, string, error) {
+	var reqBody bytes.Buffer
+	w := multipart.NewWriter(&reqBody)
+	part, err := w.CreateFormFile("picture", filename)
+	if err != nil {
+		return types.Group{}, "", err
+	}
+	if _, err := io.Copy(part, picture); err != nil {
+		return types.Group{}, "", err
+	}
+	if err := w.Close(); err != nil {
+		return types.Group{}, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.URL+id+"/picture", &reqBody)
+	if err != nil {
+		return types.Group{}, "", err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.Group{}, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return types.Group{}, "", fmt.Errorf("upload picture request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct {
+		Group        types.Group
+		ThumbnailURL string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.Group, respBody.ThumbnailURL, err
+}
+
+// DeleteGroup deletes the group, only if it is still at the given version, any if zero,
+// failing with an *etag.ConflictError otherwise
+func (c Client) DeleteGroup(ctx context.Context, token, id string, version int64) (string, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.URL+id, nil)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Add("Authorization", "Bearer "+token)
+	if version != 0 {
+		req.Header.Add(etag.HeaderIfMatch, etag.Format(version))
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", err
 	}
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return "", &etag.ConflictError{Resource: "group", ID: id, Version: version}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("delete group request returned status code %d", resp.StatusCode)
 	}
@@ -183,6 +341,252 @@ func (c Client) DeleteGroup(ctx context.Context, token, id string) (string, erro
 	return respBody.Message, nil
 }
 
+// InviteUser invites a user to become a member of a group the user of the token leads
+func (c Client) InviteUser(ctx context.Context, token, groupID, userID string) (types.Invitation, error) {
+	reqBodyBytes, err := json.Marshal(map[string]string{"userId": userID})
+	if err != nil {
+		return types.Invitation{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+groupID+"/invitations", io.NopCloser(bytes.NewBuffer(reqBodyBytes)))
+	if err != nil {
+		return types.Invitation{}, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.Invitation{}, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return types.Invitation{}, fmt.Errorf("invite user request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct{ Invitation types.Invitation }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.Invitation, err
+}
+
+// GroupInvitations lists the pending invitations to a group the user of the token leads
+func (c Client) GroupInvitations(ctx context.Context, token, groupID string) ([]types.Invitation, error) {
+	return c.invitations(ctx, token, c.URL+groupID+"/invitations")
+}
+
+// Invitations lists the pending invitations of the user of the token
+func (c Client) Invitations(ctx context.Context, token string) ([]types.Invitation, error) {
+	return c.invitations(ctx, token, c.URL+"invitations")
+}
+
+func (c Client) invitations(ctx context.Context, token, endpoint string) ([]types.Invitation, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invitations request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct{ Invitations []types.Invitation }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.Invitations, err
+}
+
+// AcceptInvitation makes the user of the token a member of the group it was invited to, returning the group
+func (c Client) AcceptInvitation(ctx context.Context, token, invitationID string) (types.Group, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"invitations/"+invitationID+"/accept", nil)
+	if err != nil {
+		return types.Group{}, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.Group{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return types.Group{}, fmt.Errorf("accept invitation request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct{ Group types.Group }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.Group, err
+}
+
+func (c Client) RejectInvitation(ctx context.Context, token, invitationID string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"invitations/"+invitationID+"/reject", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("reject invitation request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct{ Message string }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	if err != nil {
+		return "", err
+	}
+	return respBody.Message, nil
+}
+
+// RequestToJoin asks the leaders of a public group to let the user of the token in
+func (c Client) RequestToJoin(ctx context.Context, token, groupID, message string) (types.JoinRequest, error) {
+	reqBodyBytes, err := json.Marshal(map[string]string{"message": message})
+	if err != nil {
+		return types.JoinRequest{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+groupID+"/join-requests", io.NopCloser(bytes.NewBuffer(reqBodyBytes)))
+	if err != nil {
+		return types.JoinRequest{}, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.JoinRequest{}, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return types.JoinRequest{}, fmt.Errorf("join request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct{ JoinRequest types.JoinRequest }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.JoinRequest, err
+}
+
+// JoinRequests lists the pending requests to join a group the user of the token leads
+func (c Client) JoinRequests(ctx context.Context, token, groupID string) ([]types.JoinRequest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+groupID+"/join-requests", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("join requests request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct{ JoinRequests []types.JoinRequest }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.JoinRequests, err
+}
+
+// ApproveJoinRequest makes the requester a member of a group the user of the token leads, returning the group
+func (c Client) ApproveJoinRequest(ctx context.Context, token, groupID, requestID string) (types.Group, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+groupID+"/join-requests/"+requestID+"/approve", nil)
+	if err != nil {
+		return types.Group{}, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.Group{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return types.Group{}, fmt.Errorf("approve join request request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct{ Group types.Group }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.Group, err
+}
+
+func (c Client) DenyJoinRequest(ctx context.Context, token, groupID, requestID string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+groupID+"/join-requests/"+requestID+"/deny", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("deny join request request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct{ Message string }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	if err != nil {
+		return "", err
+	}
+	return respBody.Message, nil
+}
+
+// LeaveGroup takes the user of the token out of a group
+func (c Client) LeaveGroup(ctx context.Context, token, groupID string) error {
+	_, err := c.changeMembership(ctx, token, http.MethodPost, c.URL+groupID+"/leave", nil, "leave group")
+	return err
+}
+
+// RemoveMember takes a member out of a group the user of the token leads
+func (c Client) RemoveMember(ctx context.Context, token, groupID, memberID string) error {
+	_, err := c.changeMembership(ctx, token, http.MethodDelete, c.URL+groupID+"/members/"+memberID, nil, "remove member")
+	return err
+}
+
+// PromoteMember makes a member one of the leaders of a group the user of the token leads, returning the group
+func (c Client) PromoteMember(ctx context.Context, token, groupID, memberID string) (types.Group, error) {
+	return c.changeMembership(ctx, token, http.MethodPut, c.URL+groupID+"/leaders/"+memberID, nil, "promote member")
+}
+
+// DemoteLeader makes a leader a plain member of a group the user of the token leads, returning the group
+func (c Client) DemoteLeader(ctx context.Context, token, groupID, leaderID string) (types.Group, error) {
+	return c.changeMembership(ctx, token, http.MethodDelete, c.URL+groupID+"/leaders/"+leaderID, nil, "demote leader")
+}
+
+// TransferLeadership hands the leadership of the user of the token over to a member, returning the group
+func (c Client) TransferLeadership(ctx context.Context, token, groupID, memberID string) (types.Group, error) {
+	reqBodyBytes, err := json.Marshal(map[string]string{"userId": memberID})
+	if err != nil {
+		return types.Group{}, err
+	}
+	return c.changeMembership(ctx, token, http.MethodPost, c.URL+groupID+"/leadership", reqBodyBytes, "transfer leadership")
+}
+
+func (c Client) changeMembership(ctx context.Context, token, method, endpoint string, reqBodyBytes []byte, name string) (types.Group, error) {
+	var reqBody io.Reader
+	if reqBodyBytes != nil {
+		reqBody = io.NopCloser(bytes.NewBuffer(reqBodyBytes))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return types.Group{}, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.Group{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return types.Group{}, fmt.Errorf("%s request returned status code %d", name, resp.StatusCode)
+	}
+
+	var respBody struct{ Group types.Group }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.Group, err
+}
+
 func (c Client) IsGroupLeader(ctx context.Context, token, groupID string) (bool, error) {
 	_, err := c.ReadLeadingGroup(ctx, token, groupID)
 	return err == nil, err
@@ -2,14 +2,17 @@ package group_test
 
 import (
 	"context"
+	"errors"
 	"github.com/gabrielseibel1/gaef/client/group"
+	"github.com/gabrielseibel1/gaef/client/inbox"
 	"github.com/gabrielseibel1/gaef/client/user"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
-func testWithURLs(t *testing.T, userServiceURL, groupServiceURL string) {
+func testWithURLs(t *testing.T, userServiceURL, groupServiceURL, inboxURL string) {
 	// we need a users client because groups API has authentication
 
 	ctx := context.TODO()
@@ -40,6 +43,17 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL string) {
 	if err != nil {
 		t.Fatalf("usersClient.SignUp = err: %s", err.Error())
 	}
+	// verify users, since only verified users may create groups and ask to join them
+	inboxClient := inbox.Client{URL: inboxURL}
+	for _, email := range []string{"grouptest1@gmail.com", "grouptest2@gmail.com", "grouptest3@gmail.com"} {
+		code, err := inboxClient.VerificationCode(ctx, email)
+		if err != nil {
+			t.Fatalf("inboxClient.VerificationCode = err: %s", err.Error())
+		}
+		if err := usersClient.Verify(ctx, code); err != nil {
+			t.Fatalf("usersClient.Verify = err: %s", err.Error())
+		}
+	}
 	token1, err := usersClient.Login(ctx, "grouptest1@gmail.com", "test1231")
 	if err != nil {
 		t.Fatalf("usersClient.Login = err: %s", err.Error())
@@ -66,8 +80,6 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL string) {
 		Name:        "G",
 		PictureURL:  "example.com",
 		Description: "Gg",
-		Members:     []types.User{{ID: user1ID, Name: "A"}, {ID: user2ID, Name: "B"}, {ID: user3ID, Name: "C"}},
-		Leaders:     []types.User{{ID: user1ID, Name: "A"}, {ID: user3ID, Name: "C"}},
 	})
 	if err != nil {
 		t.Fatalf("groupsClient.CreateGroup = err: %s", err.Error())
@@ -79,8 +91,6 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL string) {
 		Name:        "H",
 		PictureURL:  "example.com",
 		Description: "Hh",
-		Members:     []types.User{{ID: user1ID, Name: "A"}, {ID: user2ID, Name: "B"}, {ID: user3ID, Name: "C"}},
-		Leaders:     []types.User{{ID: user1ID, Name: "A"}, {ID: user3ID, Name: "C"}},
 	})
 	if err != nil {
 		t.Fatalf("groupsClient.CreateGroup = err: %s", err.Error())
@@ -123,6 +133,143 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL string) {
 		t.Fatalf("groupsClient.IsGroupLeader() = bool: %v", isLeader)
 	}
 
+	// invitations
+	createdGroup3, err := groupsClient.CreateGroup(ctx, token1, types.Group{
+		Name:        "J",
+		Description: "Jj",
+	})
+	if err != nil {
+		t.Fatalf("groupsClient.CreateGroup = err: %s", err.Error())
+	}
+	_, err = groupsClient.InviteUser(ctx, token1, createdGroup3.ID, user2ID)
+	if err != nil {
+		t.Fatalf("groupsClient.InviteUser() = err: %s", err.Error())
+	}
+	_, err = groupsClient.InviteUser(ctx, token1, createdGroup3.ID, user3ID)
+	if err != nil {
+		t.Fatalf("groupsClient.InviteUser() = err: %s", err.Error())
+	}
+	groupInvitations, err := groupsClient.GroupInvitations(ctx, token1, createdGroup3.ID)
+	if err != nil {
+		t.Fatalf("groupsClient.GroupInvitations() = err: %s", err.Error())
+	}
+	if len(groupInvitations) != 2 {
+		t.Fatalf("expected two invitations, but groupsClient.GroupInvitations() = %v", groupInvitations)
+	}
+	invitations2, err := groupsClient.Invitations(ctx, token2)
+	if err != nil {
+		t.Fatalf("groupsClient.Invitations() = err: %s", err.Error())
+	}
+	if len(invitations2) != 1 {
+		t.Fatalf("expected one invitation, but groupsClient.Invitations() = %v", invitations2)
+	}
+	joinedGroup, err := groupsClient.AcceptInvitation(ctx, token2, invitations2[0].ID)
+	if err != nil {
+		t.Fatalf("groupsClient.AcceptInvitation() = err: %s", err.Error())
+	}
+	if len(joinedGroup.Members) != 2 {
+		t.Fatalf("expected two members, but groupsClient.AcceptInvitation() = %v", joinedGroup)
+	}
+	invitations3, err := groupsClient.Invitations(ctx, token3)
+	if err != nil {
+		t.Fatalf("groupsClient.Invitations() = err: %s", err.Error())
+	}
+	if len(invitations3) != 1 {
+		t.Fatalf("expected one invitation, but groupsClient.Invitations() = %v", invitations3)
+	}
+	_, err = groupsClient.RejectInvitation(ctx, token3, invitations3[0].ID)
+	if err != nil {
+		t.Fatalf("groupsClient.RejectInvitation() = err: %s", err.Error())
+	}
+
+	// join requests
+	joinedGroup.Visibility = types.GroupPublic
+	joinedGroup.Tags = []string{"clienttest"}
+	joinedGroup.Location = &types.Location{Name: "Porto Alegre", Latitude: -30.03, Longitude: -51.22}
+	createdGroup3, err = groupsClient.UpdateGroup(ctx, token1, joinedGroup)
+	if err != nil {
+		t.Fatalf("groupsClient.UpdateGroup() = err: %s", err.Error())
+	}
+	patchedGroup, err := groupsClient.PatchGroup(ctx, token1, createdGroup3.ID, map[string]any{"description": "patched"}, createdGroup3.Version)
+	if err != nil {
+		t.Fatalf("groupsClient.PatchGroup() = err: %s", err.Error())
+	}
+	if patchedGroup.Description != "patched" || len(patchedGroup.Members) != len(createdGroup3.Members) {
+		t.Fatalf("expected only the description to change, but groupsClient.PatchGroup() = %v", patchedGroup)
+	}
+	_, err = groupsClient.PatchGroup(ctx, token1, createdGroup3.ID, map[string]any{"description": "stale"}, createdGroup3.Version)
+	if !errors.Is(err, etag.ErrVersionMismatch) {
+		t.Fatalf("groupsClient.PatchGroup() = err: %v, want a conflict for the version it patched", err)
+	}
+	foundGroups, _, err := groupsClient.SearchGroups(ctx, token3, "J", []string{"clienttest"}, "")
+	if err != nil {
+		t.Fatalf("groupsClient.SearchGroups() = err: %s", err.Error())
+	}
+	if len(foundGroups) != 1 || foundGroups[0].ID != createdGroup3.ID {
+		t.Fatalf("expected the public group, but groupsClient.SearchGroups() = %v", foundGroups)
+	}
+	nearbyGroups, err := groupsClient.NearbyGroups(ctx, token3, -30.03, -51.22, 5)
+	if err != nil {
+		t.Fatalf("groupsClient.NearbyGroups() = err: %s", err.Error())
+	}
+	if len(nearbyGroups) != 1 || nearbyGroups[0].ID != createdGroup3.ID {
+		t.Fatalf("expected the public group, but groupsClient.NearbyGroups() = %v", nearbyGroups)
+	}
+	joinRequest, err := groupsClient.RequestToJoin(ctx, token3, createdGroup3.ID, "let me in")
+	if err != nil {
+		t.Fatalf("groupsClient.RequestToJoin() = err: %s", err.Error())
+	}
+	joinRequests, err := groupsClient.JoinRequests(ctx, token1, createdGroup3.ID)
+	if err != nil {
+		t.Fatalf("groupsClient.JoinRequests() = err: %s", err.Error())
+	}
+	if len(joinRequests) != 1 {
+		t.Fatalf("expected one join request, but groupsClient.JoinRequests() = %v", joinRequests)
+	}
+	joinedGroup, err = groupsClient.ApproveJoinRequest(ctx, token1, createdGroup3.ID, joinRequest.ID)
+	if err != nil {
+		t.Fatalf("groupsClient.ApproveJoinRequest() = err: %s", err.Error())
+	}
+	if len(joinedGroup.Members) != 3 {
+		t.Fatalf("expected three members, but groupsClient.ApproveJoinRequest() = %v", joinedGroup)
+	}
+
+	// membership
+	promotedGroup, err := groupsClient.PromoteMember(ctx, token1, createdGroup3.ID, user2ID)
+	if err != nil {
+		t.Fatalf("groupsClient.PromoteMember() = err: %s", err.Error())
+	}
+	if len(promotedGroup.Leaders) != 2 {
+		t.Fatalf("expected two leaders, but groupsClient.PromoteMember() = %v", promotedGroup)
+	}
+	_, err = groupsClient.DemoteLeader(ctx, token1, createdGroup3.ID, user2ID)
+	if err != nil {
+		t.Fatalf("groupsClient.DemoteLeader() = err: %s", err.Error())
+	}
+	err = groupsClient.RemoveMember(ctx, token1, createdGroup3.ID, user2ID)
+	if err != nil {
+		t.Fatalf("groupsClient.RemoveMember() = err: %s", err.Error())
+	}
+	err = groupsClient.LeaveGroup(ctx, token1, createdGroup3.ID)
+	if err == nil {
+		t.Fatalf("groupsClient.LeaveGroup() = nil, want an error for the last leader")
+	}
+	transferredGroup, err := groupsClient.TransferLeadership(ctx, token1, createdGroup3.ID, user3ID)
+	if err != nil {
+		t.Fatalf("groupsClient.TransferLeadership() = err: %s", err.Error())
+	}
+	if len(transferredGroup.Leaders) != 1 || transferredGroup.Leaders[0].ID != user3ID {
+		t.Fatalf("expected user 3 to lead, but groupsClient.TransferLeadership() = %v", transferredGroup)
+	}
+	err = groupsClient.LeaveGroup(ctx, token1, createdGroup3.ID)
+	if err != nil {
+		t.Fatalf("groupsClient.LeaveGroup() = err: %s", err.Error())
+	}
+	_, err = groupsClient.DeleteGroup(ctx, token3, createdGroup3.ID, 0)
+	if err != nil {
+		t.Fatalf("groupsClient.DeleteGroup() = err: %s", err.Error())
+	}
+
 	// update group
 	createdGroup1.Name = "I"
 	createdGroup1.Description = "Ii"
@@ -133,11 +280,11 @@ func testWithURLs(t *testing.T, userServiceURL, groupServiceURL string) {
 	}
 
 	// delete groups
-	_, err = groupsClient.DeleteGroup(ctx, token1, createdGroup1.ID)
+	_, err = groupsClient.DeleteGroup(ctx, token1, createdGroup1.ID, 0)
 	if err != nil {
 		t.Fatalf("groupsClient.DeleteGroup() = err: %s", err.Error())
 	}
-	_, err = groupsClient.DeleteGroup(ctx, token1, createdGroup2.ID)
+	_, err = groupsClient.DeleteGroup(ctx, token1, createdGroup2.ID, createdGroup2.Version)
 	if err != nil {
 		t.Fatalf("groupsClient.DeleteGroup() = err: %s", err.Error())
 	}
@@ -148,6 +295,7 @@ func TestClient_Localhost8081(t *testing.T) {
 		t,
 		"http://localhost:8080/api/v0/users/",
 		"http://localhost:8081/api/v0/groups/",
+		"http://localhost:8025/",
 	)
 }
 
@@ -156,5 +304,6 @@ func TestClient_Production(t *testing.T) {
 		t,
 		"https://gaef-user-service.onrender.com/api/v0/users/",
 		"https://gaef-group-service.onrender.com/api/v0/groups/",
+		"",
 	)
 }
@@ -0,0 +1,64 @@
+// Package inbox reads the mail that a Mailpit server catches from the user service,
+// so that integration tests can use the codes mailed to users.
+package inbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNoCode is returned by VerificationCode when no verification mail was sent to the address
+var ErrNoCode = errors.New("no verification code")
+
+const verificationCodeIntro = "Use this code to verify your email:"
+
+type Client struct {
+	URL string
+}
+
+// VerificationCode returns the code of the latest verification mail sent to email
+func (c Client) VerificationCode(ctx context.Context, email string) (string, error) {
+	query := url.Values{"query": {fmt.Sprintf("to:%q subject:%q", email, "Verify your email")}, "limit": {"1"}}
+	var found struct {
+		Messages []struct{ ID string }
+	}
+	if err := c.get(ctx, "api/v1/search?"+query.Encode(), &found); err != nil {
+		return "", err
+	}
+	if len(found.Messages) == 0 {
+		return "", ErrNoCode
+	}
+
+	var message struct{ Text string }
+	if err := c.get(ctx, "api/v1/message/"+url.PathEscape(found.Messages[0].ID), &message); err != nil {
+		return "", err
+	}
+	_, after, ok := strings.Cut(message.Text, verificationCodeIntro)
+	fields := strings.Fields(after)
+	if !ok || len(fields) == 0 {
+		return "", ErrNoCode
+	}
+	return fields[0], nil
+}
+
+func (c Client) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("inbox request returned status code %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
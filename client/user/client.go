@@ -4,16 +4,25 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gabrielseibel1/gaef/types"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 )
 
 type Client struct {
 	URL string
 }
 
+// ErrMFARequired is returned by Login for users with a second factor, along with the challenge token for CompleteMFA
+var ErrMFARequired = errors.New("second factor required")
+
+// ErrNotFound is returned by ReadUserProfile for users that don't exist
+var ErrNotFound = errors.New("user not found")
+
 func (c Client) Health(ctx context.Context) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+"health", nil)
 	if err != nil {
@@ -83,6 +92,46 @@ func (c Client) Login(ctx context.Context, email, password string) (string, erro
 		return "", fmt.Errorf("login request returned status code %d", resp.StatusCode)
 	}
 
+	var respBody struct {
+		Token          string
+		MFARequired    bool
+		ChallengeToken string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	if err != nil {
+		return "", err
+	}
+	if respBody.MFARequired {
+		return respBody.ChallengeToken, ErrMFARequired
+	}
+	return respBody.Token, nil
+}
+
+func (c Client) CompleteMFA(ctx context.Context, challengeToken, code string) (string, error) {
+	var body = struct {
+		ChallengeToken string
+		Code           string
+	}{
+		challengeToken, code,
+	}
+	reqBodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"session/mfa", io.NopCloser(bytes.NewBuffer(reqBodyBytes)))
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("mfa request returned status code %d", resp.StatusCode)
+	}
+
 	var respBody struct{ Token string }
 	err = json.NewDecoder(resp.Body).Decode(&respBody)
 	if err != nil {
@@ -91,6 +140,63 @@ func (c Client) Login(ctx context.Context, email, password string) (string, erro
 	return respBody.Token, nil
 }
 
+// Verify marks the email of a user as verified with the code mailed to it.
+// Tokens issued before don't carry the verified claim, so log in again afterward.
+func (c Client) Verify(ctx context.Context, code string) error {
+	var body = struct{ Code string }{code}
+	reqBodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"verification", io.NopCloser(bytes.NewBuffer(reqBodyBytes)))
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("verification request returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SearchUsers finds users by the start of their name or email, or by words in them if fullText is set.
+// Pass the returned cursor to read the next page, there are no more pages when it is empty.
+// Emails are matched but not given away, so the users found have none.
+func (c Client) SearchUsers(ctx context.Context, token, q string, fullText bool, cursor string) ([]types.User, string, error) {
+	query := url.Values{"q": {q}}
+	if fullText {
+		query.Set("mode", "text")
+	}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("search users request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct {
+		Users      []types.User
+		NextCursor string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.Users, respBody.NextCursor, err
+}
+
 func (c Client) ReadUser(ctx context.Context, token, id string) (types.User, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+id, nil)
 	if err != nil {
@@ -111,6 +217,30 @@ func (c Client) ReadUser(ctx context.Context, token, id string) (types.User, err
 	return respBody.User, err
 }
 
+// ReadUserProfile reads what any user may know about the user with the id, which leaves its email out
+func (c Client) ReadUserProfile(ctx context.Context, token, id string) (types.User, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL+"profiles/"+id, nil)
+	if err != nil {
+		return types.User{}, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.User{}, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return types.User{}, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return types.User{}, fmt.Errorf("read user profile request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct{ User types.User }
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.User, err
+}
+
 func (c Client) UpdateUser(ctx context.Context, token string, u types.User) (types.User, error) {
 	reqBodyBytes, err := json.Marshal(u)
 	if err != nil {
@@ -136,6 +266,44 @@ func (c Client) UpdateUser(ctx context.Context, token string, u types.User) (typ
 	return respBody.User, err
 }
 
+// UploadPicture sets the picture of the user, returning it with the new picture URL along with the URL of its thumbnail
+func (c Client) UploadPicture(ctx context.Context, token, id, filename string, picture io.Reader) (types.User, string, error) {
+	var reqBody bytes.Buffer
+	w := multipart.NewWriter(&reqBody)
+	part, err := w.CreateFormFile("picture", filename)
+	if err != nil {
+		return types.User{}, "", err
+	}
+	if _, err := io.Copy(part, picture); err != nil {
+		return types.User{}, "", err
+	}
+	if err := w.Close(); err != nil {
+		return types.User{}, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.URL+id+"/picture", &reqBody)
+	if err != nil {
+		return types.User{}, "", err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return types.User{}, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return types.User{}, "", fmt.Errorf("upload picture request returned status code %d", resp.StatusCode)
+	}
+
+	var respBody struct {
+		User         types.User
+		ThumbnailURL string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&respBody)
+	return respBody.User, respBody.ThumbnailURL, err
+}
+
 func (c Client) DeleteUser(ctx context.Context, token, id string) (string, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.URL+id, nil)
 	if err != nil {
@@ -40,6 +40,24 @@ func testWithURL(t *testing.T, userServiceURL string) {
 		t.Fatalf("usersClient.ReadUser = err: %s", err.Error())
 	}
 
+	// search user
+	found, _, err := usersClient.SearchUsers(ctx, token, "usertest1", false, "")
+	if err != nil {
+		t.Fatalf("usersClient.SearchUsers = err: %s", err.Error())
+	}
+	if len(found) != 1 || found[0].ID != userID {
+		t.Fatalf("usersClient.SearchUsers = %v, want user %s", found, userID)
+	}
+
+	// read profile
+	profile, err := usersClient.ReadUserProfile(ctx, token, userID)
+	if err != nil {
+		t.Fatalf("usersClient.ReadUserProfile = err: %s", err.Error())
+	}
+	if profile.ID != userID || profile.Email != "" {
+		t.Fatalf("usersClient.ReadUserProfile = %v, want user %s without email", profile, userID)
+	}
+
 	// update user
 	u.Name = "B"
 	u.Email = "usertest2@gmail.com"
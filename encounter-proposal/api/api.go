@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
+	"github.com/gabrielseibel1/gaef/types/mergepatch"
 	"github.com/gin-gonic/gin"
+	"io"
 	"net/http"
 	"strconv"
 )
@@ -29,6 +32,7 @@ type API struct {
 	appDeleter          applicationDeleter
 	leadingGroupsLister leadingGroupsLister
 	leaderChecker       groupLeaderChecker
+	epPatcher           encounterProposalPatcher
 }
 
 type encounterProposalCreator interface {
@@ -46,8 +50,11 @@ type byIDEncounterProposalReader interface {
 type encounterProposalUpdater interface {
 	Update(ctx context.Context, ep types.EncounterProposal) (types.EncounterProposal, error)
 }
+type encounterProposalPatcher interface {
+	Patch(ctx context.Context, ep types.EncounterProposal, paths []string) (types.EncounterProposal, error)
+}
 type encounterProposalDeleter interface {
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string, version int64) error
 }
 type applicationAppender interface {
 	AppendApplication(ctx context.Context, epID string, app types.Application) error
@@ -73,6 +80,7 @@ func New(
 	appDeleter applicationDeleter,
 	leadingGroupsLister leadingGroupsLister,
 	leaderChecker groupLeaderChecker,
+	epPatcher encounterProposalPatcher,
 ) API {
 	return API{
 		epCreator:           epCreator,
@@ -85,6 +93,7 @@ func New(
 		appDeleter:          appDeleter,
 		leadingGroupsLister: leadingGroupsLister,
 		leaderChecker:       leaderChecker,
+		epPatcher:           epPatcher,
 	}
 }
 
@@ -192,11 +201,13 @@ func (api API) EPReadingByIDHandler() gin.HandlerFunc {
 			return er(http.StatusNotFound, err)
 		}
 
+		ctx.Header(etag.HeaderETag, etag.Format(ep.Version))
 		return ok(encounterProposal, ep)
 
 	})
 }
 
+// EPUpdateHandler replaces the encounter proposal, only if it is at the version in If-Match, when there is one
 func (api API) EPUpdateHandler() gin.HandlerFunc {
 	return jsonHandler(func(ctx *gin.Context) result {
 
@@ -207,31 +218,95 @@ func (api API) EPUpdateHandler() gin.HandlerFunc {
 		if ep.ID != ctx.Param(EPID) {
 			return er(http.StatusUnprocessableEntity, errors.New("cannot update id"))
 		}
+		version, err := etag.Parse(ctx.GetHeader(etag.HeaderIfMatch))
+		if err != nil {
+			return er(http.StatusBadRequest, err)
+		}
 
 		// reset user input on applications field
 		readEP, err := api.byIDEPReader.ReadByID(ctx, ep.ID)
 		if err != nil {
 			return er(http.StatusNotFound, err)
 		}
+		if version != 0 && version != readEP.Version {
+			return er(http.StatusPreconditionFailed, etag.ErrVersionMismatch)
+		}
 		ep.Applications = readEP.Applications
+		ep.Version = version
 
 		ep, err = api.epUpdater.Update(ctx, ep)
+		if err != nil {
+			return er(storeErrorStatus(err), err)
+		}
+
+		ctx.Header(etag.HeaderETag, etag.Format(ep.Version))
+		return ok(encounterProposal, ep)
+
+	})
+}
+
+// EPPatchHandler changes only the fields of the encounter proposal that are in the JSON Merge Patch it takes,
+// which can't be its creator nor applications, only if it is at the version in If-Match, when there is one
+func (api API) EPPatchHandler() gin.HandlerFunc {
+	return jsonHandler(func(ctx *gin.Context) result {
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			return er(http.StatusBadRequest, err)
+		}
+		patch, err := mergepatch.Parse(body)
+		if err != nil {
+			return er(http.StatusBadRequest, err)
+		}
+		if err := patch.Allow(patchableEPFields...); err != nil {
+			return er(http.StatusUnprocessableEntity, err)
+		}
+		version, err := etag.Parse(ctx.GetHeader(etag.HeaderIfMatch))
+		if err != nil {
+			return er(http.StatusBadRequest, err)
+		}
+
+		ep, err := api.byIDEPReader.ReadByID(ctx, ctx.Param(EPID))
 		if err != nil {
 			return er(http.StatusNotFound, err)
 		}
+		if version != 0 && version != ep.Version {
+			return er(http.StatusPreconditionFailed, etag.ErrVersionMismatch)
+		}
+		paths, err := patch.Apply(&ep)
+		if err != nil {
+			return er(http.StatusBadRequest, err)
+		}
+		if len(paths) == 0 {
+			ctx.Header(etag.HeaderETag, etag.Format(ep.Version))
+			return ok(encounterProposal, ep)
+		}
 
+		ep.Version = version
+		ep, err = api.epPatcher.Patch(ctx, ep, paths)
+		if err != nil {
+			return er(storeErrorStatus(err), err)
+		}
+
+		ctx.Header(etag.HeaderETag, etag.Format(ep.Version))
 		return ok(encounterProposal, ep)
 
 	})
 }
 
+// EPDeletionHandler deletes the encounter proposal, only if it is at the version in If-Match, when there is one
 func (api API) EPDeletionHandler() gin.HandlerFunc {
 	return jsonHandler(func(ctx *gin.Context) result {
 
+		version, err := etag.Parse(ctx.GetHeader(etag.HeaderIfMatch))
+		if err != nil {
+			return er(http.StatusBadRequest, err)
+		}
+
 		id := ctx.Param(EPID)
-		err := api.epDeleter.Delete(ctx, id)
+		err = api.epDeleter.Delete(ctx, id, version)
 		if err != nil {
-			return er(http.StatusNotFound, err)
+			return er(storeErrorStatus(err), err)
 		}
 
 		return ok(message, fmt.Sprintf("deleted encounter proposal %s", id))
@@ -347,6 +422,16 @@ func next() status {
 	return status{}
 }
 
+// storeErrorStatus tells apart encounter proposals at versions other than the one the client asked to change from missing ones
+func storeErrorStatus(err error) int {
+	if errors.Is(err, etag.ErrVersionMismatch) {
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusNotFound
+}
+
+var patchableEPFields = []string{"encounterSpecification"}
+
 var (
 	encounterProposal      = "encounterProposal"
 	encounterProposalSlice = "encounterProposals"
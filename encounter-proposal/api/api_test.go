@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -51,6 +52,7 @@ func TestAPI_EPCreatorGroupLeaderCheckerMiddleware_OK(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPCreatorGroupLeaderCheckerMiddleware()(c)
 
 	// assertions
@@ -106,6 +108,7 @@ func TestAPI_EPCreatorGroupLeaderCheckerMiddleware_ReaderError(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPCreatorGroupLeaderCheckerMiddleware()(c)
 
 	// assertions
@@ -168,6 +171,7 @@ func TestAPI_EPCreatorGroupLeaderCheckerMiddleware_LeaderFalse(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPCreatorGroupLeaderCheckerMiddleware()(c)
 
 	// assertions
@@ -242,6 +246,7 @@ func TestAPI_EPCreationHandler_OK(t *testing.T) {
 		nil,
 		nil,
 		&mockLeaderChecker,
+		nil,
 	).EPCreationHandler()(c)
 
 	// assertions
@@ -313,6 +318,7 @@ func TestAPI_EPCreationHandler_BadRequest(t *testing.T) {
 		nil,
 		nil,
 		&mockLeaderChecker,
+		nil,
 	).EPCreationHandler()(c)
 
 	// assertions
@@ -394,6 +400,7 @@ func TestAPI_EPCreationHandler_CreatorError(t *testing.T) {
 		nil,
 		nil,
 		&mockLeaderChecker,
+		nil,
 	).EPCreationHandler()(c)
 
 	// assertions
@@ -475,6 +482,7 @@ func TestAPI_EPCreationHandler_LeaderFalse(t *testing.T) {
 		nil,
 		nil,
 		&mockLeaderChecker,
+		nil,
 	).EPCreationHandler()(c)
 
 	// assertions
@@ -556,6 +564,7 @@ func TestAPI_EPCreationHandler_LeaderError(t *testing.T) {
 		nil,
 		nil,
 		&mockLeaderChecker,
+		nil,
 	).EPCreationHandler()(c)
 
 	// assertions
@@ -623,6 +632,7 @@ func TestAPI_EPReadingAllHandler_OK(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPReadingAllHandler()(c)
 
 	// assertions
@@ -681,6 +691,7 @@ func TestAPI_EPReadingAllHandler_BadRequest(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPReadingAllHandler()(c)
 
 	// assertions
@@ -732,6 +743,7 @@ func TestAPI_EPReadingAllHandler_ReaderError(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPReadingAllHandler()(c)
 
 	// assertions
@@ -795,6 +807,7 @@ func TestAPI_EPReadingByUserHandler_OK(t *testing.T) {
 		nil,
 		&mockLister,
 		nil,
+		nil,
 	).EPReadingByUserHandler()(c)
 
 	// assertions
@@ -864,6 +877,7 @@ func TestAPI_EPReadingByUserHandler_ListerError(t *testing.T) {
 		nil,
 		&mockLister,
 		nil,
+		nil,
 	).EPReadingByUserHandler()(c)
 
 	// assertions
@@ -933,6 +947,7 @@ func TestAPI_EPReadingByUserHandler_ReaderError(t *testing.T) {
 		nil,
 		&mockLister,
 		nil,
+		nil,
 	).EPReadingByUserHandler()(c)
 
 	// assertions
@@ -980,6 +995,7 @@ func TestAPI_EPReadingByIDHandler_OK(t *testing.T) {
 	mockReader := mockByIDEPReader{
 		ep: types.EncounterProposal{
 			EncounterSpecification: types.EncounterSpecification{Name: "mock"},
+			Version:                3,
 		},
 		err: nil,
 	}
@@ -997,6 +1013,7 @@ func TestAPI_EPReadingByIDHandler_OK(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPReadingByIDHandler()(c)
 
 	// assertions
@@ -1015,6 +1032,10 @@ func TestAPI_EPReadingByIDHandler_OK(t *testing.T) {
 	if got, want := w.Result().StatusCode, http.StatusOK; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
+	// verify response headers
+	if got, want := w.Result().Header.Get("ETag"), `"3"`; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
 	// verify mocks received values
 	if got, want := mockReader.ctx, c; got != want {
 		t.Fatalf("got %v, want %v", got, want)
@@ -1055,6 +1076,7 @@ func TestAPI_EPReadingByIDHandler_ReaderError(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPReadingByIDHandler()(c)
 
 	// assertions
@@ -1127,6 +1149,7 @@ func TestAPI_EPUpdateHandler_OK(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPUpdateHandler()(c)
 
 	// assertions
@@ -1198,6 +1221,7 @@ func TestAPI_EPUpdateHandler_BadRequest(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPUpdateHandler()(c)
 
 	// assertions
@@ -1276,6 +1300,7 @@ func TestAPI_EPUpdateHandler_MismatchingID(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPUpdateHandler()(c)
 
 	// assertions
@@ -1354,6 +1379,7 @@ func TestAPI_EPUpdateHandler_ReaderError(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPUpdateHandler()(c)
 
 	// assertions
@@ -1432,6 +1458,7 @@ func TestAPI_EPUpdateHandler_UpdaterError(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		nil,
 	).EPUpdateHandler()(c)
 
 	// assertions
@@ -1465,18 +1492,33 @@ func TestAPI_EPUpdateHandler_UpdaterError(t *testing.T) {
 	}
 }
 
-func TestAPI_EPDeletionHandler_OK(t *testing.T) {
+func TestAPI_EPUpdateHandler_VersionMismatch(t *testing.T) {
 	// prepare test setup
 
 	// setup request
 	dummyEPID := "dummy-ep-id"
+	dummyEP := types.EncounterProposal{
+		ID:                     dummyEPID,
+		EncounterSpecification: types.EncounterSpecification{Name: "dummy"},
+	}
+	epJSON, err := json.Marshal(dummyEP)
+	if err != nil {
+		t.Fatalf("unable to marshal request body")
+	}
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
-	req := &http.Request{}
+	req := &http.Request{
+		Header: http.Header{"If-Match": {`"1"`}},
+		Body:   io.NopCloser(bytes.NewBuffer(epJSON)),
+	}
 	c.Request = req
 	c.AddParam("epid", dummyEPID)
 	// setup mocks
-	mockDeleter := mockEPDeleter{err: nil}
+	mockReader := mockByIDEPReader{
+		ep:  types.EncounterProposal{ID: dummyEPID, Version: 2},
+		err: nil,
+	}
+	mockUpdater := mockEPUpdater{}
 
 	// run code under test
 
@@ -1484,52 +1526,67 @@ func TestAPI_EPDeletionHandler_OK(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		&mockReader,
+		&mockUpdater,
 		nil,
 		nil,
-		&mockDeleter,
 		nil,
 		nil,
 		nil,
 		nil,
-	).EPDeletionHandler()(c)
+	).EPUpdateHandler()(c)
 
 	// assertions
 
 	// verify response body
 	var resp struct {
-		Message string
+		Error string
 	}
 	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
 		t.Fatalf("unable to decode response body to json")
 	}
-	if got, want := resp.Message, "deleted encounter proposal "+dummyEPID; got != want {
+	if got, want := resp.Error, etag.ErrVersionMismatch.Error(); got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 	// verify response status code
-	if got, want := w.Result().StatusCode, http.StatusOK; got != want {
+	if got, want := w.Result().StatusCode, http.StatusPreconditionFailed; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 	// verify mocks received values
-	if got, want := mockDeleter.ctx, c; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockDeleter.id, dummyEPID; got != want {
+	if got, want := mockUpdater.ctx, context.Context(nil); got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 }
 
-func TestAPI_EPDeletionHandler_DeleterError(t *testing.T) {
+func TestAPI_EPPatchHandler_OK(t *testing.T) {
 	// prepare test setup
 
 	// setup request
 	dummyEPID := "dummy-ep-id"
+	dummyEP := types.EncounterProposal{
+		ID:                     dummyEPID,
+		EncounterSpecification: types.EncounterSpecification{Name: "dummy", Description: "dummy-description"},
+		Creator:                types.Group{ID: "dummy-group-id"},
+		Applications:           []types.Application{{Description: "dummy-application"}},
+	}
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
-	req := &http.Request{}
+	req := &http.Request{
+		Body: io.NopCloser(bytes.NewBufferString(`{"encounterSpecification": {"name": "patched", "description": null}}`)),
+	}
 	c.Request = req
 	c.AddParam("epid", dummyEPID)
 	// setup mocks
-	mockDeleter := mockEPDeleter{err: errors.New("mock deleter error")}
+	mockReader := mockByIDEPReader{
+		ep:  dummyEP,
+		err: nil,
+	}
+	mockPatcher := mockEPPatcher{
+		returnEP: types.EncounterProposal{
+			EncounterSpecification: types.EncounterSpecification{Name: "mock"},
+		},
+		err: nil,
+	}
 
 	// run code under test
 
@@ -1537,73 +1594,67 @@ func TestAPI_EPDeletionHandler_DeleterError(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		&mockReader,
 		nil,
 		nil,
-		&mockDeleter,
 		nil,
 		nil,
 		nil,
 		nil,
-	).EPDeletionHandler()(c)
+		&mockPatcher,
+	).EPPatchHandler()(c)
 
 	// assertions
 
 	// verify response body
 	var resp struct {
-		Error string
+		EncounterProposal types.EncounterProposal
 	}
 	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
 		t.Fatalf("unable to decode response body to json")
 	}
-	if resp.Error == "" {
-		t.Fatalf("got response body error \"\", want some error")
+	if got, want := resp.EncounterProposal, mockPatcher.returnEP; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
 	}
 	// verify response status code
-	if got, want := w.Result().StatusCode, http.StatusNotFound; got != want {
+	if got, want := w.Result().StatusCode, http.StatusOK; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 	// verify mocks received values
-	if got, want := mockDeleter.ctx, c; got != want {
+	if got, want := mockReader.id, dummyEPID; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
-	if got, want := mockDeleter.id, dummyEPID; got != want {
+	if got, want := mockPatcher.ctx, c; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	wantEP := dummyEP
+	wantEP.EncounterSpecification = types.EncounterSpecification{Name: "patched"}
+	if got, want := mockPatcher.receiveEP, wantEP; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockPatcher.paths, []string{"encounterSpecification.description", "encounterSpecification.name"}; !reflect.DeepEqual(got, want) {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 }
 
-func TestAPI_AppCreationHandler_OK(t *testing.T) {
+func TestAPI_EPPatchHandler_NotPatchable(t *testing.T) {
 	// prepare test setup
 
 	// setup request
-	dummyGroupID := "dummy-group-id"
-	dummyApp := types.Application{
-		Applicant: types.Group{ID: dummyGroupID},
-	}
-	epJSON, err := json.Marshal(dummyApp)
-	if err != nil {
-		t.Fatalf("unable to marshal request body")
-	}
+	dummyEPID := "dummy-ep-id"
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	req := &http.Request{
-		Body: io.NopCloser(bytes.NewBuffer(epJSON)),
+		Body: io.NopCloser(bytes.NewBufferString(`{"applications": []}`)),
 	}
 	c.Request = req
-	dummyUserID := "dummy-user-id"
-	c.Set("token", dummyUserID)
-	dummyEPID := "dummy-ep-id"
 	c.AddParam("epid", dummyEPID)
 	// setup mocks
-	mockAppender := mockAppAppender{
-		ep: types.EncounterProposal{
-			EncounterSpecification: types.EncounterSpecification{Name: "mock appender proposal"},
-		},
+	mockReader := mockByIDEPReader{
+		ep:  types.EncounterProposal{ID: dummyEPID},
 		err: nil,
 	}
-	mockLeaderChecker := mockGroupLeaderChecker{
-		isLeader: true,
-		err:      nil,
-	}
+	mockPatcher := mockEPPatcher{}
 
 	// run code under test
 
@@ -1611,75 +1662,59 @@ func TestAPI_AppCreationHandler_OK(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		&mockReader,
 		nil,
 		nil,
 		nil,
-		&mockAppender,
 		nil,
 		nil,
-		&mockLeaderChecker,
-	).AppCreationHandler()(c)
+		nil,
+		&mockPatcher,
+	).EPPatchHandler()(c)
 
 	// assertions
 
 	// verify response body
 	var resp struct {
-		Message string
+		Error string
 	}
 	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
 		t.Fatalf("unable to decode response body to json")
 	}
-	if got, want := resp.Message, "applied for encounter proposal "+dummyEPID; !reflect.DeepEqual(got, want) {
+	if got, want := resp.Error, "field can't be patched: applications"; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 	// verify response status code
-	if got, want := w.Result().StatusCode, http.StatusOK; got != want {
+	if got, want := w.Result().StatusCode, http.StatusUnprocessableEntity; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 	// verify mocks received values
-	if got, want := mockLeaderChecker.ctx, c; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockLeaderChecker.groupID, dummyGroupID; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockLeaderChecker.token, dummyUserID; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockAppender.ctx, c; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockAppender.epID, dummyEPID; got != want {
+	if got, want := mockReader.ctx, nilCtx; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
-	if got, want := mockAppender.app, dummyApp; !reflect.DeepEqual(got, want) {
+	if got, want := mockPatcher.ctx, nilCtx; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 }
 
-func TestAPI_AppCreationHandler_BadRequest(t *testing.T) {
+func TestAPI_EPPatchHandler_BadRequest(t *testing.T) {
 	// prepare test setup
 
 	// setup request
+	dummyEPID := "dummy-ep-id"
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
-	req := &http.Request{}
+	req := &http.Request{
+		Body: io.NopCloser(bytes.NewBufferString(`{"encounterSpecification": {"time": "dummy"}}`)),
+	}
 	c.Request = req
-	dummyUserID := "dummy-user-id"
-	c.Set("token", dummyUserID)
-	dummyEPID := "dummy-ep-id"
 	c.AddParam("epid", dummyEPID)
 	// setup mocks
-	mockAppender := mockAppAppender{
-		ep: types.EncounterProposal{
-			EncounterSpecification: types.EncounterSpecification{Name: "mock appender proposal"},
-		},
+	mockReader := mockByIDEPReader{
+		ep:  types.EncounterProposal{ID: dummyEPID},
 		err: nil,
 	}
-	mockLeaderChecker := mockGroupLeaderChecker{
-		isLeader: true,
-		err:      nil,
-	}
+	mockPatcher := mockEPPatcher{}
 
 	// run code under test
 
@@ -1687,85 +1722,45 @@ func TestAPI_AppCreationHandler_BadRequest(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		&mockReader,
+		nil,
 		nil,
 		nil,
 		nil,
-		&mockAppender,
 		nil,
 		nil,
-		&mockLeaderChecker,
-	).AppCreationHandler()(c)
+		&mockPatcher,
+	).EPPatchHandler()(c)
 
 	// assertions
 
-	// verify response body
-	var resp struct {
-		Error string
-	}
-	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
-		t.Fatalf("unable to decode response body to json")
-	}
-	if resp.Error == "" {
-		t.Fatalf("got response body error \"\", want some error")
-	}
 	// verify response status code
 	if got, want := w.Result().StatusCode, http.StatusBadRequest; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 	// verify mocks received values
-	if got, want := mockLeaderChecker.ctx, nilCtx; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockLeaderChecker.groupID, ""; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockLeaderChecker.token, ""; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockAppender.ctx, nilCtx; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockAppender.epID, ""; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockAppender.app, emptyApp; !reflect.DeepEqual(got, want) {
+	if got, want := mockPatcher.ctx, nilCtx; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 }
 
-func TestAPI_AppCreationHandler_LeaderCheckerFalse(t *testing.T) {
+func TestAPI_EPPatchHandler_ReaderError(t *testing.T) {
 	// prepare test setup
 
 	// setup request
-	dummyGroupID := "dummy-group-id"
-	dummyApp := types.Application{
-		Applicant: types.Group{ID: dummyGroupID},
-	}
-	epJSON, err := json.Marshal(dummyApp)
-	if err != nil {
-		t.Fatalf("unable to marshal request body")
-	}
+	dummyEPID := "dummy-ep-id"
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	req := &http.Request{
-		Body: io.NopCloser(bytes.NewBuffer(epJSON)),
+		Body: io.NopCloser(bytes.NewBufferString(`{"encounterSpecification": {"name": "patched"}}`)),
 	}
 	c.Request = req
-	dummyUserID := "dummy-user-id"
-	c.Set("token", dummyUserID)
-	dummyEPID := "dummy-ep-id"
 	c.AddParam("epid", dummyEPID)
 	// setup mocks
-	mockAppender := mockAppAppender{
-		ep: types.EncounterProposal{
-			EncounterSpecification: types.EncounterSpecification{Name: "mock appender proposal"},
-		},
-		err: nil,
-	}
-	mockLeaderChecker := mockGroupLeaderChecker{
-		isLeader: false,
-		err:      nil,
+	mockReader := mockByIDEPReader{
+		err: errors.New("mock error"),
 	}
+	mockPatcher := mockEPPatcher{}
 
 	// run code under test
 
@@ -1773,14 +1768,15 @@ func TestAPI_AppCreationHandler_LeaderCheckerFalse(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		&mockReader,
 		nil,
 		nil,
 		nil,
-		&mockAppender,
 		nil,
 		nil,
-		&mockLeaderChecker,
-	).AppCreationHandler()(c)
+		nil,
+		&mockPatcher,
+	).EPPatchHandler()(c)
 
 	// assertions
 
@@ -1791,66 +1787,38 @@ func TestAPI_AppCreationHandler_LeaderCheckerFalse(t *testing.T) {
 	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
 		t.Fatalf("unable to decode response body to json")
 	}
-	if got, want := resp.Error, "user is not a leader of applicant group"; got != want {
+	if got, want := resp.Error, mockReader.err.Error(); got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 	// verify response status code
-	if got, want := w.Result().StatusCode, http.StatusUnauthorized; got != want {
+	if got, want := w.Result().StatusCode, http.StatusNotFound; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 	// verify mocks received values
-	if got, want := mockLeaderChecker.ctx, c; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockLeaderChecker.groupID, dummyGroupID; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockLeaderChecker.token, dummyUserID; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockAppender.ctx, nilCtx; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockAppender.epID, ""; got != want {
-		t.Fatalf("got %v, want %v", got, want)
-	}
-	if got, want := mockAppender.app, emptyApp; !reflect.DeepEqual(got, want) {
+	if got, want := mockPatcher.ctx, nilCtx; got != want {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 }
 
-func TestAPI_AppCreationHandler_LeaderCheckerError(t *testing.T) {
+func TestAPI_EPPatchHandler_PatcherError(t *testing.T) {
 	// prepare test setup
 
 	// setup request
-	dummyGroupID := "dummy-group-id"
-	dummyApp := types.Application{
-		Applicant: types.Group{ID: dummyGroupID},
-	}
-	epJSON, err := json.Marshal(dummyApp)
-	if err != nil {
-		t.Fatalf("unable to marshal request body")
-	}
+	dummyEPID := "dummy-ep-id"
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	req := &http.Request{
-		Body: io.NopCloser(bytes.NewBuffer(epJSON)),
+		Body: io.NopCloser(bytes.NewBufferString(`{"encounterSpecification": {"name": "patched"}}`)),
 	}
 	c.Request = req
-	dummyUserID := "dummy-user-id"
-	c.Set("token", dummyUserID)
-	dummyEPID := "dummy-ep-id"
 	c.AddParam("epid", dummyEPID)
 	// setup mocks
-	mockAppender := mockAppAppender{
-		ep: types.EncounterProposal{
-			EncounterSpecification: types.EncounterSpecification{Name: "mock appender proposal"},
-		},
+	mockReader := mockByIDEPReader{
+		ep:  types.EncounterProposal{ID: dummyEPID},
 		err: nil,
 	}
-	mockLeaderChecker := mockGroupLeaderChecker{
-		isLeader: true,
-		err:      errors.New("mock leader error"),
+	mockPatcher := mockEPPatcher{
+		err: errors.New("mock error"),
 	}
 
 	// run code under test
@@ -1859,13 +1827,686 @@ func TestAPI_AppCreationHandler_LeaderCheckerError(t *testing.T) {
 		nil,
 		nil,
 		nil,
+		&mockReader,
 		nil,
 		nil,
 		nil,
-		&mockAppender,
 		nil,
 		nil,
-		&mockLeaderChecker,
+		nil,
+		&mockPatcher,
+	).EPPatchHandler()(c)
+
+	// assertions
+
+	// verify response body
+	var resp struct {
+		Error string
+	}
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("unable to decode response body to json")
+	}
+	if got, want := resp.Error, mockPatcher.err.Error(); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify response status code
+	if got, want := w.Result().StatusCode, http.StatusNotFound; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify mocks received values
+	if got, want := mockPatcher.ctx, c; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_EPPatchHandler_IfMatch(t *testing.T) {
+	// prepare test setup
+
+	// setup request
+	dummyEPID := "dummy-ep-id"
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := &http.Request{
+		Header: http.Header{"If-Match": {`"2"`}},
+		Body:   io.NopCloser(bytes.NewBufferString(`{"encounterSpecification": {"name": "patched"}}`)),
+	}
+	c.Request = req
+	c.AddParam("epid", dummyEPID)
+	// setup mocks
+	mockReader := mockByIDEPReader{
+		ep:  types.EncounterProposal{ID: dummyEPID, Version: 2},
+		err: nil,
+	}
+	mockPatcher := mockEPPatcher{
+		returnEP: types.EncounterProposal{ID: dummyEPID, Version: 3},
+		err:      nil,
+	}
+
+	// run code under test
+
+	api.New(
+		nil,
+		nil,
+		nil,
+		&mockReader,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		&mockPatcher,
+	).EPPatchHandler()(c)
+
+	// assertions
+
+	// verify response status code
+	if got, want := w.Result().StatusCode, http.StatusOK; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify response headers
+	if got, want := w.Result().Header.Get("ETag"), `"3"`; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify mocks received values
+	if got, want := mockPatcher.receiveEP.Version, int64(2); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_EPPatchHandler_VersionMismatch(t *testing.T) {
+	// prepare test setup
+
+	// setup request
+	dummyEPID := "dummy-ep-id"
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := &http.Request{
+		Header: http.Header{"If-Match": {`"1"`}},
+		Body:   io.NopCloser(bytes.NewBufferString(`{"encounterSpecification": {"name": "patched"}}`)),
+	}
+	c.Request = req
+	c.AddParam("epid", dummyEPID)
+	// setup mocks
+	mockReader := mockByIDEPReader{
+		ep:  types.EncounterProposal{ID: dummyEPID, Version: 2},
+		err: nil,
+	}
+	mockPatcher := mockEPPatcher{}
+
+	// run code under test
+
+	api.New(
+		nil,
+		nil,
+		nil,
+		&mockReader,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		&mockPatcher,
+	).EPPatchHandler()(c)
+
+	// assertions
+
+	// verify response status code
+	if got, want := w.Result().StatusCode, http.StatusPreconditionFailed; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify mocks received values
+	if got, want := mockPatcher.ctx, context.Context(nil); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_EPPatchHandler_PatcherVersionMismatch(t *testing.T) {
+	// prepare test setup
+
+	// setup request
+	dummyEPID := "dummy-ep-id"
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := &http.Request{
+		Header: http.Header{"If-Match": {`"2"`}},
+		Body:   io.NopCloser(bytes.NewBufferString(`{"encounterSpecification": {"name": "patched"}}`)),
+	}
+	c.Request = req
+	c.AddParam("epid", dummyEPID)
+	// setup mocks
+	mockReader := mockByIDEPReader{
+		ep:  types.EncounterProposal{ID: dummyEPID, Version: 2},
+		err: nil,
+	}
+	mockPatcher := mockEPPatcher{
+		err: etag.ErrVersionMismatch,
+	}
+
+	// run code under test
+
+	api.New(
+		nil,
+		nil,
+		nil,
+		&mockReader,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		&mockPatcher,
+	).EPPatchHandler()(c)
+
+	// assertions
+
+	// verify response status code
+	if got, want := w.Result().StatusCode, http.StatusPreconditionFailed; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify mocks received values
+	if got, want := mockPatcher.ctx, c; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_EPDeletionHandler_OK(t *testing.T) {
+	// prepare test setup
+
+	// setup request
+	dummyEPID := "dummy-ep-id"
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := &http.Request{}
+	c.Request = req
+	c.AddParam("epid", dummyEPID)
+	// setup mocks
+	mockDeleter := mockEPDeleter{err: nil}
+
+	// run code under test
+
+	api.New(
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		&mockDeleter,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	).EPDeletionHandler()(c)
+
+	// assertions
+
+	// verify response body
+	var resp struct {
+		Message string
+	}
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("unable to decode response body to json")
+	}
+	if got, want := resp.Message, "deleted encounter proposal "+dummyEPID; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify response status code
+	if got, want := w.Result().StatusCode, http.StatusOK; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify mocks received values
+	if got, want := mockDeleter.ctx, c; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockDeleter.id, dummyEPID; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_EPDeletionHandler_DeleterError(t *testing.T) {
+	// prepare test setup
+
+	// setup request
+	dummyEPID := "dummy-ep-id"
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := &http.Request{}
+	c.Request = req
+	c.AddParam("epid", dummyEPID)
+	// setup mocks
+	mockDeleter := mockEPDeleter{err: errors.New("mock deleter error")}
+
+	// run code under test
+
+	api.New(
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		&mockDeleter,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	).EPDeletionHandler()(c)
+
+	// assertions
+
+	// verify response body
+	var resp struct {
+		Error string
+	}
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("unable to decode response body to json")
+	}
+	if resp.Error == "" {
+		t.Fatalf("got response body error \"\", want some error")
+	}
+	// verify response status code
+	if got, want := w.Result().StatusCode, http.StatusNotFound; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify mocks received values
+	if got, want := mockDeleter.ctx, c; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockDeleter.id, dummyEPID; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_EPDeletionHandler_IfMatch(t *testing.T) {
+	// prepare test setup
+
+	// setup request
+	dummyEPID := "dummy-ep-id"
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := &http.Request{
+		Header: http.Header{"If-Match": {`"3"`}},
+	}
+	c.Request = req
+	c.AddParam("epid", dummyEPID)
+	// setup mocks
+	mockDeleter := mockEPDeleter{err: etag.ErrVersionMismatch}
+
+	// run code under test
+
+	api.New(
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		&mockDeleter,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	).EPDeletionHandler()(c)
+
+	// assertions
+
+	// verify response status code
+	if got, want := w.Result().StatusCode, http.StatusPreconditionFailed; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify mocks received values
+	if got, want := mockDeleter.id, dummyEPID; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockDeleter.version, int64(3); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_EPDeletionHandler_MalformedIfMatch(t *testing.T) {
+	// prepare test setup
+
+	// setup request
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := &http.Request{
+		Header: http.Header{"If-Match": {"3"}},
+	}
+	c.Request = req
+	c.AddParam("epid", "dummy-ep-id")
+	// setup mocks
+	mockDeleter := mockEPDeleter{}
+
+	// run code under test
+
+	api.New(
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		&mockDeleter,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	).EPDeletionHandler()(c)
+
+	// assertions
+
+	// verify response status code
+	if got, want := w.Result().StatusCode, http.StatusBadRequest; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify mocks received values
+	if got, want := mockDeleter.ctx, context.Context(nil); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_AppCreationHandler_OK(t *testing.T) {
+	// prepare test setup
+
+	// setup request
+	dummyGroupID := "dummy-group-id"
+	dummyApp := types.Application{
+		Applicant: types.Group{ID: dummyGroupID},
+	}
+	epJSON, err := json.Marshal(dummyApp)
+	if err != nil {
+		t.Fatalf("unable to marshal request body")
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := &http.Request{
+		Body: io.NopCloser(bytes.NewBuffer(epJSON)),
+	}
+	c.Request = req
+	dummyUserID := "dummy-user-id"
+	c.Set("token", dummyUserID)
+	dummyEPID := "dummy-ep-id"
+	c.AddParam("epid", dummyEPID)
+	// setup mocks
+	mockAppender := mockAppAppender{
+		ep: types.EncounterProposal{
+			EncounterSpecification: types.EncounterSpecification{Name: "mock appender proposal"},
+		},
+		err: nil,
+	}
+	mockLeaderChecker := mockGroupLeaderChecker{
+		isLeader: true,
+		err:      nil,
+	}
+
+	// run code under test
+
+	api.New(
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		&mockAppender,
+		nil,
+		nil,
+		&mockLeaderChecker,
+		nil,
+	).AppCreationHandler()(c)
+
+	// assertions
+
+	// verify response body
+	var resp struct {
+		Message string
+	}
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("unable to decode response body to json")
+	}
+	if got, want := resp.Message, "applied for encounter proposal "+dummyEPID; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify response status code
+	if got, want := w.Result().StatusCode, http.StatusOK; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify mocks received values
+	if got, want := mockLeaderChecker.ctx, c; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockLeaderChecker.groupID, dummyGroupID; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockLeaderChecker.token, dummyUserID; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockAppender.ctx, c; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockAppender.epID, dummyEPID; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockAppender.app, dummyApp; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_AppCreationHandler_BadRequest(t *testing.T) {
+	// prepare test setup
+
+	// setup request
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := &http.Request{}
+	c.Request = req
+	dummyUserID := "dummy-user-id"
+	c.Set("token", dummyUserID)
+	dummyEPID := "dummy-ep-id"
+	c.AddParam("epid", dummyEPID)
+	// setup mocks
+	mockAppender := mockAppAppender{
+		ep: types.EncounterProposal{
+			EncounterSpecification: types.EncounterSpecification{Name: "mock appender proposal"},
+		},
+		err: nil,
+	}
+	mockLeaderChecker := mockGroupLeaderChecker{
+		isLeader: true,
+		err:      nil,
+	}
+
+	// run code under test
+
+	api.New(
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		&mockAppender,
+		nil,
+		nil,
+		&mockLeaderChecker,
+		nil,
+	).AppCreationHandler()(c)
+
+	// assertions
+
+	// verify response body
+	var resp struct {
+		Error string
+	}
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("unable to decode response body to json")
+	}
+	if resp.Error == "" {
+		t.Fatalf("got response body error \"\", want some error")
+	}
+	// verify response status code
+	if got, want := w.Result().StatusCode, http.StatusBadRequest; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify mocks received values
+	if got, want := mockLeaderChecker.ctx, nilCtx; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockLeaderChecker.groupID, ""; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockLeaderChecker.token, ""; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockAppender.ctx, nilCtx; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockAppender.epID, ""; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockAppender.app, emptyApp; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_AppCreationHandler_LeaderCheckerFalse(t *testing.T) {
+	// prepare test setup
+
+	// setup request
+	dummyGroupID := "dummy-group-id"
+	dummyApp := types.Application{
+		Applicant: types.Group{ID: dummyGroupID},
+	}
+	epJSON, err := json.Marshal(dummyApp)
+	if err != nil {
+		t.Fatalf("unable to marshal request body")
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := &http.Request{
+		Body: io.NopCloser(bytes.NewBuffer(epJSON)),
+	}
+	c.Request = req
+	dummyUserID := "dummy-user-id"
+	c.Set("token", dummyUserID)
+	dummyEPID := "dummy-ep-id"
+	c.AddParam("epid", dummyEPID)
+	// setup mocks
+	mockAppender := mockAppAppender{
+		ep: types.EncounterProposal{
+			EncounterSpecification: types.EncounterSpecification{Name: "mock appender proposal"},
+		},
+		err: nil,
+	}
+	mockLeaderChecker := mockGroupLeaderChecker{
+		isLeader: false,
+		err:      nil,
+	}
+
+	// run code under test
+
+	api.New(
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		&mockAppender,
+		nil,
+		nil,
+		&mockLeaderChecker,
+		nil,
+	).AppCreationHandler()(c)
+
+	// assertions
+
+	// verify response body
+	var resp struct {
+		Error string
+	}
+	if err := json.NewDecoder(w.Result().Body).Decode(&resp); err != nil {
+		t.Fatalf("unable to decode response body to json")
+	}
+	if got, want := resp.Error, "user is not a leader of applicant group"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify response status code
+	if got, want := w.Result().StatusCode, http.StatusUnauthorized; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	// verify mocks received values
+	if got, want := mockLeaderChecker.ctx, c; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockLeaderChecker.groupID, dummyGroupID; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockLeaderChecker.token, dummyUserID; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockAppender.ctx, nilCtx; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockAppender.epID, ""; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := mockAppender.app, emptyApp; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestAPI_AppCreationHandler_LeaderCheckerError(t *testing.T) {
+	// prepare test setup
+
+	// setup request
+	dummyGroupID := "dummy-group-id"
+	dummyApp := types.Application{
+		Applicant: types.Group{ID: dummyGroupID},
+	}
+	epJSON, err := json.Marshal(dummyApp)
+	if err != nil {
+		t.Fatalf("unable to marshal request body")
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	req := &http.Request{
+		Body: io.NopCloser(bytes.NewBuffer(epJSON)),
+	}
+	c.Request = req
+	dummyUserID := "dummy-user-id"
+	c.Set("token", dummyUserID)
+	dummyEPID := "dummy-ep-id"
+	c.AddParam("epid", dummyEPID)
+	// setup mocks
+	mockAppender := mockAppAppender{
+		ep: types.EncounterProposal{
+			EncounterSpecification: types.EncounterSpecification{Name: "mock appender proposal"},
+		},
+		err: nil,
+	}
+	mockLeaderChecker := mockGroupLeaderChecker{
+		isLeader: true,
+		err:      errors.New("mock leader error"),
+	}
+
+	// run code under test
+
+	api.New(
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		&mockAppender,
+		nil,
+		nil,
+		&mockLeaderChecker,
+		nil,
 	).AppCreationHandler()(c)
 
 	// assertions
@@ -1952,6 +2593,7 @@ func TestAPI_AppCreationHandler_AppenderError(t *testing.T) {
 		nil,
 		nil,
 		&mockLeaderChecker,
+		nil,
 	).AppCreationHandler()(c)
 
 	// assertions
@@ -2021,6 +2663,7 @@ func TestAPI_AppDeletionHandler_OK(t *testing.T) {
 		mockDeleter,
 		nil,
 		nil,
+		nil,
 	).AppDeletionHandler()(c)
 
 	// assertions
@@ -2081,6 +2724,7 @@ func TestAPI_AppDeletionHandler_DeleterError(t *testing.T) {
 		mockDeleter,
 		nil,
 		nil,
+		nil,
 	).AppDeletionHandler()(c)
 
 	// assertions
@@ -2225,18 +2869,38 @@ func (m *mockEPUpdater) Update(ctx context.Context, ep types.EncounterProposal)
 	return m.returnEP, m.err
 }
 
+type mockEPPatcher struct {
+	// receive
+	ctx       context.Context
+	receiveEP types.EncounterProposal
+	paths     []string
+
+	// return
+	returnEP types.EncounterProposal
+	err      error
+}
+
+func (m *mockEPPatcher) Patch(ctx context.Context, ep types.EncounterProposal, paths []string) (types.EncounterProposal, error) {
+	m.ctx = ctx
+	m.receiveEP = ep
+	m.paths = paths
+	return m.returnEP, m.err
+}
+
 type mockEPDeleter struct {
 	// receive
-	ctx context.Context
-	id  string
+	ctx     context.Context
+	id      string
+	version int64
 
 	// return
 	err error
 }
 
-func (m *mockEPDeleter) Delete(ctx context.Context, id string) error {
+func (m *mockEPDeleter) Delete(ctx context.Context, id string, version int64) error {
 	m.ctx = ctx
 	m.id = id
+	m.version = version
 	return m.err
 }
 
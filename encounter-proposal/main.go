@@ -2,12 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/gabrielseibel1/gaef/auth"
 	"github.com/gabrielseibel1/gaef/client/group"
-	"github.com/gabrielseibel1/gaef/client/user"
 	"github.com/gabrielseibel1/gaef/encounter-proposal/api"
 	"github.com/gabrielseibel1/gaef/encounter-proposal/store"
+	"github.com/gabrielseibel1/gaef/messenger"
 	"log"
 	"net/http"
 	"os"
@@ -27,6 +28,7 @@ type handlerGenerators struct {
 	epReadingByUserHandlerGenerator                epReadingByUserHandlerGenerator
 	epReadingByIDHandlerGenerator                  epReadingByIDHandlerGenerator
 	epUpdateHandlerGenerator                       epUpdateHandlerGenerator
+	epPatchHandlerGenerator                        epPatchHandlerGenerator
 	epDeletionHandlerGenerator                     epDeletionHandlerGenerator
 	appCreationHandlerGenerator                    appCreationHandlerGenerator
 	appDeletionHandlerGenerator                    appDeletionHandlerGenerator
@@ -34,6 +36,7 @@ type handlerGenerators struct {
 
 type authMiddlewareGenerator interface {
 	AuthMiddleware() gin.HandlerFunc
+	VerifiedMiddleware() gin.HandlerFunc
 }
 type epCreatorGroupLeaderMiddlewareGenerator interface {
 	EPCreatorGroupLeaderCheckerMiddleware() gin.HandlerFunc
@@ -53,6 +56,9 @@ type epReadingByIDHandlerGenerator interface {
 type epUpdateHandlerGenerator interface {
 	EPUpdateHandler() gin.HandlerFunc
 }
+type epPatchHandlerGenerator interface {
+	EPPatchHandler() gin.HandlerFunc
+}
 type epDeletionHandlerGenerator interface {
 	EPDeletionHandler() gin.HandlerFunc
 }
@@ -68,6 +74,13 @@ func main() {
 	port := os.Getenv("PORT")
 	userServiceURL := os.Getenv("USER_SERVICE_URL")
 	groupServiceURL := os.Getenv("GROUP_SERVICE_URL")
+	amqpURI := os.Getenv("AMQP_URI")
+	amqpExchangeUpdates := os.Getenv("AMQP_EXCHANGE_UPDATES")
+	amqpExchangeDeletes := os.Getenv("AMQP_EXCHANGE_DELETES")
+	amqpExchangeErasures := os.Getenv("AMQP_EXCHANGE_ERASURES")
+	amqpQueuePrefix := os.Getenv("AMQP_QUEUE_PREFIX")
+	amqpExchangeGroupUpdates := os.Getenv("AMQP_EXCHANGE_GROUP_UPDATES")
+	amqpExchangeGroupDeletes := os.Getenv("AMQP_EXCHANGE_GROUP_DELETES")
 	dbURI := os.Getenv("MONGODB_URI")
 	dbName := os.Getenv("MONGODB_DATABASE")
 	collectionName := os.Getenv("MONGODB_COLLECTION")
@@ -96,11 +109,13 @@ func main() {
 	}
 
 	// instantiate and inject dependencies
-	userClient := user.Client{URL: userServiceURL}
+	// verify tokens locally, with the keys published by the user service
+	keys := auth.NewRemoteKeySet(userServiceURL+".well-known/jwks.json", 15*time.Minute, 10*time.Second)
 	groupClient := group.Client{URL: groupServiceURL}
-	authHandler := auth.NewMiddlewareGenerator(userClient, api.AuthenticatedUserID, api.AuthenticatedUserToken)
+	authHandler := auth.NewMiddlewareGenerator(auth.NewJWTReader(keys), auth.NewRemoteAPIKeyExchanger(userServiceURL+"api-keys/token"), api.AuthenticatedUserID, api.AuthenticatedUserToken)
 	db := store.New(client.Database(dbName).Collection(collectionName))
-	encounterProposalsAPI := api.New(db, db, db, db, db, db, db, db, groupClient, groupClient)
+
+	encounterProposalsAPI := api.New(db, db, db, db, db, db, db, db, groupClient, groupClient, db)
 	hg := handlerGenerators{
 		authMiddlewareGenerator:                        authHandler,
 		epCreatorGroupLeaderCheckerMiddlewareGenerator: encounterProposalsAPI,
@@ -109,11 +124,38 @@ func main() {
 		epReadingByUserHandlerGenerator:                encounterProposalsAPI,
 		epReadingByIDHandlerGenerator:                  encounterProposalsAPI,
 		epUpdateHandlerGenerator:                       encounterProposalsAPI,
+		epPatchHandlerGenerator:                        encounterProposalsAPI,
 		epDeletionHandlerGenerator:                     encounterProposalsAPI,
 		appCreationHandlerGenerator:                    encounterProposalsAPI,
 		appDeletionHandlerGenerator:                    encounterProposalsAPI,
 	}
 
+	// consume user events to keep embedded users in sync, confirming the erasure of deleted ones
+	connection, err := messenger.Dial(amqpURI)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := messenger.DeclareExchange(connection, amqpExchangeErasures); err != nil {
+		log.Fatal(err)
+	}
+	msg := messenger.New(json.Marshal, amqpSource, amqpExchangeUpdates, amqpExchangeDeletes, amqpExchangeErasures, connection)
+	consumer := messenger.NewConsumer(json.Unmarshal, amqpQueuePrefix, amqpExchangeUpdates, amqpExchangeDeletes, amqpExchangeErasures, connection)
+	go messenger.KeepConsuming(context.Background(), "user updates", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return consumer.ConsumeUserUpdates(ctx, db)
+	})
+	go messenger.KeepConsuming(context.Background(), "user deletes", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return consumer.ConsumeUserDeletes(ctx, messenger.NewErasingDeleter(db, msg, messenger.ServiceEncounterProposal))
+	})
+
+	// consume group events to keep embedded groups in sync, cleaning up after deleted ones
+	groupConsumer := messenger.NewGroupConsumer(json.Unmarshal, amqpQueuePrefix, amqpExchangeGroupUpdates, amqpExchangeGroupDeletes, connection)
+	go messenger.KeepConsuming(context.Background(), "group updates", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return groupConsumer.ConsumeGroupUpdates(ctx, db)
+	})
+	go messenger.KeepConsuming(context.Background(), "group deletes", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return groupConsumer.ConsumeGroupDeletes(ctx, db)
+	})
+
 	// run HTTP server
 	server := gin.Default()
 	root := server.Group("/api/v0/encounter-proposals")
@@ -131,12 +173,19 @@ func main() {
 			creatorsOnly := byEPID.Group("", hg.epCreatorGroupLeaderCheckerMiddlewareGenerator.EPCreatorGroupLeaderCheckerMiddleware())
 			{
 				creatorsOnly.PUT("", hg.epUpdateHandlerGenerator.EPUpdateHandler())
+				creatorsOnly.PATCH("", hg.epPatchHandlerGenerator.EPPatchHandler())
 				creatorsOnly.DELETE("", hg.epDeletionHandlerGenerator.EPDeletionHandler())
 				creatorsOnly.DELETE("/applications/:"+api.AppID, hg.appDeletionHandlerGenerator.AppDeletionHandler())
 			}
 
-			byEPID.POST("/applications", hg.appCreationHandlerGenerator.AppCreationHandler())
+			byEPID.POST("/applications", hg.authMiddlewareGenerator.VerifiedMiddleware(), hg.appCreationHandlerGenerator.AppCreationHandler())
 		}
 	}
 	log.Fatal(server.Run(fmt.Sprintf("0.0.0.0:%s", port)))
 }
+
+const (
+	amqpSource            = "gaef-encounter-proposal-service"
+	amqpReconnectDelay    = time.Second
+	amqpMaxReconnectDelay = time.Minute
+)
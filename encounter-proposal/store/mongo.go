@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -23,6 +26,7 @@ func New(collection *mongo.Collection) Mongo {
 
 func (m Mongo) Create(ctx context.Context, ep types.EncounterProposal) (types.EncounterProposal, error) {
 	ep.ID = ""
+	ep.Version = 1
 	// create with a non-nil slice of len 0 to be pushable
 	ep.Applications = []types.Application{}
 	result, err := m.collection.InsertOne(ctx, ep)
@@ -82,37 +86,118 @@ func (m Mongo) ReadByGroupIDs(ctx context.Context, groupIDs []string) ([]types.E
 	return eps, nil
 }
 
+// Update replaces an encounter proposal if it is at the version of the given one, any if zero, returning it as stored
 func (m Mongo) Update(ctx context.Context, ep types.EncounterProposal) (types.EncounterProposal, error) {
 	hex, err := primitive.ObjectIDFromHex(ep.ID)
 	if err != nil {
 		return types.EncounterProposal{}, err
 	}
 
+	version := ep.Version
 	ep.ID = ""
-	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": hex}, bson.M{"$set": ep})
+	ep.Version = 0
+	return m.updateVersioned(ctx, hex, version, bson.M{"$set": ep, "$inc": bson.M{"version": 1}})
+}
+
+// Patch sets only the fields of an encounter proposal at the given dot separated paths, unsetting those it doesn't have,
+// if it is at the version of the given one, any if zero
+func (m Mongo) Patch(ctx context.Context, ep types.EncounterProposal, paths []string) (types.EncounterProposal, error) {
+	hex, err := primitive.ObjectIDFromHex(ep.ID)
 	if err != nil {
 		return types.EncounterProposal{}, err
 	}
-	if result.ModifiedCount != 1 {
-		return types.EncounterProposal{}, errors.New("no such encounter proposal")
+
+	version := ep.Version
+	ep.ID = ""
+	ep.Version = 0
+	update, err := patchUpdate(ep, paths)
+	if err != nil {
+		return types.EncounterProposal{}, err
 	}
+	update["$inc"] = bson.M{"version": 1}
+	return m.updateVersioned(ctx, hex, version, update)
+}
 
-	ep.ID = hex.Hex()
-	return ep, nil
+// updateVersioned updates an encounter proposal if it is at the given version, any if zero,
+// as encounter proposals stored before versions have none
+func (m Mongo) updateVersioned(ctx context.Context, hex primitive.ObjectID, version int64, update bson.M) (types.EncounterProposal, error) {
+	result := m.collection.FindOneAndUpdate(ctx, versioned(bson.M{"_id": hex}, version), update, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if result.Err() == mongo.ErrNoDocuments {
+		return types.EncounterProposal{}, m.unmatched(ctx, hex, version)
+	}
+	if result.Err() != nil {
+		return types.EncounterProposal{}, result.Err()
+	}
+
+	var ep types.EncounterProposal
+	err := result.Decode(&ep)
+	return ep, err
 }
 
-func (m Mongo) Delete(ctx context.Context, id string) error {
+// unmatched tells why no encounter proposal matched a versioned filter: it is at another version than the given one, or there is no such encounter proposal
+func (m Mongo) unmatched(ctx context.Context, hex primitive.ObjectID, version int64) error {
+	if version == 0 {
+		return errors.New("no such encounter proposal")
+	}
+	n, err := m.collection.CountDocuments(ctx, bson.M{"_id": hex}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("no such encounter proposal")
+	}
+	return etag.ErrVersionMismatch
+}
+
+func versioned(filter bson.M, version int64) bson.M {
+	if version != 0 {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// patchUpdate sets the fields of doc at the given dot separated paths, and unsets those that doc doesn't have
+func patchUpdate(doc interface{}, paths []string) (bson.M, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	set, unset := bson.M{}, bson.M{}
+	for _, path := range paths {
+		value, err := bson.Raw(raw).LookupErr(strings.Split(path, ".")...)
+		if errors.Is(err, bsoncore.ErrElementNotFound) {
+			unset[path] = ""
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		set[path] = value
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
+}
+
+// Delete deletes an encounter proposal if it is at the given version, any if zero
+func (m Mongo) Delete(ctx context.Context, id string, version int64) error {
 	hex, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
 
-	result, err := m.collection.DeleteOne(ctx, bson.M{"_id": hex})
+	result, err := m.collection.DeleteOne(ctx, versioned(bson.M{"_id": hex}, version))
 	if err != nil {
 		return err
 	}
 	if result.DeletedCount != 1 {
-		return errors.New("no such encounter proposal")
+		return m.unmatched(ctx, hex, version)
 	}
 
 	return nil
@@ -124,7 +209,7 @@ func (m Mongo) AppendApplication(ctx context.Context, epID string, app types.App
 		return err
 	}
 
-	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": hex}, bson.M{"$push": bson.M{"applications": app}})
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": hex}, bson.M{"$push": bson.M{"applications": app}, "$inc": bson.M{"version": 1}})
 	if err != nil {
 		return err
 	}
@@ -141,7 +226,7 @@ func (m Mongo) DeleteApplication(ctx context.Context, epID string, appID string)
 		return err
 	}
 
-	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": hex}, bson.M{"$pull": bson.M{"applications": bson.M{"applicant._id": appID}}})
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": hex}, bson.M{"$pull": bson.M{"applications": bson.M{"applicant._id": appID}}, "$inc": bson.M{"version": 1}})
 	if err != nil {
 		return err
 	}
@@ -153,3 +238,90 @@ func (m Mongo) DeleteApplication(ctx context.Context, epID string, appID string)
 }
 
 var pageSize = 50
+
+// UpdateUser replaces the copies of a user, except the ones at a newer version, as updates delivered late must not undo later ones
+func (m Mongo) UpdateUser(ctx context.Context, user types.User) error {
+	older := bson.M{"user._id": user.ID, "user.version": bson.M{"$not": bson.M{"$gt": user.Version}}}
+	for _, a := range userArrays {
+		_, err := m.collection.UpdateMany(
+			ctx,
+			bson.M{a.query: user.ID},
+			bson.M{"$set": bson.M{a.path + ".$[user]": user}, "$inc": bson.M{"version": 1}},
+			options.Update().SetArrayFilters(a.arrayFilters(user.ID, older)),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m Mongo) DeleteUser(ctx context.Context, userID string) error {
+	for _, a := range userArrays {
+		opts := options.Update()
+		if a.outer != "" {
+			opts.SetArrayFilters(a.arrayFilters(userID))
+		}
+		_, err := m.collection.UpdateMany(
+			ctx,
+			bson.M{a.query: userID},
+			bson.M{"$pull": bson.M{a.path: bson.M{"_id": userID}}, "$inc": bson.M{"version": 1}},
+			opts,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateGroup replaces the copies of a group, as the creator of encounter proposals and as the applicant of applications
+func (m Mongo) UpdateGroup(ctx context.Context, group types.Group) error {
+	_, err := m.collection.UpdateMany(ctx, bson.M{"creator._id": group.ID}, bson.M{"$set": bson.M{"creator": group}, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return err
+	}
+	_, err = m.collection.UpdateMany(
+		ctx,
+		bson.M{"applications.applicant._id": group.ID},
+		bson.M{"$set": bson.M{"applications.$[application].applicant": group}, "$inc": bson.M{"version": 1}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"application.applicant._id": group.ID}}}),
+	)
+	return err
+}
+
+// DeleteGroup deletes the encounter proposals a group created and the applications it made to others.
+// Deleting a group again is harmless, so that redelivered messages are too.
+func (m Mongo) DeleteGroup(ctx context.Context, groupID string) error {
+	_, err := m.collection.DeleteMany(ctx, bson.M{"creator._id": groupID})
+	if err != nil {
+		return err
+	}
+	_, err = m.collection.UpdateMany(
+		ctx,
+		bson.M{"applications.applicant._id": groupID},
+		bson.M{"$pull": bson.M{"applications": bson.M{"applicant._id": groupID}}, "$inc": bson.M{"version": 1}},
+	)
+	return err
+}
+
+// userArray locates an array of an encounter proposal that embeds copies of users
+type userArray struct {
+	query string // path to the ids of the embedded users
+	path  string // path to the array, with a filtered positional operator if nested in another array
+	outer string // filter of the outer array element, if nested
+}
+
+func (a userArray) arrayFilters(userID string, filters ...interface{}) options.ArrayFilters {
+	if a.outer != "" {
+		filters = append(filters, bson.M{a.outer: userID})
+	}
+	return options.ArrayFilters{Filters: filters}
+}
+
+var userArrays = []userArray{
+	{query: "creator.members._id", path: "creator.members"},
+	{query: "creator.leaders._id", path: "creator.leaders"},
+	{query: "applications.applicant.members._id", path: "applications.$[application].applicant.members", outer: "application.applicant.members._id"},
+	{query: "applications.applicant.leaders._id", path: "applications.$[application].applicant.leaders", outer: "application.applicant.leaders._id"},
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"github.com/gabrielseibel1/gaef/encounter/server"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
+	"github.com/gabrielseibel1/gaef/types/mergepatch"
 	"net/http"
 )
 
@@ -35,9 +37,10 @@ type API struct {
 	encounterDeleter     EncounterDeleter
 	encounterConfirmer   EncounterConfirmer
 	encounterDecliner    EncounterDecliner
+	encounterPatcher     EncounterPatcher
 }
 
-func New(leaderChecker LeaderChecker, encounterCreator EncounterCreator, encounterReader EncounterReader, userEncountersReader UserEncountersReader, encounterUpdater EncounterUpdater, encounterDeleter EncounterDeleter, encounterConfirmer EncounterConfirmer, encounterDecliner EncounterDecliner) API {
+func New(leaderChecker LeaderChecker, encounterCreator EncounterCreator, encounterReader EncounterReader, userEncountersReader UserEncountersReader, encounterUpdater EncounterUpdater, encounterDeleter EncounterDeleter, encounterConfirmer EncounterConfirmer, encounterDecliner EncounterDecliner, encounterPatcher EncounterPatcher) API {
 	return API{
 		leaderChecker:        leaderChecker,
 		encounterCreator:     encounterCreator,
@@ -47,6 +50,7 @@ func New(leaderChecker LeaderChecker, encounterCreator EncounterCreator, encount
 		encounterDeleter:     encounterDeleter,
 		encounterConfirmer:   encounterConfirmer,
 		encounterDecliner:    encounterDecliner,
+		encounterPatcher:     encounterPatcher,
 	}
 }
 
@@ -87,6 +91,7 @@ func (a API) ReadEncounter(ctx context.Context, userID, encID string) server.Res
 	return okResult(encounterName, enc)
 }
 
+// UpdateEncounter replaces the encounter, only if it is at the version of the given one, when it has one
 func (a API) UpdateEncounter(ctx context.Context, userID string, encID string, e types.Encounter) server.Result {
 	// TODO test
 	if encID != e.ID {
@@ -101,15 +106,28 @@ func (a API) UpdateEncounter(ctx context.Context, userID string, encID string, e
 	if !userIsLeader(enc, userID) {
 		return errResult(http.StatusUnauthorized, errUnauthorized)
 	}
+	if e.Version != 0 && e.Version != enc.Version {
+		return errResult(http.StatusPreconditionFailed, etag.ErrVersionMismatch)
+	}
 
 	enc, err = a.encounterUpdater.UpdateEncounter(ctx, e)
 	if err != nil {
-		return errResult(http.StatusNotFound, err)
+		return errResult(storeErrorStatus(err), err)
 	}
 	return okResult(encounterName, enc)
 }
 
-func (a API) DeleteEncounter(ctx context.Context, userID, encID string) server.Result {
+// PatchEncounter changes only the fields of the encounter that are in the JSON Merge Patch,
+// which can't be its groups nor who confirmed it, only if it is at the given version, any if zero
+func (a API) PatchEncounter(ctx context.Context, userID string, encID string, data []byte, version int64) server.Result {
+	patch, err := mergepatch.Parse(data)
+	if err != nil {
+		return errResult(http.StatusBadRequest, err)
+	}
+	if err := patch.Allow(patchableEncounterFields...); err != nil {
+		return errResult(http.StatusUnprocessableEntity, err)
+	}
+
 	enc, err := a.encounterReader.ReadEncounter(ctx, encID)
 	if err != nil {
 		return errResult(http.StatusNotFound, err)
@@ -118,10 +136,43 @@ func (a API) DeleteEncounter(ctx context.Context, userID, encID string) server.R
 	if !userIsLeader(enc, userID) {
 		return errResult(http.StatusUnauthorized, errUnauthorized)
 	}
+	if version != 0 && version != enc.Version {
+		return errResult(http.StatusPreconditionFailed, etag.ErrVersionMismatch)
+	}
+
+	paths, err := patch.Apply(&enc)
+	if err != nil {
+		return errResult(http.StatusBadRequest, err)
+	}
+	if len(paths) == 0 {
+		return okResult(encounterName, enc)
+	}
+
+	enc.Version = version
+	enc, err = a.encounterPatcher.PatchEncounter(ctx, enc, paths)
+	if err != nil {
+		return errResult(storeErrorStatus(err), err)
+	}
+	return okResult(encounterName, enc)
+}
 
-	if err := a.encounterDeleter.DeleteEncounter(ctx, encID); err != nil {
+// DeleteEncounter deletes the encounter, only if it is at the given version, any if zero
+func (a API) DeleteEncounter(ctx context.Context, userID, encID string, version int64) server.Result {
+	enc, err := a.encounterReader.ReadEncounter(ctx, encID)
+	if err != nil {
 		return errResult(http.StatusNotFound, err)
 	}
+
+	if !userIsLeader(enc, userID) {
+		return errResult(http.StatusUnauthorized, errUnauthorized)
+	}
+	if version != 0 && version != enc.Version {
+		return errResult(http.StatusPreconditionFailed, etag.ErrVersionMismatch)
+	}
+
+	if err := a.encounterDeleter.DeleteEncounter(ctx, encID, version); err != nil {
+		return errResult(storeErrorStatus(err), err)
+	}
 	return okResult(idName, encID)
 }
 
@@ -187,8 +238,12 @@ type EncounterUpdater interface {
 	UpdateEncounter(ctx context.Context, e types.Encounter) (types.Encounter, error)
 }
 
+type EncounterPatcher interface {
+	PatchEncounter(ctx context.Context, e types.Encounter, paths []string) (types.Encounter, error)
+}
+
 type EncounterDeleter interface {
-	DeleteEncounter(ctx context.Context, id string) error
+	DeleteEncounter(ctx context.Context, id string, version int64) error
 }
 
 type EncounterConfirmer interface {
@@ -252,6 +307,14 @@ func userIsLeader(enc types.Encounter, userID string) bool {
 	return false
 }
 
+// storeErrorStatus tells apart encounters at versions other than the one the client asked to change from missing ones
+func storeErrorStatus(err error) int {
+	if errors.Is(err, etag.ErrVersionMismatch) {
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusNotFound
+}
+
 func okResult(name string, value any) Result {
 	return Result{Status: http.StatusOK, Name: name, Value: value}
 }
@@ -260,6 +323,8 @@ func errResult(status int, err error) Result {
 	return Result{Status: status, Name: errorName, Value: err.Error()}
 }
 
+var patchableEncounterFields = []string{"encounterSpecification", "invitedUsers"}
+
 var (
 	errUnauthorized = errors.New("unauthorized")
 )
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/gabrielseibel1/gaef/encounter/api"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"testing"
@@ -15,6 +16,8 @@ func dummyAPIError(status int) api.Result {
 	return api.Result{Status: status, Name: "error", Value: dummyError.Error()}
 }
 
+var versionMismatchAPIError = api.Result{Status: http.StatusPreconditionFailed, Name: "error", Value: etag.ErrVersionMismatch.Error()}
+
 var (
 	dummyError           = errors.New("dummy error")
 	unauthorizedAPIError = api.Result{Status: http.StatusUnauthorized, Name: "error", Value: "unauthorized"}
@@ -91,10 +94,11 @@ type mocks struct {
 	encounterDeleter     api.EncounterDeleter
 	encounterConfirmer   api.EncounterConfirmer
 	encounterDecliner    api.EncounterDecliner
+	encounterPatcher     api.EncounterPatcher
 }
 
 func apiFromMocks(m mocks) api.API {
-	return api.New(m.leaderChecker, m.encounterCreator, m.encounterReader, m.userEncountersReader, m.encounterUpdater, m.encounterDeleter, m.encounterConfirmer, m.encounterDecliner)
+	return api.New(m.leaderChecker, m.encounterCreator, m.encounterReader, m.userEncountersReader, m.encounterUpdater, m.encounterDeleter, m.encounterConfirmer, m.encounterDecliner, m.encounterPatcher)
 }
 
 func TestResult_S(t *testing.T) {
@@ -401,6 +405,8 @@ func TestAPI_UpdateEncounter(t *testing.T) {
 		encID:  dummyEncounter1.ID,
 		enc:    dummyEncounter1,
 	}
+	versionedEnc := dummyEncounter1
+	versionedEnc.Version = 3
 	tests := []struct {
 		name      string
 		mocks     mocks
@@ -479,6 +485,56 @@ func TestAPI_UpdateEncounter(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "update encounter version mismatch",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: dummyEncounter2},
+				encounterUpdater: &mockEncounterUpdater{retEnc: dummyEncounter2},
+			},
+			args: args{
+				ctx:    dummyCtx,
+				userID: dummyUser1.ID,
+				encID:  dummyEncounter1.ID,
+				enc:    versionedEnc,
+			},
+			want: versionMismatchAPIError,
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: dummyEncounter2,
+				},
+				encounterUpdater: &mockEncounterUpdater{
+					retEnc: dummyEncounter2,
+				},
+			},
+		},
+		{
+			name: "update encounter updater version mismatch",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: versionedEnc},
+				encounterUpdater: &mockEncounterUpdater{err: etag.ErrVersionMismatch},
+			},
+			args: args{
+				ctx:    dummyCtx,
+				userID: dummyUser1.ID,
+				encID:  dummyEncounter1.ID,
+				enc:    versionedEnc,
+			},
+			want: versionMismatchAPIError,
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: versionedEnc,
+				},
+				encounterUpdater: &mockEncounterUpdater{
+					ctx:    dummyCtx,
+					rcvEnc: versionedEnc,
+					err:    etag.ErrVersionMismatch,
+				},
+			},
+		},
 		{
 			name: "update encounter reader error",
 			mocks: mocks{
@@ -544,17 +600,287 @@ func TestAPI_UpdateEncounter(t *testing.T) {
 	}
 }
 
+func TestAPI_PatchEncounter(t *testing.T) {
+	type args struct {
+		ctx     context.Context
+		userID  string
+		encID   string
+		patch   string
+		version int64
+	}
+	readEnc := dummyEncounter1
+	readEnc.Time = time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC) // survives the round trip through JSON
+	patchedEnc := readEnc
+	patchedEnc.Name = "dummy-encounter-name-patched"
+	patchedEnc.InvitedUsers = []types.User{dummyUser1}
+	versionedReadEnc, versionedPatchedEnc := readEnc, patchedEnc
+	versionedReadEnc.Version, versionedPatchedEnc.Version = 3, 3
+	patch := `{"encounterSpecification": {"name": "dummy-encounter-name-patched"}, "invitedUsers": [{"id": "dummy-user-id-1", "name": "dummy-user-name-1", "email": "dummy-user-email-1"}]}`
+	dummyArgs := args{
+		ctx:    dummyCtx,
+		userID: dummyUser1.ID,
+		encID:  dummyEncounter1.ID,
+		patch:  patch,
+	}
+	tests := []struct {
+		name      string
+		mocks     mocks
+		args      args
+		want      api.Result
+		wantMocks mocks
+	}{
+		{
+			name: "patch encounter ok",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: readEnc},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+			args: dummyArgs,
+			want: api.Result{
+				Status: http.StatusOK,
+				Name:   "encounter",
+				Value:  dummyEncounter2,
+			},
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: readEnc,
+				},
+				encounterPatcher: &mockEncounterPatcher{
+					ctx:      dummyCtx,
+					rcvEnc:   patchedEnc,
+					rcvPaths: []string{"encounterSpecification.name", "invitedUsers"},
+					retEnc:   dummyEncounter2,
+				},
+			},
+		},
+		{
+			name: "patch encounter not patchable",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: readEnc},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+			args: args{
+				ctx:    dummyCtx,
+				userID: dummyUser1.ID,
+				encID:  dummyEncounter1.ID,
+				patch:  `{"confirmedUsers": []}`,
+			},
+			want: api.Result{
+				Status: http.StatusUnprocessableEntity,
+				Name:   "error",
+				Value:  "field can't be patched: confirmedUsers",
+			},
+			wantMocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: readEnc},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+		},
+		{
+			name: "patch encounter not an object",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: readEnc},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+			args: args{
+				ctx:    dummyCtx,
+				userID: dummyUser1.ID,
+				encID:  dummyEncounter1.ID,
+				patch:  `null`,
+			},
+			want: api.Result{
+				Status: http.StatusBadRequest,
+				Name:   "error",
+				Value:  "merge patch must be a JSON object",
+			},
+			wantMocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: readEnc},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+		},
+		{
+			name: "patch encounter leader false",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: readEnc},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+			args: args{
+				ctx:    dummyCtx,
+				userID: dummyID,
+				encID:  dummyEncounter1.ID,
+				patch:  patch,
+			},
+			want: unauthorizedAPIError,
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: readEnc,
+				},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+		},
+		{
+			name: "patch encounter if match",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: versionedReadEnc},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+			args: args{
+				ctx:     dummyCtx,
+				userID:  dummyUser1.ID,
+				encID:   dummyEncounter1.ID,
+				patch:   patch,
+				version: 3,
+			},
+			want: api.Result{
+				Status: http.StatusOK,
+				Name:   "encounter",
+				Value:  dummyEncounter2,
+			},
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: versionedReadEnc,
+				},
+				encounterPatcher: &mockEncounterPatcher{
+					ctx:      dummyCtx,
+					rcvEnc:   versionedPatchedEnc,
+					rcvPaths: []string{"encounterSpecification.name", "invitedUsers"},
+					retEnc:   dummyEncounter2,
+				},
+			},
+		},
+		{
+			name: "patch encounter version mismatch",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: readEnc},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+			args: args{
+				ctx:     dummyCtx,
+				userID:  dummyUser1.ID,
+				encID:   dummyEncounter1.ID,
+				patch:   patch,
+				version: 3,
+			},
+			want: versionMismatchAPIError,
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: readEnc,
+				},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+		},
+		{
+			name: "patch encounter patcher version mismatch",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: versionedReadEnc},
+				encounterPatcher: &mockEncounterPatcher{err: etag.ErrVersionMismatch},
+			},
+			args: args{
+				ctx:     dummyCtx,
+				userID:  dummyUser1.ID,
+				encID:   dummyEncounter1.ID,
+				patch:   patch,
+				version: 3,
+			},
+			want: versionMismatchAPIError,
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: versionedReadEnc,
+				},
+				encounterPatcher: &mockEncounterPatcher{
+					ctx:      dummyCtx,
+					rcvEnc:   versionedPatchedEnc,
+					rcvPaths: []string{"encounterSpecification.name", "invitedUsers"},
+					err:      etag.ErrVersionMismatch,
+				},
+			},
+		},
+		{
+			name: "patch encounter reader error",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: readEnc, err: dummyError},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+			args: dummyArgs,
+			want: dummyAPIError(http.StatusNotFound),
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: readEnc,
+					err: dummyError,
+				},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2},
+			},
+		},
+		{
+			name: "patch encounter patcher error",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: readEnc},
+				encounterPatcher: &mockEncounterPatcher{retEnc: dummyEncounter2, err: dummyError},
+			},
+			args: dummyArgs,
+			want: dummyAPIError(http.StatusNotFound),
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: readEnc,
+				},
+				encounterPatcher: &mockEncounterPatcher{
+					ctx:      dummyCtx,
+					rcvEnc:   patchedEnc,
+					rcvPaths: []string{"encounterSpecification.name", "invitedUsers"},
+					retEnc:   dummyEncounter2,
+					err:      dummyError,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := apiFromMocks(tt.mocks)
+			assert.Equalf(
+				t,
+				tt.want,
+				a.PatchEncounter(tt.args.ctx, tt.args.userID, tt.args.encID, []byte(tt.args.patch), tt.args.version),
+				"PatchEncounter(%v, %v, %v, %v, %v)",
+				tt.args.ctx,
+				tt.args.userID,
+				tt.args.encID,
+				tt.args.patch,
+				tt.args.version,
+			)
+			assert.Equal(t, tt.wantMocks, tt.mocks)
+		})
+	}
+}
+
 func TestAPI_DeleteEncounter(t *testing.T) {
 	type args struct {
-		ctx    context.Context
-		userID string
-		encID  string
+		ctx     context.Context
+		userID  string
+		encID   string
+		version int64
 	}
 	dummyArgs := args{
 		ctx:    dummyCtx,
 		userID: dummyUser1.ID,
 		encID:  dummyEncounter1.ID,
 	}
+	versionedArgs := dummyArgs
+	versionedArgs.version = 3
+	versionedEnc := dummyEncounter1
+	versionedEnc.Version = 3
 	tests := []struct {
 		name      string
 		mocks     mocks
@@ -622,6 +948,67 @@ func TestAPI_DeleteEncounter(t *testing.T) {
 				encounterDeleter: &mockEncounterDeleter{},
 			},
 		},
+		{
+			name: "delete encounter if match",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: versionedEnc},
+				encounterDeleter: &mockEncounterDeleter{},
+			},
+			args: versionedArgs,
+			want: api.Result{
+				Status: http.StatusOK, Name: "id", Value: dummyEncounter1.ID},
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: versionedEnc,
+				},
+				encounterDeleter: &mockEncounterDeleter{
+					ctx:     dummyCtx,
+					id:      dummyEncounter1.ID,
+					version: 3,
+				},
+			},
+		},
+		{
+			name: "delete encounter version mismatch",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: dummyEncounter1},
+				encounterDeleter: &mockEncounterDeleter{},
+			},
+			args: versionedArgs,
+			want: versionMismatchAPIError,
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: dummyEncounter1,
+				},
+				encounterDeleter: &mockEncounterDeleter{},
+			},
+		},
+		{
+			name: "delete encounter deleter version mismatch",
+			mocks: mocks{
+				encounterReader:  &mockEncounterReader{enc: versionedEnc},
+				encounterDeleter: &mockEncounterDeleter{err: etag.ErrVersionMismatch},
+			},
+			args: versionedArgs,
+			want: versionMismatchAPIError,
+			wantMocks: mocks{
+				encounterReader: &mockEncounterReader{
+					ctx: dummyCtx,
+					id:  dummyEncounter1.ID,
+					enc: versionedEnc,
+				},
+				encounterDeleter: &mockEncounterDeleter{
+					ctx:     dummyCtx,
+					id:      dummyEncounter1.ID,
+					version: 3,
+					err:     etag.ErrVersionMismatch,
+				},
+			},
+		},
 		{
 			name: "delete encounter deleter error",
 			mocks: mocks{
@@ -650,11 +1037,12 @@ func TestAPI_DeleteEncounter(t *testing.T) {
 			assert.Equalf(
 				t,
 				tt.want,
-				a.DeleteEncounter(tt.args.ctx, tt.args.userID, tt.args.encID),
-				"DeleteEncounter(%v, %v, %v)",
+				a.DeleteEncounter(tt.args.ctx, tt.args.userID, tt.args.encID, tt.args.version),
+				"DeleteEncounter(%v, %v, %v, %v)",
 				tt.args.ctx,
 				tt.args.userID,
 				tt.args.encID,
+				tt.args.version,
 			)
 			assert.Equal(t, tt.wantMocks, tt.mocks)
 		})
@@ -1006,15 +1394,32 @@ func (m *mockEncounterUpdater) UpdateEncounter(ctx context.Context, e types.Enco
 	return m.retEnc, m.err
 }
 
+type mockEncounterPatcher struct {
+	ctx      context.Context
+	rcvEnc   types.Encounter
+	rcvPaths []string
+	retEnc   types.Encounter
+	err      error
+}
+
+func (m *mockEncounterPatcher) PatchEncounter(ctx context.Context, e types.Encounter, paths []string) (types.Encounter, error) {
+	m.ctx = ctx
+	m.rcvEnc = e
+	m.rcvPaths = paths
+	return m.retEnc, m.err
+}
+
 type mockEncounterDeleter struct {
-	ctx context.Context
-	id  string
-	err error
+	ctx     context.Context
+	id      string
+	version int64
+	err     error
 }
 
-func (m *mockEncounterDeleter) DeleteEncounter(ctx context.Context, id string) error {
+func (m *mockEncounterDeleter) DeleteEncounter(ctx context.Context, id string, version int64) error {
 	m.ctx = ctx
 	m.id = id
+	m.version = version
 	return m.err
 }
 
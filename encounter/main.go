@@ -2,13 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/gabrielseibel1/gaef/auth"
 	"github.com/gabrielseibel1/gaef/client/group"
-	"github.com/gabrielseibel1/gaef/client/user"
 	"github.com/gabrielseibel1/gaef/encounter/api"
 	"github.com/gabrielseibel1/gaef/encounter/server"
 	"github.com/gabrielseibel1/gaef/encounter/store"
+	"github.com/gabrielseibel1/gaef/messenger"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -24,6 +25,13 @@ func main() {
 	port := os.Getenv("PORT")
 	userServiceURL := os.Getenv("USER_SERVICE_URL")
 	groupServiceURL := os.Getenv("GROUP_SERVICE_URL")
+	amqpURI := os.Getenv("AMQP_URI")
+	amqpExchangeUpdates := os.Getenv("AMQP_EXCHANGE_UPDATES")
+	amqpExchangeDeletes := os.Getenv("AMQP_EXCHANGE_DELETES")
+	amqpExchangeErasures := os.Getenv("AMQP_EXCHANGE_ERASURES")
+	amqpQueuePrefix := os.Getenv("AMQP_QUEUE_PREFIX")
+	amqpExchangeGroupUpdates := os.Getenv("AMQP_EXCHANGE_GROUP_UPDATES")
+	amqpExchangeGroupDeletes := os.Getenv("AMQP_EXCHANGE_GROUP_DELETES")
 	dbURI := os.Getenv("MONGODB_URI")
 	dbName := os.Getenv("MONGODB_DATABASE")
 	collectionName := os.Getenv("MONGODB_COLLECTION")
@@ -52,12 +60,39 @@ func main() {
 	}
 
 	// instantiate and inject dependencies
-	userClient := user.Client{URL: userServiceURL}
+	// verify tokens locally, with the keys published by the user service
+	keys := auth.NewRemoteKeySet(userServiceURL+".well-known/jwks.json", 15*time.Minute, 10*time.Second)
 	groupClient := group.Client{URL: groupServiceURL}
 	mongoStore := store.New(client.Database(dbName).Collection(collectionName))
-	authentication := auth.NewMiddlewareGenerator(userClient, "userID", "token")
-	apis := api.New(groupClient, mongoStore, mongoStore, mongoStore, mongoStore, mongoStore, mongoStore, mongoStore)
-	handlers := server.New(apis, apis, apis, apis, apis, apis, apis)
+	authentication := auth.NewMiddlewareGenerator(auth.NewJWTReader(keys), auth.NewRemoteAPIKeyExchanger(userServiceURL+"api-keys/token"), "userID", "token")
+	apis := api.New(groupClient, mongoStore, mongoStore, mongoStore, mongoStore, mongoStore, mongoStore, mongoStore, mongoStore)
+	handlers := server.New(apis, apis, apis, apis, apis, apis, apis, apis)
+
+	// consume user events to keep embedded users in sync, confirming the erasure of deleted ones
+	connection, err := messenger.Dial(amqpURI)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := messenger.DeclareExchange(connection, amqpExchangeErasures); err != nil {
+		log.Fatal(err)
+	}
+	msg := messenger.New(json.Marshal, amqpSource, amqpExchangeUpdates, amqpExchangeDeletes, amqpExchangeErasures, connection)
+	consumer := messenger.NewConsumer(json.Unmarshal, amqpQueuePrefix, amqpExchangeUpdates, amqpExchangeDeletes, amqpExchangeErasures, connection)
+	go messenger.KeepConsuming(context.Background(), "user updates", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return consumer.ConsumeUserUpdates(ctx, mongoStore)
+	})
+	go messenger.KeepConsuming(context.Background(), "user deletes", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return consumer.ConsumeUserDeletes(ctx, messenger.NewErasingDeleter(mongoStore, msg, messenger.ServiceEncounter))
+	})
+
+	// consume group events to keep embedded groups in sync, cleaning up after deleted ones
+	groupConsumer := messenger.NewGroupConsumer(json.Unmarshal, amqpQueuePrefix, amqpExchangeGroupUpdates, amqpExchangeGroupDeletes, connection)
+	go messenger.KeepConsuming(context.Background(), "group updates", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return groupConsumer.ConsumeGroupUpdates(ctx, mongoStore)
+	})
+	go messenger.KeepConsuming(context.Background(), "group deletes", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return groupConsumer.ConsumeGroupDeletes(ctx, mongoStore)
+	})
 
 	// setup HTTP server
 	app := gin.Default()
@@ -74,6 +109,7 @@ func main() {
 		{
 			byID.GET("", handlers.ReadEncounterHandler())
 			byID.PUT("", handlers.UpdateEncounterHandler())
+			byID.PATCH("", handlers.PatchEncounterHandler())
 			byID.DELETE("", handlers.DeleteEncounterHandler())
 
 			confirmation := byID.Group("/confirmation")
@@ -85,3 +121,9 @@ func main() {
 	}
 	log.Fatal(app.Run(fmt.Sprintf("0.0.0.0:%s", port)))
 }
+
+const (
+	amqpSource            = "gaef-encounter-service"
+	amqpReconnectDelay    = time.Second
+	amqpMaxReconnectDelay = time.Minute
+)
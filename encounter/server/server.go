@@ -3,7 +3,9 @@ package server
 import (
 	"context"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
 	"github.com/gin-gonic/gin"
+	"io"
 	"net/http"
 )
 
@@ -15,6 +17,7 @@ type Server struct {
 	encounterDeleter      EncounterDeleter
 	encounterConfirmer    EncounterConfirmer
 	encounterDecliner     EncounterDecliner
+	encounterPatcher      EncounterPatcher
 }
 
 func New(
@@ -25,6 +28,7 @@ func New(
 	encounterDeleter EncounterDeleter,
 	encounterConfirmer EncounterConfirmer,
 	encounterDecliner EncounterDecliner,
+	encounterPatcher EncounterPatcher,
 ) Server {
 	return Server{
 		encounterCreator:      encounterCreator,
@@ -34,6 +38,7 @@ func New(
 		encounterDeleter:      encounterDeleter,
 		encounterConfirmer:    encounterConfirmer,
 		encounterDecliner:     encounterDecliner,
+		encounterPatcher:      encounterPatcher,
 	}
 }
 
@@ -69,16 +74,41 @@ func (s Server) UpdateEncounterHandler() gin.HandlerFunc {
 		if err != nil {
 			return errorResult{s: http.StatusBadRequest, e: err}
 		}
+		e.Version, err = ifMatch(c)
+		if err != nil {
+			return errorResult{s: http.StatusBadRequest, e: err}
+		}
 
 		uID, eID := userID(c), encID(c)
 		return s.encounterUpdater.UpdateEncounter(c, uID, eID, e)
 	})
 }
 
+func (s Server) PatchEncounterHandler() gin.HandlerFunc {
+	return jsonHandler(func(c *gin.Context) Result {
+		patch, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return errorResult{s: http.StatusBadRequest, e: err}
+		}
+		version, err := ifMatch(c)
+		if err != nil {
+			return errorResult{s: http.StatusBadRequest, e: err}
+		}
+
+		uID, eID := userID(c), encID(c)
+		return s.encounterPatcher.PatchEncounter(c, uID, eID, patch, version)
+	})
+}
+
 func (s Server) DeleteEncounterHandler() gin.HandlerFunc {
 	return jsonHandler(func(c *gin.Context) Result {
+		version, err := ifMatch(c)
+		if err != nil {
+			return errorResult{s: http.StatusBadRequest, e: err}
+		}
+
 		uID, eID := userID(c), encID(c)
-		return s.encounterDeleter.DeleteEncounter(c, uID, eID)
+		return s.encounterDeleter.DeleteEncounter(c, uID, eID, version)
 	})
 }
 
@@ -99,6 +129,9 @@ func (s Server) DeclineEncounterHandler() gin.HandlerFunc {
 func jsonHandler(getResult func(c *gin.Context) Result) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		result := getResult(c)
+		if e, ok := result.V().(types.Encounter); ok {
+			c.Header(etag.HeaderETag, etag.Format(e.Version))
+		}
 		c.JSON(result.S(), gin.H{result.K(): result.V()})
 	}
 }
@@ -123,6 +156,11 @@ func encID(ctx *gin.Context) string {
 	return ctx.Param(EncIDParam)
 }
 
+// ifMatch is the version of the encounter that the client wants to change, any if zero
+func ifMatch(ctx *gin.Context) (int64, error) {
+	return etag.Parse(ctx.GetHeader(etag.HeaderIfMatch))
+}
+
 var EncIDParam = "encounter-id"
 
 type Result interface {
@@ -147,8 +185,12 @@ type EncounterUpdater interface {
 	UpdateEncounter(ctx context.Context, userID string, encID string, e types.Encounter) Result
 }
 
+type EncounterPatcher interface {
+	PatchEncounter(ctx context.Context, userID string, encID string, patch []byte, version int64) Result
+}
+
 type EncounterDeleter interface {
-	DeleteEncounter(ctx context.Context, userID, encID string) Result
+	DeleteEncounter(ctx context.Context, userID, encID string, version int64) Result
 }
 
 type EncounterConfirmer interface {
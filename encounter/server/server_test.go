@@ -23,6 +23,7 @@ type serverMocks struct {
 	encounterDeleter      server.EncounterDeleter
 	encounterConfirmer    server.EncounterConfirmer
 	encounterDecliner     server.EncounterDecliner
+	encounterPatcher      server.EncounterPatcher
 }
 
 func fromMocks(m serverMocks) server.Server {
@@ -34,6 +35,7 @@ func fromMocks(m serverMocks) server.Server {
 		m.encounterDeleter,
 		m.encounterConfirmer,
 		m.encounterDecliner,
+		m.encounterPatcher,
 	)
 }
 
@@ -164,6 +166,21 @@ func TestServer_ReadEncounterHandler(t *testing.T) {
 				})
 			},
 		},
+		{
+			name: "read encounter by id handler etag",
+			mocks: serverMocks{
+				encounterReaderByID: &mockEncounterReaderByID{res: result{s: http.StatusOK, k: "encounter", v: types.Encounter{Version: 3}}},
+			},
+			request:       &http.Request{},
+			ctxValues:     map[string]any{"userID": dummyUser1.ID},
+			ctxParams:     map[string]string{"encounter-id": dummyEncounter1.ID},
+			codeUnderTest: func(s server.Server) gin.HandlerFunc { return s.ReadEncounterHandler() },
+			assertResponseOK: func(t *testing.T, r *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, r.Result().StatusCode)
+				assert.Equal(t, `"3"`, r.Result().Header.Get("ETag"))
+			},
+			assertMocksOK: func(t *testing.T, c context.Context, mocks serverMocks) {},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -207,6 +224,94 @@ func TestServer_UpdateEncounterHandler(t *testing.T) {
 				assert.Equal(t, mocks.encounterUpdater, &mockEncounterUpdater{res: dummyResult})
 			},
 		},
+		{
+			name: "update encounter handler if match",
+			mocks: serverMocks{
+				encounterUpdater: &mockEncounterUpdater{res: dummyResult},
+			},
+			request:          withIfMatch(requestWithEncounterInBody(t, dummyEncounter1), `"3"`),
+			ctxValues:        map[string]any{"userID": dummyUser1.ID},
+			ctxParams:        map[string]string{"encounter-id": dummyEncounter1.ID},
+			codeUnderTest:    func(s server.Server) gin.HandlerFunc { return s.UpdateEncounterHandler() },
+			assertResponseOK: assertBodyFromDummyResult,
+			assertMocksOK: func(t *testing.T, c context.Context, mocks serverMocks) {
+				versionedEnc := dummyEncounter1
+				versionedEnc.Version = 3
+				assert.Equal(t, mocks.encounterUpdater, &mockEncounterUpdater{
+					ctx:    c,
+					userID: dummyUser1.ID,
+					encID:  dummyEncounter1.ID,
+					enc:    versionedEnc,
+					res:    dummyResult,
+				})
+			},
+		},
+		{
+			name: "update encounter handler malformed if match",
+			mocks: serverMocks{
+				encounterUpdater: &mockEncounterUpdater{res: dummyResult},
+			},
+			request:          withIfMatch(requestWithEncounterInBody(t, dummyEncounter1), "3"),
+			ctxValues:        map[string]any{"userID": dummyUser1.ID},
+			ctxParams:        map[string]string{"encounter-id": dummyEncounter1.ID},
+			codeUnderTest:    func(s server.Server) gin.HandlerFunc { return s.UpdateEncounterHandler() },
+			assertResponseOK: assertBodyFromError,
+			assertMocksOK: func(t *testing.T, c context.Context, mocks serverMocks) {
+				assert.Equal(t, mocks.encounterUpdater, &mockEncounterUpdater{res: dummyResult})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt)
+		})
+	}
+}
+
+func TestServer_PatchEncounterHandler(t *testing.T) {
+	patch := `{"encounterSpecification": {"name": "dummy-encounter-name-2"}}`
+	tests := []test{
+		{
+			name: "patch encounter handler ok",
+			mocks: serverMocks{
+				encounterPatcher: &mockEncounterPatcher{res: dummyResult},
+			},
+			request:          &http.Request{Body: io.NopCloser(bytes.NewBufferString(patch))},
+			ctxValues:        map[string]any{"userID": dummyUser1.ID},
+			ctxParams:        map[string]string{"encounter-id": dummyEncounter1.ID},
+			codeUnderTest:    func(s server.Server) gin.HandlerFunc { return s.PatchEncounterHandler() },
+			assertResponseOK: assertBodyFromDummyResult,
+			assertMocksOK: func(t *testing.T, c context.Context, mocks serverMocks) {
+				assert.Equal(t, mocks.encounterPatcher, &mockEncounterPatcher{
+					ctx:    c,
+					userID: dummyUser1.ID,
+					encID:  dummyEncounter1.ID,
+					patch:  []byte(patch),
+					res:    dummyResult,
+				})
+			},
+		},
+		{
+			name: "patch encounter handler if match",
+			mocks: serverMocks{
+				encounterPatcher: &mockEncounterPatcher{res: dummyResult},
+			},
+			request:          withIfMatch(&http.Request{Body: io.NopCloser(bytes.NewBufferString(patch))}, `"3"`),
+			ctxValues:        map[string]any{"userID": dummyUser1.ID},
+			ctxParams:        map[string]string{"encounter-id": dummyEncounter1.ID},
+			codeUnderTest:    func(s server.Server) gin.HandlerFunc { return s.PatchEncounterHandler() },
+			assertResponseOK: assertBodyFromDummyResult,
+			assertMocksOK: func(t *testing.T, c context.Context, mocks serverMocks) {
+				assert.Equal(t, mocks.encounterPatcher, &mockEncounterPatcher{
+					ctx:     c,
+					userID:  dummyUser1.ID,
+					encID:   dummyEncounter1.ID,
+					patch:   []byte(patch),
+					version: 3,
+					res:     dummyResult,
+				})
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -236,6 +341,40 @@ func TestServer_DeleteEncounterHandler(t *testing.T) {
 				})
 			},
 		},
+		{
+			name: "delete encounter handler if match",
+			mocks: serverMocks{
+				encounterDeleter: &mockEncounterDeleter{res: dummyResult},
+			},
+			request:          withIfMatch(&http.Request{}, `"3"`),
+			ctxValues:        map[string]any{"userID": dummyUser1.ID},
+			ctxParams:        map[string]string{"encounter-id": dummyEncounter1.ID},
+			codeUnderTest:    func(s server.Server) gin.HandlerFunc { return s.DeleteEncounterHandler() },
+			assertResponseOK: assertBodyFromDummyResult,
+			assertMocksOK: func(t *testing.T, c context.Context, mocks serverMocks) {
+				assert.Equal(t, mocks.encounterDeleter, &mockEncounterDeleter{
+					ctx:     c,
+					userID:  dummyUser1.ID,
+					encID:   dummyEncounter1.ID,
+					version: 3,
+					res:     dummyResult,
+				})
+			},
+		},
+		{
+			name: "delete encounter handler malformed if match",
+			mocks: serverMocks{
+				encounterDeleter: &mockEncounterDeleter{res: dummyResult},
+			},
+			request:          withIfMatch(&http.Request{}, `W/"3"`),
+			ctxValues:        map[string]any{"userID": dummyUser1.ID},
+			ctxParams:        map[string]string{"encounter-id": dummyEncounter1.ID},
+			codeUnderTest:    func(s server.Server) gin.HandlerFunc { return s.DeleteEncounterHandler() },
+			assertResponseOK: assertBodyFromError,
+			assertMocksOK: func(t *testing.T, c context.Context, mocks serverMocks) {
+				assert.Equal(t, mocks.encounterDeleter, &mockEncounterDeleter{res: dummyResult})
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -350,6 +489,11 @@ func requestWithEncounterInBody(t *testing.T, e types.Encounter) *http.Request {
 	}
 }
 
+func withIfMatch(r *http.Request, ifMatch string) *http.Request {
+	r.Header = http.Header{"If-Match": {ifMatch}}
+	return r
+}
+
 func assertBodyFromDummyResult(t *testing.T, r *httptest.ResponseRecorder) {
 	assert.Equal(t, dummyResult.s, r.Result().StatusCode)
 	var resp struct{ DummyKey string }
@@ -441,17 +585,37 @@ func (m *mockEncounterUpdater) UpdateEncounter(ctx context.Context, userID strin
 	return m.res
 }
 
+type mockEncounterPatcher struct {
+	ctx     context.Context
+	userID  string
+	encID   string
+	patch   []byte
+	version int64
+	res     server.Result
+}
+
+func (m *mockEncounterPatcher) PatchEncounter(ctx context.Context, userID string, encID string, patch []byte, version int64) server.Result {
+	m.ctx = ctx
+	m.userID = userID
+	m.encID = encID
+	m.patch = patch
+	m.version = version
+	return m.res
+}
+
 type mockEncounterDeleter struct {
-	ctx    context.Context
-	userID string
-	encID  string
-	res    server.Result
+	ctx     context.Context
+	userID  string
+	encID   string
+	version int64
+	res     server.Result
 }
 
-func (m *mockEncounterDeleter) DeleteEncounter(ctx context.Context, userID, encID string) server.Result {
+func (m *mockEncounterDeleter) DeleteEncounter(ctx context.Context, userID, encID string, version int64) server.Result {
 	m.ctx = ctx
 	m.userID = userID
 	m.encID = encID
+	m.version = version
 	return m.res
 }
 
@@ -4,9 +4,13 @@ import (
 	"context"
 	"errors"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/etag"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+	"strings"
 )
 
 type Mongo struct {
@@ -20,7 +24,8 @@ func New(collection *mongo.Collection) Mongo {
 }
 
 func (m Mongo) CreateEncounter(ctx context.Context, e types.Encounter) (string, error) {
-	e.ID = ""                         // don't want any id specified before insertion
+	e.ID = "" // don't want any id specified before insertion
+	e.Version = 1
 	e.ConfirmedUsers = []types.User{} // create with a non-nil slice of len 0 to be pushable
 	result, err := m.collection.InsertOne(ctx, e)
 	if err != nil {
@@ -60,37 +65,117 @@ func (m Mongo) ReadUserEncounters(ctx context.Context, userID string) ([]types.E
 	return e, nil
 }
 
+// UpdateEncounter replaces an encounter if it is at the version of the given one, any if zero, returning it as stored
 func (m Mongo) UpdateEncounter(ctx context.Context, e types.Encounter) (types.Encounter, error) {
 	hex, err := primitive.ObjectIDFromHex(e.ID)
 	if err != nil {
 		return types.Encounter{}, err
 	}
 
+	version := e.Version
 	e.ID = ""
-	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": hex}, bson.M{"$set": e})
+	e.Version = 0
+	return m.updateVersioned(ctx, hex, version, bson.M{"$set": e, "$inc": bson.M{"version": 1}})
+}
+
+// PatchEncounter sets only the fields of an encounter at the given dot separated paths, unsetting those it doesn't have,
+// if it is at the version of the given one, any if zero
+func (m Mongo) PatchEncounter(ctx context.Context, e types.Encounter, paths []string) (types.Encounter, error) {
+	hex, err := primitive.ObjectIDFromHex(e.ID)
 	if err != nil {
 		return types.Encounter{}, err
 	}
-	if result.ModifiedCount != 1 {
-		return types.Encounter{}, errors.New("no such encounter")
+
+	version := e.Version
+	e.ID = ""
+	e.Version = 0
+	update, err := patchUpdate(e, paths)
+	if err != nil {
+		return types.Encounter{}, err
 	}
+	update["$inc"] = bson.M{"version": 1}
+	return m.updateVersioned(ctx, hex, version, update)
+}
 
-	e.ID = hex.Hex()
-	return e, nil
+// updateVersioned updates an encounter if it is at the given version, any if zero, as encounters stored before versions have none
+func (m Mongo) updateVersioned(ctx context.Context, hex primitive.ObjectID, version int64, update bson.M) (types.Encounter, error) {
+	result := m.collection.FindOneAndUpdate(ctx, versioned(bson.M{"_id": hex}, version), update, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if result.Err() == mongo.ErrNoDocuments {
+		return types.Encounter{}, m.unmatched(ctx, hex, version)
+	}
+	if result.Err() != nil {
+		return types.Encounter{}, result.Err()
+	}
+
+	var e types.Encounter
+	err := result.Decode(&e)
+	return e, err
 }
 
-func (m Mongo) DeleteEncounter(ctx context.Context, id string) error {
+// unmatched tells why no encounter matched a versioned filter: it is at another version than the given one, or there is no such encounter
+func (m Mongo) unmatched(ctx context.Context, hex primitive.ObjectID, version int64) error {
+	if version == 0 {
+		return errors.New("no such encounter")
+	}
+	n, err := m.collection.CountDocuments(ctx, bson.M{"_id": hex}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("no such encounter")
+	}
+	return etag.ErrVersionMismatch
+}
+
+func versioned(filter bson.M, version int64) bson.M {
+	if version != 0 {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// patchUpdate sets the fields of doc at the given dot separated paths, and unsets those that doc doesn't have
+func patchUpdate(doc interface{}, paths []string) (bson.M, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	set, unset := bson.M{}, bson.M{}
+	for _, path := range paths {
+		value, err := bson.Raw(raw).LookupErr(strings.Split(path, ".")...)
+		if errors.Is(err, bsoncore.ErrElementNotFound) {
+			unset[path] = ""
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		set[path] = value
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
+}
+
+// DeleteEncounter deletes an encounter if it is at the given version, any if zero
+func (m Mongo) DeleteEncounter(ctx context.Context, id string, version int64) error {
 	hex, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
 
-	result, err := m.collection.DeleteOne(ctx, bson.M{"_id": hex})
+	result, err := m.collection.DeleteOne(ctx, versioned(bson.M{"_id": hex}, version))
 	if err != nil {
 		return err
 	}
 	if result.DeletedCount != 1 {
-		return errors.New("no such encounter")
+		return m.unmatched(ctx, hex, version)
 	}
 
 	return nil
@@ -102,7 +187,7 @@ func (m Mongo) ConfirmEncounter(ctx context.Context, encID string, user types.Us
 		return err
 	}
 
-	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": hex}, bson.M{"$push": bson.M{"confirmedUsers": user}})
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": hex}, bson.M{"$push": bson.M{"confirmedUsers": user}, "$inc": bson.M{"version": 1}})
 	if err != nil {
 		return err
 	}
@@ -119,7 +204,7 @@ func (m Mongo) DeclineEncounter(ctx context.Context, encID, userID string) error
 		return err
 	}
 
-	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": hex}, bson.M{"$pull": bson.M{"confirmedUsers": bson.M{"_id": userID}}})
+	result, err := m.collection.UpdateOne(ctx, bson.M{"_id": hex}, bson.M{"$pull": bson.M{"confirmedUsers": bson.M{"_id": userID}}, "$inc": bson.M{"version": 1}})
 	if err != nil {
 		return err
 	}
@@ -129,3 +214,84 @@ func (m Mongo) DeclineEncounter(ctx context.Context, encID, userID string) error
 
 	return nil
 }
+
+// UpdateUser replaces the copies of a user, except the ones at a newer version, as updates delivered late must not undo later ones
+func (m Mongo) UpdateUser(ctx context.Context, user types.User) error {
+	older := bson.M{"user._id": user.ID, "user.version": bson.M{"$not": bson.M{"$gt": user.Version}}}
+	for _, a := range userArrays {
+		_, err := m.collection.UpdateMany(
+			ctx,
+			bson.M{a.query: user.ID},
+			bson.M{"$set": bson.M{a.path + ".$[user]": user}, "$inc": bson.M{"version": 1}},
+			options.Update().SetArrayFilters(a.arrayFilters(user.ID, older)),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m Mongo) DeleteUser(ctx context.Context, userID string) error {
+	for _, a := range userArrays {
+		opts := options.Update()
+		if a.outer != "" {
+			opts.SetArrayFilters(a.arrayFilters(userID))
+		}
+		_, err := m.collection.UpdateMany(
+			ctx,
+			bson.M{a.query: userID},
+			bson.M{"$pull": bson.M{a.path: bson.M{"_id": userID}}, "$inc": bson.M{"version": 1}},
+			opts,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateGroup replaces the copies of a group among the groups of encounters
+func (m Mongo) UpdateGroup(ctx context.Context, group types.Group) error {
+	_, err := m.collection.UpdateMany(
+		ctx,
+		bson.M{"groups._id": group.ID},
+		bson.M{"$set": bson.M{"groups.$[group]": group}, "$inc": bson.M{"version": 1}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"group._id": group.ID}}}),
+	)
+	return err
+}
+
+// DeleteGroup takes a group out of the encounters it took part in, deleting the ones left without groups,
+// as only leaders of their groups can manage them.
+// Deleting a group again is harmless, so that redelivered messages are too.
+func (m Mongo) DeleteGroup(ctx context.Context, groupID string) error {
+	// only the encounters of this group alone, before taking it out of the others, so that a retry finds them again
+	_, err := m.collection.DeleteMany(ctx, bson.M{"groups._id": groupID, "groups": bson.M{"$size": 1}})
+	if err != nil {
+		return err
+	}
+	_, err = m.collection.UpdateMany(ctx, bson.M{"groups._id": groupID}, bson.M{"$pull": bson.M{"groups": bson.M{"_id": groupID}}, "$inc": bson.M{"version": 1}})
+	return err
+}
+
+// userArray locates an array of an encounter that embeds copies of users
+type userArray struct {
+	query string // path to the ids of the embedded users
+	path  string // path to the array, with a filtered positional operator if nested in another array
+	outer string // filter of the outer array element, if nested
+}
+
+func (a userArray) arrayFilters(userID string, filters ...interface{}) options.ArrayFilters {
+	if a.outer != "" {
+		filters = append(filters, bson.M{a.outer: userID})
+	}
+	return options.ArrayFilters{Filters: filters}
+}
+
+var userArrays = []userArray{
+	{query: "invitedUsers._id", path: "invitedUsers"},
+	{query: "confirmedUsers._id", path: "confirmedUsers"},
+	{query: "groups.members._id", path: "groups.$[group].members", outer: "group.members._id"},
+	{query: "groups.leaders._id", path: "groups.$[group].leaders", outer: "group.leaders._id"},
+}
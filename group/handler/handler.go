@@ -4,8 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/gabrielseibel1/gaef/blob"
+	"github.com/gabrielseibel1/gaef/blob/picture"
+	userClient "github.com/gabrielseibel1/gaef/client/user"
+	"github.com/gabrielseibel1/gaef/group/membership"
+	"github.com/gabrielseibel1/gaef/group/search"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/cursor"
+	"github.com/gabrielseibel1/gaef/types/etag"
+	"github.com/gabrielseibel1/gaef/types/mergepatch"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,25 +29,116 @@ type Handler struct {
 	groupReader               GroupReader
 	groupUpdater              GroupUpdater
 	groupDeleter              GroupDeleter
+	pictureSaver              PictureSaver
+	pictureOpener             PictureOpener
+	pictureDeleter            PictureDeleter
+	invitationCreator         InvitationCreator
+	invitationsReader         InvitationsReader
+	groupInvitationsReader    GroupInvitationsReader
+	invitationReader          InvitationReader
+	invitationDeleter         InvitationDeleter
+	memberAdder               MemberAdder
+	userReader                UserReader
+	joinRequestCreator        JoinRequestCreator
+	joinRequestsReader        JoinRequestsReader
+	joinRequestReader         JoinRequestReader
+	joinRequestDeleter        JoinRequestDeleter
+	memberRemover             MemberRemover
+	leaderPromoter            LeaderPromoter
+	leaderDemoter             LeaderDemoter
+	leadershipTransferrer     LeadershipTransferrer
+	groupSearcher             GroupSearcher
+	nearbyGroupsReader        NearbyGroupsReader
+	groupPatcher              GroupPatcher
 }
 
-func New(
-	leaderChecker LeaderChecker,
-	groupCreator GroupCreator,
-	participatingGroupsReader ParticipatingGroupsReader,
-	leadingGroupsReader LeadingGroupsReader,
-	groupReader GroupReader,
-	groupUpdater GroupUpdater,
-	groupDeleter GroupDeleter,
-) Handler {
+// Groups are the group store
+type Groups struct {
+	LeaderChecker       LeaderChecker
+	Creator             GroupCreator
+	ParticipatingReader ParticipatingGroupsReader
+	LeadingReader       LeadingGroupsReader
+	Reader              GroupReader
+	Updater             GroupUpdater
+	Patcher             GroupPatcher
+	Deleter             GroupDeleter
+	Searcher            GroupSearcher
+	NearbyReader        NearbyGroupsReader
+}
+
+// Pictures store the pictures of groups
+type Pictures struct {
+	Saver   PictureSaver
+	Opener  PictureOpener
+	Deleter PictureDeleter
+}
+
+// Invitations are the invitations of users to groups
+type Invitations struct {
+	Creator       InvitationCreator
+	InviteeReader InvitationsReader
+	GroupReader   GroupInvitationsReader
+	Reader        InvitationReader
+	Deleter       InvitationDeleter
+}
+
+// JoinRequests are the requests of users to join groups
+type JoinRequests struct {
+	Creator     JoinRequestCreator
+	GroupReader JoinRequestsReader
+	Reader      JoinRequestReader
+	Deleter     JoinRequestDeleter
+}
+
+// Membership changes who is in groups and who leads them, with the profiles of users at the user service
+type Membership struct {
+	UserReader            UserReader
+	Adder                 MemberAdder
+	Remover               MemberRemover
+	LeaderPromoter        LeaderPromoter
+	LeaderDemoter         LeaderDemoter
+	LeadershipTransferrer LeadershipTransferrer
+}
+
+// Dependencies are everything the handler needs, grouped by the feature that needs it
+type Dependencies struct {
+	Groups       Groups
+	Pictures     Pictures
+	Invitations  Invitations
+	JoinRequests JoinRequests
+	Membership   Membership
+}
+
+func New(d Dependencies) Handler {
 	return Handler{
-		leaderChecker:             leaderChecker,
-		groupCreator:              groupCreator,
-		participatingGroupsReader: participatingGroupsReader,
-		leadingGroupsReader:       leadingGroupsReader,
-		groupReader:               groupReader,
-		groupUpdater:              groupUpdater,
-		groupDeleter:              groupDeleter,
+		leaderChecker:             d.Groups.LeaderChecker,
+		groupCreator:              d.Groups.Creator,
+		participatingGroupsReader: d.Groups.ParticipatingReader,
+		leadingGroupsReader:       d.Groups.LeadingReader,
+		groupReader:               d.Groups.Reader,
+		groupUpdater:              d.Groups.Updater,
+		groupDeleter:              d.Groups.Deleter,
+		pictureSaver:              d.Pictures.Saver,
+		pictureOpener:             d.Pictures.Opener,
+		pictureDeleter:            d.Pictures.Deleter,
+		invitationCreator:         d.Invitations.Creator,
+		invitationsReader:         d.Invitations.InviteeReader,
+		groupInvitationsReader:    d.Invitations.GroupReader,
+		invitationReader:          d.Invitations.Reader,
+		invitationDeleter:         d.Invitations.Deleter,
+		memberAdder:               d.Membership.Adder,
+		userReader:                d.Membership.UserReader,
+		joinRequestCreator:        d.JoinRequests.Creator,
+		joinRequestsReader:        d.JoinRequests.GroupReader,
+		joinRequestReader:         d.JoinRequests.Reader,
+		joinRequestDeleter:        d.JoinRequests.Deleter,
+		memberRemover:             d.Membership.Remover,
+		leaderPromoter:            d.Membership.LeaderPromoter,
+		leaderDemoter:             d.Membership.LeaderDemoter,
+		leadershipTransferrer:     d.Membership.LeadershipTransferrer,
+		groupSearcher:             d.Groups.Searcher,
+		nearbyGroupsReader:        d.Groups.NearbyReader,
+		groupPatcher:              d.Groups.Patcher,
 	}
 }
 
@@ -55,6 +157,8 @@ func (h Handler) OnlyLeadersMiddleware() gin.HandlerFunc {
 	}
 }
 
+// CreateGroupHandler creates a group led by its creator alone.
+// Posted members and leaders are ignored, since users only join groups by accepting invitations or having join requests approved.
 func (h Handler) CreateGroupHandler() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var group types.Group
@@ -62,8 +166,28 @@ func (h Handler) CreateGroupHandler() gin.HandlerFunc {
 			ctx.JSON(http.StatusBadRequest, ginErrorMessage(err))
 			return
 		}
+		if !validVisibility(group.Visibility) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorMessageInvalidVisibility)
+			return
+		}
+		if !normalizeDiscovery(&group) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorMessageInvalidTags)
+			return
+		}
+		if !validLocation(group.Location) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorMessageInvalidLocation)
+			return
+		}
+
+		creator, err := h.userReader.ReadUser(ctx, ctx.GetString("token"), ctx.GetString("userID"))
+		if err != nil {
+			ctx.JSON(http.StatusBadGateway, ginErrorMessage(err))
+			return
+		}
+		group.Members = []types.User{creator}
+		group.Leaders = []types.User{creator}
 
-		group, err := h.groupCreator.CreateGroup(ctx, group)
+		group, err = h.groupCreator.CreateGroup(ctx, group)
 		if err != nil {
 			ctx.JSON(http.StatusUnprocessableEntity, ginErrorMessage(err))
 			return
@@ -73,6 +197,84 @@ func (h Handler) CreateGroupHandler() gin.HandlerFunc {
 	}
 }
 
+// SearchGroupsHandler finds public groups by words in them, tags and category, a page at a time.
+// It responds with summaries of the groups, which leave out who is in them.
+func (h Handler) SearchGroupsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var query struct {
+			Q        string `form:"q"`
+			Tags     string `form:"tags"`
+			Category string `form:"category"`
+			Cursor   string `form:"cursor"`
+			Limit    int64  `form:"limit" binding:"min=0,max=50"`
+		}
+		if err := ctx.ShouldBindQuery(&query); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorMessageBadSearchQuery)
+			return
+		}
+		q := search.Query{
+			Text:     strings.TrimSpace(query.Q),
+			Tags:     normalizeTags(strings.Split(query.Tags, ",")),
+			Category: strings.ToLower(strings.TrimSpace(query.Category)),
+			Limit:    query.Limit,
+		}
+		if q.Limit == 0 {
+			q.Limit = searchPageSize
+		}
+		if query.Cursor != "" {
+			after, err := cursor.Parse(query.Cursor)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, errorMessageBadCursor)
+				return
+			}
+			q.After = after
+		}
+
+		groups, next, err := h.groupSearcher.SearchGroups(ctx, q)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, ginErrorMessage(err))
+			return
+		}
+
+		resp := gin.H{"groups": groups}
+		if next != nil {
+			resp["nextCursor"] = next.Encode()
+		}
+		ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+// NearbyGroupsHandler finds public groups with a home base within a radius of where the user is, nearest first
+func (h Handler) NearbyGroupsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var query struct {
+			// pointers, so that the equator and the prime meridian are not taken for missing coordinates
+			Lat      *float64 `form:"lat" binding:"required,min=-90,max=90"`
+			Lng      *float64 `form:"lng" binding:"required,min=-180,max=180"`
+			RadiusKm float64  `form:"radiusKm" binding:"min=0,max=500"`
+			Limit    int64    `form:"limit" binding:"min=0,max=50"`
+		}
+		if err := ctx.ShouldBindQuery(&query); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorMessageBadNearbyQuery)
+			return
+		}
+		if query.RadiusKm == 0 {
+			query.RadiusKm = nearbyRadiusKm
+		}
+		if query.Limit == 0 {
+			query.Limit = searchPageSize
+		}
+
+		groups, err := h.nearbyGroupsReader.NearbyGroups(ctx, *query.Lat, *query.Lng, query.RadiusKm, query.Limit)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, ginErrorMessage(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"groups": groups})
+	}
+}
+
 func (h Handler) ReadParticipatingGroupsHandler() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		userID := ctx.GetString("userID")
@@ -111,10 +313,13 @@ func (h Handler) ReadGroupHandler() gin.HandlerFunc {
 			return
 		}
 
+		ctx.Header(etag.HeaderETag, etag.Format(group.Version))
 		ctx.JSON(http.StatusOK, gin.H{"group": group})
 	}
 }
 
+// UpdateGroupHandler replaces the group, only if it is at the version in If-Match, when there is one.
+// Its picture, members and leaders are kept as stored, as they have endpoints of their own.
 func (h Handler) UpdateGroupHandler() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		groupID := ctx.Param("id")
@@ -128,30 +333,726 @@ func (h Handler) UpdateGroupHandler() gin.HandlerFunc {
 			ctx.JSON(http.StatusUnprocessableEntity, ginErrorMessage(errors.New("group id cannot be updated")))
 			return
 		}
+		version, err := etag.Parse(ctx.GetHeader(etag.HeaderIfMatch))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, ginErrorMessage(err))
+			return
+		}
+		group.Version = version
+		if !validVisibility(group.Visibility) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorMessageInvalidVisibility)
+			return
+		}
+		if !normalizeDiscovery(&group) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorMessageInvalidTags)
+			return
+		}
+		if !validLocation(group.Location) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorMessageInvalidLocation)
+			return
+		}
+
+		group, err = h.groupUpdater.UpdateGroup(ctx, group)
+		if err != nil {
+			ctx.JSON(storeErrorStatus(err), ginErrorMessage(err))
+			return
+		}
+
+		ctx.Header(etag.HeaderETag, etag.Format(group.Version))
+		ctx.JSON(http.StatusOK, gin.H{"group": group})
+	}
+}
+
+// PatchGroupHandler changes only the fields of the group that are in the JSON Merge Patch it takes,
+// which can't be its members and leaders, as those change by joining, leaving, promotions and demotions.
+// Like updates, patches honour If-Match.
+func (h Handler) PatchGroupHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, ginErrorMessage(err))
+			return
+		}
+		patch, err := mergepatch.Parse(body)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, ginErrorMessage(err))
+			return
+		}
+		if err := patch.Allow(patchableGroupFields...); err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, ginErrorMessage(err))
+			return
+		}
+		version, err := etag.Parse(ctx.GetHeader(etag.HeaderIfMatch))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, ginErrorMessage(err))
+			return
+		}
 
-		group, err := h.groupUpdater.UpdateGroup(ctx, group)
+		group, err := h.groupReader.ReadGroup(ctx, groupID)
 		if err != nil {
 			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
 			return
 		}
+		if version != 0 && version != group.Version {
+			ctx.JSON(http.StatusPreconditionFailed, ginErrorMessage(etag.ErrVersionMismatch))
+			return
+		}
+		paths, err := patch.Apply(&group)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, ginErrorMessage(err))
+			return
+		}
+		if !validVisibility(group.Visibility) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorMessageInvalidVisibility)
+			return
+		}
+		if !normalizeDiscovery(&group) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorMessageInvalidTags)
+			return
+		}
+		if !validLocation(group.Location) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorMessageInvalidLocation)
+			return
+		}
+		if len(paths) == 0 {
+			ctx.Header(etag.HeaderETag, etag.Format(group.Version))
+			ctx.JSON(http.StatusOK, gin.H{"group": group})
+			return
+		}
+
+		group.Version = version
+		group, err = h.groupPatcher.PatchGroup(ctx, group, paths)
+		if err != nil {
+			ctx.JSON(storeErrorStatus(err), ginErrorMessage(err))
+			return
+		}
 
+		ctx.Header(etag.HeaderETag, etag.Format(group.Version))
 		ctx.JSON(http.StatusOK, gin.H{"group": group})
 	}
 }
 
+// DeleteGroupHandler deletes the group, only if it is at the version in If-Match, when there is one.
+// Its invitations and join requests are deleted by the consumer of the group-deleted event, which retries until they are.
+// DeleteGroupHandler deletes the group along with its picture.
+// The group is already gone when deleting the picture fails, so that is only logged.
 func (h Handler) DeleteGroupHandler() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		groupID := ctx.Param("id")
 
-		err := h.groupDeleter.DeleteGroup(ctx, groupID)
+		version, err := etag.Parse(ctx.GetHeader(etag.HeaderIfMatch))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, ginErrorMessage(err))
+			return
+		}
+		group, err := h.groupReader.ReadGroup(ctx, groupID)
 		if err != nil {
 			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+		err = h.groupDeleter.DeleteGroup(ctx, groupID, version)
+		if err != nil {
+			ctx.JSON(storeErrorStatus(err), ginErrorMessage(err))
+			return
+		}
+		if err := h.pictureDeleter.DeletePicture(ctx, groupID, group.PictureURL); err != nil {
+			_ = ctx.Error(err)
 		}
 
 		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("deleted group %s", groupID)})
 	}
 }
 
+// InviteUserHandler invites a user to become a member of the group, which it becomes only if it accepts
+func (h Handler) InviteUserHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+		userID := ctx.GetString("userID")
+
+		var body struct {
+			UserID string `json:"userId" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			ctx.JSON(http.StatusBadRequest, ginErrorMessage(err))
+			return
+		}
+
+		group, err := h.groupReader.ReadGroup(ctx, groupID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+		if _, ok := findUser(group.Members, body.UserID); ok {
+			ctx.JSON(http.StatusConflict, errorMessageAlreadyMember)
+			return
+		}
+		_, err = h.userReader.ReadUserProfile(ctx, ctx.GetString("token"), body.UserID)
+		if errors.Is(err, userClient.ErrNotFound) {
+			ctx.JSON(http.StatusNotFound, errorMessageNoSuchUser)
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusBadGateway, ginErrorMessage(err))
+			return
+		}
+		pending, err := h.groupInvitationsReader.ReadGroupInvitations(ctx, groupID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, ginErrorMessage(err))
+			return
+		}
+		for _, invitation := range pending {
+			if invitation.InviteeID == body.UserID {
+				ctx.JSON(http.StatusConflict, errorMessageAlreadyInvited)
+				return
+			}
+		}
+
+		inviter, ok := findUser(group.Leaders, userID)
+		if !ok {
+			inviter = types.User{ID: userID}
+		}
+		invitation, err := h.invitationCreator.CreateInvitation(ctx, types.Invitation{
+			GroupID:   groupID,
+			GroupName: group.Name,
+			InviteeID: body.UserID,
+			Inviter:   inviter,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, ginErrorMessage(err))
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, gin.H{"invitation": invitation})
+	}
+}
+
+// ReadGroupInvitationsHandler responds with the invitations to the group that are still pending
+func (h Handler) ReadGroupInvitationsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+
+		invitations, err := h.groupInvitationsReader.ReadGroupInvitations(ctx, groupID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"invitations": invitations})
+	}
+}
+
+// ReadInvitationsHandler responds with the pending invitations of the user
+func (h Handler) ReadInvitationsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID := ctx.GetString("userID")
+
+		invitations, err := h.invitationsReader.ReadInvitations(ctx, userID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"invitations": invitations})
+	}
+}
+
+// AcceptInvitationHandler makes the user a member of the group it was invited to, with its profile at the user service
+func (h Handler) AcceptInvitationHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		invitationID := ctx.Param("invitationID")
+		userID := ctx.GetString("userID")
+
+		invitation, err := h.invitationReader.ReadInvitation(ctx, invitationID, userID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, errorMessageNoSuchInvitation)
+			return
+		}
+
+		user, err := h.userReader.ReadUser(ctx, ctx.GetString("token"), userID)
+		if err != nil {
+			ctx.JSON(http.StatusBadGateway, ginErrorMessage(err))
+			return
+		}
+
+		// the invitation is deleted only once the user is a member, so that accepting it again after a failure is safe
+		group, err := h.memberAdder.AddMember(ctx, invitation.GroupID, user)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+		if err := h.invitationDeleter.DeleteInvitation(ctx, invitationID, userID); err != nil {
+			_ = ctx.Error(err)
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"group": group})
+	}
+}
+
+// RejectInvitationHandler drops an invitation of the user
+func (h Handler) RejectInvitationHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		invitationID := ctx.Param("invitationID")
+		userID := ctx.GetString("userID")
+
+		if err := h.invitationDeleter.DeleteInvitation(ctx, invitationID, userID); err != nil {
+			ctx.JSON(http.StatusNotFound, errorMessageNoSuchInvitation)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("rejected invitation %s", invitationID)})
+	}
+}
+
+// RequestToJoinHandler asks the leaders of a public group to let the user in, with its profile at the user service
+func (h Handler) RequestToJoinHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+		userID := ctx.GetString("userID")
+
+		var body struct {
+			Message string `json:"message"`
+		}
+		// the message is optional, and so is the body
+		if err := ctx.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+			ctx.JSON(http.StatusBadRequest, ginErrorMessage(err))
+			return
+		}
+
+		group, err := h.groupReader.ReadGroup(ctx, groupID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+		if group.Visibility != types.GroupPublic {
+			ctx.JSON(http.StatusForbidden, errorMessageGroupNotPublic)
+			return
+		}
+		if _, ok := findUser(group.Members, userID); ok {
+			ctx.JSON(http.StatusConflict, errorMessageAlreadyMember)
+			return
+		}
+		pending, err := h.joinRequestsReader.ReadJoinRequests(ctx, groupID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, ginErrorMessage(err))
+			return
+		}
+		for _, request := range pending {
+			if request.Requester.ID == userID {
+				ctx.JSON(http.StatusConflict, errorMessageAlreadyRequested)
+				return
+			}
+		}
+
+		user, err := h.userReader.ReadUser(ctx, ctx.GetString("token"), userID)
+		if err != nil {
+			ctx.JSON(http.StatusBadGateway, ginErrorMessage(err))
+			return
+		}
+		request, err := h.joinRequestCreator.CreateJoinRequest(ctx, types.JoinRequest{
+			GroupID:   groupID,
+			Requester: user,
+			Message:   body.Message,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, ginErrorMessage(err))
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, gin.H{"joinRequest": request})
+	}
+}
+
+// ReadJoinRequestsHandler responds with the requests to join the group that are still pending
+func (h Handler) ReadJoinRequestsHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+
+		requests, err := h.joinRequestsReader.ReadJoinRequests(ctx, groupID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"joinRequests": requests})
+	}
+}
+
+// ApproveJoinRequestHandler makes the requester a member of the group
+func (h Handler) ApproveJoinRequestHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+		requestID := ctx.Param("requestID")
+
+		request, err := h.joinRequestReader.ReadJoinRequest(ctx, requestID, groupID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, errorMessageNoSuchJoinRequest)
+			return
+		}
+
+		// the request is deleted only once the requester is a member, so that approving it again after a failure is safe
+		group, err := h.memberAdder.AddMember(ctx, groupID, request.Requester)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+		if err := h.joinRequestDeleter.DeleteJoinRequest(ctx, requestID, groupID); err != nil {
+			_ = ctx.Error(err)
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"group": group})
+	}
+}
+
+// DenyJoinRequestHandler drops a request to join the group
+func (h Handler) DenyJoinRequestHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+		requestID := ctx.Param("requestID")
+
+		if err := h.joinRequestDeleter.DeleteJoinRequest(ctx, requestID, groupID); err != nil {
+			ctx.JSON(http.StatusNotFound, errorMessageNoSuchJoinRequest)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("denied join request %s", requestID)})
+	}
+}
+
+// LeaveGroupHandler takes the user out of the group. The last leader must hand over leadership before leaving.
+func (h Handler) LeaveGroupHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+		userID := ctx.GetString("userID")
+
+		if !h.removeMember(ctx, groupID, userID) {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("left group %s", groupID)})
+	}
+}
+
+// RemoveMemberHandler takes a member out of the group, and out of its leaders, unless it is the last leader
+func (h Handler) RemoveMemberHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+		memberID := ctx.Param("memberID")
+
+		if !h.removeMember(ctx, groupID, memberID) {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("removed member %s", memberID)})
+	}
+}
+
+// removeMember checks why a member can't be removed from the group before removing it, so that clients learn why.
+// The store checks it again, as the group may have changed meanwhile.
+func (h Handler) removeMember(ctx *gin.Context, groupID, memberID string) bool {
+	group, err := h.groupReader.ReadGroup(ctx, groupID)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+		return false
+	}
+	if _, ok := findUser(group.Members, memberID); !ok {
+		ctx.JSON(http.StatusNotFound, errorMessageNotAMember)
+		return false
+	}
+	if _, ok := findUser(group.Leaders, memberID); ok && len(group.Leaders) == 1 {
+		ctx.JSON(http.StatusConflict, errorMessageLastLeader)
+		return false
+	}
+
+	_, err = h.memberRemover.RemoveMember(ctx, groupID, memberID)
+	if err != nil {
+		ctx.JSON(membershipErrorStatus(err), membershipErrorMessage(err))
+		return false
+	}
+	return true
+}
+
+// PromoteMemberHandler makes a member of the group one of its leaders
+func (h Handler) PromoteMemberHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+		memberID := ctx.Param("memberID")
+
+		group, err := h.groupReader.ReadGroup(ctx, groupID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+		member, ok := findUser(group.Members, memberID)
+		if !ok {
+			ctx.JSON(http.StatusNotFound, errorMessageNotAMember)
+			return
+		}
+		if _, ok := findUser(group.Leaders, memberID); ok {
+			ctx.JSON(http.StatusOK, gin.H{"group": group})
+			return
+		}
+
+		group, err = h.leaderPromoter.PromoteLeader(ctx, groupID, member)
+		if err != nil {
+			ctx.JSON(membershipErrorStatus(err), membershipErrorMessage(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"group": group})
+	}
+}
+
+// DemoteLeaderHandler makes a leader of the group a plain member, unless it is the last leader
+func (h Handler) DemoteLeaderHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+		memberID := ctx.Param("memberID")
+
+		group, err := h.groupReader.ReadGroup(ctx, groupID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+		if _, ok := findUser(group.Leaders, memberID); !ok {
+			ctx.JSON(http.StatusNotFound, errorMessageNotALeader)
+			return
+		}
+		if len(group.Leaders) == 1 {
+			ctx.JSON(http.StatusConflict, errorMessageLastLeader)
+			return
+		}
+
+		group, err = h.leaderDemoter.DemoteLeader(ctx, groupID, memberID)
+		if err != nil {
+			ctx.JSON(membershipErrorStatus(err), membershipErrorMessage(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"group": group})
+	}
+}
+
+// TransferLeadershipHandler hands the leadership of the user over to a member of the group, who becomes a leader
+// in its place while the user stays a member
+func (h Handler) TransferLeadershipHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+		userID := ctx.GetString("userID")
+
+		var body struct {
+			UserID string `json:"userId" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			ctx.JSON(http.StatusBadRequest, ginErrorMessage(err))
+			return
+		}
+		if body.UserID == userID {
+			ctx.JSON(http.StatusUnprocessableEntity, errorMessageTransferToSelf)
+			return
+		}
+
+		group, err := h.groupReader.ReadGroup(ctx, groupID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+		member, ok := findUser(group.Members, body.UserID)
+		if !ok {
+			ctx.JSON(http.StatusNotFound, errorMessageNotAMember)
+			return
+		}
+
+		group, err = h.leadershipTransferrer.TransferLeadership(ctx, groupID, userID, member)
+		if err != nil {
+			ctx.JSON(membershipErrorStatus(err), membershipErrorMessage(err))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"group": group})
+	}
+}
+
+// UploadPictureHandler stores the picture of a multipart form and sets its URL as the picture of the group,
+// replacing the previous one
+func (h Handler) UploadPictureHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		groupID := ctx.Param("id")
+
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxPictureRequestSize)
+		fileHeader, err := ctx.FormFile(formKeyPicture)
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				ctx.JSON(http.StatusRequestEntityTooLarge, ginErrorMessage(picture.ErrTooLarge))
+				return
+			}
+			ctx.JSON(http.StatusBadRequest, errorMessageMissingPicture)
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorMessageMissingPicture)
+			return
+		}
+		defer file.Close()
+
+		group, err := h.groupReader.ReadGroup(ctx, groupID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+
+		pic, err := h.pictureSaver.SavePicture(ctx, groupID, file)
+		if err != nil {
+			ctx.JSON(pictureErrorStatus(err), ginErrorMessage(err))
+			return
+		}
+		oldPictureURL := group.PictureURL
+		group.PictureURL = pic.URL
+		// at the version read, so that changes made meanwhile are not overwritten, and only the picture, as clients can't set it
+		group, err = h.groupPatcher.PatchGroup(ctx, group, []string{"pictureUrl"})
+		if err != nil {
+			if oldPictureURL != pic.URL {
+				if err := h.pictureDeleter.DeletePicture(ctx, groupID, pic.URL); err != nil {
+					_ = ctx.Error(err)
+				}
+			}
+			if errors.Is(err, etag.ErrVersionMismatch) {
+				ctx.JSON(http.StatusConflict, errorMessageGroupChanged)
+				return
+			}
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+		if oldPictureURL != pic.URL {
+			if err := h.pictureDeleter.DeletePicture(ctx, groupID, oldPictureURL); err != nil {
+				_ = ctx.Error(err)
+			}
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"group": group, "thumbnailUrl": pic.ThumbnailURL})
+	}
+}
+
+// ReadPictureHandler serves a stored picture or thumbnail. Their names change with their contents, so they are cached for good.
+func (h Handler) ReadPictureHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		rc, contentType, err := h.pictureOpener.OpenPicture(ctx, ctx.Param("name"))
+		if errors.Is(err, blob.ErrNotFound) {
+			ctx.JSON(http.StatusNotFound, ginErrorMessage(err))
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, ginErrorMessage(err))
+			return
+		}
+		defer rc.Close()
+
+		ctx.DataFromReader(http.StatusOK, -1, contentType, rc, map[string]string{
+			"Cache-Control":          "public, max-age=31536000, immutable",
+			"X-Content-Type-Options": "nosniff",
+		})
+	}
+}
+
+// findUser finds the user with the given ID among the members or leaders of a group
+func findUser(users []types.User, id string) (types.User, bool) {
+	for _, u := range users {
+		if u.ID == id {
+			return u, true
+		}
+	}
+	return types.User{}, false
+}
+
+// normalizeDiscovery lowercases the tags and category of a group and drops repeated tags,
+// telling if there are few enough tags, short enough, for the group to be stored
+func normalizeDiscovery(group *types.Group) bool {
+	group.Tags = normalizeTags(group.Tags)
+	group.Category = strings.ToLower(strings.TrimSpace(group.Category))
+	if len(group.Tags) > maxTags {
+		return false
+	}
+	for _, tag := range group.Tags {
+		if len(tag) > maxTagLength {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// validVisibility tells if a group may have the given visibility, none meaning private
+func validVisibility(visibility string) bool {
+	return visibility == "" || visibility == types.GroupPublic || visibility == types.GroupPrivate
+}
+
+// validLocation tells if a group may have the given home base, which is optional
+func validLocation(location *types.Location) bool {
+	if location == nil {
+		return true
+	}
+	return location.Latitude >= -90 && location.Latitude <= 90 &&
+		location.Longitude >= -180 && location.Longitude <= 180
+}
+
+// membershipErrorStatus tells apart missing groups from groups whose members or leaders changed since they were read
+func membershipErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, membership.ErrNoSuchGroup):
+		return http.StatusNotFound
+	case errors.Is(err, membership.ErrConflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func membershipErrorMessage(err error) gin.H {
+	if errors.Is(err, membership.ErrConflict) {
+		return errorMessageGroupChanged
+	}
+	return ginErrorMessage(err)
+}
+
+// storeErrorStatus tells apart groups at versions other than the one the client asked to change from missing ones
+func storeErrorStatus(err error) int {
+	if errors.Is(err, etag.ErrVersionMismatch) {
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusNotFound
+}
+
+// pictureErrorStatus tells the client what is wrong with its picture, if anything
+func pictureErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, picture.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, picture.ErrUnsupportedType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, picture.ErrInvalidImage):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 type LeaderChecker interface {
 	IsLeader(ctx context.Context, userID string, groupID string) (bool, error)
 }
@@ -170,11 +1071,103 @@ type GroupReader interface {
 type GroupUpdater interface {
 	UpdateGroup(ctx context.Context, group types.Group) (types.Group, error)
 }
+type GroupPatcher interface {
+	PatchGroup(ctx context.Context, group types.Group, paths []string) (types.Group, error)
+}
 type GroupDeleter interface {
-	DeleteGroup(ctx context.Context, id string) error
+	DeleteGroup(ctx context.Context, id string, version int64) error
+}
+type InvitationCreator interface {
+	CreateInvitation(ctx context.Context, invitation types.Invitation) (types.Invitation, error)
+}
+type InvitationsReader interface {
+	ReadInvitations(ctx context.Context, inviteeID string) ([]types.Invitation, error)
+}
+type GroupInvitationsReader interface {
+	ReadGroupInvitations(ctx context.Context, groupID string) ([]types.Invitation, error)
+}
+type InvitationReader interface {
+	ReadInvitation(ctx context.Context, id, inviteeID string) (types.Invitation, error)
+}
+type InvitationDeleter interface {
+	DeleteInvitation(ctx context.Context, id, inviteeID string) error
+}
+type MemberAdder interface {
+	AddMember(ctx context.Context, groupID string, user types.User) (types.Group, error)
+}
+type UserReader interface {
+	ReadUser(ctx context.Context, token, id string) (types.User, error)
+	ReadUserProfile(ctx context.Context, token, id string) (types.User, error)
+}
+type JoinRequestCreator interface {
+	CreateJoinRequest(ctx context.Context, request types.JoinRequest) (types.JoinRequest, error)
 }
+type JoinRequestsReader interface {
+	ReadJoinRequests(ctx context.Context, groupID string) ([]types.JoinRequest, error)
+}
+type JoinRequestReader interface {
+	ReadJoinRequest(ctx context.Context, id, groupID string) (types.JoinRequest, error)
+}
+type JoinRequestDeleter interface {
+	DeleteJoinRequest(ctx context.Context, id, groupID string) error
+}
+type MemberRemover interface {
+	RemoveMember(ctx context.Context, groupID, userID string) (types.Group, error)
+}
+type LeaderPromoter interface {
+	PromoteLeader(ctx context.Context, groupID string, member types.User) (types.Group, error)
+}
+type LeaderDemoter interface {
+	DemoteLeader(ctx context.Context, groupID, userID string) (types.Group, error)
+}
+type LeadershipTransferrer interface {
+	TransferLeadership(ctx context.Context, groupID, leaderID string, member types.User) (types.Group, error)
+}
+type GroupSearcher interface {
+	SearchGroups(ctx context.Context, q search.Query) ([]types.GroupSummary, *cursor.Cursor, error)
+}
+type NearbyGroupsReader interface {
+	NearbyGroups(ctx context.Context, latitude, longitude, radiusKm float64, limit int64) ([]types.NearbyGroup, error)
+}
+type PictureSaver interface {
+	SavePicture(ctx context.Context, owner string, r io.Reader) (picture.Picture, error)
+}
+type PictureOpener interface {
+	OpenPicture(ctx context.Context, name string) (io.ReadCloser, string, error)
+}
+type PictureDeleter interface {
+	DeletePicture(ctx context.Context, owner, url string) error
+}
+
+const maxPictureRequestSize = 16 << 20 // bounds the form, the picture itself is bounded by the PictureSaver
+const formKeyPicture = "picture"
+const searchPageSize = 20
+const maxTags = 10
+const maxTagLength = 32
+const nearbyRadiusKm = 10
+
+var patchableGroupFields = []string{"name", "description", "visibility", "tags", "category", "location"}
 
 var errorMessageUnauthorized = gin.H{"error": "unauthorized"}
+var errorMessageMissingPicture = gin.H{"error": "missing picture"}
+var errorMessageAlreadyMember = gin.H{"error": "user is a member of the group already"}
+var errorMessageAlreadyInvited = gin.H{"error": "user is invited to the group already"}
+var errorMessageNoSuchUser = gin.H{"error": "no such user"}
+var errorMessageNoSuchInvitation = gin.H{"error": "no such invitation"}
+var errorMessageInvalidVisibility = gin.H{"error": "visibility must be public or private"}
+var errorMessageGroupNotPublic = gin.H{"error": "group is not public, join it by invitation"}
+var errorMessageAlreadyRequested = gin.H{"error": "user asked to join the group already"}
+var errorMessageNoSuchJoinRequest = gin.H{"error": "no such join request"}
+var errorMessageNotAMember = gin.H{"error": "user is not a member of the group"}
+var errorMessageNotALeader = gin.H{"error": "user is not a leader of the group"}
+var errorMessageLastLeader = gin.H{"error": "a group needs a leader, transfer the leadership first"}
+var errorMessageTransferToSelf = gin.H{"error": "leadership must be transferred to another member"}
+var errorMessageGroupChanged = gin.H{"error": "group changed meanwhile, try again"}
+var errorMessageInvalidTags = gin.H{"error": "groups may have up to 10 tags of up to 32 characters"}
+var errorMessageBadSearchQuery = gin.H{"error": "bad search query"}
+var errorMessageBadCursor = gin.H{"error": "bad cursor"}
+var errorMessageInvalidLocation = gin.H{"error": "latitude must be within [-90, 90] and longitude within [-180, 180]"}
+var errorMessageBadNearbyQuery = gin.H{"error": "lat and lng are required, radiusKm may be up to 500"}
 
 func ginErrorMessage(err error) gin.H {
 	return gin.H{"error": err.Error()}
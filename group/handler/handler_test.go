@@ -6,10 +6,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/gabrielseibel1/gaef/blob"
+	"github.com/gabrielseibel1/gaef/blob/picture"
+	userClient "github.com/gabrielseibel1/gaef/client/user"
 	"github.com/gabrielseibel1/gaef/group/handler"
+	"github.com/gabrielseibel1/gaef/group/membership"
+	"github.com/gabrielseibel1/gaef/group/search"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/cursor"
+	"github.com/gabrielseibel1/gaef/types/etag"
 	"github.com/gin-gonic/gin"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -24,6 +32,27 @@ type mocks struct {
 	groupReader               handler.GroupReader
 	groupUpdater              handler.GroupUpdater
 	groupDeleter              handler.GroupDeleter
+	pictureSaver              handler.PictureSaver
+	pictureOpener             handler.PictureOpener
+	pictureDeleter            handler.PictureDeleter
+	invitationCreator         handler.InvitationCreator
+	invitationsReader         handler.InvitationsReader
+	groupInvitationsReader    handler.GroupInvitationsReader
+	invitationReader          handler.InvitationReader
+	invitationDeleter         handler.InvitationDeleter
+	memberAdder               handler.MemberAdder
+	userReader                handler.UserReader
+	joinRequestCreator        handler.JoinRequestCreator
+	joinRequestsReader        handler.JoinRequestsReader
+	joinRequestReader         handler.JoinRequestReader
+	joinRequestDeleter        handler.JoinRequestDeleter
+	memberRemover             handler.MemberRemover
+	leaderPromoter            handler.LeaderPromoter
+	leaderDemoter             handler.LeaderDemoter
+	leadershipTransferrer     handler.LeadershipTransferrer
+	groupSearcher             handler.GroupSearcher
+	nearbyGroupsReader        handler.NearbyGroupsReader
+	groupPatcher              handler.GroupPatcher
 }
 type fields struct {
 	mocks
@@ -40,15 +69,46 @@ type test struct {
 
 func testRequest(t *testing.T, tt test, cut func(handler.Handler) gin.HandlerFunc) {
 	// setup test
-	h := handler.New(
-		tt.fields.leaderChecker,
-		tt.fields.groupCreator,
-		tt.fields.participatingGroupsReader,
-		tt.fields.leadingGroupsReader,
-		tt.fields.groupReader,
-		tt.fields.groupUpdater,
-		tt.fields.groupDeleter,
-	)
+	h := handler.New(handler.Dependencies{
+		Groups: handler.Groups{
+			LeaderChecker:       tt.fields.leaderChecker,
+			Creator:             tt.fields.groupCreator,
+			ParticipatingReader: tt.fields.participatingGroupsReader,
+			LeadingReader:       tt.fields.leadingGroupsReader,
+			Reader:              tt.fields.groupReader,
+			Updater:             tt.fields.groupUpdater,
+			Patcher:             tt.fields.groupPatcher,
+			Deleter:             tt.fields.groupDeleter,
+			Searcher:            tt.fields.groupSearcher,
+			NearbyReader:        tt.fields.nearbyGroupsReader,
+		},
+		Pictures: handler.Pictures{
+			Saver:   tt.fields.pictureSaver,
+			Opener:  tt.fields.pictureOpener,
+			Deleter: tt.fields.pictureDeleter,
+		},
+		Invitations: handler.Invitations{
+			Creator:       tt.fields.invitationCreator,
+			InviteeReader: tt.fields.invitationsReader,
+			GroupReader:   tt.fields.groupInvitationsReader,
+			Reader:        tt.fields.invitationReader,
+			Deleter:       tt.fields.invitationDeleter,
+		},
+		JoinRequests: handler.JoinRequests{
+			Creator:     tt.fields.joinRequestCreator,
+			GroupReader: tt.fields.joinRequestsReader,
+			Reader:      tt.fields.joinRequestReader,
+			Deleter:     tt.fields.joinRequestDeleter,
+		},
+		Membership: handler.Membership{
+			UserReader:            tt.fields.userReader,
+			Adder:                 tt.fields.memberAdder,
+			Remover:               tt.fields.memberRemover,
+			LeaderPromoter:        tt.fields.leaderPromoter,
+			LeaderDemoter:         tt.fields.leaderDemoter,
+			LeadershipTransferrer: tt.fields.leadershipTransferrer,
+		},
+	})
 	responseRecorder := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(responseRecorder)
 	for k, v := range tt.fields.ctxParams {
@@ -72,6 +132,11 @@ func testRequest(t *testing.T, tt test, cut func(handler.Handler) gin.HandlerFun
 }
 
 func TestHandler_CreateGroupHandler(t *testing.T) {
+	creator := types.User{ID: "dummy-creator-id", Name: "dummy-creator-name", Email: "creator@dummy.io"}
+	creatorValues := map[string]any{"userID": creator.ID, "token": "dummy-token"}
+	createdGroup := dummyGroup1
+	createdGroup.Members = []types.User{creator}
+	createdGroup.Leaders = []types.User{creator}
 	tests := []test{
 		{
 			name: "create group ok",
@@ -80,8 +145,10 @@ func TestHandler_CreateGroupHandler(t *testing.T) {
 					groupCreator: &mockGroupCreator{
 						retGroup: dummyGroup2,
 					},
+					userReader: &mockUserReader{user: creator},
 				},
-				request: &http.Request{Body: io.NopCloser(bytes.NewBuffer(dummyGroup1JSON))},
+				ctxValues: creatorValues,
+				request:   &http.Request{Body: io.NopCloser(bytes.NewBuffer(dummyGroup1JSON))},
 			},
 			responseOK: func(recorder *httptest.ResponseRecorder) error {
 				if got, want := recorder.Result().StatusCode, http.StatusCreated; got != want {
@@ -101,9 +168,63 @@ func TestHandler_CreateGroupHandler(t *testing.T) {
 				if got, want := groupCreator.ctx, ctx; got != want {
 					return fmt.Errorf("groupCreator.ctx: got %v, want %v", got, want)
 				}
-				if got, want := groupCreator.rcvGroup, dummyGroup1; !reflect.DeepEqual(got, want) {
+				if got, want := groupCreator.rcvGroup, createdGroup; !reflect.DeepEqual(got, want) {
 					return fmt.Errorf("groupCreator.rcvGroup: got %v, want %v", got, want)
 				}
+				userReader := m.userReader.(*mockUserReader)
+				if got, want := userReader.id, creator.ID; got != want {
+					return fmt.Errorf("userReader.id: got %v, want %v", got, want)
+				}
+				if got, want := userReader.token, "dummy-token"; got != want {
+					return fmt.Errorf("userReader.token: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "create group drops posted members and leaders",
+			fields: fields{
+				mocks: mocks{
+					groupCreator: &mockGroupCreator{
+						retGroup: dummyGroup2,
+					},
+					userReader: &mockUserReader{user: creator},
+				},
+				ctxValues: creatorValues,
+				request: &http.Request{Body: io.NopCloser(bytes.NewBufferString(
+					`{"name": "dummy-name", "members": [{"id": "dummy-victim-id"}], "leaders": [{"id": "dummy-victim-id"}]}`,
+				))},
+			},
+			responseOK: statusOK(http.StatusCreated),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				groupCreator := m.groupCreator.(*mockGroupCreator)
+				if got, want := groupCreator.rcvGroup.Members, []types.User{creator}; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("groupCreator.rcvGroup.Members: got %v, want %v", got, want)
+				}
+				if got, want := groupCreator.rcvGroup.Leaders, []types.User{creator}; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("groupCreator.rcvGroup.Leaders: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "create group user reader error",
+			fields: fields{
+				mocks: mocks{
+					groupCreator: &mockGroupCreator{
+						retGroup: dummyGroup2,
+					},
+					userReader: &mockUserReader{err: dummyError},
+				},
+				ctxValues: creatorValues,
+				request:   &http.Request{Body: io.NopCloser(bytes.NewBuffer(dummyGroup1JSON))},
+			},
+			responseOK: statusOK(http.StatusBadGateway),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				groupCreator := m.groupCreator.(*mockGroupCreator)
+				if got, want := groupCreator.ctx, nilCtx; got != want {
+					return fmt.Errorf("groupCreator.ctx: got %v, want %v", got, want)
+				}
 				return nil
 			},
 		},
@@ -140,6 +261,87 @@ func TestHandler_CreateGroupHandler(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name: "create group invalid visibility",
+			fields: fields{
+				mocks: mocks{
+					groupCreator: &mockGroupCreator{
+						retGroup: dummyGroup2,
+					},
+				},
+				request: &http.Request{Body: io.NopCloser(bytes.NewBufferString(`{"name": "dummy-name", "visibility": "secret"}`))},
+			},
+			responseOK: statusOK(http.StatusUnprocessableEntity),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				groupCreator := m.groupCreator.(*mockGroupCreator)
+				if got, want := groupCreator.ctx, nilCtx; got != want {
+					return fmt.Errorf("groupCreator.ctx: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "create group normalizes tags",
+			fields: fields{
+				mocks: mocks{
+					groupCreator: &mockGroupCreator{
+						retGroup: dummyGroup2,
+					},
+					userReader: &mockUserReader{user: creator},
+				},
+				ctxValues: creatorValues,
+				request:   &http.Request{Body: io.NopCloser(bytes.NewBufferString(`{"name": "dummy-name", "tags": [" Hiking", "hiking", "", "Outdoors"], "category": "Sports "}`))},
+			},
+			responseOK: statusOK(http.StatusCreated),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				groupCreator := m.groupCreator.(*mockGroupCreator)
+				if got, want := groupCreator.rcvGroup.Tags, []string{"hiking", "outdoors"}; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("groupCreator.rcvGroup.Tags: got %v, want %v", got, want)
+				}
+				if got, want := groupCreator.rcvGroup.Category, "sports"; got != want {
+					return fmt.Errorf("groupCreator.rcvGroup.Category: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "create group too many tags",
+			fields: fields{
+				mocks: mocks{
+					groupCreator: &mockGroupCreator{
+						retGroup: dummyGroup2,
+					},
+				},
+				request: &http.Request{Body: io.NopCloser(bytes.NewBufferString(`{"name": "dummy-name", "tags": ["a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"]}`))},
+			},
+			responseOK: statusOK(http.StatusUnprocessableEntity),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				groupCreator := m.groupCreator.(*mockGroupCreator)
+				if got, want := groupCreator.ctx, nilCtx; got != want {
+					return fmt.Errorf("groupCreator.ctx: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "create group location out of range",
+			fields: fields{
+				mocks: mocks{
+					groupCreator: &mockGroupCreator{
+						retGroup: dummyGroup2,
+					},
+				},
+				request: &http.Request{Body: io.NopCloser(bytes.NewBufferString(`{"name": "dummy-name", "location": {"latitude": 91, "longitude": 0}}`))},
+			},
+			responseOK: statusOK(http.StatusUnprocessableEntity),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				groupCreator := m.groupCreator.(*mockGroupCreator)
+				if got, want := groupCreator.ctx, nilCtx; got != want {
+					return fmt.Errorf("groupCreator.ctx: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
 		{
 			name: "create group creator error",
 			fields: fields{
@@ -148,8 +350,10 @@ func TestHandler_CreateGroupHandler(t *testing.T) {
 						retGroup: dummyGroup2,
 						err:      dummyError,
 					},
+					userReader: &mockUserReader{user: creator},
 				},
-				request: &http.Request{Body: io.NopCloser(bytes.NewBuffer(dummyGroup1JSON))},
+				ctxValues: creatorValues,
+				request:   &http.Request{Body: io.NopCloser(bytes.NewBuffer(dummyGroup1JSON))},
 			},
 			responseOK: func(recorder *httptest.ResponseRecorder) error {
 				if got, want := recorder.Result().StatusCode, http.StatusUnprocessableEntity; got != want {
@@ -169,7 +373,7 @@ func TestHandler_CreateGroupHandler(t *testing.T) {
 				if got, want := groupCreator.ctx, ctx; got != want {
 					return fmt.Errorf("groupCreator.ctx: got %v, want %v", got, want)
 				}
-				if got, want := groupCreator.rcvGroup, dummyGroup1; !reflect.DeepEqual(got, want) {
+				if got, want := groupCreator.rcvGroup, createdGroup; !reflect.DeepEqual(got, want) {
 					return fmt.Errorf("groupCreator.rcvGroup: got %v, want %v", got, want)
 				}
 				return nil
@@ -191,9 +395,12 @@ func TestHandler_DeleteGroupHandler(t *testing.T) {
 			name: "delete group ok",
 			fields: fields{
 				mocks: mocks{
-					groupDeleter: &mockGroupDeleter{},
+					groupReader:    &mockGroupReader{group: dummyGroup1},
+					groupDeleter:   &mockGroupDeleter{},
+					pictureDeleter: &mockPictures{},
 				},
 				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   &http.Request{},
 			},
 			responseOK: func(recorder *httptest.ResponseRecorder) error {
 				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
@@ -216,6 +423,54 @@ func TestHandler_DeleteGroupHandler(t *testing.T) {
 				if got, want := groupDeleter.groupID, dummyGroup1.ID; got != want {
 					return fmt.Errorf("groupDeleter.groupID: got %v, want %v", got, want)
 				}
+				pictures := m.pictureDeleter.(*mockPictures)
+				if got, want := pictures.deletedOwner, dummyGroup1.ID; got != want {
+					return fmt.Errorf("pictureDeleter.deletedOwner: got %v, want %v", got, want)
+				}
+				if got, want := pictures.deletedURL, dummyGroup1.PictureURL; got != want {
+					return fmt.Errorf("pictureDeleter.deletedURL: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "delete group if match",
+			fields: fields{
+				mocks: mocks{
+					groupReader:    &mockGroupReader{group: dummyGroup1},
+					groupDeleter:   &mockGroupDeleter{},
+					pictureDeleter: &mockPictures{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   &http.Request{Header: http.Header{"If-Match": {`"3"`}}},
+			},
+			responseOK: statusOK(http.StatusOK),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.groupDeleter.(*mockGroupDeleter).version, int64(3); got != want {
+					return fmt.Errorf("groupDeleter.version: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "delete group version mismatch",
+			fields: fields{
+				mocks: mocks{
+					groupReader:    &mockGroupReader{group: dummyGroup1},
+					groupDeleter:   &mockGroupDeleter{err: etag.ErrVersionMismatch},
+					pictureDeleter: &mockPictures{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   &http.Request{Header: http.Header{"If-Match": {`"3"`}}},
+			},
+			responseOK: statusOK(http.StatusPreconditionFailed),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.groupDeleter.(*mockGroupDeleter).version, int64(3); got != want {
+					return fmt.Errorf("groupDeleter.version: got %v, want %v", got, want)
+				}
+				if got := m.pictureDeleter.(*mockPictures).deletedURL; got != "" {
+					return fmt.Errorf("pictureDeleter.deletedURL: got %v, want none", got)
+				}
 				return nil
 			},
 		},
@@ -223,9 +478,12 @@ func TestHandler_DeleteGroupHandler(t *testing.T) {
 			name: "delete group deleter error",
 			fields: fields{
 				mocks: mocks{
-					groupDeleter: &mockGroupDeleter{err: dummyError},
+					groupReader:    &mockGroupReader{group: dummyGroup1},
+					groupDeleter:   &mockGroupDeleter{err: dummyError},
+					pictureDeleter: &mockPictures{},
 				},
 				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   &http.Request{},
 			},
 			responseOK: func(recorder *httptest.ResponseRecorder) error {
 				if got, want := recorder.Result().StatusCode, http.StatusNotFound; got != want {
@@ -251,6 +509,25 @@ func TestHandler_DeleteGroupHandler(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name: "delete group reader error",
+			fields: fields{
+				mocks: mocks{
+					groupReader:    &mockGroupReader{err: dummyError},
+					groupDeleter:   &mockGroupDeleter{},
+					pictureDeleter: &mockPictures{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   &http.Request{},
+			},
+			responseOK: statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got := m.groupDeleter.(*mockGroupDeleter).groupID; got != "" {
+					return fmt.Errorf("groupDeleter.groupID: got %v, want none", got)
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -414,6 +691,25 @@ func TestHandler_ReadGroupHandler(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name: "read group etag",
+			fields: fields{
+				mocks: mocks{
+					groupReader: &mockGroupReader{group: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup2.ID},
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				if got, want := recorder.Result().Header.Get("ETag"), `"2"`; got != want {
+					return fmt.Errorf("ETag: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
 		{
 			name: "read group reader error",
 			fields: fields{
@@ -630,7 +926,9 @@ func TestHandler_UpdateGroupHandler(t *testing.T) {
 		{
 			name: "update group ok",
 			fields: fields{
-				mocks:     mocks{groupUpdater: &mockGroupUpdater{retGroup: dummyGroup2}},
+				mocks: mocks{
+					groupUpdater: &mockGroupUpdater{retGroup: dummyGroup2},
+				},
 				ctxParams: map[string]string{"id": dummyGroup1.ID},
 				request:   &http.Request{Body: io.NopCloser(bytes.NewBuffer(dummyGroup1JSON))},
 			},
@@ -719,6 +1017,65 @@ func TestHandler_UpdateGroupHandler(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name: "update group if match",
+			fields: fields{
+				mocks: mocks{
+					groupUpdater: &mockGroupUpdater{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request: &http.Request{
+					Header: http.Header{"If-Match": {`"1"`}},
+					Body:   io.NopCloser(bytes.NewBuffer(dummyGroup1JSON)),
+				},
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				if got, want := recorder.Result().Header.Get("ETag"), `"2"`; got != want {
+					return fmt.Errorf("ETag: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.groupUpdater.(*mockGroupUpdater).rcvGroup.Version, int64(1); got != want {
+					return fmt.Errorf("groupUpdater.rcvGroup.Version: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "update group malformed if match",
+			fields: fields{
+				mocks:     mocks{groupUpdater: &mockGroupUpdater{retGroup: dummyGroup2}},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request: &http.Request{
+					Header: http.Header{"If-Match": {`W/"1"`}},
+					Body:   io.NopCloser(bytes.NewBuffer(dummyGroup1JSON)),
+				},
+			},
+			responseOK: statusOK(http.StatusBadRequest),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.groupUpdater.(*mockGroupUpdater).ctx, nilCtx; got != want {
+					return fmt.Errorf("groupUpdater.ctx: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "update group version mismatch",
+			fields: fields{
+				mocks:     mocks{groupUpdater: &mockGroupUpdater{err: etag.ErrVersionMismatch}},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request: &http.Request{
+					Header: http.Header{"If-Match": {`"1"`}},
+					Body:   io.NopCloser(bytes.NewBuffer(dummyGroup1JSON)),
+				},
+			},
+			responseOK:    statusOK(http.StatusPreconditionFailed),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
 		{
 			name: "update group updater error",
 			fields: fields{
@@ -760,87 +1117,2152 @@ func TestHandler_UpdateGroupHandler(t *testing.T) {
 	}
 }
 
-// mocks
-
-type mockGroupCreator struct {
+type mockGroupPatcher struct {
 	ctx      context.Context
 	rcvGroup types.Group
+	rcvPaths []string
 	retGroup types.Group
 	err      error
 }
 
-func (m *mockGroupCreator) CreateGroup(ctx context.Context, group types.Group) (types.Group, error) {
+func (m *mockGroupPatcher) PatchGroup(ctx context.Context, group types.Group, paths []string) (types.Group, error) {
 	m.ctx = ctx
 	m.rcvGroup = group
+	m.rcvPaths = paths
 	return m.retGroup, m.err
 }
 
-type mockGroupDeleter struct {
-	ctx     context.Context
-	groupID string
-	err     error
-}
-
-func (m *mockGroupDeleter) DeleteGroup(ctx context.Context, id string) error {
-	m.ctx = ctx
-	m.groupID = id
-	return m.err
-}
-
-type mockLeaderChecker struct {
-	ctx      context.Context
-	userID   string
-	groupID  string
-	isLeader bool
-	err      error
-}
-
-func (m *mockLeaderChecker) IsLeader(ctx context.Context, userID string, groupID string) (bool, error) {
-	m.ctx = ctx
-	m.userID = userID
-	m.groupID = groupID
-	return m.isLeader, m.err
-}
-
-type mockGroupReader struct {
-	ctx     context.Context
-	groupID string
-	group   types.Group
-	err     error
-}
-
-func (m *mockGroupReader) ReadGroup(ctx context.Context, id string) (types.Group, error) {
-	m.ctx = ctx
-	m.groupID = id
-	return m.group, m.err
-}
-
-type mockLeadingGroupsReader struct {
-	ctx    context.Context
-	userID string
-	groups []types.Group
-	err    error
-}
-
-func (m *mockLeadingGroupsReader) ReadLeadingGroups(ctx context.Context, userID string) ([]types.Group, error) {
-	m.ctx = ctx
-	m.userID = userID
-	return m.groups, m.err
+func patchRequest(patch string) *http.Request {
+	return &http.Request{Body: io.NopCloser(bytes.NewBufferString(patch))}
 }
 
-type mockParticipatingGroupsReader struct {
-	ctx    context.Context
-	userID string
-	groups []types.Group
-	err    error
-}
+func TestHandler_PatchGroupHandler(t *testing.T) {
+	patchedGroup := dummyGroup1
+	patchedGroup.Name = "dummy-new-name"
+	patchedGroup.Tags = []string{"dummy-tag"}
+	patchedGroup.Location = &dummyLocation
+	patch := `{"name": "dummy-new-name", "tags": ["Dummy-Tag", "dummy-tag"], "location": {"name": "dummy-location-name", "latitude": -30.03, "longitude": -51.22}}`
 
-func (m *mockParticipatingGroupsReader) ReadParticipatingGroups(ctx context.Context, userID string) ([]types.Group, error) {
-	m.ctx = ctx
-	m.userID = userID
+	notPatched := func(m mocks, ctx *gin.Context) error {
+		if got, want := m.groupPatcher.(*mockGroupPatcher).ctx, nilCtx; got != want {
+			return fmt.Errorf("groupPatcher.ctx: got %v, want %v", got, want)
+		}
+		return nil
+	}
+	tests := []test{
+		{
+			name: "patch group ok",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup1},
+					groupPatcher: &mockGroupPatcher{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   patchRequest(patch),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct{ Group types.Group }
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.Group, dummyGroup2; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("resp.Group: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.groupReader.(*mockGroupReader).groupID, dummyGroup1.ID; got != want {
+					return fmt.Errorf("groupReader.groupID: got %v, want %v", got, want)
+				}
+				groupPatcher := m.groupPatcher.(*mockGroupPatcher)
+				if got, want := groupPatcher.ctx, ctx; got != want {
+					return fmt.Errorf("groupPatcher.ctx: got %v, want %v", got, want)
+				}
+				if got, want := groupPatcher.rcvGroup, patchedGroup; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("groupPatcher.rcvGroup: got %v, want %v", got, want)
+				}
+				if got, want := groupPatcher.rcvPaths, []string{"location", "name", "tags"}; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("groupPatcher.rcvPaths: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "patch group empty patch",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup1},
+					groupPatcher: &mockGroupPatcher{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   patchRequest(`{}`),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct{ Group types.Group }
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.Group, dummyGroup1; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("resp.Group: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: notPatched,
+		},
+		{
+			name: "patch group not an object",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup1},
+					groupPatcher: &mockGroupPatcher{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   patchRequest(`["dummy"]`),
+			},
+			responseOK:    statusOK(http.StatusBadRequest),
+			sideEffectsOK: notPatched,
+		},
+		{
+			name: "patch group members",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup1},
+					groupPatcher: &mockGroupPatcher{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   patchRequest(`{"name": "dummy-new-name", "members": []}`),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusUnprocessableEntity; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct{ Error string }
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.Error, "field can't be patched: members"; got != want {
+					return fmt.Errorf("resp.Error: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.groupReader.(*mockGroupReader).ctx, nilCtx; got != want {
+					return fmt.Errorf("groupReader.ctx: got %v, want %v", got, want)
+				}
+				return notPatched(m, ctx)
+			},
+		},
+		{
+			name: "patch group picture url",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup1},
+					groupPatcher: &mockGroupPatcher{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   patchRequest(`{"pictureUrl": "https://elsewhere.io/dummy.png"}`),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusUnprocessableEntity; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: notPatched,
+		},
+		{
+			name: "patch group wrong type",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup1},
+					groupPatcher: &mockGroupPatcher{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   patchRequest(`{"name": 1}`),
+			},
+			responseOK:    statusOK(http.StatusBadRequest),
+			sideEffectsOK: notPatched,
+		},
+		{
+			name: "patch group invalid visibility",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup1},
+					groupPatcher: &mockGroupPatcher{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   patchRequest(`{"visibility": "dummy-visibility"}`),
+			},
+			responseOK:    statusOK(http.StatusUnprocessableEntity),
+			sideEffectsOK: notPatched,
+		},
+		{
+			name: "patch group location out of range",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup1},
+					groupPatcher: &mockGroupPatcher{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   patchRequest(`{"location": {"latitude": 91}}`),
+			},
+			responseOK:    statusOK(http.StatusUnprocessableEntity),
+			sideEffectsOK: notPatched,
+		},
+		{
+			name: "patch group if match",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup2},
+					groupPatcher: &mockGroupPatcher{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup2.ID},
+				request: &http.Request{
+					Header: http.Header{"If-Match": {`"2"`}},
+					Body:   io.NopCloser(bytes.NewBufferString(patch)),
+				},
+			},
+			responseOK: statusOK(http.StatusOK),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.groupPatcher.(*mockGroupPatcher).rcvGroup.Version, int64(2); got != want {
+					return fmt.Errorf("groupPatcher.rcvGroup.Version: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "patch group if match stale",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup2},
+					groupPatcher: &mockGroupPatcher{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup2.ID},
+				request: &http.Request{
+					Header: http.Header{"If-Match": {`"1"`}},
+					Body:   io.NopCloser(bytes.NewBufferString(`{}`)),
+				},
+			},
+			responseOK:    statusOK(http.StatusPreconditionFailed),
+			sideEffectsOK: notPatched,
+		},
+		{
+			name: "patch group version mismatch",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup2},
+					groupPatcher: &mockGroupPatcher{err: etag.ErrVersionMismatch},
+				},
+				ctxParams: map[string]string{"id": dummyGroup2.ID},
+				request: &http.Request{
+					Header: http.Header{"If-Match": {`"2"`}},
+					Body:   io.NopCloser(bytes.NewBufferString(patch)),
+				},
+			},
+			responseOK:    statusOK(http.StatusPreconditionFailed),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+		{
+			name: "patch group reader error",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{err: dummyError},
+					groupPatcher: &mockGroupPatcher{retGroup: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   patchRequest(patch),
+			},
+			responseOK:    statusOK(http.StatusNotFound),
+			sideEffectsOK: notPatched,
+		},
+		{
+			name: "patch group patcher error",
+			fields: fields{
+				mocks: mocks{
+					groupReader:  &mockGroupReader{group: dummyGroup1},
+					groupPatcher: &mockGroupPatcher{err: dummyError},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   patchRequest(patch),
+			},
+			responseOK: statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.PatchGroupHandler()
+			})
+		})
+	}
+}
+
+// mocks
+
+type mockGroupCreator struct {
+	ctx      context.Context
+	rcvGroup types.Group
+	retGroup types.Group
+	err      error
+}
+
+func (m *mockGroupCreator) CreateGroup(ctx context.Context, group types.Group) (types.Group, error) {
+	m.ctx = ctx
+	m.rcvGroup = group
+	return m.retGroup, m.err
+}
+
+type mockGroupDeleter struct {
+	ctx     context.Context
+	groupID string
+	version int64
+	err     error
+}
+
+func (m *mockGroupDeleter) DeleteGroup(ctx context.Context, id string, version int64) error {
+	m.ctx = ctx
+	m.groupID = id
+	m.version = version
+	return m.err
+}
+
+type mockLeaderChecker struct {
+	ctx      context.Context
+	userID   string
+	groupID  string
+	isLeader bool
+	err      error
+}
+
+func (m *mockLeaderChecker) IsLeader(ctx context.Context, userID string, groupID string) (bool, error) {
+	m.ctx = ctx
+	m.userID = userID
+	m.groupID = groupID
+	return m.isLeader, m.err
+}
+
+type mockGroupReader struct {
+	ctx     context.Context
+	groupID string
+	group   types.Group
+	err     error
+}
+
+func (m *mockGroupReader) ReadGroup(ctx context.Context, id string) (types.Group, error) {
+	m.ctx = ctx
+	m.groupID = id
+	return m.group, m.err
+}
+
+type mockLeadingGroupsReader struct {
+	ctx    context.Context
+	userID string
+	groups []types.Group
+	err    error
+}
+
+func (m *mockLeadingGroupsReader) ReadLeadingGroups(ctx context.Context, userID string) ([]types.Group, error) {
+	m.ctx = ctx
+	m.userID = userID
+	return m.groups, m.err
+}
+
+type mockParticipatingGroupsReader struct {
+	ctx    context.Context
+	userID string
+	groups []types.Group
+	err    error
+}
+
+func (m *mockParticipatingGroupsReader) ReadParticipatingGroups(ctx context.Context, userID string) ([]types.Group, error) {
+	m.ctx = ctx
+	m.userID = userID
+	return m.groups, m.err
+}
+
+type mockPictures struct {
+	ctx          context.Context
+	owner        string
+	data         []byte
+	name         string
+	deletedOwner string
+	deletedURL   string
+	picture      picture.Picture
+	contentType  string
+	err          error
+}
+
+func (m *mockPictures) SavePicture(ctx context.Context, owner string, r io.Reader) (picture.Picture, error) {
+	m.ctx = ctx
+	m.owner = owner
+	m.data, _ = io.ReadAll(r)
+	return m.picture, m.err
+}
+
+func (m *mockPictures) OpenPicture(ctx context.Context, name string) (io.ReadCloser, string, error) {
+	m.ctx = ctx
+	m.name = name
+	if m.err != nil {
+		return nil, "", m.err
+	}
+	return io.NopCloser(bytes.NewReader(m.data)), m.contentType, nil
+}
+
+func (m *mockPictures) DeletePicture(ctx context.Context, owner, url string) error {
+	m.deletedOwner = owner
+	m.deletedURL = url
+	return nil
+}
+
+func pictureRequest(field string, data []byte) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile(field, "dummy.png")
+	_, _ = part.Write(data)
+	_ = w.Close()
+	req := httptest.NewRequest(http.MethodPut, "/dummy-id-1/picture", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestHandler_UploadPictureHandler(t *testing.T) {
+	updatedGroup := dummyGroup1
+	updatedGroup.PictureURL = dummyPicture.URL
+
+	tests := []test{
+		{
+			name: "upload picture ok",
+			fields: fields{
+				mocks: mocks{
+					groupReader:    &mockGroupReader{group: dummyGroup1},
+					groupPatcher:   &mockGroupPatcher{retGroup: updatedGroup},
+					pictureSaver:   &mockPictures{picture: dummyPicture},
+					pictureDeleter: &mockPictures{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   pictureRequest("picture", []byte("dummy-picture")),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct {
+					Group        types.Group
+					ThumbnailURL string
+				}
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.Group, updatedGroup; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("resp.Group: got %v, want %v", got, want)
+				}
+				if got, want := resp.ThumbnailURL, dummyPicture.ThumbnailURL; got != want {
+					return fmt.Errorf("resp.ThumbnailURL: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				pictureSaver := m.pictureSaver.(*mockPictures)
+				if got, want := pictureSaver.owner, dummyGroup1.ID; got != want {
+					return fmt.Errorf("pictureSaver.owner: got %v, want %v", got, want)
+				}
+				if got, want := string(pictureSaver.data), "dummy-picture"; got != want {
+					return fmt.Errorf("pictureSaver.data: got %v, want %v", got, want)
+				}
+				groupPatcher := m.groupPatcher.(*mockGroupPatcher)
+				if got, want := groupPatcher.rcvGroup, updatedGroup; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("groupPatcher.rcvGroup: got %v, want %v", got, want)
+				}
+				if got, want := groupPatcher.rcvPaths, []string{"pictureUrl"}; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("groupPatcher.rcvPaths: got %v, want %v", got, want)
+				}
+				pictureDeleter := m.pictureDeleter.(*mockPictures)
+				if got, want := pictureDeleter.deletedOwner, dummyGroup1.ID; got != want {
+					return fmt.Errorf("pictureDeleter.deletedOwner: got %v, want %v", got, want)
+				}
+				if got, want := pictureDeleter.deletedURL, dummyGroup1.PictureURL; got != want {
+					return fmt.Errorf("pictureDeleter.deletedURL: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "upload picture missing file",
+			fields: fields{
+				mocks: mocks{
+					groupReader:    &mockGroupReader{group: dummyGroup1},
+					groupPatcher:   &mockGroupPatcher{retGroup: updatedGroup},
+					pictureSaver:   &mockPictures{picture: dummyPicture},
+					pictureDeleter: &mockPictures{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   pictureRequest("dummy", []byte("dummy-picture")),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusBadRequest; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.pictureSaver.(*mockPictures).ctx, nilCtx; got != want {
+					return fmt.Errorf("pictureSaver.ctx: got %v, want %v", got, want)
+				}
+				if got, want := m.groupPatcher.(*mockGroupPatcher).ctx, nilCtx; got != want {
+					return fmt.Errorf("groupPatcher.ctx: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "upload picture unsupported type",
+			fields: fields{
+				mocks: mocks{
+					groupReader:    &mockGroupReader{group: dummyGroup1},
+					groupPatcher:   &mockGroupPatcher{retGroup: updatedGroup},
+					pictureSaver:   &mockPictures{err: picture.ErrUnsupportedType},
+					pictureDeleter: &mockPictures{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   pictureRequest("picture", []byte("dummy-picture")),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusUnsupportedMediaType; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.groupPatcher.(*mockGroupPatcher).ctx, nilCtx; got != want {
+					return fmt.Errorf("groupPatcher.ctx: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "upload picture no such group",
+			fields: fields{
+				mocks: mocks{
+					groupReader:    &mockGroupReader{err: dummyError},
+					groupPatcher:   &mockGroupPatcher{retGroup: updatedGroup},
+					pictureSaver:   &mockPictures{picture: dummyPicture},
+					pictureDeleter: &mockPictures{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   pictureRequest("picture", []byte("dummy-picture")),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusNotFound; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.pictureSaver.(*mockPictures).ctx, nilCtx; got != want {
+					return fmt.Errorf("pictureSaver.ctx: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "upload picture patcher error",
+			fields: fields{
+				mocks: mocks{
+					groupReader:    &mockGroupReader{group: dummyGroup1},
+					groupPatcher:   &mockGroupPatcher{err: dummyError},
+					pictureSaver:   &mockPictures{picture: dummyPicture},
+					pictureDeleter: &mockPictures{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				request:   pictureRequest("picture", []byte("dummy-picture")),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusNotFound; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				pictureDeleter := m.pictureDeleter.(*mockPictures)
+				if got, want := pictureDeleter.deletedURL, dummyPicture.URL; got != want {
+					return fmt.Errorf("pictureDeleter.deletedURL: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.UploadPictureHandler()
+			})
+		})
+	}
+}
+
+func TestHandler_ReadPictureHandler(t *testing.T) {
+	tests := []test{
+		{
+			name: "read picture ok",
+			fields: fields{
+				mocks:     mocks{pictureOpener: &mockPictures{data: []byte("dummy-picture"), contentType: "image/png"}},
+				ctxParams: map[string]string{"name": "dummy.png"},
+				request:   httptest.NewRequest(http.MethodGet, "/pictures/dummy.png", nil),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				if got, want := recorder.Body.String(), "dummy-picture"; got != want {
+					return fmt.Errorf("recorder.Body: got %v, want %v", got, want)
+				}
+				if got, want := recorder.Header().Get("Content-Type"), "image/png"; got != want {
+					return fmt.Errorf("Content-Type: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.pictureOpener.(*mockPictures).name, "dummy.png"; got != want {
+					return fmt.Errorf("pictureOpener.name: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "read picture not found",
+			fields: fields{
+				mocks:     mocks{pictureOpener: &mockPictures{err: blob.ErrNotFound}},
+				ctxParams: map[string]string{"name": "dummy.png"},
+				request:   httptest.NewRequest(http.MethodGet, "/pictures/dummy.png", nil),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusNotFound; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.ReadPictureHandler()
+			})
+		})
+	}
+}
+
+type mockInvitations struct {
+	ctx          context.Context
+	groupID      string
+	inviteeID    string
+	invitationID string
+	created      types.Invitation
+	deleted      bool
+	invitation   types.Invitation
+	invitations  []types.Invitation
+	err          error
+}
+
+func (m *mockInvitations) CreateInvitation(ctx context.Context, invitation types.Invitation) (types.Invitation, error) {
+	m.ctx = ctx
+	m.created = invitation
+	invitation.ID = dummyInvitation.ID
+	return invitation, m.err
+}
+
+func (m *mockInvitations) ReadInvitations(ctx context.Context, inviteeID string) ([]types.Invitation, error) {
+	m.ctx = ctx
+	m.inviteeID = inviteeID
+	return m.invitations, m.err
+}
+
+func (m *mockInvitations) ReadGroupInvitations(ctx context.Context, groupID string) ([]types.Invitation, error) {
+	m.ctx = ctx
+	m.groupID = groupID
+	return m.invitations, m.err
+}
+
+func (m *mockInvitations) ReadInvitation(ctx context.Context, id, inviteeID string) (types.Invitation, error) {
+	m.ctx = ctx
+	m.invitationID = id
+	m.inviteeID = inviteeID
+	return m.invitation, m.err
+}
+
+func (m *mockInvitations) DeleteInvitation(ctx context.Context, id, inviteeID string) error {
+	m.ctx = ctx
+	m.invitationID = id
+	m.inviteeID = inviteeID
+	m.deleted = m.err == nil
+	return m.err
+}
+
+type mockMemberAdder struct {
+	ctx     context.Context
+	groupID string
+	user    types.User
+	group   types.Group
+	err     error
+}
+
+func (m *mockMemberAdder) AddMember(ctx context.Context, groupID string, user types.User) (types.Group, error) {
+	m.ctx = ctx
+	m.groupID = groupID
+	m.user = user
+	return m.group, m.err
+}
+
+type mockUserReader struct {
+	token string
+	id    string
+	user  types.User
+	err   error
+}
+
+func (m *mockUserReader) ReadUser(ctx context.Context, token, id string) (types.User, error) {
+	m.token = token
+	m.id = id
+	return m.user, m.err
+}
+
+func (m *mockUserReader) ReadUserProfile(ctx context.Context, token, id string) (types.User, error) {
+	m.token = token
+	m.id = id
+	return m.user, m.err
+}
+
+func statusOK(want int) func(*httptest.ResponseRecorder) error {
+	return func(recorder *httptest.ResponseRecorder) error {
+		if got := recorder.Result().StatusCode; got != want {
+			return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+		}
+		return nil
+	}
+}
+
+func TestHandler_InviteUserHandler(t *testing.T) {
+	inviteeID := "dummy-user-id-3"
+	leader := dummyGroup1.Leaders[0]
+	inviteRequest := func(userID string) *http.Request {
+		body, _ := json.Marshal(gin.H{"userId": userID})
+		return httptest.NewRequest(http.MethodPost, "/dummy-id-1/invitations", bytes.NewReader(body))
+	}
+
+	tests := []test{
+		{
+			name: "invite user ok",
+			fields: fields{
+				mocks: mocks{
+					groupReader:            &mockGroupReader{group: dummyGroup1},
+					userReader:             &mockUserReader{user: types.User{ID: inviteeID}},
+					groupInvitationsReader: &mockInvitations{},
+					invitationCreator:      &mockInvitations{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				ctxValues: map[string]any{"userID": leader.ID, "token": "dummyToken"},
+				request:   inviteRequest(inviteeID),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusCreated; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct{ Invitation types.Invitation }
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.Invitation.ID, dummyInvitation.ID; got != want {
+					return fmt.Errorf("resp.Invitation.ID: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				userReader := m.userReader.(*mockUserReader)
+				if got, want := userReader.id, inviteeID; got != want {
+					return fmt.Errorf("userReader.id: got %v, want %v", got, want)
+				}
+				if got, want := userReader.token, "dummyToken"; got != want {
+					return fmt.Errorf("userReader.token: got %v, want %v", got, want)
+				}
+				created := m.invitationCreator.(*mockInvitations).created
+				if got, want := created.GroupID, dummyGroup1.ID; got != want {
+					return fmt.Errorf("created.GroupID: got %v, want %v", got, want)
+				}
+				if got, want := created.GroupName, dummyGroup1.Name; got != want {
+					return fmt.Errorf("created.GroupName: got %v, want %v", got, want)
+				}
+				if got, want := created.InviteeID, inviteeID; got != want {
+					return fmt.Errorf("created.InviteeID: got %v, want %v", got, want)
+				}
+				if got, want := created.Inviter, leader; got != want {
+					return fmt.Errorf("created.Inviter: got %v, want %v", got, want)
+				}
+				if created.CreatedAt.IsZero() {
+					return errors.New("created.CreatedAt: got zero time, want now")
+				}
+				return nil
+			},
+		},
+		{
+			name: "invite user already member",
+			fields: fields{
+				mocks: mocks{
+					groupReader:            &mockGroupReader{group: dummyGroup1},
+					groupInvitationsReader: &mockInvitations{},
+					invitationCreator:      &mockInvitations{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				ctxValues: map[string]any{"userID": leader.ID},
+				request:   inviteRequest(dummyGroup1.Members[0].ID),
+			},
+			responseOK: statusOK(http.StatusConflict),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if created := m.invitationCreator.(*mockInvitations).created; created != (types.Invitation{}) {
+					return fmt.Errorf("invitationCreator.created: got %v, want none", created)
+				}
+				return nil
+			},
+		},
+		{
+			name: "invite user already invited",
+			fields: fields{
+				mocks: mocks{
+					groupReader:            &mockGroupReader{group: dummyGroup1},
+					userReader:             &mockUserReader{user: types.User{ID: dummyInvitation.InviteeID}},
+					groupInvitationsReader: &mockInvitations{invitations: []types.Invitation{dummyInvitation}},
+					invitationCreator:      &mockInvitations{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				ctxValues: map[string]any{"userID": leader.ID},
+				request:   inviteRequest(dummyInvitation.InviteeID),
+			},
+			responseOK: statusOK(http.StatusConflict),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if created := m.invitationCreator.(*mockInvitations).created; created != (types.Invitation{}) {
+					return fmt.Errorf("invitationCreator.created: got %v, want none", created)
+				}
+				return nil
+			},
+		},
+		{
+			name: "invite user not found",
+			fields: fields{
+				mocks: mocks{
+					groupReader:            &mockGroupReader{group: dummyGroup1},
+					userReader:             &mockUserReader{err: fmt.Errorf("read user: %w", userClient.ErrNotFound)},
+					groupInvitationsReader: &mockInvitations{},
+					invitationCreator:      &mockInvitations{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				ctxValues: map[string]any{"userID": leader.ID},
+				request:   inviteRequest(inviteeID),
+			},
+			responseOK: statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if created := m.invitationCreator.(*mockInvitations).created; created != (types.Invitation{}) {
+					return fmt.Errorf("invitationCreator.created: got %v, want none", created)
+				}
+				return nil
+			},
+		},
+		{
+			name: "invite user reader error",
+			fields: fields{
+				mocks: mocks{
+					groupReader:            &mockGroupReader{group: dummyGroup1},
+					userReader:             &mockUserReader{err: dummyError},
+					groupInvitationsReader: &mockInvitations{},
+					invitationCreator:      &mockInvitations{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				ctxValues: map[string]any{"userID": leader.ID},
+				request:   inviteRequest(inviteeID),
+			},
+			responseOK: statusOK(http.StatusBadGateway),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if created := m.invitationCreator.(*mockInvitations).created; created != (types.Invitation{}) {
+					return fmt.Errorf("invitationCreator.created: got %v, want none", created)
+				}
+				return nil
+			},
+		},
+		{
+			name: "invite user bad request",
+			fields: fields{
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				ctxValues: map[string]any{"userID": leader.ID},
+				request:   inviteRequest(""),
+			},
+			responseOK:    statusOK(http.StatusBadRequest),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+		{
+			name: "invite user group reader error",
+			fields: fields{
+				mocks: mocks{
+					groupReader: &mockGroupReader{err: dummyError},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				ctxValues: map[string]any{"userID": leader.ID},
+				request:   inviteRequest(inviteeID),
+			},
+			responseOK:    statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.InviteUserHandler()
+			})
+		})
+	}
+}
+
+func TestHandler_ReadInvitationsHandler(t *testing.T) {
+	tests := []test{
+		{
+			name: "read invitations ok",
+			fields: fields{
+				mocks: mocks{
+					invitationsReader: &mockInvitations{invitations: []types.Invitation{dummyInvitation}},
+				},
+				ctxValues: map[string]any{"userID": dummyInvitation.InviteeID},
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct{ Invitations []types.Invitation }
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.Invitations, []types.Invitation{dummyInvitation}; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("resp.Invitations: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.invitationsReader.(*mockInvitations).inviteeID, dummyInvitation.InviteeID; got != want {
+					return fmt.Errorf("invitationsReader.inviteeID: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "read invitations reader error",
+			fields: fields{
+				mocks: mocks{
+					invitationsReader: &mockInvitations{err: dummyError},
+				},
+				ctxValues: map[string]any{"userID": dummyInvitation.InviteeID},
+			},
+			responseOK:    statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.ReadInvitationsHandler()
+			})
+		})
+	}
+}
+
+func TestHandler_AcceptInvitationHandler(t *testing.T) {
+	invitee := types.User{ID: dummyInvitation.InviteeID, Name: "dummy-invitee-name", Email: "invitee@dummy.io"}
+	joinedGroup := dummyGroup1
+	joinedGroup.Members = append([]types.User{invitee}, dummyGroup1.Members...)
+
+	tests := []test{
+		{
+			name: "accept invitation ok",
+			fields: fields{
+				mocks: mocks{
+					invitationReader:  &mockInvitations{invitation: dummyInvitation},
+					invitationDeleter: &mockInvitations{},
+					userReader:        &mockUserReader{user: invitee},
+					memberAdder:       &mockMemberAdder{group: joinedGroup},
+				},
+				ctxParams: map[string]string{"invitationID": dummyInvitation.ID},
+				ctxValues: map[string]any{"userID": invitee.ID, "token": "dummy-token"},
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct{ Group types.Group }
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.Group, joinedGroup; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("resp.Group: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				reader := m.invitationReader.(*mockInvitations)
+				if got, want := reader.invitationID, dummyInvitation.ID; got != want {
+					return fmt.Errorf("invitationReader.invitationID: got %v, want %v", got, want)
+				}
+				if got, want := reader.inviteeID, invitee.ID; got != want {
+					return fmt.Errorf("invitationReader.inviteeID: got %v, want %v", got, want)
+				}
+				if got, want := m.userReader.(*mockUserReader).token, "dummy-token"; got != want {
+					return fmt.Errorf("userReader.token: got %v, want %v", got, want)
+				}
+				memberAdder := m.memberAdder.(*mockMemberAdder)
+				if got, want := memberAdder.groupID, dummyInvitation.GroupID; got != want {
+					return fmt.Errorf("memberAdder.groupID: got %v, want %v", got, want)
+				}
+				if got, want := memberAdder.user, invitee; got != want {
+					return fmt.Errorf("memberAdder.user: got %v, want %v", got, want)
+				}
+				if !m.invitationDeleter.(*mockInvitations).deleted {
+					return errors.New("invitationDeleter.deleted: got false, want true")
+				}
+				return nil
+			},
+		},
+		{
+			name: "accept invitation of another user",
+			fields: fields{
+				mocks: mocks{
+					invitationReader:  &mockInvitations{err: dummyError},
+					invitationDeleter: &mockInvitations{},
+					userReader:        &mockUserReader{user: invitee},
+					memberAdder:       &mockMemberAdder{group: joinedGroup},
+				},
+				ctxParams: map[string]string{"invitationID": dummyInvitation.ID},
+				ctxValues: map[string]any{"userID": "dummy-other-user-id", "token": "dummy-token"},
+			},
+			responseOK: statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got := m.memberAdder.(*mockMemberAdder).groupID; got != "" {
+					return fmt.Errorf("memberAdder.groupID: got %v, want none", got)
+				}
+				return nil
+			},
+		},
+		{
+			name: "accept invitation user reader error",
+			fields: fields{
+				mocks: mocks{
+					invitationReader:  &mockInvitations{invitation: dummyInvitation},
+					invitationDeleter: &mockInvitations{},
+					userReader:        &mockUserReader{err: dummyError},
+					memberAdder:       &mockMemberAdder{group: joinedGroup},
+				},
+				ctxParams: map[string]string{"invitationID": dummyInvitation.ID},
+				ctxValues: map[string]any{"userID": invitee.ID, "token": "dummy-token"},
+			},
+			responseOK: statusOK(http.StatusBadGateway),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got := m.memberAdder.(*mockMemberAdder).groupID; got != "" {
+					return fmt.Errorf("memberAdder.groupID: got %v, want none", got)
+				}
+				if m.invitationDeleter.(*mockInvitations).deleted {
+					return errors.New("invitationDeleter.deleted: got true, want false")
+				}
+				return nil
+			},
+		},
+		{
+			name: "accept invitation member adder error",
+			fields: fields{
+				mocks: mocks{
+					invitationReader:  &mockInvitations{invitation: dummyInvitation},
+					invitationDeleter: &mockInvitations{},
+					userReader:        &mockUserReader{user: invitee},
+					memberAdder:       &mockMemberAdder{err: dummyError},
+				},
+				ctxParams: map[string]string{"invitationID": dummyInvitation.ID},
+				ctxValues: map[string]any{"userID": invitee.ID, "token": "dummy-token"},
+			},
+			responseOK: statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if m.invitationDeleter.(*mockInvitations).deleted {
+					return errors.New("invitationDeleter.deleted: got true, want false")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.AcceptInvitationHandler()
+			})
+		})
+	}
+}
+
+func TestHandler_RejectInvitationHandler(t *testing.T) {
+	tests := []test{
+		{
+			name: "reject invitation ok",
+			fields: fields{
+				mocks: mocks{
+					invitationDeleter: &mockInvitations{},
+				},
+				ctxParams: map[string]string{"invitationID": dummyInvitation.ID},
+				ctxValues: map[string]any{"userID": dummyInvitation.InviteeID},
+			},
+			responseOK: statusOK(http.StatusOK),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				deleter := m.invitationDeleter.(*mockInvitations)
+				if got, want := deleter.invitationID, dummyInvitation.ID; got != want {
+					return fmt.Errorf("invitationDeleter.invitationID: got %v, want %v", got, want)
+				}
+				if got, want := deleter.inviteeID, dummyInvitation.InviteeID; got != want {
+					return fmt.Errorf("invitationDeleter.inviteeID: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "reject invitation deleter error",
+			fields: fields{
+				mocks: mocks{
+					invitationDeleter: &mockInvitations{err: dummyError},
+				},
+				ctxParams: map[string]string{"invitationID": dummyInvitation.ID},
+				ctxValues: map[string]any{"userID": dummyInvitation.InviteeID},
+			},
+			responseOK:    statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.RejectInvitationHandler()
+			})
+		})
+	}
+}
+
+type mockJoinRequests struct {
+	ctx       context.Context
+	groupID   string
+	requestID string
+	created   types.JoinRequest
+	deleted   bool
+	request   types.JoinRequest
+	requests  []types.JoinRequest
+	err       error
+}
+
+func (m *mockJoinRequests) CreateJoinRequest(ctx context.Context, request types.JoinRequest) (types.JoinRequest, error) {
+	m.ctx = ctx
+	m.created = request
+	request.ID = dummyJoinRequest.ID
+	return request, m.err
+}
+
+func (m *mockJoinRequests) ReadJoinRequests(ctx context.Context, groupID string) ([]types.JoinRequest, error) {
+	m.ctx = ctx
+	m.groupID = groupID
+	return m.requests, m.err
+}
+
+func (m *mockJoinRequests) ReadJoinRequest(ctx context.Context, id, groupID string) (types.JoinRequest, error) {
+	m.ctx = ctx
+	m.requestID = id
+	m.groupID = groupID
+	return m.request, m.err
+}
+
+func (m *mockJoinRequests) DeleteJoinRequest(ctx context.Context, id, groupID string) error {
+	m.ctx = ctx
+	m.requestID = id
+	m.groupID = groupID
+	m.deleted = m.err == nil
+	return m.err
+}
+
+func TestHandler_RequestToJoinHandler(t *testing.T) {
+	publicGroup := dummyGroup1
+	publicGroup.Visibility = types.GroupPublic
+	requester := dummyJoinRequest.Requester
+	joinRequest := func(body string) *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/dummy-id-1/join-requests", bytes.NewBufferString(body))
+	}
+
+	tests := []test{
+		{
+			name: "request to join ok",
+			fields: fields{
+				mocks: mocks{
+					groupReader:        &mockGroupReader{group: publicGroup},
+					joinRequestsReader: &mockJoinRequests{},
+					joinRequestCreator: &mockJoinRequests{},
+					userReader:         &mockUserReader{user: requester},
+				},
+				ctxParams: map[string]string{"id": publicGroup.ID},
+				ctxValues: map[string]any{"userID": requester.ID, "token": "dummy-token"},
+				request:   joinRequest(`{"message": "dummy-message"}`),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusCreated; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct{ JoinRequest types.JoinRequest }
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.JoinRequest.ID, dummyJoinRequest.ID; got != want {
+					return fmt.Errorf("resp.JoinRequest.ID: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				created := m.joinRequestCreator.(*mockJoinRequests).created
+				if got, want := created.GroupID, publicGroup.ID; got != want {
+					return fmt.Errorf("created.GroupID: got %v, want %v", got, want)
+				}
+				if got, want := created.Requester, requester; got != want {
+					return fmt.Errorf("created.Requester: got %v, want %v", got, want)
+				}
+				if got, want := created.Message, "dummy-message"; got != want {
+					return fmt.Errorf("created.Message: got %v, want %v", got, want)
+				}
+				if got, want := m.userReader.(*mockUserReader).token, "dummy-token"; got != want {
+					return fmt.Errorf("userReader.token: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "request to join without message",
+			fields: fields{
+				mocks: mocks{
+					groupReader:        &mockGroupReader{group: publicGroup},
+					joinRequestsReader: &mockJoinRequests{},
+					joinRequestCreator: &mockJoinRequests{},
+					userReader:         &mockUserReader{user: requester},
+				},
+				ctxParams: map[string]string{"id": publicGroup.ID},
+				ctxValues: map[string]any{"userID": requester.ID, "token": "dummy-token"},
+				request:   joinRequest(""),
+			},
+			responseOK:    statusOK(http.StatusCreated),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+		{
+			name: "request to join private group",
+			fields: fields{
+				mocks: mocks{
+					groupReader:        &mockGroupReader{group: dummyGroup1},
+					joinRequestsReader: &mockJoinRequests{},
+					joinRequestCreator: &mockJoinRequests{},
+					userReader:         &mockUserReader{user: requester},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID},
+				ctxValues: map[string]any{"userID": requester.ID, "token": "dummy-token"},
+				request:   joinRequest(""),
+			},
+			responseOK: statusOK(http.StatusForbidden),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if created := m.joinRequestCreator.(*mockJoinRequests).created; created != (types.JoinRequest{}) {
+					return fmt.Errorf("joinRequestCreator.created: got %v, want none", created)
+				}
+				return nil
+			},
+		},
+		{
+			name: "request to join as a member",
+			fields: fields{
+				mocks: mocks{
+					groupReader:        &mockGroupReader{group: publicGroup},
+					joinRequestsReader: &mockJoinRequests{},
+					joinRequestCreator: &mockJoinRequests{},
+					userReader:         &mockUserReader{user: requester},
+				},
+				ctxParams: map[string]string{"id": publicGroup.ID},
+				ctxValues: map[string]any{"userID": publicGroup.Members[0].ID, "token": "dummy-token"},
+				request:   joinRequest(""),
+			},
+			responseOK:    statusOK(http.StatusConflict),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+		{
+			name: "request to join again",
+			fields: fields{
+				mocks: mocks{
+					groupReader:        &mockGroupReader{group: publicGroup},
+					joinRequestsReader: &mockJoinRequests{requests: []types.JoinRequest{dummyJoinRequest}},
+					joinRequestCreator: &mockJoinRequests{},
+					userReader:         &mockUserReader{user: requester},
+				},
+				ctxParams: map[string]string{"id": publicGroup.ID},
+				ctxValues: map[string]any{"userID": requester.ID, "token": "dummy-token"},
+				request:   joinRequest(""),
+			},
+			responseOK: statusOK(http.StatusConflict),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if created := m.joinRequestCreator.(*mockJoinRequests).created; created != (types.JoinRequest{}) {
+					return fmt.Errorf("joinRequestCreator.created: got %v, want none", created)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.RequestToJoinHandler()
+			})
+		})
+	}
+}
+
+func TestHandler_ApproveJoinRequestHandler(t *testing.T) {
+	joinedGroup := dummyGroup1
+	joinedGroup.Members = append([]types.User{dummyJoinRequest.Requester}, dummyGroup1.Members...)
+
+	tests := []test{
+		{
+			name: "approve join request ok",
+			fields: fields{
+				mocks: mocks{
+					joinRequestReader:  &mockJoinRequests{request: dummyJoinRequest},
+					joinRequestDeleter: &mockJoinRequests{},
+					memberAdder:        &mockMemberAdder{group: joinedGroup},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID, "requestID": dummyJoinRequest.ID},
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct{ Group types.Group }
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.Group, joinedGroup; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("resp.Group: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				reader := m.joinRequestReader.(*mockJoinRequests)
+				if got, want := reader.requestID, dummyJoinRequest.ID; got != want {
+					return fmt.Errorf("joinRequestReader.requestID: got %v, want %v", got, want)
+				}
+				if got, want := reader.groupID, dummyGroup1.ID; got != want {
+					return fmt.Errorf("joinRequestReader.groupID: got %v, want %v", got, want)
+				}
+				memberAdder := m.memberAdder.(*mockMemberAdder)
+				if got, want := memberAdder.groupID, dummyGroup1.ID; got != want {
+					return fmt.Errorf("memberAdder.groupID: got %v, want %v", got, want)
+				}
+				if got, want := memberAdder.user, dummyJoinRequest.Requester; got != want {
+					return fmt.Errorf("memberAdder.user: got %v, want %v", got, want)
+				}
+				if !m.joinRequestDeleter.(*mockJoinRequests).deleted {
+					return errors.New("joinRequestDeleter.deleted: got false, want true")
+				}
+				return nil
+			},
+		},
+		{
+			name: "approve join request of another group",
+			fields: fields{
+				mocks: mocks{
+					joinRequestReader:  &mockJoinRequests{err: dummyError},
+					joinRequestDeleter: &mockJoinRequests{},
+					memberAdder:        &mockMemberAdder{group: joinedGroup},
+				},
+				ctxParams: map[string]string{"id": dummyGroup2.ID, "requestID": dummyJoinRequest.ID},
+			},
+			responseOK: statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got := m.memberAdder.(*mockMemberAdder).groupID; got != "" {
+					return fmt.Errorf("memberAdder.groupID: got %v, want none", got)
+				}
+				return nil
+			},
+		},
+		{
+			name: "approve join request member adder error",
+			fields: fields{
+				mocks: mocks{
+					joinRequestReader:  &mockJoinRequests{request: dummyJoinRequest},
+					joinRequestDeleter: &mockJoinRequests{},
+					memberAdder:        &mockMemberAdder{err: dummyError},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID, "requestID": dummyJoinRequest.ID},
+			},
+			responseOK: statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if m.joinRequestDeleter.(*mockJoinRequests).deleted {
+					return errors.New("joinRequestDeleter.deleted: got true, want false")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.ApproveJoinRequestHandler()
+			})
+		})
+	}
+}
+
+func TestHandler_DenyJoinRequestHandler(t *testing.T) {
+	tests := []test{
+		{
+			name: "deny join request ok",
+			fields: fields{
+				mocks: mocks{
+					joinRequestDeleter: &mockJoinRequests{},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID, "requestID": dummyJoinRequest.ID},
+			},
+			responseOK: statusOK(http.StatusOK),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				deleter := m.joinRequestDeleter.(*mockJoinRequests)
+				if got, want := deleter.requestID, dummyJoinRequest.ID; got != want {
+					return fmt.Errorf("joinRequestDeleter.requestID: got %v, want %v", got, want)
+				}
+				if got, want := deleter.groupID, dummyGroup1.ID; got != want {
+					return fmt.Errorf("joinRequestDeleter.groupID: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "deny join request deleter error",
+			fields: fields{
+				mocks: mocks{
+					joinRequestDeleter: &mockJoinRequests{err: dummyError},
+				},
+				ctxParams: map[string]string{"id": dummyGroup1.ID, "requestID": dummyJoinRequest.ID},
+			},
+			responseOK:    statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.DenyJoinRequestHandler()
+			})
+		})
+	}
+}
+
+type mockMembership struct {
+	ctx      context.Context
+	groupID  string
+	userID   string
+	leaderID string
+	member   types.User
+	group    types.Group
+	err      error
+}
+
+func (m *mockMembership) RemoveMember(ctx context.Context, groupID, userID string) (types.Group, error) {
+	m.ctx = ctx
+	m.groupID = groupID
+	m.userID = userID
+	return m.group, m.err
+}
+
+func (m *mockMembership) PromoteLeader(ctx context.Context, groupID string, member types.User) (types.Group, error) {
+	m.ctx = ctx
+	m.groupID = groupID
+	m.member = member
+	return m.group, m.err
+}
+
+func (m *mockMembership) DemoteLeader(ctx context.Context, groupID, userID string) (types.Group, error) {
+	m.ctx = ctx
+	m.groupID = groupID
+	m.userID = userID
+	return m.group, m.err
+}
+
+func (m *mockMembership) TransferLeadership(ctx context.Context, groupID, leaderID string, member types.User) (types.Group, error) {
+	m.ctx = ctx
+	m.groupID = groupID
+	m.leaderID = leaderID
+	m.member = member
+	return m.group, m.err
+}
+
+// membershipGroup has two leaders and a member that doesn't lead
+var membershipGroup = types.Group{
+	ID:      "dummy-membership-group-id",
+	Members: []types.User{{ID: "dummy-leader-1"}, {ID: "dummy-leader-2"}, {ID: "dummy-member", Name: "dummy-member-name"}},
+	Leaders: []types.User{{ID: "dummy-leader-1"}, {ID: "dummy-leader-2"}},
+}
+
+func TestHandler_LeaveGroupHandler(t *testing.T) {
+	soleLeaderGroup := membershipGroup
+	soleLeaderGroup.Leaders = membershipGroup.Leaders[:1]
+
+	tests := []test{
+		{
+			name: "leave group ok",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: membershipGroup},
+					memberRemover: &mockMembership{group: dummyGroup2},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID},
+				ctxValues: map[string]any{"userID": "dummy-member"},
+			},
+			responseOK: statusOK(http.StatusOK),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				remover := m.memberRemover.(*mockMembership)
+				if got, want := remover.groupID, membershipGroup.ID; got != want {
+					return fmt.Errorf("memberRemover.groupID: got %v, want %v", got, want)
+				}
+				if got, want := remover.userID, "dummy-member"; got != want {
+					return fmt.Errorf("memberRemover.userID: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "leave group as one of the leaders",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: membershipGroup},
+					memberRemover: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID},
+				ctxValues: map[string]any{"userID": "dummy-leader-1"},
+			},
+			responseOK:    statusOK(http.StatusOK),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+		{
+			name: "leave group as the last leader",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: soleLeaderGroup},
+					memberRemover: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID},
+				ctxValues: map[string]any{"userID": "dummy-leader-1"},
+			},
+			responseOK: statusOK(http.StatusConflict),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got := m.memberRemover.(*mockMembership).userID; got != "" {
+					return fmt.Errorf("memberRemover.userID: got %v, want none", got)
+				}
+				return nil
+			},
+		},
+		{
+			name: "leave group not a member",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: membershipGroup},
+					memberRemover: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID},
+				ctxValues: map[string]any{"userID": "dummy-stranger"},
+			},
+			responseOK:    statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+		{
+			name: "leave group changed meanwhile",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: membershipGroup},
+					memberRemover: &mockMembership{err: membership.ErrConflict},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID},
+				ctxValues: map[string]any{"userID": "dummy-leader-1"},
+			},
+			responseOK:    statusOK(http.StatusConflict),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+		{
+			name: "leave group deleted meanwhile",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: membershipGroup},
+					memberRemover: &mockMembership{err: membership.ErrNoSuchGroup},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID},
+				ctxValues: map[string]any{"userID": "dummy-leader-1"},
+			},
+			responseOK:    statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+		{
+			name: "leave group store error",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: membershipGroup},
+					memberRemover: &mockMembership{err: dummyError},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID},
+				ctxValues: map[string]any{"userID": "dummy-leader-1"},
+			},
+			responseOK:    statusOK(http.StatusInternalServerError),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.LeaveGroupHandler()
+			})
+		})
+	}
+}
+
+func TestHandler_RemoveMemberHandler(t *testing.T) {
+	tests := []test{
+		{
+			name: "remove member ok",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: membershipGroup},
+					memberRemover: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID, "memberID": "dummy-member"},
+				ctxValues: map[string]any{"userID": "dummy-leader-1"},
+			},
+			responseOK: statusOK(http.StatusOK),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.memberRemover.(*mockMembership).userID, "dummy-member"; got != want {
+					return fmt.Errorf("memberRemover.userID: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "remove member not a member",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: membershipGroup},
+					memberRemover: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID, "memberID": "dummy-stranger"},
+				ctxValues: map[string]any{"userID": "dummy-leader-1"},
+			},
+			responseOK:    statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.RemoveMemberHandler()
+			})
+		})
+	}
+}
+
+func TestHandler_PromoteMemberHandler(t *testing.T) {
+	promotedGroup := membershipGroup
+	promotedGroup.Leaders = membershipGroup.Members
+
+	tests := []test{
+		{
+			name: "promote member ok",
+			fields: fields{
+				mocks: mocks{
+					groupReader:    &mockGroupReader{group: membershipGroup},
+					leaderPromoter: &mockMembership{group: promotedGroup},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID, "memberID": "dummy-member"},
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct{ Group types.Group }
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.Group, promotedGroup; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("resp.Group: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				promoter := m.leaderPromoter.(*mockMembership)
+				if got, want := promoter.member, membershipGroup.Members[2]; got != want {
+					return fmt.Errorf("leaderPromoter.member: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "promote member already a leader",
+			fields: fields{
+				mocks: mocks{
+					groupReader:    &mockGroupReader{group: membershipGroup},
+					leaderPromoter: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID, "memberID": "dummy-leader-2"},
+			},
+			responseOK: statusOK(http.StatusOK),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got := m.leaderPromoter.(*mockMembership).member; got != (types.User{}) {
+					return fmt.Errorf("leaderPromoter.member: got %v, want none", got)
+				}
+				return nil
+			},
+		},
+		{
+			name: "promote member not a member",
+			fields: fields{
+				mocks: mocks{
+					groupReader:    &mockGroupReader{group: membershipGroup},
+					leaderPromoter: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID, "memberID": "dummy-stranger"},
+			},
+			responseOK:    statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.PromoteMemberHandler()
+			})
+		})
+	}
+}
+
+func TestHandler_DemoteLeaderHandler(t *testing.T) {
+	soleLeaderGroup := membershipGroup
+	soleLeaderGroup.Leaders = membershipGroup.Leaders[:1]
+
+	tests := []test{
+		{
+			name: "demote leader ok",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: membershipGroup},
+					leaderDemoter: &mockMembership{group: soleLeaderGroup},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID, "memberID": "dummy-leader-2"},
+			},
+			responseOK: statusOK(http.StatusOK),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got, want := m.leaderDemoter.(*mockMembership).userID, "dummy-leader-2"; got != want {
+					return fmt.Errorf("leaderDemoter.userID: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "demote last leader",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: soleLeaderGroup},
+					leaderDemoter: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID, "memberID": "dummy-leader-1"},
+			},
+			responseOK: statusOK(http.StatusConflict),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got := m.leaderDemoter.(*mockMembership).userID; got != "" {
+					return fmt.Errorf("leaderDemoter.userID: got %v, want none", got)
+				}
+				return nil
+			},
+		},
+		{
+			name: "demote leader not a leader",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: membershipGroup},
+					leaderDemoter: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID, "memberID": "dummy-member"},
+			},
+			responseOK:    statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+		{
+			name: "demote leader changed meanwhile",
+			fields: fields{
+				mocks: mocks{
+					groupReader:   &mockGroupReader{group: membershipGroup},
+					leaderDemoter: &mockMembership{err: membership.ErrConflict},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID, "memberID": "dummy-leader-2"},
+			},
+			responseOK:    statusOK(http.StatusConflict),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.DemoteLeaderHandler()
+			})
+		})
+	}
+}
+
+func TestHandler_TransferLeadershipHandler(t *testing.T) {
+	transferRequest := func(userID string) *http.Request {
+		body, _ := json.Marshal(gin.H{"userId": userID})
+		return httptest.NewRequest(http.MethodPost, "/dummy-membership-group-id/leadership", bytes.NewReader(body))
+	}
+
+	tests := []test{
+		{
+			name: "transfer leadership ok",
+			fields: fields{
+				mocks: mocks{
+					groupReader:           &mockGroupReader{group: membershipGroup},
+					leadershipTransferrer: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID},
+				ctxValues: map[string]any{"userID": "dummy-leader-1"},
+				request:   transferRequest("dummy-member"),
+			},
+			responseOK: statusOK(http.StatusOK),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				transferrer := m.leadershipTransferrer.(*mockMembership)
+				if got, want := transferrer.leaderID, "dummy-leader-1"; got != want {
+					return fmt.Errorf("leadershipTransferrer.leaderID: got %v, want %v", got, want)
+				}
+				if got, want := transferrer.member, membershipGroup.Members[2]; got != want {
+					return fmt.Errorf("leadershipTransferrer.member: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "transfer leadership to self",
+			fields: fields{
+				mocks: mocks{
+					groupReader:           &mockGroupReader{group: membershipGroup},
+					leadershipTransferrer: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID},
+				ctxValues: map[string]any{"userID": "dummy-leader-1"},
+				request:   transferRequest("dummy-leader-1"),
+			},
+			responseOK:    statusOK(http.StatusUnprocessableEntity),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+		{
+			name: "transfer leadership to a stranger",
+			fields: fields{
+				mocks: mocks{
+					groupReader:           &mockGroupReader{group: membershipGroup},
+					leadershipTransferrer: &mockMembership{},
+				},
+				ctxParams: map[string]string{"id": membershipGroup.ID},
+				ctxValues: map[string]any{"userID": "dummy-leader-1"},
+				request:   transferRequest("dummy-stranger"),
+			},
+			responseOK: statusOK(http.StatusNotFound),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got := m.leadershipTransferrer.(*mockMembership).leaderID; got != "" {
+					return fmt.Errorf("leadershipTransferrer.leaderID: got %v, want none", got)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.TransferLeadershipHandler()
+			})
+		})
+	}
+}
+
+type mockGroupSearcher struct {
+	ctx    context.Context
+	query  search.Query
+	groups []types.GroupSummary
+	next   *cursor.Cursor
+	err    error
+}
+
+func (m *mockGroupSearcher) SearchGroups(ctx context.Context, q search.Query) ([]types.GroupSummary, *cursor.Cursor, error) {
+	m.ctx = ctx
+	m.query = q
+	return m.groups, m.next, m.err
+}
+
+func TestHandler_SearchGroupsHandler(t *testing.T) {
+	summaries := []types.GroupSummary{{ID: dummyGroup1.ID, Name: dummyGroup1.Name, Tags: []string{"hiking"}, MemberCount: 1}}
+	next := &cursor.Cursor{Score: 1.5, ID: dummyGroup1.ID}
+
+	tests := []test{
+		{
+			name: "search groups ok",
+			fields: fields{
+				mocks: mocks{
+					groupSearcher: &mockGroupSearcher{groups: summaries, next: next},
+				},
+				request: httptest.NewRequest(http.MethodGet, "/?q=+mountain+trips+&tags=Hiking,,outdoors,hiking&category=Sports&limit=10", nil),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct {
+					Groups     []types.GroupSummary
+					NextCursor string
+				}
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.Groups, summaries; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("resp.Groups: got %v, want %v", got, want)
+				}
+				if got, want := resp.NextCursor, next.Encode(); got != want {
+					return fmt.Errorf("resp.NextCursor: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				want := search.Query{Text: "mountain trips", Tags: []string{"hiking", "outdoors"}, Category: "sports", Limit: 10}
+				if got := m.groupSearcher.(*mockGroupSearcher).query; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("groupSearcher.query: got %+v, want %+v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "search groups next page",
+			fields: fields{
+				mocks: mocks{
+					groupSearcher: &mockGroupSearcher{groups: summaries},
+				},
+				request: httptest.NewRequest(http.MethodGet, "/?cursor="+next.Encode(), nil),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp map[string]any
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if _, ok := resp["nextCursor"]; ok {
+					return errors.New("resp.nextCursor: got a cursor on the last page, want none")
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				want := search.Query{After: next, Limit: 20}
+				if got := m.groupSearcher.(*mockGroupSearcher).query; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("groupSearcher.query: got %+v, want %+v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "search groups bad cursor",
+			fields: fields{
+				mocks: mocks{
+					groupSearcher: &mockGroupSearcher{},
+				},
+				request: httptest.NewRequest(http.MethodGet, "/?cursor=not-a-cursor", nil),
+			},
+			responseOK: statusOK(http.StatusBadRequest),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got := m.groupSearcher.(*mockGroupSearcher).ctx; got != nilCtx {
+					return fmt.Errorf("groupSearcher.ctx: got %v, want %v", got, nilCtx)
+				}
+				return nil
+			},
+		},
+		{
+			name: "search groups searcher error",
+			fields: fields{
+				mocks: mocks{
+					groupSearcher: &mockGroupSearcher{err: dummyError},
+				},
+				request: httptest.NewRequest(http.MethodGet, "/?q=dummy", nil),
+			},
+			responseOK:    statusOK(http.StatusInternalServerError),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.SearchGroupsHandler()
+			})
+		})
+	}
+}
+
+type mockNearbyGroupsReader struct {
+	ctx       context.Context
+	latitude  float64
+	longitude float64
+	radiusKm  float64
+	limit     int64
+	groups    []types.NearbyGroup
+	err       error
+}
+
+func (m *mockNearbyGroupsReader) NearbyGroups(ctx context.Context, latitude, longitude, radiusKm float64, limit int64) ([]types.NearbyGroup, error) {
+	m.ctx = ctx
+	m.latitude = latitude
+	m.longitude = longitude
+	m.radiusKm = radiusKm
+	m.limit = limit
 	return m.groups, m.err
 }
 
+func TestHandler_NearbyGroupsHandler(t *testing.T) {
+	nearby := []types.NearbyGroup{{
+		GroupSummary: types.GroupSummary{ID: dummyGroup1.ID, Name: dummyGroup1.Name, MemberCount: 1, Location: &dummyLocation},
+		DistanceKm:   1.5,
+	}}
+
+	tests := []test{
+		{
+			name: "nearby groups ok",
+			fields: fields{
+				mocks: mocks{
+					nearbyGroupsReader: &mockNearbyGroupsReader{groups: nearby},
+				},
+				request: httptest.NewRequest(http.MethodGet, "/nearby?lat=-30.03&lng=-51.22&radiusKm=25&limit=5", nil),
+			},
+			responseOK: func(recorder *httptest.ResponseRecorder) error {
+				if got, want := recorder.Result().StatusCode, http.StatusOK; got != want {
+					return fmt.Errorf("recorder.Result().StatusCode: got %v, want %v", got, want)
+				}
+				var resp struct{ Groups []types.NearbyGroup }
+				if err := json.NewDecoder(recorder.Result().Body).Decode(&resp); err != nil {
+					return err
+				}
+				if got, want := resp.Groups, nearby; !reflect.DeepEqual(got, want) {
+					return fmt.Errorf("resp.Groups: got %v, want %v", got, want)
+				}
+				return nil
+			},
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				reader := m.nearbyGroupsReader.(*mockNearbyGroupsReader)
+				if got, want := reader.latitude, -30.03; got != want {
+					return fmt.Errorf("nearbyGroupsReader.latitude: got %v, want %v", got, want)
+				}
+				if got, want := reader.longitude, -51.22; got != want {
+					return fmt.Errorf("nearbyGroupsReader.longitude: got %v, want %v", got, want)
+				}
+				if got, want := reader.radiusKm, 25.0; got != want {
+					return fmt.Errorf("nearbyGroupsReader.radiusKm: got %v, want %v", got, want)
+				}
+				if got, want := reader.limit, int64(5); got != want {
+					return fmt.Errorf("nearbyGroupsReader.limit: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "nearby groups defaults at the origin",
+			fields: fields{
+				mocks: mocks{
+					nearbyGroupsReader: &mockNearbyGroupsReader{groups: nearby},
+				},
+				request: httptest.NewRequest(http.MethodGet, "/nearby?lat=0&lng=0", nil),
+			},
+			responseOK: statusOK(http.StatusOK),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				reader := m.nearbyGroupsReader.(*mockNearbyGroupsReader)
+				if got, want := reader.radiusKm, 10.0; got != want {
+					return fmt.Errorf("nearbyGroupsReader.radiusKm: got %v, want %v", got, want)
+				}
+				if got, want := reader.limit, int64(20); got != want {
+					return fmt.Errorf("nearbyGroupsReader.limit: got %v, want %v", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name: "nearby groups missing longitude",
+			fields: fields{
+				mocks: mocks{
+					nearbyGroupsReader: &mockNearbyGroupsReader{},
+				},
+				request: httptest.NewRequest(http.MethodGet, "/nearby?lat=10", nil),
+			},
+			responseOK: statusOK(http.StatusBadRequest),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got := m.nearbyGroupsReader.(*mockNearbyGroupsReader).ctx; got != nilCtx {
+					return fmt.Errorf("nearbyGroupsReader.ctx: got %v, want %v", got, nilCtx)
+				}
+				return nil
+			},
+		},
+		{
+			name: "nearby groups latitude out of range",
+			fields: fields{
+				mocks: mocks{
+					nearbyGroupsReader: &mockNearbyGroupsReader{},
+				},
+				request: httptest.NewRequest(http.MethodGet, "/nearby?lat=100&lng=10", nil),
+			},
+			responseOK: statusOK(http.StatusBadRequest),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error {
+				if got := m.nearbyGroupsReader.(*mockNearbyGroupsReader).ctx; got != nilCtx {
+					return fmt.Errorf("nearbyGroupsReader.ctx: got %v, want %v", got, nilCtx)
+				}
+				return nil
+			},
+		},
+		{
+			name: "nearby groups radius too large",
+			fields: fields{
+				mocks: mocks{
+					nearbyGroupsReader: &mockNearbyGroupsReader{},
+				},
+				request: httptest.NewRequest(http.MethodGet, "/nearby?lat=10&lng=10&radiusKm=501", nil),
+			},
+			responseOK:    statusOK(http.StatusBadRequest),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+		{
+			name: "nearby groups reader error",
+			fields: fields{
+				mocks: mocks{
+					nearbyGroupsReader: &mockNearbyGroupsReader{err: dummyError},
+				},
+				request: httptest.NewRequest(http.MethodGet, "/nearby?lat=10&lng=10", nil),
+			},
+			responseOK:    statusOK(http.StatusInternalServerError),
+			sideEffectsOK: func(m mocks, ctx *gin.Context) error { return nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRequest(t, tt, func(h handler.Handler) gin.HandlerFunc {
+				return h.NearbyGroupsHandler()
+			})
+		})
+	}
+}
+
 // empty values
 var (
 	nilCtx     context.Context = nil
@@ -864,6 +3286,29 @@ var (
 		Description: "dummy-description-2",
 		Members:     []types.User{{ID: "dummy-user-id-1-2", Name: "dummy-user-name-1-2"}},
 		Leaders:     []types.User{{ID: "dummy-user-id-2-2", Name: "dummy-user-name-2-2"}},
+		Version:     2,
+	}
+	dummyPicture = picture.Picture{
+		URL:          "https://dummy.io/api/v0/groups/pictures/dummy-id-1-0123456789abcdef.png",
+		ThumbnailURL: "https://dummy.io/api/v0/groups/pictures/dummy-id-1-0123456789abcdef-thumb.png",
+	}
+	dummyInvitation = types.Invitation{
+		ID:        "dummy-invitation-id",
+		GroupID:   dummyGroup1.ID,
+		GroupName: dummyGroup1.Name,
+		InviteeID: "dummy-invitee-id",
+		Inviter:   dummyGroup1.Leaders[0],
+	}
+	dummyJoinRequest = types.JoinRequest{
+		ID:        "dummy-join-request-id",
+		GroupID:   dummyGroup1.ID,
+		Requester: types.User{ID: "dummy-requester-id", Name: "dummy-requester-name"},
+		Message:   "dummy-message",
+	}
+	dummyLocation = types.Location{
+		Name:      "dummy-location-name",
+		Latitude:  -30.03,
+		Longitude: -51.22,
 	}
 	dummyGroup1JSON, _ = json.Marshal(dummyGroup1)
 	dummyError         = errors.New("dummy error")
@@ -2,11 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/gabrielseibel1/gaef/auth"
-	"github.com/gabrielseibel1/gaef/client/user"
+	"github.com/gabrielseibel1/gaef/blob"
+	"github.com/gabrielseibel1/gaef/blob/picture"
+	userClient "github.com/gabrielseibel1/gaef/client/user"
 	"github.com/gabrielseibel1/gaef/group/handler"
+	"github.com/gabrielseibel1/gaef/group/outbox"
 	"github.com/gabrielseibel1/gaef/group/store"
+	"github.com/gabrielseibel1/gaef/messenger"
 	"log"
 	"net/http"
 	"os"
@@ -22,9 +27,18 @@ func main() {
 	// read environment variables
 	port := os.Getenv("PORT")
 	userServiceURL := os.Getenv("USER_SERVICE_URL")
+	amqpURI := os.Getenv("AMQP_URI")
+	amqpExchangeUpdates := os.Getenv("AMQP_EXCHANGE_UPDATES")
+	amqpExchangeDeletes := os.Getenv("AMQP_EXCHANGE_DELETES")
+	amqpExchangeErasures := os.Getenv("AMQP_EXCHANGE_ERASURES")
+	amqpQueuePrefix := os.Getenv("AMQP_QUEUE_PREFIX")
+	amqpExchangeGroupUpdates := os.Getenv("AMQP_EXCHANGE_GROUP_UPDATES")
+	amqpExchangeGroupDeletes := os.Getenv("AMQP_EXCHANGE_GROUP_DELETES")
 	dbURI := os.Getenv("MONGODB_URI")
 	dbName := os.Getenv("MONGODB_DATABASE")
 	collectionName := os.Getenv("MONGODB_COLLECTION")
+	publicURL := os.Getenv("PUBLIC_URL")
+	picturesDir := os.Getenv("PICTURES_DIR")
 
 	// connect to mongoDB
 	serverAPIOptions := options.ServerAPI(options.ServerAPIVersion1)
@@ -50,9 +64,88 @@ func main() {
 	}
 
 	// instantiate and inject dependencies
-	a := auth.NewMiddlewareGenerator(user.Client{URL: userServiceURL}, "userID", "token")
+	// verify tokens locally, with the keys published by the user service
+	keys := auth.NewRemoteKeySet(userServiceURL+".well-known/jwks.json", 15*time.Minute, 10*time.Second)
+	a := auth.NewMiddlewareGenerator(auth.NewJWTReader(keys), auth.NewRemoteAPIKeyExchanger(userServiceURL+"api-keys/token"), "userID", "token")
 	s := store.New(client.Database(dbName).Collection(collectionName))
-	h := handler.New(s, s, s, s, s, s, s)
+	if err := s.CreateSearchIndex(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := s.CreateGeoIndex(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	is := store.NewInvitationStore(client.Database(dbName).Collection(invitationsCollectionName))
+	if err := is.CreateInvitationIndex(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	js := store.NewJoinRequestStore(client.Database(dbName).Collection(joinRequestsCollectionName))
+	if err := js.CreateJoinRequestIndex(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	// keep pictures in a directory if set, in GridFS otherwise
+	var pictureStore blob.Store = blob.NewGridFSStore(client.Database(dbName), picturesBucketName)
+	if picturesDir != "" {
+		pictureStore, err = blob.NewLocalStore(picturesDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	p := picture.New(pictureStore, publicURL+picturesPath, maxPictureSize, thumbnailSize)
+
+	// publish group events for the services that embed copies of groups
+	connection, err := messenger.Dial(amqpURI)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, exchange := range []string{amqpExchangeErasures, amqpExchangeGroupUpdates, amqpExchangeGroupDeletes} {
+		if err := messenger.DeclareExchange(connection, exchange); err != nil {
+			log.Fatal(err)
+		}
+	}
+	gm := messenger.NewGroupMessenger(json.Marshal, amqpSource, amqpExchangeGroupUpdates, amqpExchangeGroupDeletes, connection)
+
+	h := handler.New(handler.Dependencies{
+		Groups: handler.Groups{
+			LeaderChecker:       s,
+			Creator:             s,
+			ParticipatingReader: s,
+			LeadingReader:       s,
+			Reader:              s,
+			Updater:             s,
+			Patcher:             s,
+			Deleter:             s,
+			Searcher:            s,
+			NearbyReader:        s,
+		},
+		Pictures: handler.Pictures{
+			Saver:   p,
+			Opener:  p,
+			Deleter: p,
+		},
+		Invitations: handler.Invitations{
+			Creator:       is,
+			InviteeReader: is,
+			GroupReader:   is,
+			Reader:        is,
+			Deleter:       is,
+		},
+		JoinRequests: handler.JoinRequests{
+			Creator:     js,
+			GroupReader: js,
+			Reader:      js,
+			Deleter:     js,
+		},
+		Membership: handler.Membership{
+			UserReader:            userClient.Client{URL: userServiceURL},
+			Adder:                 s,
+			Remover:               s,
+			LeaderPromoter:        s,
+			LeaderDemoter:         s,
+			LeadershipTransferrer: s,
+		},
+	})
+	rly := outbox.NewRelay(s, s, gm, gm, outboxRelayInterval, outboxRelayMaxBackoff)
+
 	handlers := handlers{
 		auth:                    a,
 		onlyLeaders:             h,
@@ -61,25 +154,70 @@ func main() {
 		readLeadingGroups:       h,
 		readGroup:               h,
 		updateGroup:             h,
+		patchGroup:              h,
 		deleteGroup:             h,
+		groupPicture:            h,
+		invitations:             h,
+		joinRequests:            h,
+		membership:              h,
+		searchGroups:            h,
+		nearbyGroups:            h,
 	}
 
+	// send events recorded in the outbox to the broker
+	go func() { log.Fatal(rly.Run(context.Background())) }()
+
+	// consume user events to keep embedded users in sync, confirming the erasure of deleted ones
+	msg := messenger.New(json.Marshal, amqpSource, amqpExchangeUpdates, amqpExchangeDeletes, amqpExchangeErasures, connection)
+	consumer := messenger.NewConsumer(json.Unmarshal, amqpQueuePrefix, amqpExchangeUpdates, amqpExchangeDeletes, amqpExchangeErasures, connection)
+	go messenger.KeepConsuming(context.Background(), "user updates", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return consumer.ConsumeUserUpdates(ctx, s)
+	})
+	go messenger.KeepConsuming(context.Background(), "user deletes", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return consumer.ConsumeUserDeletes(ctx, messenger.NewErasingDeleter(userDeleters{s, is, js}, msg, messenger.ServiceGroup))
+	})
+
+	// consume the deletes of groups to delete their invitations and join requests too, retrying until they are
+	groupConsumer := messenger.NewGroupConsumer(json.Unmarshal, amqpQueuePrefix, amqpExchangeGroupUpdates, amqpExchangeGroupDeletes, connection)
+	go messenger.KeepConsuming(context.Background(), "group deletes", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return groupConsumer.ConsumeGroupDeletes(ctx, groupDeleters{is, js})
+	})
+
 	// run http server
 	server := gin.Default()
 	groups := server.Group("/api/v0/groups")
 	groups.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	groups.GET("/pictures/:name", handlers.groupPicture.ReadPictureHandler())
 	authed := groups.Group("", handlers.auth.AuthMiddleware())
 	{
-		authed.POST("/", handlers.createGroup.CreateGroupHandler())
+		authed.POST("/", handlers.auth.VerifiedMiddleware(), handlers.createGroup.CreateGroupHandler())
+		authed.GET("/", handlers.searchGroups.SearchGroupsHandler())
+		authed.GET("/nearby", handlers.nearbyGroups.NearbyGroupsHandler())
 		authed.GET("/participating", handlers.readParticipatingGroups.ReadParticipatingGroupsHandler())
 		authed.GET("/leading", handlers.readLeadingGroups.ReadLeadingGroupsHandler())
+		authed.GET("/invitations", handlers.invitations.ReadInvitationsHandler())
+		authed.POST("/invitations/:invitationID/accept", handlers.invitations.AcceptInvitationHandler())
+		authed.POST("/invitations/:invitationID/reject", handlers.invitations.RejectInvitationHandler())
 		authed.GET("/:id", handlers.readGroup.ReadGroupHandler())
+		authed.POST("/:id/join-requests", handlers.auth.VerifiedMiddleware(), handlers.joinRequests.RequestToJoinHandler())
+		authed.POST("/:id/leave", handlers.membership.LeaveGroupHandler())
 
 		forLeaders := authed.Group("", handlers.onlyLeaders.OnlyLeadersMiddleware())
 		{
 			forLeaders.GET("/leading/:id", handlers.readGroup.ReadGroupHandler())
 			forLeaders.PUT("/:id", handlers.updateGroup.UpdateGroupHandler())
+			forLeaders.PATCH("/:id", handlers.patchGroup.PatchGroupHandler())
+			forLeaders.PUT("/:id/picture", handlers.groupPicture.UploadPictureHandler())
 			forLeaders.DELETE("/:id", handlers.deleteGroup.DeleteGroupHandler())
+			forLeaders.POST("/:id/invitations", handlers.invitations.InviteUserHandler())
+			forLeaders.GET("/:id/invitations", handlers.invitations.ReadGroupInvitationsHandler())
+			forLeaders.GET("/:id/join-requests", handlers.joinRequests.ReadJoinRequestsHandler())
+			forLeaders.POST("/:id/join-requests/:requestID/approve", handlers.joinRequests.ApproveJoinRequestHandler())
+			forLeaders.POST("/:id/join-requests/:requestID/deny", handlers.joinRequests.DenyJoinRequestHandler())
+			forLeaders.DELETE("/:id/members/:memberID", handlers.membership.RemoveMemberHandler())
+			forLeaders.PUT("/:id/leaders/:memberID", handlers.membership.PromoteMemberHandler())
+			forLeaders.DELETE("/:id/leaders/:memberID", handlers.membership.DemoteLeaderHandler())
+			forLeaders.POST("/:id/leadership", handlers.membership.TransferLeadershipHandler())
 		}
 	}
 	log.Fatal(server.Run(fmt.Sprintf("0.0.0.0:%s", port)))
@@ -93,11 +231,19 @@ type handlers struct {
 	readLeadingGroups       ReadLeadingGroupsHandler
 	readGroup               ReadGroupHandler
 	updateGroup             UpdateGroupHandler
+	patchGroup              PatchGroupHandler
 	deleteGroup             DeleteGroupHandler
+	groupPicture            GroupPictureHandler
+	invitations             InvitationsHandler
+	joinRequests            JoinRequestsHandler
+	membership              MembershipHandler
+	searchGroups            SearchGroupsHandler
+	nearbyGroups            NearbyGroupsHandler
 }
 
 type AuthMiddleware interface {
 	AuthMiddleware() gin.HandlerFunc
+	VerifiedMiddleware() gin.HandlerFunc
 }
 type OnlyLeadersMiddleware interface {
 	OnlyLeadersMiddleware() gin.HandlerFunc
@@ -105,6 +251,12 @@ type OnlyLeadersMiddleware interface {
 type CreateGroupHandler interface {
 	CreateGroupHandler() gin.HandlerFunc
 }
+type SearchGroupsHandler interface {
+	SearchGroupsHandler() gin.HandlerFunc
+}
+type NearbyGroupsHandler interface {
+	NearbyGroupsHandler() gin.HandlerFunc
+}
 type ReadParticipatingGroupsHandler interface {
 	ReadParticipatingGroupsHandler() gin.HandlerFunc
 }
@@ -117,6 +269,71 @@ type ReadGroupHandler interface {
 type UpdateGroupHandler interface {
 	UpdateGroupHandler() gin.HandlerFunc
 }
+type PatchGroupHandler interface {
+	PatchGroupHandler() gin.HandlerFunc
+}
 type DeleteGroupHandler interface {
 	DeleteGroupHandler() gin.HandlerFunc
 }
+type GroupPictureHandler interface {
+	UploadPictureHandler() gin.HandlerFunc
+	ReadPictureHandler() gin.HandlerFunc
+}
+type InvitationsHandler interface {
+	InviteUserHandler() gin.HandlerFunc
+	ReadGroupInvitationsHandler() gin.HandlerFunc
+	ReadInvitationsHandler() gin.HandlerFunc
+	AcceptInvitationHandler() gin.HandlerFunc
+	RejectInvitationHandler() gin.HandlerFunc
+}
+type JoinRequestsHandler interface {
+	RequestToJoinHandler() gin.HandlerFunc
+	ReadJoinRequestsHandler() gin.HandlerFunc
+	ApproveJoinRequestHandler() gin.HandlerFunc
+	DenyJoinRequestHandler() gin.HandlerFunc
+}
+type MembershipHandler interface {
+	LeaveGroupHandler() gin.HandlerFunc
+	RemoveMemberHandler() gin.HandlerFunc
+	PromoteMemberHandler() gin.HandlerFunc
+	DemoteLeaderHandler() gin.HandlerFunc
+	TransferLeadershipHandler() gin.HandlerFunc
+}
+
+// userDeleters purges users from every collection that holds them
+type userDeleters []messenger.UserDeleter
+
+func (ds userDeleters) DeleteUser(ctx context.Context, userID string) error {
+	for _, d := range ds {
+		if err := d.DeleteUser(ctx, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupDeleters purges deleted groups from every collection that refers to them
+type groupDeleters []messenger.GroupDeleter
+
+func (ds groupDeleters) DeleteGroup(ctx context.Context, groupID string) error {
+	for _, d := range ds {
+		if err := d.DeleteGroup(ctx, groupID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	amqpSource                 = "gaef-group-service"
+	outboxRelayInterval        = time.Second
+	outboxRelayMaxBackoff      = time.Minute
+	amqpReconnectDelay         = time.Second
+	amqpMaxReconnectDelay      = time.Minute
+	invitationsCollectionName  = "invitations"
+	joinRequestsCollectionName = "join-requests"
+	picturesBucketName         = "pictures"
+	picturesPath               = "/api/v0/groups/pictures/"
+	maxPictureSize             = 5 << 20
+	thumbnailSize              = 128
+)
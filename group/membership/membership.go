@@ -0,0 +1,12 @@
+// Package membership tells apart the reasons why the members or leaders of a group were not changed
+package membership
+
+import "errors"
+
+var (
+	// ErrNoSuchGroup is the error of changing the members or leaders of a group that doesn't exist
+	ErrNoSuchGroup = errors.New("no such group")
+	// ErrConflict is the error of a change that the members or leaders of the group don't allow anymore,
+	// such as removing its last leader, as they changed since they were read
+	ErrConflict = errors.New("group changed meanwhile, try again")
+)
@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"context"
+	"github.com/gabrielseibel1/gaef/messenger"
+	"github.com/gabrielseibel1/gaef/types"
+	"time"
+)
+
+type EventType string
+
+const (
+	GroupUpdated EventType = "group-updated"
+	GroupDeleted EventType = "group-deleted"
+)
+
+// Event is a message waiting to be sent to the broker, stored in the group it is about together with the change that caused it
+type Event struct {
+	ID        string    `bson:"id"`
+	Type      EventType `bson:"type"`
+	CreatedAt time.Time `bson:"createdAt"`
+	// Group is the group the event is stored in, as it is when the event is read.
+	// Updates carry the latest state of the group, which is what the services that embed copies of it keep.
+	Group types.Group `bson:"-"`
+}
+
+func NewEvent(eventType EventType) Event {
+	return Event{
+		ID:        messenger.NewEventID(),
+		Type:      eventType,
+		CreatedAt: time.Now(),
+	}
+}
+
+// dependencies
+
+type UpdateMessenger interface {
+	SendGroupUpdatedMessage(ctx context.Context, eventID string, group types.Group) error
+}
+type DeleteMessenger interface {
+	SendGroupDeletedMessage(ctx context.Context, eventID, groupID string) error
+}
+
+// implementation
+
+// NewRelay creates a relay of the outbox of groups, which sends the events of each group in order
+func NewRelay(
+	reader messenger.PendingEventsReader[Event],
+	acknowledger messenger.EventAcknowledger[Event],
+	updateMessenger UpdateMessenger,
+	deleteMessenger DeleteMessenger,
+	interval time.Duration,
+	maxBackoff time.Duration,
+) messenger.Relay[Event] {
+	key := func(event Event) string { return event.Group.ID }
+	publish := func(ctx context.Context, event Event) error {
+		switch event.Type {
+		case GroupUpdated:
+			return updateMessenger.SendGroupUpdatedMessage(ctx, event.ID, event.Group)
+		case GroupDeleted:
+			return deleteMessenger.SendGroupDeletedMessage(ctx, event.ID, event.Group.ID)
+		}
+		return nil
+	}
+	return messenger.NewRelay[Event](reader, acknowledger, key, publish, interval, maxBackoff)
+}
@@ -0,0 +1,113 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gabrielseibel1/gaef/group/outbox"
+	"github.com/gabrielseibel1/gaef/messenger"
+	"github.com/gabrielseibel1/gaef/types"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mockPublisher struct {
+	// receive
+	exchanges  []string
+	messageIDs []string
+
+	// return
+	errs []error
+}
+
+func (m *mockPublisher) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	m.exchanges = append(m.exchanges, exchange)
+	m.messageIDs = append(m.messageIDs, msg.MessageId)
+	if len(m.errs) == 0 {
+		return nil
+	}
+	err := m.errs[0]
+	m.errs = m.errs[1:]
+	return err
+}
+
+type mockStore struct {
+	// receive
+	acknowledged []string
+
+	// return
+	events []outbox.Event
+}
+
+func (m *mockStore) ReadPendingEvents(ctx context.Context) ([]outbox.Event, error) {
+	return m.events, nil
+}
+
+func (m *mockStore) AcknowledgeEvent(ctx context.Context, event outbox.Event) error {
+	m.acknowledged = append(m.acknowledged, event.ID)
+	return nil
+}
+
+var (
+	dummyError  = errors.New("dummy-error")
+	dummyGroup1 = types.Group{ID: "dummy-id-1", Name: "dummy-name-1"}
+	dummyGroup2 = types.Group{ID: "dummy-id-2", Name: "dummy-name-2"}
+	dummyEvents = []outbox.Event{
+		{ID: "1", Type: outbox.GroupUpdated, Group: dummyGroup1},
+		{ID: "2", Type: outbox.GroupUpdated, Group: dummyGroup2},
+		{ID: "3", Type: outbox.GroupDeleted, Group: dummyGroup1},
+	}
+)
+
+// the relay itself is tested in messenger, this tests the events of groups are published where they belong, in order
+func TestNewRelay(t *testing.T) {
+	tests := []struct {
+		name             string
+		store            *mockStore
+		publisher        *mockPublisher
+		wantErr          bool
+		wantExchanges    []string
+		wantMessageIDs   []string
+		wantAcknowledged []string
+	}{
+		{
+			name:             "drain ok",
+			store:            &mockStore{events: dummyEvents},
+			publisher:        &mockPublisher{},
+			wantErr:          false,
+			wantExchanges:    []string{"updates", "updates", "deletes"},
+			wantMessageIDs:   []string{"1", "2", "3"},
+			wantAcknowledged: []string{"1", "2", "3"},
+		},
+		{
+			name:             "drain publisher error keeps events of the group in order",
+			store:            &mockStore{events: dummyEvents},
+			publisher:        &mockPublisher{errs: []error{dummyError}},
+			wantErr:          true,
+			wantExchanges:    []string{"updates", "updates"},
+			wantMessageIDs:   []string{"1", "2"},
+			wantAcknowledged: []string{"2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := messenger.NewGroupMessenger(json.Marshal, "dummy-source", "updates", "deletes", tt.publisher)
+			r := outbox.NewRelay(tt.store, tt.store, m, m, time.Millisecond, time.Millisecond)
+
+			if err := r.Drain(context.TODO()); (err != nil) != tt.wantErr {
+				t.Errorf("Drain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got, want := tt.publisher.exchanges, tt.wantExchanges; !reflect.DeepEqual(got, want) {
+				t.Errorf("Drain() published to %v, want %v", got, want)
+			}
+			if got, want := tt.publisher.messageIDs, tt.wantMessageIDs; !reflect.DeepEqual(got, want) {
+				t.Errorf("Drain() published message ids %v, want %v", got, want)
+			}
+			if got, want := tt.store.acknowledged, tt.wantAcknowledged; !reflect.DeepEqual(got, want) {
+				t.Errorf("Drain() acknowledged %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package search
+
+import "github.com/gabrielseibel1/gaef/types/cursor"
+
+// Query is a page of a search for public groups, by words in their name, description or tags, and by tags and category
+type Query struct {
+	// Text matches whole words, ranking groups by relevance, or any group if empty
+	Text     string
+	Tags     []string // groups must have all of them
+	Category string
+	After    *cursor.Cursor
+	Limit    int64
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/gabrielseibel1/gaef/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoInvitationStore struct {
+	collection *mongo.Collection
+}
+
+func NewInvitationStore(collection *mongo.Collection) *MongoInvitationStore {
+	return &MongoInvitationStore{
+		collection: collection,
+	}
+}
+
+// CreateInvitationIndex keeps a user from having more than one pending invitation to the same group
+func (s MongoInvitationStore) CreateInvitationIndex(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "groupId", Value: 1}, {Key: "inviteeId", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("invitation-group-invitee"),
+	})
+	return err
+}
+
+func (s MongoInvitationStore) CreateInvitation(ctx context.Context, invitation types.Invitation) (types.Invitation, error) {
+	invitation.ID = ""
+	res, err := s.collection.InsertOne(ctx, invitation)
+	if err != nil {
+		return types.Invitation{}, err
+	}
+	invitation.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return invitation, nil
+}
+
+// ReadInvitations reads the pending invitations of a user, newest first
+func (s MongoInvitationStore) ReadInvitations(ctx context.Context, inviteeID string) ([]types.Invitation, error) {
+	return s.find(ctx, bson.M{"inviteeId": inviteeID})
+}
+
+// ReadGroupInvitations reads the pending invitations to a group, newest first
+func (s MongoInvitationStore) ReadGroupInvitations(ctx context.Context, groupID string) ([]types.Invitation, error) {
+	return s.find(ctx, bson.M{"groupId": groupID})
+}
+
+func (s MongoInvitationStore) find(ctx context.Context, filter bson.M) ([]types.Invitation, error) {
+	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	invitations := make([]types.Invitation, 0)
+	if err := cursor.All(ctx, &invitations); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// ReadInvitation reads a pending invitation, given its ID, if it is for the given user
+func (s MongoInvitationStore) ReadInvitation(ctx context.Context, id, inviteeID string) (types.Invitation, error) {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.Invitation{}, err
+	}
+
+	res := s.collection.FindOne(ctx, bson.M{"_id": hexID, "inviteeId": inviteeID})
+	if res.Err() != nil {
+		return types.Invitation{}, res.Err()
+	}
+
+	var invitation types.Invitation
+	err = res.Decode(&invitation)
+	return invitation, err
+}
+
+// DeleteInvitation deletes a pending invitation, given its ID, if it is for the given user
+func (s MongoInvitationStore) DeleteInvitation(ctx context.Context, id, inviteeID string) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	res, err := s.collection.DeleteOne(ctx, bson.M{"_id": hexID, "inviteeId": inviteeID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return errors.New("no such invitation")
+	}
+	return nil
+}
+
+// DeleteGroup deletes the invitations to a group, once it is deleted
+func (s MongoInvitationStore) DeleteGroup(ctx context.Context, groupID string) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"groupId": groupID})
+	return err
+}
+
+// DeleteUser deletes the invitations of a user and the ones it sent
+func (s MongoInvitationStore) DeleteUser(ctx context.Context, userID string) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"$or": bson.A{
+		bson.M{"inviteeId": userID},
+		bson.M{"inviter._id": userID},
+	}})
+	return err
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/gabrielseibel1/gaef/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoJoinRequestStore struct {
+	collection *mongo.Collection
+}
+
+func NewJoinRequestStore(collection *mongo.Collection) *MongoJoinRequestStore {
+	return &MongoJoinRequestStore{
+		collection: collection,
+	}
+}
+
+// CreateJoinRequestIndex keeps a user from having more than one pending request to join the same group
+func (s MongoJoinRequestStore) CreateJoinRequestIndex(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "groupId", Value: 1}, {Key: "requester._id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("join-request-group-requester"),
+	})
+	return err
+}
+
+func (s MongoJoinRequestStore) CreateJoinRequest(ctx context.Context, request types.JoinRequest) (types.JoinRequest, error) {
+	request.ID = ""
+	res, err := s.collection.InsertOne(ctx, request)
+	if err != nil {
+		return types.JoinRequest{}, err
+	}
+	request.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return request, nil
+}
+
+// ReadJoinRequests reads the pending requests to join a group, oldest first
+func (s MongoJoinRequestStore) ReadJoinRequests(ctx context.Context, groupID string) ([]types.JoinRequest, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"groupId": groupID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	requests := make([]types.JoinRequest, 0)
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// ReadJoinRequest reads a pending request, given its ID, if it is to join the given group
+func (s MongoJoinRequestStore) ReadJoinRequest(ctx context.Context, id, groupID string) (types.JoinRequest, error) {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.JoinRequest{}, err
+	}
+
+	res := s.collection.FindOne(ctx, bson.M{"_id": hexID, "groupId": groupID})
+	if res.Err() != nil {
+		return types.JoinRequest{}, res.Err()
+	}
+
+	var request types.JoinRequest
+	err = res.Decode(&request)
+	return request, err
+}
+
+// DeleteJoinRequest deletes a pending request, given its ID, if it is to join the given group
+func (s MongoJoinRequestStore) DeleteJoinRequest(ctx context.Context, id, groupID string) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	res, err := s.collection.DeleteOne(ctx, bson.M{"_id": hexID, "groupId": groupID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return errors.New("no such join request")
+	}
+	return nil
+}
+
+// DeleteGroup deletes the requests to join a group, once it is deleted
+func (s MongoJoinRequestStore) DeleteGroup(ctx context.Context, groupID string) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"groupId": groupID})
+	return err
+}
+
+// DeleteUser deletes the requests of a user to join groups
+func (s MongoJoinRequestStore) DeleteUser(ctx context.Context, userID string) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"requester._id": userID})
+	return err
+}
@@ -3,11 +3,19 @@ package store
 import (
 	"context"
 	"errors"
+	"github.com/gabrielseibel1/gaef/group/membership"
+	"github.com/gabrielseibel1/gaef/group/outbox"
+	"github.com/gabrielseibel1/gaef/group/search"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/cursor"
+	"github.com/gabrielseibel1/gaef/types/etag"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
 )
 
 type MongoStore struct {
@@ -36,7 +44,8 @@ func (s MongoStore) IsLeader(ctx context.Context, userID string, groupID string)
 
 func (s MongoStore) CreateGroup(ctx context.Context, group types.Group) (types.Group, error) {
 	group.ID = ""
-	res, err := s.collection.InsertOne(ctx, group)
+	group.Version = 1
+	res, err := s.collection.InsertOne(ctx, newGroupDocument(group))
 	if err != nil {
 		return types.Group{}, err
 	}
@@ -46,13 +55,13 @@ func (s MongoStore) CreateGroup(ctx context.Context, group types.Group) (types.G
 }
 
 func (s MongoStore) ReadParticipatingGroups(ctx context.Context, userID string) ([]types.Group, error) {
-	cursor, err := s.collection.Find(ctx, bson.M{
+	cursor, err := s.collection.Find(ctx, live(bson.M{
 		"members": bson.M{
 			"$elemMatch": bson.M{
 				"_id": userID,
 			},
 		},
-	})
+	}))
 	if err != nil {
 		return nil, err
 	}
@@ -66,13 +75,13 @@ func (s MongoStore) ReadParticipatingGroups(ctx context.Context, userID string)
 }
 
 func (s MongoStore) ReadLeadingGroups(ctx context.Context, userID string) ([]types.Group, error) {
-	cursor, err := s.collection.Find(ctx, bson.M{
+	cursor, err := s.collection.Find(ctx, live(bson.M{
 		"leaders": bson.M{
 			"$elemMatch": bson.M{
 				"_id": userID,
 			},
 		},
-	})
+	}))
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +100,7 @@ func (s MongoStore) ReadGroup(ctx context.Context, id string) (types.Group, erro
 		return types.Group{}, err
 	}
 
-	res := s.collection.FindOne(ctx, bson.M{"_id": hexID})
+	res := s.collection.FindOne(ctx, live(bson.M{"_id": hexID}))
 	if res.Err() != nil {
 		return types.Group{}, res.Err()
 	}
@@ -104,34 +113,570 @@ func (s MongoStore) ReadGroup(ctx context.Context, id string) (types.Group, erro
 	return group, err
 }
 
+// UpdateGroup replaces the replaceable fields of a group if it is at the version of the given one, any if zero,
+// returning it as stored
 func (s MongoStore) UpdateGroup(ctx context.Context, group types.Group) (types.Group, error) {
 	hexID, err := primitive.ObjectIDFromHex(group.ID)
 	if err != nil {
 		return types.Group{}, err
 	}
+	version := group.Version
 	group.ID = "" // so that mongo doesn't think we are updating the id
-	res, err := s.collection.UpdateOne(ctx, bson.M{"_id": hexID}, bson.M{"$set": group})
+	group.Version = 0
+	update, err := patchUpdate(newGroupDocument(group), replaceableGroupFields)
 	if err != nil {
 		return types.Group{}, err
 	}
-	if res.MatchedCount == 0 {
-		return types.Group{}, errors.New("no such group")
+	update["$inc"] = bson.M{"version": 1}
+	return s.updateVersioned(ctx, hexID, version, update)
+}
+
+// replaceableGroupFields are the fields that replacing a group sets. They leave out its picture, only set by uploads,
+// and its members and leaders, only changed by the membership endpoints, so that replacing can't bypass them.
+var replaceableGroupFields = []string{"name", "description", "visibility", "tags", "category", "location", "geo"}
+
+// PatchGroup sets only the fields of a group at the given dot separated paths, unsetting those it doesn't have,
+// if it is at the version of the given one, any if zero
+func (s MongoStore) PatchGroup(ctx context.Context, group types.Group, paths []string) (types.Group, error) {
+	hexID, err := primitive.ObjectIDFromHex(group.ID)
+	if err != nil {
+		return types.Group{}, err
 	}
-	group.ID = hexID.Hex()
-	return group, nil
+	version := group.Version
+	group.ID = "" // so that mongo doesn't think we are updating the id
+	group.Version = 0
+	for _, path := range paths {
+		if path == "location" || strings.HasPrefix(path, "location.") {
+			// keep the point of geospatial queries where the home base is
+			paths = append(paths[:len(paths):len(paths)], "geo")
+			break
+		}
+	}
+	update, err := patchUpdate(newGroupDocument(group), paths)
+	if err != nil {
+		return types.Group{}, err
+	}
+	update["$inc"] = bson.M{"version": 1}
+	return s.updateVersioned(ctx, hexID, version, update)
+}
+
+// updateVersioned updates a group if it is at the given version, any if zero, as groups stored before versions have none,
+// recording a group-updated event
+func (s MongoStore) updateVersioned(ctx context.Context, hexID primitive.ObjectID, version int64, update bson.M) (types.Group, error) {
+	res := s.collection.FindOneAndUpdate(ctx, live(versioned(bson.M{"_id": hexID}, version)), recorded(update), options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() == mongo.ErrNoDocuments {
+		return types.Group{}, s.unmatched(ctx, hexID, version)
+	}
+	if res.Err() != nil {
+		return types.Group{}, res.Err()
+	}
+
+	var group types.Group
+	err := res.Decode(&group)
+	return group, err
+}
+
+// AddMember pushes a user into the members of a group, unless it is a member already
+func (s MongoStore) AddMember(ctx context.Context, groupID string, user types.User) (types.Group, error) {
+	hexID, err := primitive.ObjectIDFromHex(groupID)
+	if err != nil {
+		return types.Group{}, err
+	}
+
+	res := s.collection.FindOneAndUpdate(
+		ctx,
+		live(bson.M{"_id": hexID, "members._id": bson.M{"$ne": user.ID}}),
+		recorded(bson.M{"$push": bson.M{"members": user}, "$inc": bson.M{"version": 1}}),
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+	if res.Err() == mongo.ErrNoDocuments {
+		// either there is no such group or the user is in it already
+		return s.ReadGroup(ctx, groupID)
+	}
+	if res.Err() != nil {
+		return types.Group{}, res.Err()
+	}
+
+	var group types.Group
+	err = res.Decode(&group)
+	return group, err
+}
+
+// RemoveMember pulls a user out of the members of a group, and out of its leaders, unless it is the last leader
+func (s MongoStore) RemoveMember(ctx context.Context, groupID, userID string) (types.Group, error) {
+	return s.updateMembership(ctx, groupID,
+		bson.M{
+			"members._id": userID,
+			"$or": bson.A{
+				bson.M{"leaders._id": bson.M{"$ne": userID}},
+				bson.M{"leaders.1": bson.M{"$exists": true}},
+			},
+		},
+		recorded(bson.M{
+			"$pull": bson.M{
+				"members": bson.M{"_id": userID},
+				"leaders": bson.M{"_id": userID},
+			},
+			"$inc": bson.M{"version": 1},
+		}),
+	)
+}
+
+// PromoteLeader pushes a member of a group into its leaders, unless it is a leader already
+func (s MongoStore) PromoteLeader(ctx context.Context, groupID string, member types.User) (types.Group, error) {
+	return s.updateMembership(ctx, groupID,
+		bson.M{"members._id": member.ID, "leaders._id": bson.M{"$ne": member.ID}},
+		recorded(bson.M{"$push": bson.M{"leaders": member}, "$inc": bson.M{"version": 1}}),
+	)
+}
+
+// DemoteLeader pulls a user out of the leaders of a group, unless it is the last leader
+func (s MongoStore) DemoteLeader(ctx context.Context, groupID, userID string) (types.Group, error) {
+	return s.updateMembership(ctx, groupID,
+		bson.M{"leaders._id": userID, "leaders.1": bson.M{"$exists": true}},
+		recorded(bson.M{"$pull": bson.M{"leaders": bson.M{"_id": userID}}, "$inc": bson.M{"version": 1}}),
+	)
 }
 
-func (s MongoStore) DeleteGroup(ctx context.Context, id string) error {
+// TransferLeadership replaces a leader of a group with one of its members, in a single update
+func (s MongoStore) TransferLeadership(ctx context.Context, groupID, leaderID string, member types.User) (types.Group, error) {
+	return s.updateMembership(ctx, groupID,
+		bson.M{"leaders._id": leaderID, "members._id": member.ID},
+		recordedPipeline(bson.M{
+			"leaders": bson.M{"$concatArrays": bson.A{
+				bson.M{"$filter": bson.M{
+					"input": "$leaders",
+					"cond": bson.M{"$and": bson.A{
+						bson.M{"$ne": bson.A{"$$this._id", leaderID}},
+						bson.M{"$ne": bson.A{"$$this._id", member.ID}},
+					}},
+				}},
+				// literal, so that fields of the user are never taken for expressions
+				bson.M{"$literal": bson.A{member}},
+			}},
+		}),
+	)
+}
+
+// updateMembership updates the members or leaders of a group if it matches the filter, which keeps the update
+// from breaking the invariants that leaders are members and that there is a leader.
+// It fails with membership.ErrNoSuchGroup if there is no such group and with membership.ErrConflict if it doesn't match.
+func (s MongoStore) updateMembership(ctx context.Context, groupID string, filter bson.M, update interface{}) (types.Group, error) {
+	hexID, err := primitive.ObjectIDFromHex(groupID)
+	if err != nil {
+		return types.Group{}, err
+	}
+	filter["_id"] = hexID
+
+	res := s.collection.FindOneAndUpdate(ctx, live(filter), update, options.FindOneAndUpdate().SetReturnDocument(options.After))
+	if res.Err() == mongo.ErrNoDocuments {
+		n, err := s.collection.CountDocuments(ctx, live(bson.M{"_id": hexID}), options.Count().SetLimit(1))
+		if err != nil {
+			return types.Group{}, err
+		}
+		if n == 0 {
+			return types.Group{}, membership.ErrNoSuchGroup
+		}
+		return types.Group{}, membership.ErrConflict
+	}
+	if res.Err() != nil {
+		return types.Group{}, res.Err()
+	}
+
+	var group types.Group
+	err = res.Decode(&group)
+	return group, err
+}
+
+// DeleteGroup marks a group as deleted if it is at the given version, any if zero, and records a group-deleted event, atomically.
+// The document is removed once the outbox is drained, see AcknowledgeEvent.
+func (s MongoStore) DeleteGroup(ctx context.Context, id string, version int64) error {
 	hexID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
-	res, err := s.collection.DeleteOne(ctx, bson.M{"_id": hexID})
+	res, err := s.collection.UpdateOne(
+		ctx,
+		live(versioned(bson.M{"_id": hexID}, version)),
+		bson.M{
+			"$set":  bson.M{"deleted": true},
+			"$push": bson.M{"outbox": outbox.NewEvent(outbox.GroupDeleted)},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return s.unmatched(ctx, hexID, version)
+	}
+	return nil
+}
+
+// unmatched tells why no group matched a versioned filter: it is at another version than the given one, or there is no such group
+func (s MongoStore) unmatched(ctx context.Context, hexID primitive.ObjectID, version int64) error {
+	if version == 0 {
+		return errors.New("no such group")
+	}
+	n, err := s.collection.CountDocuments(ctx, live(bson.M{"_id": hexID}), options.Count().SetLimit(1))
 	if err != nil {
 		return err
 	}
-	if res.DeletedCount == 0 {
+	if n == 0 {
 		return errors.New("no such group")
 	}
+	return etag.ErrVersionMismatch
+}
+
+// ReadPendingEvents reads the events in the outboxes of all groups, each along with the group as it is now
+func (s MongoStore) ReadPendingEvents(ctx context.Context) ([]outbox.Event, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"outbox.0": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		types.Group `bson:",inline"`
+		Outbox      []outbox.Event `bson:"outbox"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	var events []outbox.Event
+	for _, doc := range docs {
+		for _, event := range doc.Outbox {
+			event.Group = doc.Group
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// AcknowledgeEvent takes a sent event out of the outbox of its group
+func (s MongoStore) AcknowledgeEvent(ctx context.Context, event outbox.Event) error {
+	hexID, err := primitive.ObjectIDFromHex(event.Group.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.collection.UpdateOne(ctx, bson.M{"_id": hexID}, bson.M{"$pull": bson.M{"outbox": bson.M{"id": event.ID}}})
+	if err != nil {
+		return err
+	}
+
+	// a deleted group is only removed after all of its events are sent
+	_, err = s.collection.DeleteOne(ctx, bson.M{"_id": hexID, "deleted": true, "outbox": bson.M{"$size": 0}})
+	return err
+}
+
+// CreateSearchIndex creates the text index that group searches need, if it doesn't exist yet
+func (s MongoStore) CreateSearchIndex(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "name", Value: "text"}, {Key: "tags", Value: "text"}, {Key: "description", Value: "text"}},
+		Options: options.Index().SetName("group-search").SetWeights(bson.M{
+			"name":        10,
+			"tags":        5,
+			"description": 1,
+		}),
+	})
+	return err
+}
+
+// CreateGeoIndex creates the geospatial index that searches for nearby groups need, if it doesn't exist yet
+func (s MongoStore) CreateGeoIndex(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "geo", Value: "2dsphere"}},
+		Options: options.Index().SetName("group-geo"),
+	})
+	return err
+}
+
+// NearbyGroups reads the public groups with a home base within the radius of a point, nearest first
+func (s MongoStore) NearbyGroups(ctx context.Context, latitude, longitude, radiusKm float64, limit int64) ([]types.NearbyGroup, error) {
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$geoNear", Value: bson.M{
+			"near":          newGeoPoint(types.Location{Latitude: latitude, Longitude: longitude}),
+			"key":           "geo",
+			"distanceField": "distance",
+			"maxDistance":   radiusKm * 1000,
+			"spherical":     true,
+			"query":         live(bson.M{"visibility": types.GroupPublic}),
+		}}},
+		bson.D{{Key: "$limit", Value: limit}},
+		bson.D{{Key: "$project", Value: summaryProjection(bson.M{
+			"distanceKm": bson.M{"$divide": bson.A{"$distance", 1000}},
+		})}},
+	}
+
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]types.NearbyGroup, 0)
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// SearchGroups reads a page of the public groups that match the query, along with the cursor of the next page if there is one.
+// Groups matching text are ranked by relevance, others come in the order they were created.
+func (s MongoStore) SearchGroups(ctx context.Context, q search.Query) ([]types.GroupSummary, *cursor.Cursor, error) {
+	filter := live(bson.M{"visibility": types.GroupPublic})
+	if len(q.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": q.Tags}
+	}
+	if q.Category != "" {
+		filter["category"] = q.Category
+	}
+	fullText := q.Text != ""
+	pipeline := mongo.Pipeline{}
+	if fullText {
+		filter["$text"] = bson.M{"$search": q.Text}
+		pipeline = append(pipeline,
+			bson.D{{Key: "$match", Value: filter}},
+			bson.D{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+		)
+	} else {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: filter}})
+	}
+
+	if q.After != nil {
+		afterID, err := primitive.ObjectIDFromHex(q.After.ID)
+		if err != nil {
+			return nil, nil, cursor.ErrMalformed
+		}
+		after := bson.M{"_id": bson.M{"$gt": afterID}}
+		if fullText {
+			after = bson.M{"$or": bson.A{
+				bson.M{"score": bson.M{"$lt": q.After.Score}},
+				bson.M{"score": q.After.Score, "_id": bson.M{"$gt": afterID}},
+			}}
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: after}})
+	}
+
+	sort := bson.D{{Key: "_id", Value: 1}}
+	if fullText {
+		sort = bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}
+	}
+	// one more than the page, to tell if there is a next one
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: sort}},
+		bson.D{{Key: "$limit", Value: q.Limit + 1}},
+		bson.D{{Key: "$project", Value: summaryProjection(bson.M{"score": 1})}},
+	)
+
+	results, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, nil, err
+	}
+	var docs []struct {
+		types.GroupSummary `bson:",inline"`
+		Score              float64 `bson:"score"`
+	}
+	if err := results.All(ctx, &docs); err != nil {
+		return nil, nil, err
+	}
+
+	var next *cursor.Cursor
+	if int64(len(docs)) > q.Limit {
+		docs = docs[:q.Limit]
+		last := docs[len(docs)-1]
+		next = &cursor.Cursor{Score: last.Score, ID: last.ID}
+	}
+	groups := make([]types.GroupSummary, 0, len(docs))
+	for _, doc := range docs {
+		groups = append(groups, doc.GroupSummary)
+	}
+	return groups, next, nil
+}
+
+// summaryProjection projects groups into GroupSummary documents, along with the given fields
+func summaryProjection(fields bson.M) bson.M {
+	projection := bson.M{
+		"name":        1,
+		"pictureUrl":  1,
+		"description": 1,
+		"tags":        1,
+		"category":    1,
+		"location":    1,
+		"memberCount": bson.M{"$size": bson.M{"$ifNull": bson.A{"$members", bson.A{}}}},
+	}
+	for k, v := range fields {
+		projection[k] = v
+	}
+	return projection
+}
+
+// UpdateUser replaces the copies of a user in the groups it is in, recording a group-updated event for each group,
+// so that the services that embed copies of groups get the user updated too.
+// Copies at a newer version are kept, as updates delivered late must not undo later ones.
+func (s MongoStore) UpdateUser(ctx context.Context, user types.User) error {
+	replaced := func(field string) bson.M {
+		return bson.M{"$map": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$" + field, bson.A{}}},
+			"in": bson.M{"$cond": bson.A{
+				bson.M{"$and": bson.A{
+					bson.M{"$eq": bson.A{"$$this._id", user.ID}},
+					bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$$this.version", 0}}, user.Version}},
+				}},
+				// literal, so that fields of the user are never taken for expressions
+				bson.M{"$literal": user},
+				"$$this",
+			}},
+		}}
+	}
+	older := bson.M{"_id": user.ID, "version": bson.M{"$not": bson.M{"$gt": user.Version}}}
+	return s.updateGroupsOf(ctx, older, bson.M{
+		"members": replaced("members"),
+		"leaders": replaced("leaders"),
+	})
+}
+
+// DeleteUser pulls a user out of the members and leaders of the groups it is in, recording a group-updated event for each group.
+// Groups it was the last leader of are handed over to their oldest member, so that they keep having a leader.
+func (s MongoStore) DeleteUser(ctx context.Context, userID string) error {
+	without := func(field string) bson.M {
+		return bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$" + field, bson.A{}}},
+			"cond":  bson.M{"$ne": bson.A{"$$this._id", userID}},
+		}}
+	}
+	return s.updateGroupsOf(ctx, bson.M{"_id": userID}, bson.M{
+		"members": without("members"),
+		"leaders": bson.M{"$let": bson.M{
+			"vars": bson.M{"leaders": without("leaders"), "members": without("members")},
+			"in": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{bson.M{"$size": "$$leaders"}, 0}},
+				"$$leaders",
+				// members join in order, so the first one is the oldest
+				bson.M{"$slice": bson.A{"$$members", 1}},
+			}},
+		}},
+	})
+}
+
+// updateGroupsOf sets fields of each group with a member or leader that matches user, one group at a time,
+// so that each gets a group-updated event of its own
+func (s MongoStore) updateGroupsOf(ctx context.Context, user bson.M, set bson.M) error {
+	of := func() bson.M {
+		return live(bson.M{"$or": bson.A{bson.M{"members": bson.M{"$elemMatch": user}}, bson.M{"leaders": bson.M{"$elemMatch": user}}}})
+	}
+	cursor, err := s.collection.Find(ctx, of(), options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		filter := of()
+		filter["_id"] = doc.ID
+		_, err := s.collection.UpdateOne(ctx, filter, recordedPipeline(set))
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// live restricts a filter to groups that are not deleted, as deleted ones are kept until their events are sent
+func live(filter bson.M) bson.M {
+	filter["deleted"] = bson.M{"$ne": true}
+	return filter
+}
+
+// recorded adds a group-updated event to the outbox of the group in an update, so that it is sent once the update is made
+func recorded(update bson.M) bson.M {
+	push, _ := update["$push"].(bson.M)
+	if push == nil {
+		push = bson.M{}
+	}
+	push["outbox"] = outbox.NewEvent(outbox.GroupUpdated)
+	update["$push"] = push
+	return update
+}
+
+// recordedPipeline makes an update pipeline that sets fields of a group, bumps its version and adds a group-updated
+// event to its outbox, for updates that need expressions of the fields of the group
+func recordedPipeline(set bson.M) bson.A {
+	stage := bson.M{
+		"version": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+		"outbox": bson.M{"$concatArrays": bson.A{
+			bson.M{"$ifNull": bson.A{"$outbox", bson.A{}}},
+			bson.A{bson.M{"$literal": outbox.NewEvent(outbox.GroupUpdated)}},
+		}},
+	}
+	for k, v := range set {
+		stage[k] = v
+	}
+	return bson.A{bson.M{"$set": stage}}
+}
+
+// versioned restricts a filter to documents at the given version, unless it is zero
+func versioned(filter bson.M, version int64) bson.M {
+	if version != 0 {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// patchUpdate sets the fields of doc at the given dot separated paths, and unsets those that doc doesn't have
+func patchUpdate(doc interface{}, paths []string) (bson.M, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	set, unset := bson.M{}, bson.M{}
+	for _, path := range paths {
+		value, err := bson.Raw(raw).LookupErr(strings.Split(path, ".")...)
+		if errors.Is(err, bsoncore.ErrElementNotFound) {
+			unset[path] = ""
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		set[path] = value
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
+}
+
+// groupDocument is how groups are stored, with their home base also as a GeoJSON point for geospatial queries
+type groupDocument struct {
+	types.Group `bson:",inline"`
+	Geo         *geoPoint `bson:"geo,omitempty"`
+}
+
+func newGroupDocument(group types.Group) groupDocument {
+	doc := groupDocument{Group: group}
+	if group.Location != nil {
+		point := newGeoPoint(*group.Location)
+		doc.Geo = &point
+	}
+	return doc
+}
+
+// geoPoint is a GeoJSON point, which takes the longitude before the latitude
+type geoPoint struct {
+	Type        string    `bson:"type"`
+	Coordinates []float64 `bson:"coordinates"`
+}
+
+func newGeoPoint(location types.Location) geoPoint {
+	return geoPoint{Type: "Point", Coordinates: []float64{location.Longitude, location.Latitude}}
+}
@@ -0,0 +1,122 @@
+package messenger
+
+import (
+	"context"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"log"
+	"sync"
+	"time"
+)
+
+// Connection is a connection to the broker that is dialed again whenever it is closed.
+// Consumers open channels of their own on it, so that a channel closed by an error in one of them doesn't stop the others,
+// and it publishes on a channel of its own, opened again whenever it is closed.
+type Connection struct {
+	uri        string
+	mu         sync.Mutex
+	connection *amqp.Connection
+	publishing *amqp.Channel
+}
+
+// Dial connects to the broker at uri
+func Dial(uri string) (*Connection, error) {
+	c := &Connection{uri: uri}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.dial(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// dial returns the connection, dialing it again if it is closed. It must be called with c.mu held.
+func (c *Connection) dial() (*amqp.Connection, error) {
+	if c.connection != nil && !c.connection.IsClosed() {
+		return c.connection, nil
+	}
+	connection, err := amqp.Dial(c.uri)
+	if err != nil {
+		return nil, err
+	}
+	c.connection = connection
+	return connection, nil
+}
+
+// Channel opens a new channel, for a single consumer
+func (c *Connection) Channel() (Channel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	connection, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	channel, err := connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// PublishWithContext publishes on the channel for publishing, opening it again if it is closed
+func (c *Connection) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	channel, err := c.publishingChannel()
+	if err != nil {
+		return err
+	}
+	return channel.PublishWithContext(ctx, exchange, key, mandatory, immediate, msg)
+}
+
+func (c *Connection) publishingChannel() (*amqp.Channel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.publishing != nil && !c.publishing.IsClosed() {
+		return c.publishing, nil
+	}
+	connection, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	channel, err := connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	c.publishing = channel
+	return channel, nil
+}
+
+// ExchangeDeclare declares an exchange on a channel of its own, which is closed afterwards
+func (c *Connection) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	channel, err := c.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+	return channel.ExchangeDeclare(name, kind, durable, autoDelete, internal, noWait, args)
+}
+
+// KeepConsuming runs consume again whenever it stops, such as when its channel or connection is closed, until ctx is done.
+// It waits for reconnectDelay before running it again, twice as long each time it stops right away, up to maxReconnectDelay.
+func KeepConsuming(ctx context.Context, name string, reconnectDelay, maxReconnectDelay time.Duration, consume func(ctx context.Context) error) error {
+	wait := reconnectDelay
+	for {
+		started := time.Now()
+		err := consume(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if time.Since(started) > maxReconnectDelay {
+			wait = reconnectDelay
+		}
+		log.Printf("consuming %s: %s, consuming again in %s", name, err, wait)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+		if wait > maxReconnectDelay {
+			wait = maxReconnectDelay
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package messenger_test
+
+import (
+	"context"
+	"errors"
+	"github.com/gabrielseibel1/gaef/messenger"
+	"testing"
+	"time"
+)
+
+func TestKeepConsuming(t *testing.T) {
+	ctx, cancel := context.WithCancel(dummyCtx)
+	defer cancel()
+
+	// consuming stops twice, as if its channel was closed, and runs until ctx is done the third time
+	calls := 0
+	err := messenger.KeepConsuming(ctx, "dummy", time.Millisecond, 10*time.Millisecond, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return messenger.ErrDeliveriesClosed
+		}
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("KeepConsuming() error = %v, want %v", err, context.Canceled)
+	}
+	if got, want := calls, 3; got != want {
+		t.Errorf("KeepConsuming() calls = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,290 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gabrielseibel1/gaef/types"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"log"
+	"time"
+)
+
+type Consumer struct {
+	unmarshal              Unmarshaller
+	channels               ChannelOpener
+	queuePrefix            string
+	amqpUserUpdateExchange string
+	amqpUserDeleteExchange string
+	amqpUserEraseExchange  string
+}
+
+func NewConsumer(unmarshaller Unmarshaller, queuePrefix, amqpUserUpdateExchange, amqpUserDeleteExchange, amqpUserEraseExchange string, channels ChannelOpener) Consumer {
+	return Consumer{
+		unmarshal:              unmarshaller,
+		channels:               channels,
+		queuePrefix:            queuePrefix,
+		amqpUserUpdateExchange: amqpUserUpdateExchange,
+		amqpUserDeleteExchange: amqpUserDeleteExchange,
+		amqpUserEraseExchange:  amqpUserEraseExchange,
+	}
+}
+
+// ChannelOpener opens a channel for each consumer, such as Connection does
+type ChannelOpener interface {
+	Channel() (Channel, error)
+}
+
+type Channel interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	QueueUnbind(name, key, exchange string, args amqp.Table) error
+	QueueDelete(name string, ifUnused, ifEmpty, noWait bool) (int, error)
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	Close() error
+}
+
+type Unmarshaller func(data []byte, v any) error
+
+type UserUpdater interface {
+	UpdateUser(ctx context.Context, user types.User) error
+}
+
+type UserDeleter interface {
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+type ErasureRecorder interface {
+	RecordErasure(ctx context.Context, userID, service string) error
+}
+
+var (
+	ErrDeliveriesClosed = errors.New("deliveries channel closed")
+	errMalformedMessage = errors.New("malformed message")
+)
+
+const (
+	// MaxRedeliveries is how many times a message that failed is delivered again before it is dead-lettered
+	MaxRedeliveries = 5
+	// prefetchCount bounds the messages delivered but not yet acknowledged, as they are handled one at a time
+	prefetchCount = 16
+	// retryDelay is how long a message that failed waits in the retry queue before it is delivered again
+	retryDelay = 10 * time.Second
+	// queueSuffix names the quorum queues apart from the classic ones that were declared before them,
+	// as a queue can't be declared again with another type
+	queueSuffix = ".quorum"
+)
+
+// ConsumeUserUpdates blocks handling user updated messages until ctx is done or its channel is closed
+func (c Consumer) ConsumeUserUpdates(ctx context.Context, updater UserUpdater) error {
+	return c.consume(ctx, c.amqpUserUpdateExchange, TypeUserUpdated, func(data []byte) error {
+		var user types.User
+		if err := c.unmarshal(data, &user); err != nil || user.ID == "" {
+			return errMalformedMessage
+		}
+		return updater.UpdateUser(ctx, user)
+	})
+}
+
+// ConsumeUserDeletes blocks handling user deleted messages until ctx is done or its channel is closed
+func (c Consumer) ConsumeUserDeletes(ctx context.Context, deleter UserDeleter) error {
+	return c.consume(ctx, c.amqpUserDeleteExchange, TypeUserDeleted, func(data []byte) error {
+		var deleted UserDeleted
+		if err := c.unmarshal(data, &deleted); err != nil || deleted.ID == "" {
+			return errMalformedMessage
+		}
+		return deleter.DeleteUser(ctx, deleted.ID)
+	})
+}
+
+// ConsumeUserErasures blocks handling user erased messages until ctx is done or its channel is closed
+func (c Consumer) ConsumeUserErasures(ctx context.Context, recorder ErasureRecorder) error {
+	return c.consume(ctx, c.amqpUserEraseExchange, TypeUserErased, func(data []byte) error {
+		var erased UserErased
+		if err := c.unmarshal(data, &erased); err != nil || erased.ID == "" || erased.Service == "" {
+			return errMalformedMessage
+		}
+		return recorder.RecordErasure(ctx, erased.ID, erased.Service)
+	})
+}
+
+// consume declares the queues of exchange and handles its messages on a channel of its own, until ctx is done or the channel is closed
+func (c Consumer) consume(ctx context.Context, exchange, eventType string, handleData func(data []byte) error) error {
+	c.retireLegacyQueue(exchange)
+
+	channel, err := c.channels.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	// messages that can't be handled are kept aside in a queue of the consuming service, to be looked into
+	deadLetterExchange := c.queuePrefix + ".dead-letters"
+	if err := declareQueue(channel, deadLetterExchange, deadLetterExchange, nil); err != nil {
+		return err
+	}
+
+	// messages that failed wait in a retry queue, from which they expire back into the queue they came from
+	queue := c.queuePrefix + "." + exchange + queueSuffix
+	retryExchange := queue + ".retry"
+	err = declareQueue(channel, retryExchange, retryExchange, amqp.Table{
+		"x-message-ttl":             retryDelay.Milliseconds(),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": queue,
+	})
+	if err != nil {
+		return err
+	}
+
+	// one durable queue per consuming service, so that messages are kept while it is down
+	err = declareQueue(channel, exchange, queue, amqp.Table{
+		amqp.QueueTypeArg:        amqp.QueueTypeQuorum,
+		"x-dead-letter-exchange": retryExchange,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = channel.Qos(prefetchCount, 0, false)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := channel.Consume(
+		queue,
+		"",
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+	return c.handle(ctx, channel, queue, deadLetterExchange, deliveries, eventType, handleData)
+}
+
+// retireLegacyQueue unbinds the classic queue that consumed exchange before the quorum one, so that it stops collecting
+// messages nobody consumes, and deletes it if it is empty. Messages left in it are logged for operators to move.
+// It works on a channel of its own, as the broker closes the channel when there is no such queue or it isn't empty.
+func (c Consumer) retireLegacyQueue(exchange string) {
+	legacy := c.queuePrefix + "." + exchange
+	channel, err := c.channels.Channel()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	if _, err := channel.QueueDeclarePassive(legacy, true, false, false, false, nil); err != nil {
+		return // there is no legacy queue
+	}
+	if err := channel.QueueUnbind(legacy, "", exchange, nil); err != nil {
+		log.Printf("legacy queue %s: %s", legacy, err)
+		return
+	}
+	if _, err := channel.QueueDelete(legacy, false, true, false); err != nil {
+		log.Printf("legacy queue %s has messages left, move them to %s: %s", legacy, legacy+queueSuffix, err)
+	}
+}
+
+// declareQueue declares an exchange and a durable queue bound to it
+func declareQueue(channel Channel, exchange, queue string, args amqp.Table) error {
+	err := DeclareExchange(channel, exchange)
+	if err != nil {
+		return err
+	}
+	_, err = channel.QueueDeclare(
+		queue,
+		true,
+		false,
+		false,
+		false,
+		args,
+	)
+	if err != nil {
+		return err
+	}
+	return channel.QueueBind(queue, "", exchange, false, nil)
+}
+
+// handle decodes the envelope of each delivery and passes its payload to handleData.
+// Messages that can't be decoded, such as ones with unknown schema versions, are dead-lettered,
+// and so are the ones that failed to be handled MaxRedeliveries times already.
+// Other failures are rejected into the retry queue, to be delivered again after a while.
+func (c Consumer) handle(ctx context.Context, channel Channel, queue, deadLetterExchange string, deliveries <-chan amqp.Delivery, eventType string, handleData func(data []byte) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case d, ok := <-deliveries:
+			if !ok {
+				return ErrDeliveriesClosed
+			}
+			var err error
+			switch handleErr := c.handleDelivery(d, eventType, handleData); {
+			case handleErr == nil:
+				err = d.Ack(false)
+			case errors.Is(handleErr, errMalformedMessage), retries(d, queue) >= MaxRedeliveries:
+				// retrying will never succeed, or is unlikely to, so dead-letter it
+				err = deadLetter(ctx, channel, deadLetterExchange, d)
+			default:
+				err = d.Nack(false, false)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// deadLetter moves a delivery to the dead letter exchange, as rejecting it would send it to the retry queue
+func deadLetter(ctx context.Context, channel Channel, deadLetterExchange string, d amqp.Delivery) error {
+	err := channel.PublishWithContext(ctx, deadLetterExchange, "", false, false, amqp.Publishing{
+		Headers:         d.Headers,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		MessageId:       d.MessageId,
+		Timestamp:       d.Timestamp,
+		Type:            d.Type,
+		AppId:           d.AppId,
+		Body:            d.Body,
+	})
+	if err != nil {
+		return err
+	}
+	return d.Ack(false)
+}
+
+func (c Consumer) handleDelivery(d amqp.Delivery, eventType string, handleData func(data []byte) error) error {
+	envelope, err := decodeEnvelope(c.unmarshal, d.Body, eventType)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errMalformedMessage, err)
+	}
+	return handleData(envelope.Data)
+}
+
+// retries is how many times a delivery was rejected from queue into its retry queue before
+func retries(d amqp.Delivery, queue string) int64 {
+	deaths, _ := d.Headers["x-death"].([]interface{})
+	for _, death := range deaths {
+		death, ok := death.(amqp.Table)
+		if !ok || death["queue"] != queue || death["reason"] != "rejected" {
+			continue
+		}
+		switch count := death["count"].(type) {
+		case int64:
+			return count
+		case int32:
+			return int64(count)
+		case int:
+			return int64(count)
+		}
+	}
+	return 0
+}
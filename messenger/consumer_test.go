@@ -0,0 +1,462 @@
+package messenger_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gabrielseibel1/gaef/messenger"
+	"github.com/gabrielseibel1/gaef/types"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"reflect"
+	"testing"
+)
+
+type mockChannel struct {
+	// receive
+	exchange          string
+	queue             string
+	queues            map[string]amqp.Table
+	boundQueue        string
+	boundExchange     string
+	consumedQueue     string
+	prefetchCount     int
+	published         []amqp.Publishing
+	publishedExchange string
+	unboundQueue      string
+	deletedQueue      string
+
+	// return
+	deliveries  chan amqp.Delivery
+	legacyQueue bool
+	err         error
+}
+
+func (m *mockChannel) Channel() (messenger.Channel, error) {
+	return m, nil
+}
+
+func (m *mockChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error {
+	m.exchange = name
+	return m.err
+}
+
+func (m *mockChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	m.queue = name
+	if m.queues == nil {
+		m.queues = make(map[string]amqp.Table)
+	}
+	m.queues[name] = args
+	return amqp.Queue{Name: name}, m.err
+}
+
+func (m *mockChannel) QueueDeclarePassive(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	if !m.legacyQueue {
+		return amqp.Queue{}, errors.New("no such queue")
+	}
+	return amqp.Queue{Name: name}, nil
+}
+
+func (m *mockChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	m.boundQueue = name
+	m.boundExchange = exchange
+	return m.err
+}
+
+func (m *mockChannel) QueueUnbind(name, key, exchange string, args amqp.Table) error {
+	m.unboundQueue = name
+	return nil
+}
+
+func (m *mockChannel) QueueDelete(name string, ifUnused, ifEmpty, noWait bool) (int, error) {
+	m.deletedQueue = name
+	return 0, nil
+}
+
+func (m *mockChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	m.consumedQueue = queue
+	return m.deliveries, m.err
+}
+
+func (m *mockChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	m.prefetchCount = prefetchCount
+	return m.err
+}
+
+func (m *mockChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	m.publishedExchange = exchange
+	m.published = append(m.published, msg)
+	return nil
+}
+
+func (m *mockChannel) Close() error {
+	return nil
+}
+
+type mockAcknowledger struct {
+	acked    []uint64
+	nacked   []uint64
+	requeued []uint64
+}
+
+func (m *mockAcknowledger) Ack(tag uint64, multiple bool) error {
+	m.acked = append(m.acked, tag)
+	return nil
+}
+
+func (m *mockAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	m.nacked = append(m.nacked, tag)
+	if requeue {
+		m.requeued = append(m.requeued, tag)
+	}
+	return nil
+}
+
+func (m *mockAcknowledger) Reject(tag uint64, requeue bool) error {
+	return m.Nack(tag, false, requeue)
+}
+
+type mockUserUpdater struct {
+	users []types.User
+	err   error
+}
+
+func (m *mockUserUpdater) UpdateUser(ctx context.Context, user types.User) error {
+	m.users = append(m.users, user)
+	return m.err
+}
+
+type mockUserDeleter struct {
+	ids []string
+	err error
+}
+
+func (m *mockUserDeleter) DeleteUser(ctx context.Context, userID string) error {
+	m.ids = append(m.ids, userID)
+	return m.err
+}
+
+func deliveries(acknowledger amqp.Acknowledger, bodies ...[]byte) chan amqp.Delivery {
+	ch := make(chan amqp.Delivery, len(bodies))
+	for i, body := range bodies {
+		ch <- amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: uint64(i + 1), Body: body}
+	}
+	close(ch)
+	return ch
+}
+
+func TestConsumer_ConsumeUserUpdates(t *testing.T) {
+	tests := []struct {
+		name             string
+		bodies           [][]byte
+		channelErr       error
+		updaterErr       error
+		wantErr          error
+		wantUsers        []types.User
+		wantAcked        []uint64
+		wantNacked       []uint64
+		wantDeadLettered int
+	}{
+		{
+			name:      "consume user updates ok",
+			bodies:    [][]byte{dummyBodyUpdate, dummyBodyUpdate},
+			wantErr:   messenger.ErrDeliveriesClosed,
+			wantUsers: []types.User{dummyUser, dummyUser},
+			wantAcked: []uint64{1, 2},
+		},
+		{
+			name:             "consume user updates malformed message",
+			bodies:           [][]byte{[]byte("{"), dummyBodyDeletion, dummyBodyUpdate},
+			wantErr:          messenger.ErrDeliveriesClosed,
+			wantUsers:        []types.User{dummyUser},
+			wantAcked:        []uint64{1, 2, 3},
+			wantDeadLettered: 2,
+		},
+		{
+			name:       "consume user updates updater error",
+			bodies:     [][]byte{dummyBodyUpdate},
+			updaterErr: dummyError,
+			wantErr:    messenger.ErrDeliveriesClosed,
+			wantUsers:  []types.User{dummyUser},
+			wantNacked: []uint64{1},
+		},
+		{
+			name:       "consume user updates channel error",
+			channelErr: dummyError,
+			wantErr:    dummyError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acknowledger := &mockAcknowledger{}
+			channel := &mockChannel{deliveries: deliveries(acknowledger, tt.bodies...), err: tt.channelErr}
+			updater := &mockUserUpdater{err: tt.updaterErr}
+
+			c := messenger.NewConsumer(json.Unmarshal, "dummy-service", dummyExchangeName, "", "", channel)
+			if err := c.ConsumeUserUpdates(dummyCtx, updater); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ConsumeUserUpdates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got, want := updater.users, tt.wantUsers; !reflect.DeepEqual(got, want) {
+				t.Errorf("ConsumeUserUpdates() users = %v, want %v", got, want)
+			}
+			if got, want := acknowledger.acked, tt.wantAcked; !reflect.DeepEqual(got, want) {
+				t.Errorf("ConsumeUserUpdates() acked = %v, want %v", got, want)
+			}
+			if got, want := acknowledger.nacked, tt.wantNacked; !reflect.DeepEqual(got, want) {
+				t.Errorf("ConsumeUserUpdates() nacked = %v, want %v", got, want)
+			}
+			if got, want := acknowledger.requeued, []uint64(nil); !reflect.DeepEqual(got, want) {
+				t.Errorf("ConsumeUserUpdates() requeued = %v, want %v", got, want)
+			}
+			if got, want := len(channel.published), tt.wantDeadLettered; got != want {
+				t.Errorf("ConsumeUserUpdates() dead-lettered = %v, want %v", got, want)
+			}
+			// the dead letter exchange is declared first, so a failing channel doesn't get to the other one
+			if got, want := channel.exchange, dummyExchangeName; tt.channelErr == nil && got != want {
+				t.Errorf("ConsumeUserUpdates() exchange = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestConsumer_ConsumeUserDeletes(t *testing.T) {
+	tests := []struct {
+		name             string
+		bodies           [][]byte
+		deleterErr       error
+		wantErr          error
+		wantIDs          []string
+		wantAcked        []uint64
+		wantNacked       []uint64
+		wantDeadLettered int
+	}{
+		{
+			name:      "consume user deletes ok",
+			bodies:    [][]byte{dummyBodyDeletion},
+			wantErr:   messenger.ErrDeliveriesClosed,
+			wantIDs:   []string{dummyID},
+			wantAcked: []uint64{1},
+		},
+		{
+			name:             "consume user deletes malformed message",
+			bodies:           [][]byte{dummyBodyUpdate, dummyBodyDeletion},
+			wantErr:          messenger.ErrDeliveriesClosed,
+			wantIDs:          []string{dummyID},
+			wantAcked:        []uint64{1, 2},
+			wantDeadLettered: 1,
+		},
+		{
+			name:       "consume user deletes deleter error",
+			bodies:     [][]byte{dummyBodyDeletion},
+			deleterErr: dummyError,
+			wantErr:    messenger.ErrDeliveriesClosed,
+			wantIDs:    []string{dummyID},
+			wantNacked: []uint64{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acknowledger := &mockAcknowledger{}
+			channel := &mockChannel{deliveries: deliveries(acknowledger, tt.bodies...)}
+			deleter := &mockUserDeleter{err: tt.deleterErr}
+
+			c := messenger.NewConsumer(json.Unmarshal, "dummy-service", "", dummyExchangeName, "", channel)
+			if err := c.ConsumeUserDeletes(dummyCtx, deleter); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ConsumeUserDeletes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got, want := deleter.ids, tt.wantIDs; !reflect.DeepEqual(got, want) {
+				t.Errorf("ConsumeUserDeletes() ids = %v, want %v", got, want)
+			}
+			if got, want := acknowledger.acked, tt.wantAcked; !reflect.DeepEqual(got, want) {
+				t.Errorf("ConsumeUserDeletes() acked = %v, want %v", got, want)
+			}
+			if got, want := acknowledger.nacked, tt.wantNacked; !reflect.DeepEqual(got, want) {
+				t.Errorf("ConsumeUserDeletes() nacked = %v, want %v", got, want)
+			}
+			if got, want := len(channel.published), tt.wantDeadLettered; got != want {
+				t.Errorf("ConsumeUserDeletes() dead-lettered = %v, want %v", got, want)
+			}
+			if got, want := channel.boundQueue, "dummy-service."+dummyExchangeName+".quorum"; got != want {
+				t.Errorf("ConsumeUserDeletes() bound queue = %v, want %v", got, want)
+			}
+			if got, want := channel.consumedQueue, channel.boundQueue; got != want {
+				t.Errorf("ConsumeUserDeletes() consumed queue = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestConsumer_RetriesAndDeadLettersExhaustedMessages(t *testing.T) {
+	queue := "dummy-service." + dummyExchangeName + ".quorum"
+	rejected := func(count int64) amqp.Table {
+		return amqp.Table{"x-death": []interface{}{
+			amqp.Table{"queue": queue + ".retry", "reason": "expired", "count": count},
+			amqp.Table{"queue": queue, "reason": "rejected", "count": count},
+		}}
+	}
+	acknowledger := &mockAcknowledger{}
+	ch := make(chan amqp.Delivery, 2)
+	ch <- amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: 1, Body: dummyBodyUpdate, Headers: rejected(messenger.MaxRedeliveries - 1)}
+	ch <- amqp.Delivery{Acknowledger: acknowledger, DeliveryTag: 2, Body: dummyBodyUpdate, Headers: rejected(messenger.MaxRedeliveries)}
+	close(ch)
+	channel := &mockChannel{deliveries: ch}
+
+	c := messenger.NewConsumer(json.Unmarshal, "dummy-service", dummyExchangeName, "", "", channel)
+	if err := c.ConsumeUserUpdates(dummyCtx, &mockUserUpdater{err: dummyError}); !errors.Is(err, messenger.ErrDeliveriesClosed) {
+		t.Errorf("ConsumeUserUpdates() error = %v, want %v", err, messenger.ErrDeliveriesClosed)
+	}
+
+	// the first goes to the retry queue, the second to the dead letters
+	if got, want := acknowledger.nacked, []uint64{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeUserUpdates() nacked = %v, want %v", got, want)
+	}
+	if got, want := acknowledger.requeued, []uint64(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeUserUpdates() requeued = %v, want %v", got, want)
+	}
+	if got, want := acknowledger.acked, []uint64{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeUserUpdates() acked = %v, want %v", got, want)
+	}
+	if got, want := len(channel.published), 1; got != want {
+		t.Fatalf("ConsumeUserUpdates() dead-lettered = %v, want %v", got, want)
+	}
+	if got, want := channel.publishedExchange, "dummy-service.dead-letters"; got != want {
+		t.Errorf("ConsumeUserUpdates() dead letter exchange = %v, want %v", got, want)
+	}
+	if got, want := channel.published[0].Body, dummyBodyUpdate; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeUserUpdates() dead-lettered body = %s, want %s", got, want)
+	}
+
+	// failing messages are rejected into the retry queue, which expires them back into the queue
+	if got, want := channel.queues[queue]["x-dead-letter-exchange"], queue+".retry"; got != want {
+		t.Errorf("ConsumeUserUpdates() queue dead letter exchange = %v, want %v", got, want)
+	}
+	if got, want := channel.queues[queue]["x-queue-type"], "quorum"; got != want {
+		t.Errorf("ConsumeUserUpdates() queue type = %v, want %v", got, want)
+	}
+	if got, want := channel.queues[queue+".retry"]["x-dead-letter-routing-key"], queue; got != want {
+		t.Errorf("ConsumeUserUpdates() retry queue dead letter routing key = %v, want %v", got, want)
+	}
+	if got, ok := channel.queues[queue+".retry"]["x-message-ttl"].(int64); !ok || got <= 0 {
+		t.Errorf("ConsumeUserUpdates() retry queue message ttl = %v, want some", got)
+	}
+	if got := channel.prefetchCount; got <= 0 {
+		t.Errorf("ConsumeUserUpdates() prefetch count = %v, want some", got)
+	}
+}
+
+func TestConsumer_RetiresLegacyQueue(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		channel := &mockChannel{deliveries: deliveries(&mockAcknowledger{}), legacyQueue: legacy}
+
+		c := messenger.NewConsumer(json.Unmarshal, "dummy-service", dummyExchangeName, "", "", channel)
+		if err := c.ConsumeUserUpdates(dummyCtx, &mockUserUpdater{}); !errors.Is(err, messenger.ErrDeliveriesClosed) {
+			t.Errorf("ConsumeUserUpdates() error = %v, want %v", err, messenger.ErrDeliveriesClosed)
+		}
+
+		want := ""
+		if legacy {
+			want = "dummy-service." + dummyExchangeName
+		}
+		if got := channel.unboundQueue; got != want {
+			t.Errorf("ConsumeUserUpdates() with legacy queue %v unbound queue = %v, want %v", legacy, got, want)
+		}
+		if got := channel.deletedQueue; got != want {
+			t.Errorf("ConsumeUserUpdates() with legacy queue %v deleted queue = %v, want %v", legacy, got, want)
+		}
+	}
+}
+
+func TestConsumer_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(dummyCtx)
+	cancel()
+	channel := &mockChannel{deliveries: make(chan amqp.Delivery)}
+
+	c := messenger.NewConsumer(json.Unmarshal, "dummy-service", dummyExchangeName, "", "", channel)
+	if err := c.ConsumeUserUpdates(ctx, &mockUserUpdater{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("ConsumeUserUpdates() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func envelopeBody(t *testing.T, e messenger.Envelope) []byte {
+	body, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("unable to marshal envelope: %v", err)
+	}
+	return body
+}
+
+func TestConsumer_DecodesEnvelopes(t *testing.T) {
+	// messages as published by the messenger
+	publisher := &mockPublisher{}
+	m := messenger.New(json.Marshal, dummySource, "", "", "", publisher)
+	if err := m.SendUserUpdatedMessage(dummyCtx, dummyEventID, dummyUser); err != nil {
+		t.Fatalf("SendUserUpdatedMessage() error = %v", err)
+	}
+	published := publisher.msg.Body
+
+	future := messenger.Envelope{
+		SpecVersion:   messenger.SpecVersion,
+		ID:            "future",
+		Type:          messenger.TypeUserUpdated,
+		SchemaVersion: messenger.SchemaVersion + 1,
+		Data:          dummyBodyUpdate,
+	}
+	wrongType := future
+	wrongType.Type, wrongType.SchemaVersion = messenger.TypeUserDeleted, messenger.SchemaVersion
+	wrongSpec := future
+	wrongSpec.SpecVersion, wrongSpec.SchemaVersion = "0.3", messenger.SchemaVersion
+
+	acknowledger := &mockAcknowledger{}
+	channel := &mockChannel{deliveries: deliveries(
+		acknowledger,
+		published,
+		dummyBodyUpdate, // legacy, upgraded from version 0
+		envelopeBody(t, future),
+		envelopeBody(t, wrongType),
+		envelopeBody(t, wrongSpec),
+	)}
+	updater := &mockUserUpdater{}
+
+	c := messenger.NewConsumer(json.Unmarshal, "dummy-service", dummyExchangeName, "", "", channel)
+	if err := c.ConsumeUserUpdates(dummyCtx, updater); !errors.Is(err, messenger.ErrDeliveriesClosed) {
+		t.Errorf("ConsumeUserUpdates() error = %v, want %v", err, messenger.ErrDeliveriesClosed)
+	}
+
+	if got, want := updater.users, []types.User{dummyUser, dummyUser}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeUserUpdates() users = %v, want %v", got, want)
+	}
+	if got, want := acknowledger.acked, []uint64{1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeUserUpdates() acked = %v, want %v", got, want)
+	}
+	if got := acknowledger.nacked; len(got) != 0 {
+		t.Errorf("ConsumeUserUpdates() nacked = %v, want none", got)
+	}
+	if got, want := len(channel.published), 3; got != want {
+		t.Errorf("ConsumeUserUpdates() dead-lettered = %v, want %v", got, want)
+	}
+}
+
+func TestConsumer_UpgradesLegacyDeletes(t *testing.T) {
+	publisher := &mockPublisher{}
+	m := messenger.New(json.Marshal, dummySource, "", "", "", publisher)
+	if err := m.SendUserDeletedMessage(dummyCtx, dummyEventID, dummyID); err != nil {
+		t.Fatalf("SendUserDeletedMessage() error = %v", err)
+	}
+
+	acknowledger := &mockAcknowledger{}
+	channel := &mockChannel{deliveries: deliveries(acknowledger, publisher.msg.Body, dummyBodyDeletion)}
+	deleter := &mockUserDeleter{}
+
+	c := messenger.NewConsumer(json.Unmarshal, "dummy-service", "", dummyExchangeName, "", channel)
+	if err := c.ConsumeUserDeletes(dummyCtx, deleter); !errors.Is(err, messenger.ErrDeliveriesClosed) {
+		t.Errorf("ConsumeUserDeletes() error = %v, want %v", err, messenger.ErrDeliveriesClosed)
+	}
+
+	if got, want := deleter.ids, []string{dummyID, dummyID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeUserDeletes() ids = %v, want %v", got, want)
+	}
+	if got, want := acknowledger.acked, []uint64{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeUserDeletes() acked = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package messenger
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"time"
+)
+
+// Envelope wraps every message as a CloudEvents 1.0 event in structured JSON mode,
+// so that tools that understand CloudEvents can read our exchanges
+type Envelope struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	SchemaVersion   int             `json:"schemaversion"`
+	Data            json.RawMessage `json:"data"`
+}
+
+const (
+	SpecVersion     = "1.0"
+	ContentType     = "application/cloudevents+json"
+	DataContentType = "application/json"
+
+	TypeUserUpdated = "com.gaef.user.updated"
+	TypeUserDeleted = "com.gaef.user.deleted"
+	TypeUserErased  = "com.gaef.user.erased"
+
+	TypeGroupUpdated = "com.gaef.group.updated"
+	TypeGroupDeleted = "com.gaef.group.deleted"
+
+	// SchemaVersion is the version of the payloads produced by this package.
+	// Version 0 is the legacy format, a bare payload with no envelope.
+	SchemaVersion = 1
+)
+
+// UserDeleted is the payload of TypeUserDeleted events
+type UserDeleted struct {
+	ID string `json:"id"`
+}
+
+// UserErased is the payload of TypeUserErased events, by which a service confirms it purged the data of a deleted user
+type UserErased struct {
+	ID      string `json:"id"`
+	Service string `json:"service"`
+}
+
+// GroupDeleted is the payload of TypeGroupDeleted events
+type GroupDeleted struct {
+	ID string `json:"id"`
+}
+
+// the services that hold user data, each of which confirms its erasure
+const (
+	ServiceUser              = "user"
+	ServiceGroup             = "group"
+	ServiceEncounterProposal = "encounter-proposal"
+	ServiceEncounter         = "encounter"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+// upgraders convert the payload of an event type from a schema version to the next one
+var upgraders = map[string]map[int]func(data json.RawMessage) (json.RawMessage, error){
+	TypeUserUpdated: {
+		0: func(data json.RawMessage) (json.RawMessage, error) { return data, nil },
+	},
+	TypeUserDeleted: {
+		0: func(data json.RawMessage) (json.RawMessage, error) {
+			var id string
+			if err := json.Unmarshal(data, &id); err != nil {
+				return nil, err
+			}
+			return json.Marshal(UserDeleted{ID: id})
+		},
+	},
+}
+
+func newEnvelope(source, eventType, id string, data []byte) Envelope {
+	return Envelope{
+		SpecVersion:     SpecVersion,
+		ID:              id,
+		Source:          source,
+		Type:            eventType,
+		Time:            time.Now().UTC(),
+		DataContentType: DataContentType,
+		SchemaVersion:   SchemaVersion,
+		Data:            data,
+	}
+}
+
+// publishing sends the envelope in structured mode, with all of its attributes in the body.
+// The plain AMQP properties mirror some of them, for tools that don't read CloudEvents.
+func (e Envelope) publishing(body []byte) amqp.Publishing {
+	return amqp.Publishing{
+		ContentType: ContentType,
+		MessageId:   e.ID,
+		Type:        e.Type,
+		Timestamp:   e.Time,
+		AppId:       e.Source,
+		Body:        body,
+	}
+}
+
+// decodeEnvelope reads an event of the wanted type, upgrading its payload to the current schema version.
+// Bodies with no envelope are taken as version 0 payloads of the wanted type.
+func decodeEnvelope(unmarshal Unmarshaller, body []byte, wantType string) (Envelope, error) {
+	var e Envelope
+	if err := unmarshal(body, &e); err != nil || e.SpecVersion == "" {
+		e = Envelope{Type: wantType, SchemaVersion: 0, Data: body}
+	}
+
+	if e.SpecVersion != "" && e.SpecVersion != SpecVersion {
+		return Envelope{}, fmt.Errorf("unsupported cloudevents spec version %q", e.SpecVersion)
+	}
+	if e.Type != wantType {
+		return Envelope{}, fmt.Errorf("got event type %q, want %q", e.Type, wantType)
+	}
+	if e.SchemaVersion < 0 || e.SchemaVersion > SchemaVersion {
+		return Envelope{}, fmt.Errorf("%w %d of %s", ErrUnsupportedVersion, e.SchemaVersion, e.Type)
+	}
+
+	for ; e.SchemaVersion < SchemaVersion; e.SchemaVersion++ {
+		upgrade, ok := upgraders[e.Type][e.SchemaVersion]
+		if !ok {
+			return Envelope{}, fmt.Errorf("%w %d of %s", ErrUnsupportedVersion, e.SchemaVersion, e.Type)
+		}
+		data, err := upgrade(e.Data)
+		if err != nil {
+			return Envelope{}, err
+		}
+		e.Data = data
+	}
+	return e, nil
+}
+
+// NewEventID generates a random event ID, 12 bytes in hex like the ObjectIDs of the documents with outboxes,
+// so that all events have IDs of the same format whether they were stored in an outbox or not
+func NewEventID() string {
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,53 @@
+package messenger
+
+import (
+	"context"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type ExchangeDeclarer interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+}
+
+// DeclareExchange declares a durable fanout exchange, as all of ours are.
+// Declaring is idempotent, so both publishers and consumers do it to not depend on which one starts first.
+func DeclareExchange(declarer ExchangeDeclarer, name string) error {
+	return declarer.ExchangeDeclare(
+		name,
+		amqp.ExchangeFanout,
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+}
+
+type ErasureConfirmer interface {
+	SendUserErasedMessage(ctx context.Context, userID, service string) error
+}
+
+// ErasingDeleter purges users with a UserDeleter and confirms it on behalf of a service,
+// so that the user service can tell when the data of a user is gone from everywhere
+type ErasingDeleter struct {
+	deleter   UserDeleter
+	confirmer ErasureConfirmer
+	service   string
+}
+
+func NewErasingDeleter(deleter UserDeleter, confirmer ErasureConfirmer, service string) ErasingDeleter {
+	return ErasingDeleter{
+		deleter:   deleter,
+		confirmer: confirmer,
+		service:   service,
+	}
+}
+
+// DeleteUser fails if the confirmation can't be sent, so that the message is redelivered.
+// Deleting users again must then be harmless.
+func (ed ErasingDeleter) DeleteUser(ctx context.Context, userID string) error {
+	if err := ed.deleter.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+	return ed.confirmer.SendUserErasedMessage(ctx, userID, ed.service)
+}
@@ -0,0 +1,120 @@
+package messenger_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gabrielseibel1/gaef/messenger"
+	"reflect"
+	"testing"
+)
+
+type mockErasureRecorder struct {
+	erasures []messenger.UserErased
+	err      error
+}
+
+func (m *mockErasureRecorder) RecordErasure(ctx context.Context, userID, service string) error {
+	m.erasures = append(m.erasures, messenger.UserErased{ID: userID, Service: service})
+	return m.err
+}
+
+type mockErasureConfirmer struct {
+	erasures []messenger.UserErased
+	err      error
+}
+
+func (m *mockErasureConfirmer) SendUserErasedMessage(ctx context.Context, userID, service string) error {
+	m.erasures = append(m.erasures, messenger.UserErased{ID: userID, Service: service})
+	return m.err
+}
+
+func TestConsumer_ConsumeUserErasures(t *testing.T) {
+	// messages as published by the messenger
+	publisher := &mockPublisher{}
+	m := messenger.New(json.Marshal, dummySource, "", "", dummyExchangeName, publisher)
+	if err := m.SendUserErasedMessage(dummyCtx, dummyID, messenger.ServiceGroup); err != nil {
+		t.Fatalf("SendUserErasedMessage() error = %v", err)
+	}
+	if got, want := publisher.exchange, dummyExchangeName; got != want {
+		t.Errorf("SendUserErasedMessage() exchange = %v, want %v", got, want)
+	}
+	dummyDataErasure, _ := json.Marshal(messenger.UserErased{ID: dummyID, Service: messenger.ServiceGroup})
+	verifyPublishing(t, publisher.msg, messenger.TypeUserErased, dummyDataErasure)
+
+	acknowledger := &mockAcknowledger{}
+	channel := &mockChannel{deliveries: deliveries(
+		acknowledger,
+		publisher.msg.Body,
+		dummyDataErasure, // there is no legacy version of erasures
+	)}
+	recorder := &mockErasureRecorder{}
+
+	// run code under test
+	c := messenger.NewConsumer(json.Unmarshal, "dummy-service", "", "", dummyExchangeName, channel)
+	err := c.ConsumeUserErasures(dummyCtx, recorder)
+
+	// assertions
+	if !errors.Is(err, messenger.ErrDeliveriesClosed) {
+		t.Errorf("ConsumeUserErasures() error = %v, want %v", err, messenger.ErrDeliveriesClosed)
+	}
+	if got, want := channel.boundExchange, dummyExchangeName; got != want {
+		t.Errorf("ConsumeUserErasures() bound exchange = %v, want %v", got, want)
+	}
+	if got, want := recorder.erasures, []messenger.UserErased{{ID: dummyID, Service: messenger.ServiceGroup}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeUserErasures() erasures = %v, want %v", got, want)
+	}
+	if got, want := acknowledger.acked, []uint64{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeUserErasures() acked = %v, want %v", got, want)
+	}
+	if got, want := len(channel.published), 1; got != want {
+		t.Errorf("ConsumeUserErasures() dead-lettered = %v, want %v", got, want)
+	}
+}
+
+func TestErasingDeleter(t *testing.T) {
+	tests := []struct {
+		name          string
+		deleterErr    error
+		confirmerErr  error
+		wantErr       error
+		wantConfirmed []messenger.UserErased
+	}{
+		{
+			name:          "confirms erasure",
+			wantConfirmed: []messenger.UserErased{{ID: dummyID, Service: messenger.ServiceEncounter}},
+		},
+		{
+			name:       "deleter error",
+			deleterErr: dummyError,
+			wantErr:    dummyError,
+		},
+		{
+			name:          "confirmer error",
+			confirmerErr:  dummyError,
+			wantErr:       dummyError,
+			wantConfirmed: []messenger.UserErased{{ID: dummyID, Service: messenger.ServiceEncounter}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// prepare test setup
+			deleter := &mockUserDeleter{err: tt.deleterErr}
+			confirmer := &mockErasureConfirmer{err: tt.confirmerErr}
+
+			// run code under test
+			err := messenger.NewErasingDeleter(deleter, confirmer, messenger.ServiceEncounter).DeleteUser(dummyCtx, dummyID)
+
+			// assertions
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("DeleteUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if got, want := deleter.ids, []string{dummyID}; !reflect.DeepEqual(got, want) {
+				t.Errorf("DeleteUser() deleted = %v, want %v", got, want)
+			}
+			if got, want := confirmer.erasures, tt.wantConfirmed; !reflect.DeepEqual(got, want) {
+				t.Errorf("DeleteUser() confirmed = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package messenger
+
+import (
+	"context"
+	"github.com/gabrielseibel1/gaef/types"
+)
+
+// GroupMessenger publishes the events of groups, for services that embed copies of them to keep in sync
+type GroupMessenger struct {
+	messenger               Messenger
+	amqpGroupUpdateExchange string
+	amqpGroupDeleteExchange string
+}
+
+// NewGroupMessenger creates a GroupMessenger that publishes events on behalf of source, the producer service
+func NewGroupMessenger(marshaller Marshaller, source, amqpGroupUpdateExchange, amqpGroupDeleteExchange string, publisher Publisher) GroupMessenger {
+	return GroupMessenger{
+		messenger:               Messenger{marshal: marshaller, publisher: publisher, source: source},
+		amqpGroupUpdateExchange: amqpGroupUpdateExchange,
+		amqpGroupDeleteExchange: amqpGroupDeleteExchange,
+	}
+}
+
+// SendGroupUpdatedMessage publishes the new state of a group as the event of the given ID, such as the one it has in an outbox
+func (m GroupMessenger) SendGroupUpdatedMessage(ctx context.Context, eventID string, group types.Group) error {
+	return m.messenger.sendMessage(ctx, m.amqpGroupUpdateExchange, TypeGroupUpdated, eventID, group)
+}
+
+// SendGroupDeletedMessage publishes the deletion of a group as the event of the given ID, such as the one it has in an outbox
+func (m GroupMessenger) SendGroupDeletedMessage(ctx context.Context, eventID, groupID string) error {
+	return m.messenger.sendMessage(ctx, m.amqpGroupDeleteExchange, TypeGroupDeleted, eventID, GroupDeleted{ID: groupID})
+}
+
+// GroupConsumer consumes the events of groups
+type GroupConsumer struct {
+	consumer                Consumer
+	amqpGroupUpdateExchange string
+	amqpGroupDeleteExchange string
+}
+
+func NewGroupConsumer(unmarshaller Unmarshaller, queuePrefix, amqpGroupUpdateExchange, amqpGroupDeleteExchange string, channels ChannelOpener) GroupConsumer {
+	return GroupConsumer{
+		consumer:                Consumer{unmarshal: unmarshaller, channels: channels, queuePrefix: queuePrefix},
+		amqpGroupUpdateExchange: amqpGroupUpdateExchange,
+		amqpGroupDeleteExchange: amqpGroupDeleteExchange,
+	}
+}
+
+type GroupUpdater interface {
+	UpdateGroup(ctx context.Context, group types.Group) error
+}
+
+type GroupDeleter interface {
+	DeleteGroup(ctx context.Context, groupID string) error
+}
+
+// ConsumeGroupUpdates blocks handling group updated messages until ctx is done or its channel is closed
+func (c GroupConsumer) ConsumeGroupUpdates(ctx context.Context, updater GroupUpdater) error {
+	return c.consumer.consume(ctx, c.amqpGroupUpdateExchange, TypeGroupUpdated, func(data []byte) error {
+		var group types.Group
+		if err := c.consumer.unmarshal(data, &group); err != nil || group.ID == "" {
+			return errMalformedMessage
+		}
+		return updater.UpdateGroup(ctx, group)
+	})
+}
+
+// ConsumeGroupDeletes blocks handling group deleted messages until ctx is done or its channel is closed
+func (c GroupConsumer) ConsumeGroupDeletes(ctx context.Context, deleter GroupDeleter) error {
+	return c.consumer.consume(ctx, c.amqpGroupDeleteExchange, TypeGroupDeleted, func(data []byte) error {
+		var deleted GroupDeleted
+		if err := c.consumer.unmarshal(data, &deleted); err != nil || deleted.ID == "" {
+			return errMalformedMessage
+		}
+		return deleter.DeleteGroup(ctx, deleted.ID)
+	})
+}
@@ -0,0 +1,161 @@
+package messenger_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gabrielseibel1/gaef/messenger"
+	"github.com/gabrielseibel1/gaef/types"
+	"reflect"
+	"testing"
+)
+
+type mockGroupUpdater struct {
+	groups []types.Group
+	err    error
+}
+
+func (m *mockGroupUpdater) UpdateGroup(ctx context.Context, group types.Group) error {
+	m.groups = append(m.groups, group)
+	return m.err
+}
+
+type mockGroupDeleter struct {
+	ids []string
+	err error
+}
+
+func (m *mockGroupDeleter) DeleteGroup(ctx context.Context, groupID string) error {
+	m.ids = append(m.ids, groupID)
+	return m.err
+}
+
+var (
+	dummyGroup               = types.Group{ID: dummyID, Name: "dummy-group-name", Members: []types.User{dummyUser}, Leaders: []types.User{dummyUser}}
+	dummyDataGroupUpdate, _  = json.Marshal(dummyGroup)
+	dummyDataGroupDelete, _  = json.Marshal(messenger.GroupDeleted{ID: dummyID})
+	dummyGroupUpdateExchange = "dummy-group-update-exchange"
+	dummyGroupDeleteExchange = "dummy-group-delete-exchange"
+)
+
+func TestGroupMessenger_SendGroupUpdatedMessage(t *testing.T) {
+	publisher := &mockPublisher{}
+	m := messenger.NewGroupMessenger(json.Marshal, dummySource, dummyGroupUpdateExchange, dummyGroupDeleteExchange, publisher)
+
+	if err := m.SendGroupUpdatedMessage(dummyCtx, dummyEventID, dummyGroup); err != nil {
+		t.Fatalf("SendGroupUpdatedMessage() error = %v", err)
+	}
+	if got, want := publisher.exchange, dummyGroupUpdateExchange; got != want {
+		t.Errorf("SendGroupUpdatedMessage() exchange = %v, want %v", got, want)
+	}
+	verifyPublishing(t, publisher.msg, messenger.TypeGroupUpdated, dummyDataGroupUpdate)
+}
+
+func TestGroupMessenger_SendGroupDeletedMessage(t *testing.T) {
+	publisher := &mockPublisher{}
+	m := messenger.NewGroupMessenger(json.Marshal, dummySource, dummyGroupUpdateExchange, dummyGroupDeleteExchange, publisher)
+
+	if err := m.SendGroupDeletedMessage(dummyCtx, dummyEventID, dummyID); err != nil {
+		t.Fatalf("SendGroupDeletedMessage() error = %v", err)
+	}
+	if got, want := publisher.exchange, dummyGroupDeleteExchange; got != want {
+		t.Errorf("SendGroupDeletedMessage() exchange = %v, want %v", got, want)
+	}
+	verifyPublishing(t, publisher.msg, messenger.TypeGroupDeleted, dummyDataGroupDelete)
+
+	publisher.err = dummyError
+	if err := m.SendGroupDeletedMessage(dummyCtx, dummyEventID, dummyID); !errors.Is(err, dummyError) {
+		t.Errorf("SendGroupDeletedMessage() error = %v, want %v", err, dummyError)
+	}
+}
+
+func TestGroupConsumer_ConsumeGroupUpdates(t *testing.T) {
+	publisher := &mockPublisher{}
+	m := messenger.NewGroupMessenger(json.Marshal, dummySource, dummyGroupUpdateExchange, dummyGroupDeleteExchange, publisher)
+	if err := m.SendGroupUpdatedMessage(dummyCtx, dummyEventID, dummyGroup); err != nil {
+		t.Fatalf("SendGroupUpdatedMessage() error = %v", err)
+	}
+	withoutID := envelopeBody(t, messenger.Envelope{
+		SpecVersion:   messenger.SpecVersion,
+		Type:          messenger.TypeGroupUpdated,
+		SchemaVersion: messenger.SchemaVersion,
+		Data:          json.RawMessage(`{"name":"dummy-group-name"}`),
+	})
+
+	acknowledger := &mockAcknowledger{}
+	channel := &mockChannel{deliveries: deliveries(
+		acknowledger,
+		publisher.msg.Body,
+		dummyDataGroupUpdate, // bare payloads were never published for groups, so there is nothing to upgrade
+		withoutID,
+	)}
+	updater := &mockGroupUpdater{}
+
+	c := messenger.NewGroupConsumer(json.Unmarshal, "dummy-service", dummyGroupUpdateExchange, dummyGroupDeleteExchange, channel)
+	if err := c.ConsumeGroupUpdates(dummyCtx, updater); !errors.Is(err, messenger.ErrDeliveriesClosed) {
+		t.Errorf("ConsumeGroupUpdates() error = %v, want %v", err, messenger.ErrDeliveriesClosed)
+	}
+
+	if got, want := updater.groups, []types.Group{dummyGroup}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeGroupUpdates() groups = %v, want %v", got, want)
+	}
+	if got, want := acknowledger.acked, []uint64{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConsumeGroupUpdates() acked = %v, want %v", got, want)
+	}
+	if got, want := len(channel.published), 2; got != want {
+		t.Errorf("ConsumeGroupUpdates() dead-lettered = %v, want %v", got, want)
+	}
+	if got, want := channel.boundQueue, "dummy-service."+dummyGroupUpdateExchange+".quorum"; got != want {
+		t.Errorf("ConsumeGroupUpdates() bound queue = %v, want %v", got, want)
+	}
+}
+
+func TestGroupConsumer_ConsumeGroupDeletes(t *testing.T) {
+	publisher := &mockPublisher{}
+	m := messenger.NewGroupMessenger(json.Marshal, dummySource, dummyGroupUpdateExchange, dummyGroupDeleteExchange, publisher)
+	if err := m.SendGroupDeletedMessage(dummyCtx, dummyEventID, dummyID); err != nil {
+		t.Fatalf("SendGroupDeletedMessage() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		deleterErr error
+		wantAcked  []uint64
+		wantNacked []uint64
+	}{
+		{
+			name:      "consume group deletes ok",
+			wantAcked: []uint64{1},
+		},
+		{
+			name:       "consume group deletes deleter error",
+			deleterErr: dummyError,
+			wantNacked: []uint64{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acknowledger := &mockAcknowledger{}
+			channel := &mockChannel{deliveries: deliveries(acknowledger, publisher.msg.Body)}
+			deleter := &mockGroupDeleter{err: tt.deleterErr}
+
+			c := messenger.NewGroupConsumer(json.Unmarshal, "dummy-service", dummyGroupUpdateExchange, dummyGroupDeleteExchange, channel)
+			if err := c.ConsumeGroupDeletes(dummyCtx, deleter); !errors.Is(err, messenger.ErrDeliveriesClosed) {
+				t.Errorf("ConsumeGroupDeletes() error = %v, want %v", err, messenger.ErrDeliveriesClosed)
+			}
+
+			if got, want := deleter.ids, []string{dummyID}; !reflect.DeepEqual(got, want) {
+				t.Errorf("ConsumeGroupDeletes() ids = %v, want %v", got, want)
+			}
+			if got, want := acknowledger.acked, tt.wantAcked; !reflect.DeepEqual(got, want) {
+				t.Errorf("ConsumeGroupDeletes() acked = %v, want %v", got, want)
+			}
+			if got, want := acknowledger.nacked, tt.wantNacked; !reflect.DeepEqual(got, want) {
+				t.Errorf("ConsumeGroupDeletes() nacked = %v, want %v", got, want)
+			}
+			if got, want := channel.exchange, dummyGroupDeleteExchange; got != want {
+				t.Errorf("ConsumeGroupDeletes() exchange = %v, want %v", got, want)
+			}
+		})
+	}
+}
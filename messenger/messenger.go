@@ -9,16 +9,21 @@ import (
 type Messenger struct {
 	marshal                Marshaller
 	publisher              Publisher
+	source                 string
 	amqpUserUpdateExchange string
 	amqpUserDeleteExchange string
+	amqpUserEraseExchange  string
 }
 
-func New(marshaller Marshaller, amqpUserUpdateExchange, amqpUserDeleteExchange string, publisher Publisher) Messenger {
+// New creates a Messenger that publishes events on behalf of source, the producer service
+func New(marshaller Marshaller, source, amqpUserUpdateExchange, amqpUserDeleteExchange, amqpUserEraseExchange string, publisher Publisher) Messenger {
 	return Messenger{
 		marshal:                marshaller,
 		publisher:              publisher,
+		source:                 source,
 		amqpUserUpdateExchange: amqpUserUpdateExchange,
 		amqpUserDeleteExchange: amqpUserDeleteExchange,
+		amqpUserEraseExchange:  amqpUserEraseExchange,
 	}
 }
 
@@ -28,8 +33,14 @@ type Publisher interface {
 
 type Marshaller func(v any) ([]byte, error)
 
-func (m Messenger) sendMessage(ctx context.Context, exchange string, msg any) error {
-	body, err := m.marshal(msg)
+func (m Messenger) sendMessage(ctx context.Context, exchange, eventType, eventID string, msg any) error {
+	data, err := m.marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	envelope := newEnvelope(m.source, eventType, eventID, data)
+	body, err := m.marshal(envelope)
 	if err != nil {
 		return err
 	}
@@ -40,17 +51,22 @@ func (m Messenger) sendMessage(ctx context.Context, exchange string, msg any) er
 		"",
 		false,
 		false,
-		amqp.Publishing{
-			Body: body,
-		},
+		envelope.publishing(body),
 	)
 	return err
 }
 
-func (m Messenger) SendUserDeletedMessage(ctx context.Context, userID string) error {
-	return m.sendMessage(ctx, m.amqpUserDeleteExchange, userID)
+// SendUserDeletedMessage publishes the deletion of a user as the event of the given ID, such as the one it has in an outbox,
+// so that consumers can tell the same event sent again apart from a new one
+func (m Messenger) SendUserDeletedMessage(ctx context.Context, eventID, userID string) error {
+	return m.sendMessage(ctx, m.amqpUserDeleteExchange, TypeUserDeleted, eventID, UserDeleted{ID: userID})
+}
+
+// SendUserUpdatedMessage publishes the new state of a user as the event of the given ID, as SendUserDeletedMessage does
+func (m Messenger) SendUserUpdatedMessage(ctx context.Context, eventID string, user types.User) error {
+	return m.sendMessage(ctx, m.amqpUserUpdateExchange, TypeUserUpdated, eventID, user)
 }
 
-func (m Messenger) SendUserUpdatedMessage(ctx context.Context, user types.User) error {
-	return m.sendMessage(ctx, m.amqpUserUpdateExchange, user)
+func (m Messenger) SendUserErasedMessage(ctx context.Context, userID, service string) error {
+	return m.sendMessage(ctx, m.amqpUserEraseExchange, TypeUserErased, NewEventID(), UserErased{ID: userID, Service: service})
 }
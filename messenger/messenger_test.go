@@ -2,6 +2,7 @@ package messenger_test
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"github.com/gabrielseibel1/gaef/messenger"
@@ -34,13 +35,71 @@ func (m *mockPublisher) PublishWithContext(ctx context.Context, exchange, key st
 var (
 	dummyCtx             = context.TODO()
 	dummyExchangeName    = "dummy-exchange-name"
+	dummySource          = "dummy-source"
 	dummyID              = "dummy-id"
+	dummyEventID         = "dummy-event-id"
 	dummyUser            = types.User{ID: dummyID, Name: "dummy-name"}
 	dummyError           = errors.New("dummy-error")
 	dummyBodyDeletion, _ = json.Marshal(dummyID)
 	dummyBodyUpdate, _   = json.Marshal(dummyUser)
+	dummyDataDeletion, _ = json.Marshal(messenger.UserDeleted{ID: dummyID})
 )
 
+// verifyPublishing checks that the published message is a structured mode envelope of the event type around the data,
+// with some of its attributes mirrored in the AMQP properties
+func verifyPublishing(t *testing.T, msg amqp.Publishing, eventType string, data []byte) {
+	var e messenger.Envelope
+	if err := json.Unmarshal(msg.Body, &e); err != nil {
+		t.Fatalf("unable to decode envelope: %v", err)
+	}
+	if got, want := e.SpecVersion, messenger.SpecVersion; got != want {
+		t.Errorf("envelope specversion = %v, want %v", got, want)
+	}
+	if got, want := e.Type, eventType; got != want {
+		t.Errorf("envelope type = %v, want %v", got, want)
+	}
+	if got, want := e.Source, dummySource; got != want {
+		t.Errorf("envelope source = %v, want %v", got, want)
+	}
+	if got, want := e.SchemaVersion, messenger.SchemaVersion; got != want {
+		t.Errorf("envelope schema version = %v, want %v", got, want)
+	}
+	if got, want := e.DataContentType, messenger.DataContentType; got != want {
+		t.Errorf("envelope datacontenttype = %v, want %v", got, want)
+	}
+	if got, want := string(e.Data), string(data); got != want {
+		t.Errorf("envelope data = %v, want %v", got, want)
+	}
+	if e.ID == "" || e.Time.IsZero() {
+		t.Errorf("envelope id = %q and time = %v, want them set", e.ID, e.Time)
+	}
+	if got, want := e.ID, dummyEventID; eventType != messenger.TypeUserErased && got != want {
+		t.Errorf("envelope id = %v, want %v", got, want)
+	}
+	if got, want := msg.ContentType, messenger.ContentType; got != want {
+		t.Errorf("publishing content type = %v, want %v", got, want)
+	}
+	if got, want := msg.MessageId, e.ID; got != want {
+		t.Errorf("publishing message id = %v, want %v", got, want)
+	}
+	if got, want := msg.Type, e.Type; got != want {
+		t.Errorf("publishing type = %v, want %v", got, want)
+	}
+	if got, want := msg.AppId, e.Source; got != want {
+		t.Errorf("publishing app id = %v, want %v", got, want)
+	}
+	if got, want := msg.Timestamp, e.Time; !got.Equal(want) {
+		t.Errorf("publishing timestamp = %v, want %v", got, want)
+	}
+	if id, err := hex.DecodeString(e.ID); eventType == messenger.TypeUserErased && (err != nil || len(id) != 12) {
+		t.Errorf("envelope id = %v, want 12 bytes in hex", e.ID)
+	}
+	// binary mode attributes would contradict the body
+	for k := range msg.Headers {
+		t.Errorf("publishing header %v, want none", k)
+	}
+}
+
 func TestMessenger_SendUserDeletedMessage(t *testing.T) {
 	type fields struct {
 		marshaller       messenger.Marshaller
@@ -56,7 +115,7 @@ func TestMessenger_SendUserDeletedMessage(t *testing.T) {
 		fields        fields
 		args          args
 		wantErr       bool
-		wantPublisher messenger.Publisher
+		wantPublisher *mockPublisher
 	}{
 		{
 			name: "send user deleted message ok",
@@ -73,9 +132,6 @@ func TestMessenger_SendUserDeletedMessage(t *testing.T) {
 			wantPublisher: &mockPublisher{
 				ctx:      dummyCtx,
 				exchange: dummyExchangeName,
-				msg: amqp.Publishing{
-					Body: dummyBodyDeletion,
-				},
 			},
 		},
 		{
@@ -93,10 +149,7 @@ func TestMessenger_SendUserDeletedMessage(t *testing.T) {
 			wantPublisher: &mockPublisher{
 				ctx:      dummyCtx,
 				exchange: dummyExchangeName,
-				msg: amqp.Publishing{
-					Body: dummyBodyDeletion,
-				},
-				err: dummyError,
+				err:      dummyError,
 			},
 		},
 		{
@@ -116,11 +169,16 @@ func TestMessenger_SendUserDeletedMessage(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			m := messenger.New(tt.fields.marshaller, "", tt.fields.amqpExchangeName, tt.fields.publisher)
-			if err := m.SendUserDeletedMessage(tt.args.ctx, tt.args.userID); (err != nil) != tt.wantErr {
+			m := messenger.New(tt.fields.marshaller, dummySource, "", tt.fields.amqpExchangeName, "", tt.fields.publisher)
+			if err := m.SendUserDeletedMessage(tt.args.ctx, dummyEventID, tt.args.userID); (err != nil) != tt.wantErr {
 				t.Errorf("SendUserDeletedMessage() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if got, want := tt.fields.publisher, tt.wantPublisher; !reflect.DeepEqual(got, want) {
+			publisher := tt.fields.publisher.(*mockPublisher)
+			if tt.wantPublisher.exchange != "" {
+				verifyPublishing(t, publisher.msg, messenger.TypeUserDeleted, dummyDataDeletion)
+			}
+			publisher.msg = amqp.Publishing{}
+			if got, want := publisher, tt.wantPublisher; !reflect.DeepEqual(got, want) {
 				t.Errorf("SendUserDeletedMessage() Publisher = %v, wantPublisher = %v", got, want)
 			}
 		})
@@ -142,7 +200,7 @@ func TestMessenger_SendUserUpdatedMessage(t *testing.T) {
 		fields        fields
 		args          args
 		wantErr       bool
-		wantPublisher messenger.Publisher
+		wantPublisher *mockPublisher
 	}{
 		{
 			name: "send user updated message ok",
@@ -159,9 +217,6 @@ func TestMessenger_SendUserUpdatedMessage(t *testing.T) {
 			wantPublisher: &mockPublisher{
 				ctx:      dummyCtx,
 				exchange: dummyExchangeName,
-				msg: amqp.Publishing{
-					Body: dummyBodyUpdate,
-				},
 			},
 		},
 		{
@@ -179,10 +234,7 @@ func TestMessenger_SendUserUpdatedMessage(t *testing.T) {
 			wantPublisher: &mockPublisher{
 				ctx:      dummyCtx,
 				exchange: dummyExchangeName,
-				msg: amqp.Publishing{
-					Body: dummyBodyUpdate,
-				},
-				err: dummyError,
+				err:      dummyError,
 			},
 		},
 		{
@@ -202,11 +254,16 @@ func TestMessenger_SendUserUpdatedMessage(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			m := messenger.New(tt.fields.marshaller, tt.fields.amqpExchangeName, "", tt.fields.publisher)
-			if err := m.SendUserUpdatedMessage(tt.args.ctx, tt.args.user); (err != nil) != tt.wantErr {
+			m := messenger.New(tt.fields.marshaller, dummySource, tt.fields.amqpExchangeName, "", "", tt.fields.publisher)
+			if err := m.SendUserUpdatedMessage(tt.args.ctx, dummyEventID, tt.args.user); (err != nil) != tt.wantErr {
 				t.Errorf("SendUserUpdatedMessage() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if got, want := tt.fields.publisher, tt.wantPublisher; !reflect.DeepEqual(got, want) {
+			publisher := tt.fields.publisher.(*mockPublisher)
+			if tt.wantPublisher.exchange != "" {
+				verifyPublishing(t, publisher.msg, messenger.TypeUserUpdated, dummyBodyUpdate)
+			}
+			publisher.msg = amqp.Publishing{}
+			if got, want := publisher, tt.wantPublisher; !reflect.DeepEqual(got, want) {
 				t.Errorf("SendUserUpdatedMessage() Publisher = %v, wantPublisher = %v", got, want)
 			}
 		})
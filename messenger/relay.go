@@ -0,0 +1,100 @@
+package messenger
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PendingEventsReader reads the events waiting in an outbox, those of each document in the order they were recorded
+type PendingEventsReader[E any] interface {
+	ReadPendingEvents(ctx context.Context) ([]E, error)
+}
+
+// EventAcknowledger removes a sent event from its outbox
+type EventAcknowledger[E any] interface {
+	AcknowledgeEvent(ctx context.Context, event E) error
+}
+
+// Relay drains the outbox of a collection, sending pending events to the broker.
+// An event is only acknowledged after it is sent, so delivery is at-least-once.
+type Relay[E any] struct {
+	reader       PendingEventsReader[E]
+	acknowledger EventAcknowledger[E]
+	key          func(event E) string
+	publish      func(ctx context.Context, event E) error
+	interval     time.Duration
+	maxBackoff   time.Duration
+}
+
+// NewRelay creates a Relay that sends events with publish. Events of the same key, such as the ID of the document
+// they are stored in, are sent in order.
+func NewRelay[E any](
+	reader PendingEventsReader[E],
+	acknowledger EventAcknowledger[E],
+	key func(event E) string,
+	publish func(ctx context.Context, event E) error,
+	interval time.Duration,
+	maxBackoff time.Duration,
+) Relay[E] {
+	return Relay[E]{
+		reader:       reader,
+		acknowledger: acknowledger,
+		key:          key,
+		publish:      publish,
+		interval:     interval,
+		maxBackoff:   maxBackoff,
+	}
+}
+
+// Run drains the outbox every interval until ctx is done, backing off exponentially while draining fails
+func (r Relay[E]) Run(ctx context.Context) error {
+	wait := r.interval
+	for {
+		if err := r.Drain(ctx); err != nil {
+			log.Printf("outbox relay: %s, retrying in %s", err, wait)
+			wait *= 2
+			if wait > r.maxBackoff {
+				wait = r.maxBackoff
+			}
+		} else {
+			wait = r.interval
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Drain sends all pending events once, returning the last error found.
+// After a failure, the remaining events of the same key are left for the next drain, to keep them in order.
+func (r Relay[E]) Drain(ctx context.Context) error {
+	events, err := r.reader.ReadPendingEvents(ctx)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	failedKeys := make(map[string]bool)
+	for _, event := range events {
+		key := r.key(event)
+		if failedKeys[key] {
+			continue
+		}
+		if err := r.relay(ctx, event); err != nil {
+			failedKeys[key] = true
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (r Relay[E]) relay(ctx context.Context, event E) error {
+	if err := r.publish(ctx, event); err != nil {
+		return err
+	}
+	return r.acknowledger.AcknowledgeEvent(ctx, event)
+}
@@ -0,0 +1,129 @@
+package messenger_test
+
+import (
+	"context"
+	"errors"
+	"github.com/gabrielseibel1/gaef/messenger"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type dummyEvent struct {
+	ID  string
+	Key string
+}
+
+type mockOutbox struct {
+	// receive
+	published    []string
+	acknowledged []string
+
+	// return
+	events     []dummyEvent
+	readErr    error
+	publishErr []error
+	ackErr     error
+}
+
+func (m *mockOutbox) ReadPendingEvents(ctx context.Context) ([]dummyEvent, error) {
+	return m.events, m.readErr
+}
+
+func (m *mockOutbox) AcknowledgeEvent(ctx context.Context, event dummyEvent) error {
+	if m.ackErr != nil {
+		return m.ackErr
+	}
+	m.acknowledged = append(m.acknowledged, event.ID)
+	return nil
+}
+
+func (m *mockOutbox) publish(ctx context.Context, event dummyEvent) error {
+	m.published = append(m.published, event.ID)
+	if len(m.publishErr) == 0 {
+		return nil
+	}
+	err := m.publishErr[0]
+	m.publishErr = m.publishErr[1:]
+	return err
+}
+
+func (m *mockOutbox) relay(maxBackoff time.Duration) messenger.Relay[dummyEvent] {
+	key := func(event dummyEvent) string { return event.Key }
+	return messenger.NewRelay[dummyEvent](m, m, key, m.publish, time.Millisecond, maxBackoff)
+}
+
+var dummyEvents = []dummyEvent{
+	{ID: "1", Key: "dummy-key-1"},
+	{ID: "2", Key: "dummy-key-2"},
+	{ID: "3", Key: "dummy-key-1"},
+}
+
+func TestRelay_Drain(t *testing.T) {
+	tests := []struct {
+		name             string
+		outbox           *mockOutbox
+		wantErr          bool
+		wantPublished    []string
+		wantAcknowledged []string
+	}{
+		{
+			name:             "drain ok",
+			outbox:           &mockOutbox{events: dummyEvents},
+			wantPublished:    []string{"1", "2", "3"},
+			wantAcknowledged: []string{"1", "2", "3"},
+		},
+		{
+			name:             "drain publish error keeps events of the key in order",
+			outbox:           &mockOutbox{events: dummyEvents, publishErr: []error{dummyError}},
+			wantErr:          true,
+			wantPublished:    []string{"1", "2"},
+			wantAcknowledged: []string{"2"},
+		},
+		{
+			name:          "drain acknowledge error",
+			outbox:        &mockOutbox{events: dummyEvents[:1], ackErr: dummyError},
+			wantErr:       true,
+			wantPublished: []string{"1"},
+		},
+		{
+			name:    "drain read error",
+			outbox:  &mockOutbox{events: dummyEvents, readErr: dummyError},
+			wantErr: true,
+		},
+		{
+			name:   "drain nothing pending",
+			outbox: &mockOutbox{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.outbox.relay(time.Millisecond).Drain(dummyCtx); (err != nil) != tt.wantErr {
+				t.Errorf("Drain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got, want := tt.outbox.published, tt.wantPublished; !reflect.DeepEqual(got, want) {
+				t.Errorf("Drain() published %v, want %v", got, want)
+			}
+			if got, want := tt.outbox.acknowledged, tt.wantAcknowledged; !reflect.DeepEqual(got, want) {
+				t.Errorf("Drain() acknowledged %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRelay_Run_RetriesUntilDelivered(t *testing.T) {
+	outbox := &mockOutbox{events: dummyEvents[:1], publishErr: []error{dummyError, dummyError}}
+
+	ctx, cancel := context.WithTimeout(dummyCtx, 100*time.Millisecond)
+	defer cancel()
+	if err := outbox.relay(4 * time.Millisecond).Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if got := len(outbox.published); got < 3 {
+		t.Errorf("Run() published %d times, want at least 3", got)
+	}
+	if got := len(outbox.acknowledged); got < 1 {
+		t.Errorf("Run() acknowledged %d times, want at least 1", got)
+	}
+}
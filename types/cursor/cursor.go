@@ -0,0 +1,36 @@
+// Package cursor points at where a page of search results ended, so that clients read the next one without skipping
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Cursor points at the last result of a page, the next page starts right after it.
+// Score is the relevance of the result, for searches ranked by it.
+type Cursor struct {
+	Score float64 `json:"s,omitempty"`
+	ID    string  `json:"id"`
+}
+
+var ErrMalformed = errors.New("malformed cursor")
+
+// Encode makes the cursor opaque, so that clients just pass it back
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Parse reads a cursor made by Encode
+func Parse(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return nil, ErrMalformed
+	}
+	return &c, nil
+}
@@ -0,0 +1,33 @@
+package cursor_test
+
+import (
+	"errors"
+	"github.com/gabrielseibel1/gaef/types/cursor"
+	"testing"
+)
+
+func TestCursor_EncodeAndParse(t *testing.T) {
+	for _, c := range []cursor.Cursor{
+		{Score: 1.5, ID: "642aaa3d3e2f1ec4f0aa0f2a"},
+		{ID: "642aaa3d3e2f1ec4f0aa0f2a"},
+	} {
+		// run code under test
+		parsed, err := cursor.Parse(c.Encode())
+
+		// assertions
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if got, want := *parsed, c; got != want {
+			t.Errorf("got cursor %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestParse_Malformed(t *testing.T) {
+	for _, s := range []string{"", "not base64!", "bm90IGpzb24", cursor.Cursor{Score: 1}.Encode()} {
+		if _, err := cursor.Parse(s); !errors.Is(err, cursor.ErrMalformed) {
+			t.Errorf("Parse(%q) error = %v, want %v", s, err, cursor.ErrMalformed)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Package etag tags resources with their versions, so that clients change them only if nobody did since they read them
+package etag
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	HeaderETag    = "ETag"
+	HeaderIfMatch = "If-Match"
+)
+
+var (
+	// ErrVersionMismatch is the error of changing a resource at a version other than its current one
+	ErrVersionMismatch = errors.New("resource changed meanwhile, read it again")
+	ErrMalformed       = errors.New("If-Match must be a single strong entity tag or *")
+)
+
+// Format makes the strong entity tag of a version
+func Format(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// Parse reads the version in an If-Match header, 0 meaning any version, when there is no header or it is *
+func Parse(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	unquoted, err := strconv.Unquote(header)
+	if err != nil || !strings.HasPrefix(header, `"`) {
+		return 0, ErrMalformed
+	}
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version < 0 {
+		return 0, ErrMalformed
+	}
+	return version, nil
+}
+
+// ConflictError is returned by clients when a change is refused, as the resource changed since the version it was based on
+type ConflictError struct {
+	Resource string
+	ID       string
+	Version  int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %s changed since version %d, read it again", e.Resource, e.ID, e.Version)
+}
+
+// Is makes a ConflictError match ErrVersionMismatch
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrVersionMismatch
+}
@@ -0,0 +1,57 @@
+package etag_test
+
+import (
+	"errors"
+	"github.com/gabrielseibel1/gaef/types/etag"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	if got, want := etag.Format(42), `"42"`; got != want {
+		t.Errorf("Format() = %v, want %v", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    int64
+		wantErr error
+	}{
+		{name: "no header", header: "", want: 0},
+		{name: "any", header: "*", want: 0},
+		{name: "version", header: `"42"`, want: 42},
+		{name: "formatted", header: etag.Format(7), want: 7},
+		{name: "unquoted", header: "42", wantErr: etag.ErrMalformed},
+		{name: "weak", header: `W/"42"`, wantErr: etag.ErrMalformed},
+		{name: "list", header: `"41", "42"`, wantErr: etag.ErrMalformed},
+		{name: "not a version", header: `"dummy"`, wantErr: etag.ErrMalformed},
+		{name: "negative", header: `"-1"`, wantErr: etag.ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := etag.Parse(tt.header)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConflictError(t *testing.T) {
+	var err error = &etag.ConflictError{Resource: "group", ID: "dummy-id", Version: 3}
+	if !errors.Is(err, etag.ErrVersionMismatch) {
+		t.Errorf("errors.Is(%v, ErrVersionMismatch) = false, want true", err)
+	}
+	var conflict *etag.ConflictError
+	if !errors.As(err, &conflict) || conflict.Version != 3 {
+		t.Errorf("errors.As(%v) = %v, want the conflict", err, conflict)
+	}
+	if got, want := err.Error(), "group dummy-id changed since version 3, read it again"; got != want {
+		t.Errorf("Error() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+// Package mergepatch applies JSON Merge Patches (RFC 7396), telling which fields they change,
+// so that stores can update only those instead of replacing whole documents
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ContentType is the media type of JSON Merge Patches
+const ContentType = "application/merge-patch+json"
+
+var (
+	ErrNotAnObject  = errors.New("merge patch must be a JSON object")
+	ErrNotPatchable = errors.New("field can't be patched")
+)
+
+// Patch is a JSON Merge Patch of an object
+type Patch struct {
+	doc map[string]any
+}
+
+// Parse reads a patch, which must be an object, as patches of anything else replace the whole target
+func Parse(data []byte) (Patch, error) {
+	var doc any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return Patch{}, err
+	}
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return Patch{}, ErrNotAnObject
+	}
+	return Patch{doc: obj}, nil
+}
+
+// Allow checks that the patch changes none but the given top level fields
+func (p Patch) Allow(fields ...string) error {
+	allowed := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		allowed[f] = true
+	}
+	for _, f := range sortedKeys(p.doc) {
+		if !allowed[f] {
+			return fmt.Errorf("%w: %s", ErrNotPatchable, f)
+		}
+	}
+	return nil
+}
+
+// Apply merges the patch into v, a pointer to a struct, returning the dot separated paths of the fields it set or removed.
+// Nested objects are merged field by field where v has an object already, and set whole otherwise, as are arrays.
+func (p Patch) Apply(v any) ([]string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var target map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&target); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	merged := merge(target, p.doc, "", &paths)
+	data, err = json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	// decode into a zero value, so that removed fields don't keep their old values
+	rv := reflect.ValueOf(v).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func merge(target map[string]any, patch map[string]any, prefix string, paths *[]string) map[string]any {
+	if target == nil {
+		target = make(map[string]any)
+	}
+	for _, k := range sortedKeys(patch) {
+		pv := patch[k]
+		if pv == nil {
+			delete(target, k)
+			*paths = append(*paths, prefix+k)
+			continue
+		}
+		pobj, patchIsObject := pv.(map[string]any)
+		tobj, targetIsObject := target[k].(map[string]any)
+		if patchIsObject && targetIsObject {
+			target[k] = merge(tobj, pobj, prefix+k+".", paths)
+			continue
+		}
+		if patchIsObject {
+			// merging into nothing drops the nulls of the patch
+			target[k] = merge(nil, pobj, prefix+k+".", new([]string))
+		} else {
+			target[k] = pv
+		}
+		*paths = append(*paths, prefix+k)
+	}
+	return target
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
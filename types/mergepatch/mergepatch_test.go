@@ -0,0 +1,151 @@
+package mergepatch_test
+
+import (
+	"errors"
+	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/mergepatch"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPatch_Apply(t *testing.T) {
+	group := types.Group{
+		ID:          "dummy-id",
+		Name:        "dummy-name",
+		Description: "dummy-description",
+		Members:     []types.User{{ID: "dummy-user-id"}},
+		Tags:        []string{"a", "b"},
+		Location:    &types.Location{Name: "dummy-location", Latitude: 1, Longitude: 2},
+	}
+
+	tests := []struct {
+		name      string
+		patch     string
+		want      func(g types.Group) types.Group
+		wantPaths []string
+	}{
+		{
+			name:      "empty patch",
+			patch:     `{}`,
+			want:      func(g types.Group) types.Group { return g },
+			wantPaths: nil,
+		},
+		{
+			name:  "set fields",
+			patch: `{"name": "new-name", "tags": ["c"]}`,
+			want: func(g types.Group) types.Group {
+				g.Name = "new-name"
+				g.Tags = []string{"c"}
+				return g
+			},
+			wantPaths: []string{"name", "tags"},
+		},
+		{
+			name:  "remove fields",
+			patch: `{"description": null, "location": null}`,
+			want: func(g types.Group) types.Group {
+				g.Description = ""
+				g.Location = nil
+				return g
+			},
+			wantPaths: []string{"description", "location"},
+		},
+		{
+			name:  "merge nested object",
+			patch: `{"location": {"name": "new-location", "latitude": 3}}`,
+			want: func(g types.Group) types.Group {
+				g.Location = &types.Location{Name: "new-location", Latitude: 3, Longitude: 2}
+				return g
+			},
+			wantPaths: []string{"location.latitude", "location.name"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := mergepatch.Parse([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			g := group
+			g.Location = &types.Location{Name: "dummy-location", Latitude: 1, Longitude: 2}
+
+			paths, err := p.Apply(&g)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if got, want := g, tt.want(group); !reflect.DeepEqual(got, want) {
+				t.Errorf("Apply() group = %+v, want %+v", got, want)
+			}
+			if got, want := paths, tt.wantPaths; !reflect.DeepEqual(got, want) {
+				t.Errorf("Apply() paths = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPatch_Apply_ObjectIntoNothing(t *testing.T) {
+	group := types.Group{Name: "dummy-name"}
+	p, err := mergepatch.Parse([]byte(`{"location": {"name": "new-location", "latitude": 3, "longitude": null}}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	paths, err := p.Apply(&group)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got, want := group.Location, (&types.Location{Name: "new-location", Latitude: 3}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply() location = %+v, want %+v", got, want)
+	}
+	// set whole, as there is no object to merge into
+	if got, want := paths, []string{"location"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply() paths = %v, want %v", got, want)
+	}
+}
+
+func TestPatch_Apply_KeepsTypes(t *testing.T) {
+	ep := types.EncounterProposal{EncounterSpecification: types.EncounterSpecification{Name: "dummy-name"}}
+	p, err := mergepatch.Parse([]byte(`{"encounterSpecification": {"time": "2023-01-02T15:04:05Z"}}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	paths, err := p.Apply(&ep)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got, want := ep.Time, time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Apply() time = %v, want %v", got, want)
+	}
+	if got, want := ep.Name, "dummy-name"; got != want {
+		t.Errorf("Apply() name = %v, want %v", got, want)
+	}
+	if got, want := paths, []string{"encounterSpecification.time"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Apply() paths = %v, want %v", got, want)
+	}
+}
+
+func TestParse_NotAnObject(t *testing.T) {
+	for _, patch := range []string{`[]`, `"name"`, `null`} {
+		if _, err := mergepatch.Parse([]byte(patch)); !errors.Is(err, mergepatch.ErrNotAnObject) {
+			t.Errorf("Parse(%s) error = %v, want %v", patch, err, mergepatch.ErrNotAnObject)
+		}
+	}
+	if _, err := mergepatch.Parse([]byte(`{`)); err == nil {
+		t.Errorf("Parse() error = nil, want a syntax error")
+	}
+}
+
+func TestPatch_Allow(t *testing.T) {
+	p, err := mergepatch.Parse([]byte(`{"name": "new-name", "members": []}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if err := p.Allow("name", "members"); err != nil {
+		t.Errorf("Allow() error = %v, want nil", err)
+	}
+	if err := p.Allow("name"); !errors.Is(err, mergepatch.ErrNotPatchable) {
+		t.Errorf("Allow() error = %v, want %v", err, mergepatch.ErrNotPatchable)
+	}
+}
@@ -9,14 +9,34 @@ type User struct {
 	Email      string `json:"email" bson:"email"`
 	Name       string `json:"name" bson:"name"`
 	PictureURL string `json:"pictureUrl" bson:"pictureUrl"`
+	// Version counts the changes to the user, by which its copies in other services tell newer updates from older ones
+	Version int64 `json:"version,omitempty" bson:"version,omitempty"`
 }
 
 type UserWithHashedPassword struct {
 	ID             string `json:"id" bson:"_id,omitempty"`
 	User           `json:"user" bson:"user"`
 	HashedPassword string `json:"hashedPassword" bson:"hashedPassword"`
+	Verified       bool   `json:"verified" bson:"verified"`
+	MFAEnabled     bool   `json:"mfaEnabled" bson:"mfaEnabled"`
+	Role           string `json:"role" bson:"role"`
+	Suspended      bool   `json:"suspended" bson:"suspended"`
+	// Identities are the accounts of the user at OpenID providers, users created with one have no password
+	Identities []Identity `json:"identities,omitempty" bson:"identities,omitempty"`
 }
 
+// Identity is an account of a user at an OpenID provider, which it can log in with
+type Identity struct {
+	Issuer  string `json:"issuer" bson:"issuer"`
+	Subject string `json:"subject" bson:"subject"`
+}
+
+// roles of users, a user without a role is a RoleUser
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type Group struct {
 	ID          string `json:"id" bson:"_id,omitempty"`
 	Name        string `json:"name" bson:"name"`
@@ -24,6 +44,57 @@ type Group struct {
 	Description string `json:"description" bson:"description"`
 	Members     []User `json:"members" bson:"members"`
 	Leaders     []User `json:"leaders" bson:"leaders"`
+	Visibility  string `json:"visibility" bson:"visibility"`
+	// Tags and Category help users discover public groups
+	Tags     []string `json:"tags" bson:"tags"`
+	Category string   `json:"category" bson:"category"`
+	// Location is the home base of the group, if it has one, for users to find groups near them
+	Location *Location `json:"location,omitempty" bson:"location,omitempty"`
+	// Version counts the changes to the group, its ETag, which the store sets and leaves out of updates when zero
+	Version int64 `json:"version" bson:"version,omitempty"`
+}
+
+// GroupSummary is what users discovering groups see of them, which leaves out who is in them
+type GroupSummary struct {
+	ID          string    `json:"id" bson:"_id,omitempty"`
+	Name        string    `json:"name" bson:"name"`
+	PictureURL  string    `json:"pictureUrl" bson:"pictureUrl"`
+	Description string    `json:"description" bson:"description"`
+	Tags        []string  `json:"tags" bson:"tags"`
+	Category    string    `json:"category" bson:"category"`
+	MemberCount int       `json:"memberCount" bson:"memberCount"`
+	Location    *Location `json:"location,omitempty" bson:"location,omitempty"`
+}
+
+// NearbyGroup is a GroupSummary along with how far its home base is from where the user searched
+type NearbyGroup struct {
+	GroupSummary `bson:",inline"`
+	DistanceKm   float64 `json:"distanceKm" bson:"distanceKm"`
+}
+
+// visibilities of groups, a group without a visibility is a GroupPrivate
+const (
+	GroupPublic  = "public"  // anyone may ask to join
+	GroupPrivate = "private" // only invited users may join
+)
+
+// Invitation is a pending invitation of a user, by a leader of a group, to become a member of the group
+type Invitation struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	GroupID   string    `json:"groupId" bson:"groupId"`
+	GroupName string    `json:"groupName" bson:"groupName"`
+	InviteeID string    `json:"inviteeId" bson:"inviteeId"`
+	Inviter   User      `json:"inviter" bson:"inviter"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// JoinRequest is a pending request of a user to become a member of a public group, for its leaders to approve or deny
+type JoinRequest struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	GroupID   string    `json:"groupId" bson:"groupId"`
+	Requester User      `json:"requester" bson:"requester"`
+	Message   string    `json:"message" bson:"message"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
 }
 
 type EncounterProposal struct {
@@ -31,6 +102,7 @@ type EncounterProposal struct {
 	EncounterSpecification `json:"encounterSpecification" bson:"encounterSpecification"`
 	Creator                Group         `json:"creator" bson:"creator"`
 	Applications           []Application `json:"applications" bson:"applications"`
+	Version                int64         `json:"version" bson:"version,omitempty"`
 }
 
 type EncounterSpecification struct {
@@ -57,4 +129,5 @@ type Encounter struct {
 	Groups                 []Group `json:"groups" bson:"groups"`
 	InvitedUsers           []User  `json:"invitedUsers" bson:"invitedUsers"`
 	ConfirmedUsers         []User  `json:"confirmedUsers" bson:"confirmedUsers"`
+	Version                int64   `json:"version" bson:"version,omitempty"`
 }
@@ -0,0 +1,83 @@
+package apikey
+
+import (
+	"errors"
+	"github.com/gabrielseibel1/gaef/auth"
+	"github.com/gabrielseibel1/gaef/user/secret"
+	"strings"
+	"time"
+)
+
+// APIKey is a named credential of a user for scripts and other services, limited to its scopes until it expires.
+// Only the hash of the key is stored, the key itself is shown once when created.
+type APIKey struct {
+	ID         string     `bson:"_id" json:"id"`
+	UserID     string     `bson:"userID" json:"userID"`
+	Name       string     `bson:"name" json:"name"`
+	Scopes     []string   `bson:"scopes" json:"scopes"`
+	Hash       string     `bson:"hash" json:"-"`
+	CreatedAt  time.Time  `bson:"createdAt" json:"createdAt"`
+	ExpiresAt  time.Time  `bson:"expiresAt" json:"expiresAt"`
+	LastUsedAt *time.Time `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+}
+
+// Prefix tells API keys apart from other secrets, e.g. when they leak into logs or repositories
+const Prefix = "gaef_"
+
+var (
+	ErrMalformedKey  = errors.New("malformed api key")
+	ErrInvalidScopes = errors.New("scopes must be read, write or both")
+)
+
+// New creates a key for the user that expires after ttl, returning it along with the key to be shown to the user
+func New(userID, name string, scopes []string, ttl time.Duration) (APIKey, string, error) {
+	if err := ValidateScopes(scopes); err != nil {
+		return APIKey{}, "", err
+	}
+	id, err := secret.New(12)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	s, err := secret.New(32)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	key := Prefix + id + "." + s
+	now := time.Now()
+	return APIKey{
+		ID:        id,
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		Hash:      secret.Hash(key),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, key, nil
+}
+
+// Parse returns the ID of the API key and the hash of the key
+func Parse(key string) (string, string, error) {
+	if !strings.HasPrefix(key, Prefix) {
+		return "", "", ErrMalformedKey
+	}
+	id, s, ok := strings.Cut(key[len(Prefix):], ".")
+	if !ok || id == "" || s == "" {
+		return "", "", ErrMalformedKey
+	}
+	return id, secret.Hash(key), nil
+}
+
+// ValidateScopes checks that scopes are known and not repeated
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return ErrInvalidScopes
+	}
+	seen := make(map[string]bool, len(scopes))
+	for _, s := range scopes {
+		if (s != auth.ScopeRead && s != auth.ScopeWrite) || seen[s] {
+			return ErrInvalidScopes
+		}
+		seen[s] = true
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package apikey_test
+
+import (
+	"errors"
+	"github.com/gabrielseibel1/gaef/user/apikey"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	// run code under test
+	k, key, err := apikey.New("dummyID", "ci", []string{"read", "write"}, time.Hour)
+
+	// assertions
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !strings.HasPrefix(key, apikey.Prefix) {
+		t.Errorf("New() key = %s, want prefix %s", key, apikey.Prefix)
+	}
+	id, hash, err := apikey.Parse(key)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if id != k.ID {
+		t.Errorf("Parse() id = %s, want %s", id, k.ID)
+	}
+	if hash != k.Hash {
+		t.Errorf("Parse() hash = %s, want %s", hash, k.Hash)
+	}
+	if strings.Contains(key, k.Hash) {
+		t.Errorf("New() key contains its hash")
+	}
+	if got, want := k.ExpiresAt.Sub(k.CreatedAt), time.Hour; got != want {
+		t.Errorf("New() key valid for %v, want %v", got, want)
+	}
+}
+
+func TestParse_Malformed(t *testing.T) {
+	for _, key := range []string{"", "gaef_", "gaef_id", "gaef_.secret", "gaef_id.", "id.secret"} {
+		if _, _, err := apikey.Parse(key); !errors.Is(err, apikey.ErrMalformedKey) {
+			t.Errorf("Parse(%q) error = %v, want %v", key, err, apikey.ErrMalformedKey)
+		}
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		scopes  []string
+		wantErr bool
+	}{
+		{scopes: []string{"read"}},
+		{scopes: []string{"write"}},
+		{scopes: []string{"read", "write"}},
+		{scopes: nil, wantErr: true},
+		{scopes: []string{"admin"}, wantErr: true},
+		{scopes: []string{"read", "read"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := apikey.ValidateScopes(tt.scopes); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateScopes(%v) error = %v, want error %v", tt.scopes, err, tt.wantErr)
+		}
+	}
+}
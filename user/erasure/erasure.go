@@ -0,0 +1,56 @@
+package erasure
+
+import (
+	"github.com/gabrielseibel1/gaef/messenger"
+	"time"
+)
+
+// Services are the services that hold data of a user, each of which must confirm it erased it
+var Services = []string{
+	messenger.ServiceUser,
+	messenger.ServiceGroup,
+	messenger.ServiceEncounterProposal,
+	messenger.ServiceEncounter,
+}
+
+// Erasure tracks the purge of the data of a deleted user across services.
+// Confirmations may arrive before the erasure is requested, so a service counts as erased by its time alone.
+type Erasure struct {
+	UserID      string               `bson:"_id"`
+	RequestedAt time.Time            `bson:"requestedAt"`
+	Services    []string             `bson:"services"`
+	ErasedAt    map[string]time.Time `bson:"erasedAt"`
+}
+
+// ServiceStatus tells if a service already purged the data of the user
+type ServiceStatus struct {
+	Service  string     `json:"service"`
+	Erased   bool       `json:"erased"`
+	ErasedAt *time.Time `json:"erasedAt,omitempty"`
+}
+
+// Status is what is shown of an erasure
+type Status struct {
+	UserID      string          `json:"userId"`
+	RequestedAt time.Time       `json:"requestedAt"`
+	Services    []ServiceStatus `json:"services"`
+	Complete    bool            `json:"complete"`
+}
+
+func (e Erasure) Status() Status {
+	status := Status{
+		UserID:      e.UserID,
+		RequestedAt: e.RequestedAt,
+		Services:    make([]ServiceStatus, 0, len(e.Services)),
+		Complete:    !e.RequestedAt.IsZero(),
+	}
+	for _, service := range e.Services {
+		s := ServiceStatus{Service: service}
+		if erasedAt, ok := e.ErasedAt[service]; ok {
+			s.Erased, s.ErasedAt = true, &erasedAt
+		}
+		status.Complete = status.Complete && s.Erased
+		status.Services = append(status.Services, s)
+	}
+	return status
+}
@@ -0,0 +1,62 @@
+package erasure_test
+
+import (
+	"github.com/gabrielseibel1/gaef/user/erasure"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestErasure_Status(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		erasure      erasure.Erasure
+		wantErased   []bool
+		wantComplete bool
+	}{
+		{
+			name:         "pending",
+			erasure:      erasure.Erasure{UserID: "dummyID", RequestedAt: now, Services: []string{"user", "group"}, ErasedAt: map[string]time.Time{"user": now}},
+			wantErased:   []bool{true, false},
+			wantComplete: false,
+		},
+		{
+			name:         "complete",
+			erasure:      erasure.Erasure{UserID: "dummyID", RequestedAt: now, Services: []string{"user", "group"}, ErasedAt: map[string]time.Time{"user": now, "group": now}},
+			wantErased:   []bool{true, true},
+			wantComplete: true,
+		},
+		{
+			name:         "confirmed before requested",
+			erasure:      erasure.Erasure{UserID: "dummyID", ErasedAt: map[string]time.Time{"group": now}},
+			wantErased:   []bool{},
+			wantComplete: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// run code under test
+			status := tt.erasure.Status()
+
+			// assertions
+			erased := make([]bool, 0, len(status.Services))
+			for i, s := range status.Services {
+				if got, want := s.Service, tt.erasure.Services[i]; got != want {
+					t.Errorf("got service %s, want %s", got, want)
+				}
+				if s.Erased != (s.ErasedAt != nil) {
+					t.Errorf("got erased %v at %v, want both or neither", s.Erased, s.ErasedAt)
+				}
+				erased = append(erased, s.Erased)
+			}
+			if got, want := erased, tt.wantErased; !reflect.DeepEqual(got, want) {
+				t.Errorf("got erased %v, want %v", got, want)
+			}
+			if got, want := status.Complete, tt.wantComplete; got != want {
+				t.Errorf("got complete %v, want %v", got, want)
+			}
+		})
+	}
+}
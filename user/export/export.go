@@ -0,0 +1,165 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/user/session"
+	"io"
+	"sort"
+)
+
+// Archive is what the services hold about a user, by section
+type Archive map[string]any
+
+// Account is what the user service holds about a user, all but its password
+type Account struct {
+	User       types.User `json:"user"`
+	Verified   bool       `json:"verified"`
+	MFAEnabled bool       `json:"mfaEnabled"`
+	Role       string     `json:"role"`
+	Suspended  bool       `json:"suspended"`
+
+	Identities []types.Identity `json:"identities,omitempty"`
+}
+
+// Groups are the groups a user takes part in, and the ones it leads
+type Groups struct {
+	Participating []types.Group `json:"participating"`
+	Leading       []types.Group `json:"leading"`
+}
+
+// SourceError tells which service the data of a user couldn't be read from
+type SourceError struct {
+	Source string
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	return "failed to export data from the " + e.Source + " service: " + e.Err.Error()
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// dependencies
+
+type AccountReader interface {
+	ReadSensitiveByID(ctx context.Context, id string) (types.UserWithHashedPassword, error)
+}
+type SessionLister interface {
+	ReadSessions(ctx context.Context, userID string) ([]session.Session, error)
+}
+type ParticipatingGroupsReader interface {
+	ParticipatingGroups(ctx context.Context, token string) ([]types.Group, error)
+}
+type LeadingGroupsReader interface {
+	LeadingGroups(ctx context.Context, token string) ([]types.Group, error)
+}
+type EncounterProposalsReader interface {
+	Mine(ctx context.Context, token string) ([]types.EncounterProposal, error)
+}
+type EncountersReader interface {
+	GetUserEncounters(ctx context.Context, token string) ([]types.Encounter, error)
+}
+
+// implementation
+
+// Exporter collects the data of a user from all services, reading the other services on behalf of the user
+type Exporter struct {
+	accountReader             AccountReader
+	sessionLister             SessionLister
+	participatingGroupsReader ParticipatingGroupsReader
+	leadingGroupsReader       LeadingGroupsReader
+	encounterProposalsReader  EncounterProposalsReader
+	encountersReader          EncountersReader
+}
+
+func New(
+	accountReader AccountReader,
+	sessionLister SessionLister,
+	participatingGroupsReader ParticipatingGroupsReader,
+	leadingGroupsReader LeadingGroupsReader,
+	encounterProposalsReader EncounterProposalsReader,
+	encountersReader EncountersReader,
+) Exporter {
+	return Exporter{
+		accountReader:             accountReader,
+		sessionLister:             sessionLister,
+		participatingGroupsReader: participatingGroupsReader,
+		leadingGroupsReader:       leadingGroupsReader,
+		encounterProposalsReader:  encounterProposalsReader,
+		encountersReader:          encountersReader,
+	}
+}
+
+// ExportUserData collects the data of the user, with the token of the user to read the other services.
+// Failing to read the account is returned as is, failing to read a service as a *SourceError.
+func (e Exporter) ExportUserData(ctx context.Context, token, id string) (Archive, error) {
+	u, err := e.accountReader.ReadSensitiveByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := e.sessionLister.ReadSessions(ctx, id)
+	if err != nil {
+		return nil, &SourceError{Source: "user", Err: err}
+	}
+
+	var groups Groups
+	groups.Participating, err = e.participatingGroupsReader.ParticipatingGroups(ctx, token)
+	if err != nil {
+		return nil, &SourceError{Source: "group", Err: err}
+	}
+	groups.Leading, err = e.leadingGroupsReader.LeadingGroups(ctx, token)
+	if err != nil {
+		return nil, &SourceError{Source: "group", Err: err}
+	}
+	proposals, err := e.encounterProposalsReader.Mine(ctx, token)
+	if err != nil {
+		return nil, &SourceError{Source: "encounter-proposal", Err: err}
+	}
+	encounters, err := e.encountersReader.GetUserEncounters(ctx, token)
+	if err != nil {
+		return nil, &SourceError{Source: "encounter", Err: err}
+	}
+
+	return Archive{
+		"account": Account{
+			User:       u.User,
+			Verified:   u.Verified,
+			MFAEnabled: u.MFAEnabled,
+			Role:       u.Role,
+			Suspended:  u.Suspended,
+			Identities: u.Identities,
+		},
+		"sessions":           sessions,
+		"groups":             groups,
+		"encounterProposals": proposals,
+		"encounters":         encounters,
+	}, nil
+}
+
+// WriteZIP writes the archive as a ZIP file with a JSON document per section
+func (a Archive) WriteZIP(w io.Writer) error {
+	sections := make([]string, 0, len(a))
+	for section := range a {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+
+	zw := zip.NewWriter(w)
+	for _, section := range sections {
+		f, err := zw.Create(section + ".json")
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(a[section]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
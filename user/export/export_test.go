@@ -0,0 +1,180 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/user/export"
+	"github.com/gabrielseibel1/gaef/user/session"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type mockServices struct {
+	// receive
+	id     string
+	tokens []string
+
+	// return
+	user        types.UserWithHashedPassword
+	userErr     error
+	sessionsErr error
+	groupsErr   error
+}
+
+var (
+	dummyUser       = types.User{ID: "dummyID", Name: "dummyName", Email: "dummy@gaef.io"}
+	dummySessions   = []session.Session{{ID: "dummySessionID", UserID: "dummyID", RefreshTokenHash: "dummyHash"}}
+	dummyGroups     = []types.Group{{ID: "dummyGroupID", Name: "dummyGroupName"}}
+	dummyProposals  = []types.EncounterProposal{{ID: "dummyProposalID"}}
+	dummyEncounters = []types.Encounter{{ID: "dummyEncounterID"}}
+	dummyError      = errors.New("dummy error")
+)
+
+func (m *mockServices) ReadSensitiveByID(ctx context.Context, id string) (types.UserWithHashedPassword, error) {
+	m.id = id
+	return m.user, m.userErr
+}
+
+func (m *mockServices) ReadSessions(ctx context.Context, userID string) ([]session.Session, error) {
+	return dummySessions, m.sessionsErr
+}
+
+func (m *mockServices) ParticipatingGroups(ctx context.Context, token string) ([]types.Group, error) {
+	m.tokens = append(m.tokens, token)
+	return dummyGroups, m.groupsErr
+}
+
+func (m *mockServices) LeadingGroups(ctx context.Context, token string) ([]types.Group, error) {
+	m.tokens = append(m.tokens, token)
+	return nil, m.groupsErr
+}
+
+func (m *mockServices) Mine(ctx context.Context, token string) ([]types.EncounterProposal, error) {
+	m.tokens = append(m.tokens, token)
+	return dummyProposals, nil
+}
+
+func (m *mockServices) GetUserEncounters(ctx context.Context, token string) ([]types.Encounter, error) {
+	m.tokens = append(m.tokens, token)
+	return dummyEncounters, nil
+}
+
+func newExporter(m *mockServices) export.Exporter {
+	return export.New(m, m, m, m, m, m)
+}
+
+func TestExporter_ExportUserData(t *testing.T) {
+	// prepare test setup
+	m := &mockServices{user: types.UserWithHashedPassword{ID: "dummyID", User: dummyUser, HashedPassword: "dummyHash", Verified: true, Role: types.RoleUser}}
+
+	// run code under test
+	archive, err := newExporter(m).ExportUserData(context.TODO(), "dummyToken", "dummyID")
+
+	// assertions
+	if err != nil {
+		t.Fatalf("ExportUserData() error = %v", err)
+	}
+	if got, want := m.id, "dummyID"; got != want {
+		t.Errorf("got account id %s, want %s", got, want)
+	}
+	if got, want := m.tokens, []string{"dummyToken", "dummyToken", "dummyToken", "dummyToken"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got tokens %v, want %v", got, want)
+	}
+	want := export.Archive{
+		"account":            export.Account{User: dummyUser, Verified: true, Role: types.RoleUser},
+		"sessions":           dummySessions,
+		"groups":             export.Groups{Participating: dummyGroups},
+		"encounterProposals": dummyProposals,
+		"encounters":         dummyEncounters,
+	}
+	if !reflect.DeepEqual(archive, want) {
+		t.Errorf("got archive %v, want %v", archive, want)
+	}
+
+	// no secrets are exported
+	b, err := json.Marshal(archive)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if bytes.Contains(b, []byte("dummyHash")) {
+		t.Errorf("got archive with hashes %s, want none", b)
+	}
+}
+
+func TestExporter_ExportUserData_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		services   *mockServices
+		wantErr    error
+		wantSource string
+	}{
+		{name: "no such user", services: &mockServices{userErr: dummyError}, wantErr: dummyError},
+		{name: "sessions error", services: &mockServices{sessionsErr: dummyError}, wantErr: dummyError, wantSource: "user"},
+		{name: "group service error", services: &mockServices{groupsErr: dummyError}, wantErr: dummyError, wantSource: "group"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// run code under test
+			_, err := newExporter(tt.services).ExportUserData(context.TODO(), "dummyToken", "dummyID")
+
+			// assertions
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExportUserData() error = %v, want %v", err, tt.wantErr)
+			}
+			var sourceErr *export.SourceError
+			if errors.As(err, &sourceErr) != (tt.wantSource != "") {
+				t.Fatalf("ExportUserData() error = %v, want source %q", err, tt.wantSource)
+			}
+			if tt.wantSource != "" && sourceErr.Source != tt.wantSource {
+				t.Errorf("got source %s, want %s", sourceErr.Source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestArchive_WriteZIP(t *testing.T) {
+	// prepare test setup
+	archive := export.Archive{
+		"account":    export.Account{User: dummyUser},
+		"encounters": dummyEncounters,
+	}
+	var buf bytes.Buffer
+
+	// run code under test
+	err := archive.WriteZIP(&buf)
+
+	// assertions
+	if err != nil {
+		t.Fatalf("WriteZIP() error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if got, want := names, []string{"account.json", "encounters.json"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got files %v, want %v", got, want)
+	}
+	f, err := zr.Open("account.json")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	b, _ := io.ReadAll(f)
+	var account export.Account
+	if err := json.Unmarshal(b, &account); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if got, want := account.User, dummyUser; got != want {
+		t.Errorf("got account user %v, want %v", got, want)
+	}
+}
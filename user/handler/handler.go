@@ -1,10 +1,29 @@
 package handler
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"github.com/gabrielseibel1/gaef/auth"
+	"github.com/gabrielseibel1/gaef/blob"
+	"github.com/gabrielseibel1/gaef/blob/picture"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/cursor"
+	"github.com/gabrielseibel1/gaef/user/apikey"
+	"github.com/gabrielseibel1/gaef/user/erasure"
+	"github.com/gabrielseibel1/gaef/user/export"
+	"github.com/gabrielseibel1/gaef/user/mfa"
+	"github.com/gabrielseibel1/gaef/user/oidc"
+	"github.com/gabrielseibel1/gaef/user/search"
+	"github.com/gabrielseibel1/gaef/user/secret"
+	"github.com/gabrielseibel1/gaef/user/session"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -34,92 +53,587 @@ type Updater interface {
 type Deleter interface {
 	Delete(ctx context.Context, id string) error
 }
-type UpdateMessenger interface {
-	SendUserUpdatedMessage(ctx context.Context, user types.User) error
+type TokenSigner interface {
+	Sign(claims jwt.MapClaims) (string, error)
 }
-type DeleteMessenger interface {
-	SendUserDeletedMessage(ctx context.Context, userID string) error
+type VerificationKeyReader interface {
+	VerificationKey(token *jwt.Token) (interface{}, error)
+}
+type KeySetReader interface {
+	KeySet() []auth.JWK
+}
+type SessionCreator interface {
+	CreateSession(ctx context.Context, s session.Session) error
+}
+type SessionRotator interface {
+	RotateRefreshToken(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) (session.Session, error)
+}
+type SessionReader interface {
+	ReadSession(ctx context.Context, id string) (session.Session, error)
+}
+type SessionLister interface {
+	ReadSessions(ctx context.Context, userID string) ([]session.Session, error)
+}
+type SessionRevoker interface {
+	RevokeSession(ctx context.Context, userID, id string) error
+}
+type AllSessionsRevoker interface {
+	RevokeSessions(ctx context.Context, userID string) error
+}
+type OtherSessionsRevoker interface {
+	RevokeOtherSessions(ctx context.Context, userID, keepID string) error
+}
+type SensitiveByIDReader interface {
+	ReadSensitiveByID(ctx context.Context, id string) (types.UserWithHashedPassword, error)
+}
+type PasswordUpdater interface {
+	UpdatePassword(ctx context.Context, id, hashedPassword string) error
+}
+type PasswordResetCreator interface {
+	CreatePasswordReset(ctx context.Context, id, tokenHash string, expiresAt time.Time) error
+}
+type PasswordResetter interface {
+	ResetPassword(ctx context.Context, tokenHash, hashedPassword string) (string, error)
+}
+type PasswordResetNotifier interface {
+	NotifyPasswordReset(ctx context.Context, user types.User, token string) error
+}
+type VerificationNotifier interface {
+	NotifyVerification(ctx context.Context, user types.User, code string) error
+}
+type EmailVerifier interface {
+	VerifyEmail(ctx context.Context, id, email string) error
+}
+type LoginThrottler interface {
+	RetryAfter(ctx context.Context, keys ...string) (time.Duration, error)
+}
+type LoginFailureRecorder interface {
+	RecordFailure(ctx context.Context, keys ...string) error
+}
+type LoginAttemptsResetter interface {
+	ResetAttempts(ctx context.Context, keys ...string) error
+}
+type MFAEnrollmentCreator interface {
+	CreateMFAEnrollment(ctx context.Context, id, secret string) error
+}
+type MFAReader interface {
+	ReadMFA(ctx context.Context, id string) (mfa.Enrollment, error)
+}
+type MFAEnabler interface {
+	EnableMFA(ctx context.Context, id, secret string, recoveryCodeHashes []string, step int64) error
+}
+type TOTPStepUser interface {
+	UseTOTPStep(ctx context.Context, id string, step int64) error
+}
+type RecoveryCodeUser interface {
+	UseRecoveryCode(ctx context.Context, id, codeHash string) error
+}
+type RecoveryCodesReplacer interface {
+	ReplaceRecoveryCodes(ctx context.Context, id string, recoveryCodeHashes []string) error
+}
+type RehashChecker interface {
+	NeedsRehash(hashedPassword string) bool
+}
+type PasswordRehasher interface {
+	RehashPassword(ctx context.Context, id, oldHash, newHash string) error
+}
+type UsersReader interface {
+	ReadUsers(ctx context.Context, query string, skip, limit int64) ([]types.UserWithHashedPassword, error)
+}
+type SuspensionSetter interface {
+	SetSuspended(ctx context.Context, id string, suspended bool) error
+}
+type RoleSetter interface {
+	SetRole(ctx context.Context, id, role string) error
+}
+type UserSearcher interface {
+	SearchUsers(ctx context.Context, q search.Query) ([]types.User, *cursor.Cursor, error)
+}
+type PictureSaver interface {
+	SavePicture(ctx context.Context, owner string, r io.Reader) (picture.Picture, error)
+}
+type PictureOpener interface {
+	OpenPicture(ctx context.Context, name string) (io.ReadCloser, string, error)
+}
+type PictureDeleter interface {
+	DeletePicture(ctx context.Context, owner, url string) error
+}
+type UserDataExporter interface {
+	ExportUserData(ctx context.Context, token, id string) (export.Archive, error)
+}
+type ErasureCreator interface {
+	CreateErasure(ctx context.Context, userID string, services []string) error
+}
+type ErasureReader interface {
+	ReadErasure(ctx context.Context, userID string) (erasure.Erasure, error)
+}
+type APIKeyCreator interface {
+	CreateAPIKey(ctx context.Context, k apikey.APIKey) error
+}
+type APIKeyReader interface {
+	ReadAPIKey(ctx context.Context, id string) (apikey.APIKey, error)
+}
+type APIKeysLister interface {
+	ReadAPIKeys(ctx context.Context, userID string) ([]apikey.APIKey, error)
+}
+type APIKeyUser interface {
+	UseAPIKey(ctx context.Context, id, hash string) (apikey.APIKey, error)
+}
+type APIKeyRevoker interface {
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+}
+type AllAPIKeysRevoker interface {
+	RevokeAPIKeys(ctx context.Context, userID string) error
+}
+type OIDCAuthorizer interface {
+	AuthorizationURL(state, nonce, codeChallenge string) string
+}
+type OIDCExchanger interface {
+	Exchange(ctx context.Context, code, codeVerifier string) (oidc.Claims, error)
+}
+type ByIdentityReader interface {
+	ReadSensitiveByIdentity(ctx context.Context, issuer, subject string) (types.UserWithHashedPassword, error)
+}
+type IdentityLinker interface {
+	LinkIdentity(ctx context.Context, id string, identity types.Identity) error
+}
+
+// Users are the user store and the password hashing of the users in it
+type Users struct {
+	Hasher        PasswordHasher
+	Verifier      PasswordVerifier
+	Creator       Creator
+	ByIDReader    ByIDReader
+	ByEmailReader ByEmailReader
+	Updater       Updater
+	Deleter       Deleter
+	Searcher      UserSearcher
+}
+
+// Tokens sign access tokens and publish the keys that verify them
+type Tokens struct {
+	Signer       TokenSigner
+	KeyReader    VerificationKeyReader
+	KeySetReader KeySetReader
+}
+
+// Sessions keep the refresh tokens of logged in users
+type Sessions struct {
+	Creator      SessionCreator
+	Rotator      SessionRotator
+	Reader       SessionReader
+	Lister       SessionLister
+	Revoker      SessionRevoker
+	AllRevoker   AllSessionsRevoker
+	OtherRevoker OtherSessionsRevoker
+}
+
+// Passwords change, reset and rehash the passwords of users
+type Passwords struct {
+	SensitiveByIDReader SensitiveByIDReader
+	Updater             PasswordUpdater
+	ResetCreator        PasswordResetCreator
+	Resetter            PasswordResetter
+	ResetNotifier       PasswordResetNotifier
+	RehashChecker       RehashChecker
+	Rehasher            PasswordRehasher
+}
+
+// Verification confirms that users own their email addresses
+type Verification struct {
+	Notifier      VerificationNotifier
+	EmailVerifier EmailVerifier
+}
+
+// Logins throttle repeated failed logins
+type Logins struct {
+	Throttler        LoginThrottler
+	FailureRecorder  LoginFailureRecorder
+	AttemptsResetter LoginAttemptsResetter
+}
+
+// MFA enrolls users in and checks their second factors
+type MFA struct {
+	EnrollmentCreator     MFAEnrollmentCreator
+	Reader                MFAReader
+	Enabler               MFAEnabler
+	TOTPStepUser          TOTPStepUser
+	RecoveryCodeUser      RecoveryCodeUser
+	RecoveryCodesReplacer RecoveryCodesReplacer
+}
+
+// Admin lets administrators list, suspend and promote users
+type Admin struct {
+	UsersReader      UsersReader
+	SuspensionSetter SuspensionSetter
+	RoleSetter       RoleSetter
+}
+
+// Pictures store the profile pictures of users
+type Pictures struct {
+	Saver   PictureSaver
+	Opener  PictureOpener
+	Deleter PictureDeleter
+}
+
+// Privacy exports the data of users and tracks its erasure once they are deleted
+type Privacy struct {
+	Exporter       UserDataExporter
+	ErasureCreator ErasureCreator
+	ErasureReader  ErasureReader
+}
+
+// APIKeys issue, exchange and revoke the API keys of users
+type APIKeys struct {
+	Creator    APIKeyCreator
+	Reader     APIKeyReader
+	Lister     APIKeysLister
+	User       APIKeyUser
+	Revoker    APIKeyRevoker
+	AllRevoker AllAPIKeysRevoker
+}
+
+// OIDC logs users in with an external identity provider
+type OIDC struct {
+	Authorizer       OIDCAuthorizer
+	Exchanger        OIDCExchanger
+	ByIdentityReader ByIdentityReader
+	IdentityLinker   IdentityLinker
+}
+
+// Dependencies are everything the handler needs, grouped by the feature that needs it.
+// A feature whose dependencies are left unset must not be routed.
+type Dependencies struct {
+	Users        Users
+	Tokens       Tokens
+	Sessions     Sessions
+	Passwords    Passwords
+	Verification Verification
+	Logins       Logins
+	MFA          MFA
+	Admin        Admin
+	Pictures     Pictures
+	Privacy      Privacy
+	APIKeys      APIKeys
+	OIDC         OIDC
 }
 
 // implementation
 
 type Handler struct {
-	hasher              PasswordHasher
-	verifier            PasswordVerifier
-	creator             Creator
-	byIDReader          ByIDReader
-	byEmailReader       ByEmailReader
-	updater             Updater
-	deleter             Deleter
-	userUpdateMessenger UpdateMessenger
-	userDeleteMessenger DeleteMessenger
-	jwtSecret           []byte
-}
-
-func New(
-	hasher PasswordHasher,
-	verifier PasswordVerifier,
-	creator Creator,
-	byIDReader ByIDReader,
-	byEmailReader ByEmailReader,
-	updater Updater,
-	deleter Deleter,
-	userUpdateMessenger UpdateMessenger,
-	userDeleteMessenger DeleteMessenger,
-	jwtSecret []byte,
-) *Handler {
+	hasher        PasswordHasher
+	verifier      PasswordVerifier
+	creator       Creator
+	byIDReader    ByIDReader
+	byEmailReader ByEmailReader
+	updater       Updater
+	deleter       Deleter
+	signer        TokenSigner
+	keyReader     VerificationKeyReader
+	keySetReader  KeySetReader
+
+	sessionCreator       SessionCreator
+	sessionRotator       SessionRotator
+	sessionReader        SessionReader
+	sessionLister        SessionLister
+	sessionRevoker       SessionRevoker
+	allSessionsRevoker   AllSessionsRevoker
+	otherSessionsRevoker OtherSessionsRevoker
+
+	sensitiveByIDReader   SensitiveByIDReader
+	passwordUpdater       PasswordUpdater
+	passwordResetCreator  PasswordResetCreator
+	passwordResetter      PasswordResetter
+	passwordResetNotifier PasswordResetNotifier
+
+	verificationNotifier VerificationNotifier
+	emailVerifier        EmailVerifier
+
+	loginThrottler        LoginThrottler
+	loginFailureRecorder  LoginFailureRecorder
+	loginAttemptsResetter LoginAttemptsResetter
+
+	mfaEnrollmentCreator  MFAEnrollmentCreator
+	mfaReader             MFAReader
+	mfaEnabler            MFAEnabler
+	totpStepUser          TOTPStepUser
+	recoveryCodeUser      RecoveryCodeUser
+	recoveryCodesReplacer RecoveryCodesReplacer
+
+	rehashChecker    RehashChecker
+	passwordRehasher PasswordRehasher
+
+	usersReader      UsersReader
+	suspensionSetter SuspensionSetter
+	roleSetter       RoleSetter
+
+	userSearcher UserSearcher
+
+	pictureSaver   PictureSaver
+	pictureOpener  PictureOpener
+	pictureDeleter PictureDeleter
+
+	userDataExporter UserDataExporter
+	erasureCreator   ErasureCreator
+	erasureReader    ErasureReader
+
+	apiKeyCreator     APIKeyCreator
+	apiKeyReader      APIKeyReader
+	apiKeysLister     APIKeysLister
+	apiKeyUser        APIKeyUser
+	apiKeyRevoker     APIKeyRevoker
+	allAPIKeysRevoker AllAPIKeysRevoker
+
+	oidcAuthorizer   OIDCAuthorizer
+	oidcExchanger    OIDCExchanger
+	byIdentityReader ByIdentityReader
+	identityLinker   IdentityLinker
+}
+
+func New(d Dependencies) *Handler {
 	return &Handler{
-		hasher:              hasher,
-		verifier:            verifier,
-		creator:             creator,
-		byIDReader:          byIDReader,
-		byEmailReader:       byEmailReader,
-		updater:             updater,
-		deleter:             deleter,
-		userUpdateMessenger: userUpdateMessenger,
-		userDeleteMessenger: userDeleteMessenger,
-		jwtSecret:           jwtSecret,
+		hasher:        d.Users.Hasher,
+		verifier:      d.Users.Verifier,
+		creator:       d.Users.Creator,
+		byIDReader:    d.Users.ByIDReader,
+		byEmailReader: d.Users.ByEmailReader,
+		updater:       d.Users.Updater,
+		deleter:       d.Users.Deleter,
+		signer:        d.Tokens.Signer,
+		keyReader:     d.Tokens.KeyReader,
+		keySetReader:  d.Tokens.KeySetReader,
+
+		sessionCreator:       d.Sessions.Creator,
+		sessionRotator:       d.Sessions.Rotator,
+		sessionReader:        d.Sessions.Reader,
+		sessionLister:        d.Sessions.Lister,
+		sessionRevoker:       d.Sessions.Revoker,
+		allSessionsRevoker:   d.Sessions.AllRevoker,
+		otherSessionsRevoker: d.Sessions.OtherRevoker,
+
+		sensitiveByIDReader:   d.Passwords.SensitiveByIDReader,
+		passwordUpdater:       d.Passwords.Updater,
+		passwordResetCreator:  d.Passwords.ResetCreator,
+		passwordResetter:      d.Passwords.Resetter,
+		passwordResetNotifier: d.Passwords.ResetNotifier,
+
+		verificationNotifier: d.Verification.Notifier,
+		emailVerifier:        d.Verification.EmailVerifier,
+
+		loginThrottler:        d.Logins.Throttler,
+		loginFailureRecorder:  d.Logins.FailureRecorder,
+		loginAttemptsResetter: d.Logins.AttemptsResetter,
+
+		mfaEnrollmentCreator:  d.MFA.EnrollmentCreator,
+		mfaReader:             d.MFA.Reader,
+		mfaEnabler:            d.MFA.Enabler,
+		totpStepUser:          d.MFA.TOTPStepUser,
+		recoveryCodeUser:      d.MFA.RecoveryCodeUser,
+		recoveryCodesReplacer: d.MFA.RecoveryCodesReplacer,
+
+		rehashChecker:    d.Passwords.RehashChecker,
+		passwordRehasher: d.Passwords.Rehasher,
+
+		usersReader:      d.Admin.UsersReader,
+		suspensionSetter: d.Admin.SuspensionSetter,
+		roleSetter:       d.Admin.RoleSetter,
+
+		userSearcher: d.Users.Searcher,
+
+		pictureSaver:   d.Pictures.Saver,
+		pictureOpener:  d.Pictures.Opener,
+		pictureDeleter: d.Pictures.Deleter,
+
+		userDataExporter: d.Privacy.Exporter,
+		erasureCreator:   d.Privacy.ErasureCreator,
+		erasureReader:    d.Privacy.ErasureReader,
+
+		apiKeyCreator:     d.APIKeys.Creator,
+		apiKeyReader:      d.APIKeys.Reader,
+		apiKeysLister:     d.APIKeys.Lister,
+		apiKeyUser:        d.APIKeys.User,
+		apiKeyRevoker:     d.APIKeys.Revoker,
+		allAPIKeysRevoker: d.APIKeys.AllRevoker,
+
+		oidcAuthorizer:   d.OIDC.Authorizer,
+		oidcExchanger:    d.OIDC.Exchanger,
+		byIdentityReader: d.OIDC.ByIdentityReader,
+		identityLinker:   d.OIDC.IdentityLinker,
 	}
 }
 
 func (sh Handler) JWTAuthMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		authHeader := ctx.GetHeader("Authorization")
-		if authHeader == "" || len(authHeader) <= len("Bearer ") {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorMissingAuthorizationHeader)
+		if !sh.authenticate(ctx, true) {
 			return
 		}
-		authHeader = authHeader[len("Bearer "):]
+		ctx.Next()
+	}
+}
 
-		token, err := jwt.Parse(authHeader, func(token *jwt.Token) (interface{}, error) {
-			return sh.jwtSecret, nil
-		})
-		if err != nil || !token.Valid {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorInvalidToken)
+// AdminAuthMiddleware lets admins act on any user. The role is read from the store rather than from the token,
+// so that it can be revoked right away. Admins must log in, as a leaked API key must not give away their powers.
+func (sh Handler) AdminAuthMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !sh.authenticate(ctx, false) {
 			return
 		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorInvalidToken)
+		if ctx.GetString(paramKeyAuthenticatedSessionID) == "" {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, messageErrorSessionRequired)
 			return
 		}
-		tokenUserID, ok := claims["sub"].(string)
-		if !ok {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorInvalidToken)
+		u, err := sh.sensitiveByIDReader.ReadSensitiveByID(ctx, ctx.GetString(paramKeyAuthenticatedUserID))
+		if err != nil || u.Role != types.RoleAdmin {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, messageErrorForbidden)
 			return
 		}
+		ctx.Next()
+	}
+}
 
-		paramUserID := ctx.Param("id")
-		if paramUserID != "" && tokenUserID != paramUserID {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorUnauthorized)
-			return
-		}
+// authenticate checks the token and its session, or the API key, aborting with 401 if they are not valid.
+// With ownerOnly, the token must also belong to the user in the id param, if any.
+func (sh Handler) authenticate(ctx *gin.Context, ownerOnly bool) bool {
+	authHeader := ctx.GetHeader("Authorization")
+	if strings.HasPrefix(authHeader, "ApiKey ") {
+		return sh.authenticateAPIKey(ctx, authHeader[len("ApiKey "):], ownerOnly)
+	}
+	if authHeader == "" || len(authHeader) <= len("Bearer ") {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorMissingAuthorizationHeader)
+		return false
+	}
+	authHeader = authHeader[len("Bearer "):]
+
+	token, err := jwt.Parse(authHeader, sh.keyReader.VerificationKey)
+	if err != nil || !token.Valid {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorInvalidToken)
+		return false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorInvalidToken)
+		return false
+	}
+	tokenUserID, ok := claims["sub"].(string)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorInvalidToken)
+		return false
+	}
+
+	paramUserID := ctx.Param("id")
+	if ownerOnly && paramUserID != "" && tokenUserID != paramUserID {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorUnauthorized)
+		return false
+	}
 
+	// tokens issued for API keys are only as valid as the key, and limited to its scopes
+	if keyID, ok := claims["akid"].(string); ok {
+		k, err := sh.apiKeyReader.ReadAPIKey(ctx, keyID)
+		if err != nil || k.UserID != tokenUserID {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorInvalidAPIKey)
+			return false
+		}
+		if !(auth.Claims{Scopes: k.Scopes}).Allows(ctx.Request.Method) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, messageErrorInsufficientScope)
+			return false
+		}
 		ctx.Set(paramKeyAuthenticatedUserID, tokenUserID)
+		ctx.Set(paramKeyAuthenticatedToken, authHeader)
+		return true
+	}
 
-		ctx.Next()
+	// other tokens are only as valid as the session they were issued for
+	tokenSessionID, ok := claims["sid"].(string)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorInvalidToken)
+		return false
+	}
+	s, err := sh.sessionReader.ReadSession(ctx, tokenSessionID)
+	if err != nil || s.UserID != tokenUserID {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorInvalidSession)
+		return false
+	}
+
+	ctx.Set(paramKeyAuthenticatedUserID, tokenUserID)
+	ctx.Set(paramKeyAuthenticatedSessionID, tokenSessionID)
+	ctx.Set(paramKeyAuthenticatedToken, authHeader)
+	return true
+}
+
+// authenticateAPIKey checks an API key given instead of a token, aborting with 401 if it is not valid.
+// Requests authenticated with keys carry no session, and a token for the key to call other services with.
+func (sh Handler) authenticateAPIKey(ctx *gin.Context, key string, ownerOnly bool) bool {
+	k, u, err := sh.useAPIKey(ctx, key)
+	if errors.Is(err, errSuspended) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, messageErrorSuspended)
+		return false
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorInvalidAPIKey)
+		return false
+	}
+
+	paramUserID := ctx.Param("id")
+	if ownerOnly && paramUserID != "" && k.UserID != paramUserID {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, messageErrorUnauthorized)
+		return false
+	}
+	if !(auth.Claims{Scopes: k.Scopes}).Allows(ctx.Request.Method) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, messageErrorInsufficientScope)
+		return false
+	}
+
+	token, _, err := sh.signAPIKeyToken(u, k)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, messageErrorGenerateToken)
+		return false
+	}
+
+	ctx.Set(paramKeyAuthenticatedUserID, k.UserID)
+	ctx.Set(paramKeyAuthenticatedToken, token)
+	return true
+}
+
+var errSuspended = errors.New("user is suspended")
+
+// useAPIKey reads a valid API key and its user, who must not be suspended
+func (sh Handler) useAPIKey(ctx context.Context, key string) (apikey.APIKey, types.UserWithHashedPassword, error) {
+	id, hash, err := apikey.Parse(key)
+	if err != nil {
+		return apikey.APIKey{}, types.UserWithHashedPassword{}, err
+	}
+	k, err := sh.apiKeyUser.UseAPIKey(ctx, id, hash)
+	if err != nil {
+		return apikey.APIKey{}, types.UserWithHashedPassword{}, err
+	}
+	u, err := sh.sensitiveByIDReader.ReadSensitiveByID(ctx, k.UserID)
+	if err != nil {
+		return apikey.APIKey{}, types.UserWithHashedPassword{}, err
+	}
+	if u.Suspended {
+		return apikey.APIKey{}, types.UserWithHashedPassword{}, errSuspended
 	}
+	return k, u, nil
+}
+
+// signAPIKeyToken signs an access token for an API key, which expires with the key at the latest
+func (sh Handler) signAPIKeyToken(u types.UserWithHashedPassword, k apikey.APIKey) (string, time.Time, error) {
+	expiresAt := time.Now().Add(jwtTTL)
+	if k.ExpiresAt.Before(expiresAt) {
+		expiresAt = k.ExpiresAt
+	}
+	token, err := sh.signer.Sign(jwt.MapClaims{
+		"name":     u.Name,
+		"email":    u.Email,
+		"verified": u.Verified,
+		"role":     role(u),
+		"sub":      u.ID,
+		"akid":     k.ID,
+		"scope":    strings.Join(k.Scopes, " "),
+		"exp":      expiresAt.Unix(),
+	})
+	return token, expiresAt, err
 }
 
 func (sh Handler) Signup() gin.HandlerFunc {
@@ -152,6 +666,12 @@ func (sh Handler) Signup() gin.HandlerFunc {
 			return
 		}
 
+		// the account exists even if the code can't be sent now, and another one can be requested
+		user.User.ID = id
+		if err := sh.sendVerificationCode(ctx, user.User); err != nil {
+			_ = ctx.Error(err)
+		}
+
 		ctx.JSON(http.StatusCreated, gin.H{"id": id})
 	}
 }
@@ -167,112 +687,1441 @@ func (sh Handler) Login() gin.HandlerFunc {
 			return
 		}
 
+		// failed attempts are tracked per email and per IP, to slow down guessing the password of an account
+		// as well as trying a few passwords against many accounts
+		attemptKeys := []string{
+			"email:" + strings.ToLower(strings.TrimSpace(json.Email)),
+			"ip:" + ctx.ClientIP(),
+		}
+		if !sh.checkAttempts(ctx, attemptKeys) {
+			return
+		}
+
 		u, err := sh.byEmailReader.ReadSensitiveByEmail(ctx, json.Email)
+		if err == nil {
+			err = sh.verifier.CompareHashAndPassword(u.HashedPassword, json.Password)
+		}
 		if err != nil {
+			if err := sh.loginFailureRecorder.RecordFailure(ctx, attemptKeys...); err != nil {
+				_ = ctx.Error(err)
+			}
 			ctx.JSON(http.StatusUnauthorized, messageErrorUnauthorized)
 			return
 		}
 
-		err = sh.verifier.CompareHashAndPassword(u.HashedPassword, json.Password)
-		if err != nil {
-			ctx.JSON(http.StatusUnauthorized, messageErrorUnauthorized)
+		if err := sh.loginAttemptsResetter.ResetAttempts(ctx, attemptKeys...); err != nil {
+			_ = ctx.Error(err)
+		}
+
+		// only tell that the account is suspended to whoever knows its password
+		if u.Suspended {
+			ctx.JSON(http.StatusForbidden, messageErrorSuspended)
 			return
 		}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"name":  u.Name,
-			"email": u.Email,
-			"sub":   u.ID,
-			"exp":   time.Now().Add(jwtTTL).Unix(),
+		// the password is only known now, so this is the chance to upgrade a hash of an old algorithm or weak parameters
+		if sh.rehashChecker.NeedsRehash(u.HashedPassword) {
+			if err := sh.rehash(ctx, u, json.Password); err != nil {
+				_ = ctx.Error(err)
+			}
+		}
+
+		sh.completeLogin(ctx, u)
+	}
+}
+
+// completeLogin starts a session for a user who proved its identity, or challenges it for its second factor
+func (sh Handler) completeLogin(ctx *gin.Context, u types.UserWithHashedPassword) {
+	// with a second factor, the first one only earns a challenge to be completed with CompleteMFA
+	if u.MFAEnabled {
+		challengeToken, err := sh.signer.Sign(jwt.MapClaims{
+			"purpose": purposeMFAChallenge,
+			"sub":     u.ID,
+			"exp":     time.Now().Add(mfaChallengeTTL).Unix(),
 		})
-		tokenString, err := token.SignedString(sh.jwtSecret)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateToken)
 			return
 		}
-
-		ctx.JSON(http.StatusOK, gin.H{"token": tokenString})
+		ctx.JSON(http.StatusOK, gin.H{"mfaRequired": true, "challengeToken": challengeToken})
+		return
 	}
+
+	sh.startSession(ctx, u)
 }
 
-func (sh Handler) GetIDFromToken() gin.HandlerFunc {
+// StartOIDCLogin responds with the URL to log in at the OpenID provider, and a state token to complete the login with.
+// The state token keeps what the provider must give back and the PKCE code verifier, signed so that it can't be forged.
+func (sh Handler) StartOIDCLogin() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		id := ctx.GetString(paramKeyAuthenticatedUserID)
-		ctx.JSON(http.StatusOK, gin.H{"id": id})
+		state, err := secret.New(16)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateToken)
+			return
+		}
+		nonce, err := secret.New(16)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateToken)
+			return
+		}
+		verifier, challenge, err := oidc.NewPKCE()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateToken)
+			return
+		}
+		stateToken, err := sh.signer.Sign(jwt.MapClaims{
+			"purpose":  purposeOIDCLogin,
+			"state":    state,
+			"nonce":    nonce,
+			"verifier": verifier,
+			"exp":      time.Now().Add(oidcLoginTTL).Unix(),
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateToken)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"authorizationUrl": sh.oidcAuthorizer.AuthorizationURL(state, nonce, challenge),
+			"stateToken":       stateToken,
+		})
 	}
 }
 
-func (sh Handler) GetUserFromID() gin.HandlerFunc {
+// CompleteOIDCLogin logs in the user with the code the OpenID provider redirected it with, as Login does.
+// The first login with an identity signs the user up without a password, unless its email is taken,
+// in which case the owner of the email must log in and link the identity with LinkOIDCIdentity.
+func (sh Handler) CompleteOIDCLogin() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		id := ctx.GetString(paramKeyAuthenticatedUserID)
-		user, err := sh.byIDReader.ReadByID(ctx, id)
+		claims, ok := sh.readOIDCClaims(ctx)
+		if !ok {
+			return
+		}
+		identity := types.Identity{Issuer: claims.Issuer, Subject: claims.Subject}
+
+		u, err := sh.byIdentityReader.ReadSensitiveByIdentity(ctx, identity.Issuer, identity.Subject)
 		if err != nil {
-			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			u, ok = sh.signupWithIdentity(ctx, claims, identity)
+			if !ok {
+				return
+			}
+		}
+		if u.Suspended {
+			ctx.JSON(http.StatusForbidden, messageErrorSuspended)
 			return
 		}
-		ctx.JSON(http.StatusOK, gin.H{"user": user})
+
+		sh.completeLogin(ctx, u)
 	}
 }
 
-func (sh Handler) UpdateUser() gin.HandlerFunc {
+func (sh Handler) signupWithIdentity(ctx *gin.Context, claims oidc.Claims, identity types.Identity) (types.UserWithHashedPassword, bool) {
+	if claims.Email == "" {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "the identity provider didn't share an email"})
+		return types.UserWithHashedPassword{}, false
+	}
+	if _, err := sh.byEmailReader.ReadSensitiveByEmail(ctx, claims.Email); err == nil {
+		ctx.JSON(http.StatusConflict, messageErrorIdentityNotLinked)
+		return types.UserWithHashedPassword{}, false
+	}
+
+	u := types.UserWithHashedPassword{
+		User:       types.User{Email: claims.Email, Name: claims.Name},
+		Verified:   claims.EmailVerified,
+		Identities: []types.Identity{identity},
+	}
+	id, err := sh.creator.Create(ctx, u)
+	if err != nil {
+		ctx.JSON(http.StatusConflict, messageErrorIdentityNotLinked)
+		return types.UserWithHashedPassword{}, false
+	}
+	u.ID, u.User.ID = id, id
+
+	// providers don't always vouch for emails, which must then be verified as with Signup
+	if !u.Verified {
+		if err := sh.sendVerificationCode(ctx, u.User); err != nil {
+			_ = ctx.Error(err)
+		}
+	}
+	return u, true
+}
+
+// LinkOIDCIdentity lets the user log in with an identity at the OpenID provider from now on
+func (sh Handler) LinkOIDCIdentity() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		id := ctx.GetString(paramKeyAuthenticatedUserID)
-
-		var user types.User
-		if err := ctx.ShouldBindJSON(&user); err != nil {
-			ctx.JSON(http.StatusBadRequest, messageErrorMissingUserData)
+		if ctx.GetString(paramKeyAuthenticatedSessionID) == "" {
+			ctx.JSON(http.StatusForbidden, messageErrorSessionRequired)
 			return
 		}
 
-		if !(id == user.ID) {
-			ctx.JSON(http.StatusUnauthorized, messageErrorUnauthorized)
+		claims, ok := sh.readOIDCClaims(ctx)
+		if !ok {
 			return
 		}
+		identity := types.Identity{Issuer: claims.Issuer, Subject: claims.Subject}
 
-		err := sh.updater.Update(ctx, user)
-		if err != nil {
-			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+		linked, err := sh.byIdentityReader.ReadSensitiveByIdentity(ctx, identity.Issuer, identity.Subject)
+		if err == nil && linked.ID != id {
+			ctx.JSON(http.StatusConflict, messageErrorIdentityLinked)
+			return
+		}
+		if err == nil {
+			ctx.JSON(http.StatusOK, gin.H{"identity": identity})
+			return
+		}
+		// another user may link the identity right after it is read, which only the unique index of identities tells
+		err = sh.identityLinker.LinkIdentity(ctx, id, identity)
+		if errors.Is(err, oidc.ErrIdentityLinked) {
+			ctx.JSON(http.StatusConflict, messageErrorIdentityLinked)
 			return
 		}
-
-		err = sh.userUpdateMessenger.SendUserUpdatedMessage(ctx, user)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, messageErrorMessageBroker)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to link identity"})
 			return
 		}
 
-		ctx.JSON(http.StatusOK, gin.H{"user": user})
+		ctx.JSON(http.StatusOK, gin.H{"identity": identity})
 	}
 }
 
-func (sh Handler) DeleteUser() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		id := ctx.GetString(paramKeyAuthenticatedUserID)
+// readOIDCClaims checks the code the OpenID provider redirected the user with against the state token it was sent with,
+// responding with 400 or 401 if they don't match
+func (sh Handler) readOIDCClaims(ctx *gin.Context) (oidc.Claims, bool) {
+	var json struct {
+		Code       string `json:"code" binding:"required"`
+		State      string `json:"state" binding:"required"`
+		StateToken string `json:"stateToken" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&json); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing code, state or state token"})
+		return oidc.Claims{}, false
+	}
 
-		err := sh.deleter.Delete(ctx, id)
-		if err != nil {
-			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+	token, err := jwt.Parse(json.StateToken, sh.keyReader.VerificationKey)
+	if err != nil || !token.Valid {
+		ctx.JSON(http.StatusUnauthorized, messageErrorInvalidOIDCLogin)
+		return oidc.Claims{}, false
+	}
+	stateClaims, _ := token.Claims.(jwt.MapClaims)
+	purpose, _ := stateClaims["purpose"].(string)
+	state, _ := stateClaims["state"].(string)
+	nonce, _ := stateClaims["nonce"].(string)
+	verifier, _ := stateClaims["verifier"].(string)
+	if purpose != purposeOIDCLogin || state == "" || nonce == "" || verifier == "" ||
+		subtle.ConstantTimeCompare([]byte(state), []byte(json.State)) != 1 {
+		ctx.JSON(http.StatusUnauthorized, messageErrorInvalidOIDCLogin)
+		return oidc.Claims{}, false
+	}
+
+	claims, err := sh.oidcExchanger.Exchange(ctx, json.Code, verifier)
+	if err != nil {
+		_ = ctx.Error(err)
+		ctx.JSON(http.StatusUnauthorized, messageErrorInvalidOIDCLogin)
+		return oidc.Claims{}, false
+	}
+	// the nonce ties the ID token to this login, so that tokens issued for other logins can't be replayed
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		ctx.JSON(http.StatusUnauthorized, messageErrorInvalidOIDCLogin)
+		return oidc.Claims{}, false
+	}
+	return claims, true
+}
+
+func (sh Handler) rehash(ctx context.Context, u types.UserWithHashedPassword, password string) error {
+	hashedPassword, err := sh.hasher.GenerateFromPassword(password)
+	if err != nil {
+		return err
+	}
+	return sh.passwordRehasher.RehashPassword(ctx, u.ID, u.HashedPassword, hashedPassword)
+}
+
+// startSession creates a session for the user and responds with its first access token and its refresh token
+func (sh Handler) startSession(ctx *gin.Context, u types.UserWithHashedPassword) {
+	s, refreshToken, err := session.New(u.ID, ctx.Request.UserAgent(), sessionTTL)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, messageErrorCreateSession)
+		return
+	}
+	err = sh.sessionCreator.CreateSession(ctx, s)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, messageErrorCreateSession)
+		return
+	}
+
+	tokenString, err := sh.signToken(u, s.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, messageErrorGenerateToken)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"token": tokenString, "refreshToken": refreshToken})
+}
+
+// checkAttempts responds with 429 and returns false if any of the keys is locked out after too many failed attempts
+func (sh Handler) checkAttempts(ctx *gin.Context, attemptKeys []string) bool {
+	retryAfter, err := sh.loginThrottler.RetryAfter(ctx, attemptKeys...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, messageErrorLoginAttempts)
+		return false
+	}
+	if retryAfter > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, messageErrorTooManyLoginAttempts)
+		return false
+	}
+	return true
+}
+
+// CompleteMFA exchanges the challenge token from Login and a TOTP or recovery code for a session
+func (sh Handler) CompleteMFA() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var json struct {
+			ChallengeToken string `json:"challengeToken" binding:"required"`
+			Code           string `json:"code" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&json); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing challenge token or code"})
 			return
 		}
 
-		err = sh.userDeleteMessenger.SendUserDeletedMessage(ctx, id)
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, messageErrorMessageBroker)
+		challenge, err := jwt.Parse(json.ChallengeToken, sh.keyReader.VerificationKey)
+		if err != nil || !challenge.Valid {
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidChallenge)
 			return
 		}
-
-		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("deleted user %s", id)})
+		claims, _ := challenge.Claims.(jwt.MapClaims)
+		purpose, _ := claims["purpose"].(string)
+		id, _ := claims["sub"].(string)
+		if purpose != purposeMFAChallenge || id == "" {
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidChallenge)
+			return
+		}
+
+		// codes are short, so guessing them is throttled per user
+		attemptKeys := []string{"mfa:" + id}
+		if !sh.checkAttempts(ctx, attemptKeys) {
+			return
+		}
+
+		enrollment, err := sh.mfaReader.ReadMFA(ctx, id)
+		if err != nil || enrollment.Secret == "" {
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidChallenge)
+			return
+		}
+		if !sh.useSecondFactor(ctx, id, enrollment, json.Code, true) {
+			if err := sh.loginFailureRecorder.RecordFailure(ctx, attemptKeys...); err != nil {
+				_ = ctx.Error(err)
+			}
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidMFACode)
+			return
+		}
+		if err := sh.loginAttemptsResetter.ResetAttempts(ctx, attemptKeys...); err != nil {
+			_ = ctx.Error(err)
+		}
+
+		u, err := sh.sensitiveByIDReader.ReadSensitiveByID(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		if u.Suspended {
+			ctx.JSON(http.StatusForbidden, messageErrorSuspended)
+			return
+		}
+		sh.startSession(ctx, u)
+	}
+}
+
+// useSecondFactor checks a TOTP code, or a recovery code if allowed, consuming it so that it can't be used again
+func (sh Handler) useSecondFactor(ctx context.Context, id string, enrollment mfa.Enrollment, code string, allowRecoveryCodes bool) bool {
+	if step, ok := mfa.Validate(enrollment.Secret, code, time.Now()); ok {
+		return sh.totpStepUser.UseTOTPStep(ctx, id, step) == nil
+	}
+	if allowRecoveryCodes {
+		return sh.recoveryCodeUser.UseRecoveryCode(ctx, id, mfa.HashRecoveryCode(code)) == nil
+	}
+	return false
+}
+
+// EnrollMFA generates a TOTP secret for the user, which must be confirmed with ConfirmMFA before it is required
+func (sh Handler) EnrollMFA() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetString(paramKeyAuthenticatedUserID)
+
+		u, err := sh.sensitiveByIDReader.ReadSensitiveByID(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		if u.MFAEnabled {
+			ctx.JSON(http.StatusConflict, messageErrorMFAEnabled)
+			return
+		}
+
+		totpSecret, err := mfa.NewSecret()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+			return
+		}
+		err = sh.mfaEnrollmentCreator.CreateMFAEnrollment(ctx, id, totpSecret)
+		if err != nil {
+			ctx.JSON(http.StatusConflict, messageErrorMFAEnabled)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, gin.H{"secret": totpSecret, "uri": mfa.URI(mfaIssuer, u.Email, totpSecret)})
+	}
+}
+
+// ConfirmMFA enables the pending secret of the user with a valid code, responding with its recovery codes
+func (sh Handler) ConfirmMFA() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetString(paramKeyAuthenticatedUserID)
+
+		var json struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&json); err != nil {
+			ctx.JSON(http.StatusBadRequest, messageErrorMissingMFACode)
+			return
+		}
+
+		enrollment, err := sh.mfaReader.ReadMFA(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		if enrollment.PendingSecret == "" {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "no pending two-factor enrollment"})
+			return
+		}
+		step, ok := mfa.Validate(enrollment.PendingSecret, json.Code, time.Now())
+		if !ok {
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidMFACode)
+			return
+		}
+
+		codes, hashes, err := mfa.NewRecoveryCodes()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateRecoveryCodes)
+			return
+		}
+		err = sh.mfaEnabler.EnableMFA(ctx, id, enrollment.PendingSecret, hashes, step)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable two-factor authentication"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+	}
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, requiring a TOTP code
+func (sh Handler) RegenerateRecoveryCodes() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetString(paramKeyAuthenticatedUserID)
+
+		var json struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&json); err != nil {
+			ctx.JSON(http.StatusBadRequest, messageErrorMissingMFACode)
+			return
+		}
+
+		attemptKeys := []string{"mfa:" + id}
+		if !sh.checkAttempts(ctx, attemptKeys) {
+			return
+		}
+
+		enrollment, err := sh.mfaReader.ReadMFA(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		if enrollment.Secret == "" {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is not enabled"})
+			return
+		}
+		if !sh.useSecondFactor(ctx, id, enrollment, json.Code, false) {
+			if err := sh.loginFailureRecorder.RecordFailure(ctx, attemptKeys...); err != nil {
+				_ = ctx.Error(err)
+			}
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidMFACode)
+			return
+		}
+		if err := sh.loginAttemptsResetter.ResetAttempts(ctx, attemptKeys...); err != nil {
+			_ = ctx.Error(err)
+		}
+
+		codes, hashes, err := mfa.NewRecoveryCodes()
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateRecoveryCodes)
+			return
+		}
+		err = sh.recoveryCodesReplacer.ReplaceRecoveryCodes(ctx, id, hashes)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateRecoveryCodes)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+	}
+}
+
+func (sh Handler) RefreshSession() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var json struct {
+			RefreshToken string `json:"refreshToken" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&json); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing refresh token"})
+			return
+		}
+
+		sessionID, oldHash, err := session.ParseRefreshToken(json.RefreshToken)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidRefreshToken)
+			return
+		}
+		refreshToken, newHash, err := session.NewRefreshToken(sessionID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateToken)
+			return
+		}
+		s, err := sh.sessionRotator.RotateRefreshToken(ctx, sessionID, oldHash, newHash, time.Now().Add(sessionTTL))
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidRefreshToken)
+			return
+		}
+
+		u, err := sh.sensitiveByIDReader.ReadSensitiveByID(ctx, s.UserID)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidRefreshToken)
+			return
+		}
+		if u.Suspended {
+			ctx.JSON(http.StatusForbidden, messageErrorSuspended)
+			return
+		}
+		tokenString, err := sh.signToken(u, s.ID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateToken)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"token": tokenString, "refreshToken": refreshToken})
+	}
+}
+
+func (sh Handler) Logout() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID := ctx.GetString(paramKeyAuthenticatedUserID)
+		sessionID := ctx.GetString(paramKeyAuthenticatedSessionID)
+
+		err := sh.sessionRevoker.RevokeSession(ctx, userID, sessionID)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorSessionNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("revoked session %s", sessionID)})
+	}
+}
+
+func (sh Handler) GetSessions() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID := ctx.GetString(paramKeyAuthenticatedUserID)
+
+		sessions, err := sh.sessionLister.ReadSessions(ctx, userID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read sessions"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"sessions": sessions})
+	}
+}
+
+func (sh Handler) RevokeSessions() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID := ctx.GetString(paramKeyAuthenticatedUserID)
+
+		err := sh.allSessionsRevoker.RevokeSessions(ctx, userID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("revoked sessions of user %s", userID)})
+	}
+}
+
+// CreateAPIKey creates a key for the user, which is in the response and can't be read again.
+// Keys can only be created from a session, so that a leaked key can't be used to mint longer lived ones.
+func (sh Handler) CreateAPIKey() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID := ctx.GetString(paramKeyAuthenticatedUserID)
+		if ctx.GetString(paramKeyAuthenticatedSessionID) == "" {
+			ctx.JSON(http.StatusForbidden, messageErrorSessionRequired)
+			return
+		}
+
+		var req struct {
+			Name          string   `json:"name" binding:"required"`
+			Scopes        []string `json:"scopes" binding:"required"`
+			ExpiresInDays int      `json:"expiresInDays"`
+		}
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, messageErrorMissingAPIKeyData)
+			return
+		}
+		if req.ExpiresInDays == 0 {
+			req.ExpiresInDays = apiKeyDefaultDays
+		}
+		if req.ExpiresInDays < 0 || req.ExpiresInDays > apiKeyMaxDays {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expiresInDays must be between 1 and %d", apiKeyMaxDays)})
+			return
+		}
+
+		k, key, err := apikey.New(userID, req.Name, req.Scopes, time.Duration(req.ExpiresInDays)*24*time.Hour)
+		if errors.Is(err, apikey.ErrInvalidScopes) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorCreateAPIKey)
+			return
+		}
+		err = sh.apiKeyCreator.CreateAPIKey(ctx, k)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorCreateAPIKey)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, gin.H{"apiKey": k, "key": key})
+	}
+}
+
+func (sh Handler) GetAPIKeys() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID := ctx.GetString(paramKeyAuthenticatedUserID)
+
+		keys, err := sh.apiKeysLister.ReadAPIKeys(ctx, userID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read api keys"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"apiKeys": keys})
+	}
+}
+
+func (sh Handler) RevokeAPIKey() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID := ctx.GetString(paramKeyAuthenticatedUserID)
+		id := ctx.Param("keyID")
+
+		err := sh.apiKeyRevoker.RevokeAPIKey(ctx, userID, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorAPIKeyNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("revoked api key %s", id)})
+	}
+}
+
+// ExchangeAPIKey responds with a short-lived access token for the API key in the Authorization header,
+// which lets other services accept keys while still verifying tokens by themselves
+func (sh Handler) ExchangeAPIKey() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authHeader := ctx.GetHeader("Authorization")
+		if !strings.HasPrefix(authHeader, "ApiKey ") {
+			ctx.JSON(http.StatusUnauthorized, messageErrorMissingAuthorizationHeader)
+			return
+		}
+
+		k, u, err := sh.useAPIKey(ctx, authHeader[len("ApiKey "):])
+		if errors.Is(err, errSuspended) {
+			ctx.JSON(http.StatusForbidden, messageErrorSuspended)
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidAPIKey)
+			return
+		}
+		token, expiresAt, err := sh.signAPIKeyToken(u, k)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateToken)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"token": token, "expiresAt": expiresAt})
+	}
+}
+
+// ChangePassword sets a new password given the current one and revokes all API keys and other sessions of the user,
+// as whoever else knew the old password could have created some. The session the password is changed from is kept.
+func (sh Handler) ChangePassword() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetString(paramKeyAuthenticatedUserID)
+
+		var json struct {
+			CurrentPassword string `json:"currentPassword" binding:"required"`
+			NewPassword     string `json:"newPassword" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&json); err != nil {
+			ctx.JSON(http.StatusBadRequest, messageErrorMissingPasswords)
+			return
+		}
+
+		u, err := sh.sensitiveByIDReader.ReadSensitiveByID(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+
+		// a stolen access token must not be a way around the limit on guessing the password at login
+		attemptKeys := []string{
+			"email:" + strings.ToLower(strings.TrimSpace(u.Email)),
+			"ip:" + ctx.ClientIP(),
+		}
+		if !sh.checkAttempts(ctx, attemptKeys) {
+			return
+		}
+
+		err = sh.verifier.CompareHashAndPassword(u.HashedPassword, json.CurrentPassword)
+		if err != nil {
+			if err := sh.loginFailureRecorder.RecordFailure(ctx, attemptKeys...); err != nil {
+				_ = ctx.Error(err)
+			}
+			ctx.JSON(http.StatusUnauthorized, messageErrorUnauthorized)
+			return
+		}
+		if err := sh.loginAttemptsResetter.ResetAttempts(ctx, attemptKeys...); err != nil {
+			_ = ctx.Error(err)
+		}
+
+		hashedPassword, err := sh.hasher.GenerateFromPassword(json.NewPassword)
+		if err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, messageErrorBadPassword)
+			return
+		}
+
+		err = sh.passwordUpdater.UpdatePassword(ctx, id, hashedPassword)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+
+		err = sh.otherSessionsRevoker.RevokeOtherSessions(ctx, id, ctx.GetString(paramKeyAuthenticatedSessionID))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+			return
+		}
+		err = sh.allAPIKeysRevoker.RevokeAPIKeys(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorRevokeAPIKeys)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("changed password of user %s", id)})
+	}
+}
+
+// RequestPasswordReset sends a reset token to the user with the email. It answers the same whether
+// the email is registered or not, so that it can't be used to find out which emails are.
+func (sh Handler) RequestPasswordReset() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var json struct {
+			Email string `json:"email" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&json); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing email"})
+			return
+		}
+
+		u, err := sh.byEmailReader.ReadSensitiveByEmail(ctx, json.Email)
+		if err != nil {
+			ctx.JSON(http.StatusAccepted, messagePasswordResetRequested)
+			return
+		}
+
+		token, err := secret.New(32)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateToken)
+			return
+		}
+		err = sh.passwordResetCreator.CreatePasswordReset(ctx, u.ID, secret.Hash(token), time.Now().Add(passwordResetTTL))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, messageErrorGenerateToken)
+			return
+		}
+
+		err = sh.passwordResetNotifier.NotifyPasswordReset(ctx, u.User, token)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send password reset token"})
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, messagePasswordResetRequested)
+	}
+}
+
+// ResetPassword sets a new password with a reset token and revokes all sessions and API keys of the user.
+// Access tokens already issued are rejected here right away, and by other services once they expire.
+func (sh Handler) ResetPassword() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var json struct {
+			Token       string `json:"token" binding:"required"`
+			NewPassword string `json:"newPassword" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&json); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing token or password"})
+			return
+		}
+
+		hashedPassword, err := sh.hasher.GenerateFromPassword(json.NewPassword)
+		if err != nil {
+			ctx.JSON(http.StatusUnprocessableEntity, messageErrorBadPassword)
+			return
+		}
+
+		id, err := sh.passwordResetter.ResetPassword(ctx, secret.Hash(json.Token), hashedPassword)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired reset token"})
+			return
+		}
+
+		if !sh.revokeAccess(ctx, id) {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("reset password of user %s", id)})
+	}
+}
+
+// revokeAccess revokes all sessions and API keys of the user, responding with 500 and returning false if it fails
+func (sh Handler) revokeAccess(ctx *gin.Context, id string) bool {
+	if err := sh.allSessionsRevoker.RevokeSessions(ctx, id); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return false
+	}
+	if err := sh.allAPIKeysRevoker.RevokeAPIKeys(ctx, id); err != nil {
+		ctx.JSON(http.StatusInternalServerError, messageErrorRevokeAPIKeys)
+		return false
+	}
+	return true
+}
+
+func (sh Handler) signToken(u types.UserWithHashedPassword, sessionID string) (string, error) {
+	return sh.signer.Sign(jwt.MapClaims{
+		"name":     u.Name,
+		"email":    u.Email,
+		"verified": u.Verified,
+		"role":     role(u),
+		"sub":      u.ID,
+		"sid":      sessionID,
+		"exp":      time.Now().Add(jwtTTL).Unix(),
+	})
+}
+
+func role(u types.UserWithHashedPassword) string {
+	if u.Role == "" {
+		return types.RoleUser
+	}
+	return u.Role
+}
+
+// sendVerificationCode sends the user a signed code that proves it owns its email
+func (sh Handler) sendVerificationCode(ctx context.Context, u types.User) error {
+	code, err := sh.signer.Sign(jwt.MapClaims{
+		"purpose": purposeEmailVerification,
+		"sub":     u.ID,
+		"email":   u.Email,
+		"exp":     time.Now().Add(verificationCodeTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	return sh.verificationNotifier.NotifyVerification(ctx, u, code)
+}
+
+func (sh Handler) RequestVerification() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetString(paramKeyAuthenticatedUserID)
+
+		u, err := sh.sensitiveByIDReader.ReadSensitiveByID(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		if u.Verified {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "email already verified"})
+			return
+		}
+
+		u.User.ID = id
+		err = sh.sendVerificationCode(ctx, u.User)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification code"})
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, gin.H{"message": fmt.Sprintf("sent verification code to %s", u.Email)})
+	}
+}
+
+// Verify marks the email of a user as verified with a code from sendVerificationCode.
+// Tokens issued from then on carry the verified claim.
+func (sh Handler) Verify() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var json struct {
+			Code string `json:"code" binding:"required"`
+		}
+		if err := ctx.ShouldBindJSON(&json); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing verification code"})
+			return
+		}
+
+		code, err := jwt.Parse(json.Code, sh.keyReader.VerificationKey)
+		if err != nil || !code.Valid {
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidVerificationCode)
+			return
+		}
+		claims, _ := code.Claims.(jwt.MapClaims)
+		purpose, _ := claims["purpose"].(string)
+		id, _ := claims["sub"].(string)
+		email, _ := claims["email"].(string)
+		if purpose != purposeEmailVerification || id == "" || email == "" {
+			ctx.JSON(http.StatusUnauthorized, messageErrorInvalidVerificationCode)
+			return
+		}
+
+		err = sh.emailVerifier.VerifyEmail(ctx, id, email)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("verified email %s", email)})
+	}
+}
+
+func (sh Handler) GetIDFromToken() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetString(paramKeyAuthenticatedUserID)
+		ctx.JSON(http.StatusOK, gin.H{"id": id})
+	}
+}
+
+func (sh Handler) GetKeySet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(keySetMaxAge.Seconds())))
+		ctx.JSON(http.StatusOK, gin.H{"keys": sh.keySetReader.KeySet()})
+	}
+}
+
+func (sh Handler) GetUserFromID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetString(paramKeyAuthenticatedUserID)
+		user, err := sh.byIDReader.ReadByID(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"user": user})
+	}
+}
+
+// GetUserProfile responds with what any user may know about another one, which leaves its email out
+func (sh Handler) GetUserProfile() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user, err := sh.byIDReader.ReadByID(ctx, ctx.Param("userID"))
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"user": types.User{ID: user.ID, Name: user.Name, PictureURL: user.PictureURL}})
+	}
+}
+
+// SearchUsers finds users by the start of their name or email, or by words in them with mode=text, a page at a time
+func (sh Handler) SearchUsers() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var query struct {
+			Q      string `form:"q" binding:"required"`
+			Mode   string `form:"mode" binding:"omitempty,oneof=prefix text"`
+			Cursor string `form:"cursor"`
+			Limit  int64  `form:"limit" binding:"min=0,max=50"`
+		}
+		if err := ctx.ShouldBindQuery(&query); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing or bad search query"})
+			return
+		}
+		q := search.Query{
+			Text:     strings.TrimSpace(query.Q),
+			FullText: query.Mode == "text",
+			Limit:    query.Limit,
+		}
+		if q.Text == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "missing or bad search query"})
+			return
+		}
+		if q.Limit == 0 {
+			q.Limit = searchPageSize
+		}
+		if query.Cursor != "" {
+			after, err := cursor.Parse(query.Cursor)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "bad cursor"})
+				return
+			}
+			q.After = after
+		}
+
+		users, next, err := sh.userSearcher.SearchUsers(ctx, q)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search users"})
+			return
+		}
+
+		resp := gin.H{"users": users}
+		if next != nil {
+			resp["nextCursor"] = next.Encode()
+		}
+		ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+func (sh Handler) UpdateUser() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetString(paramKeyAuthenticatedUserID)
+
+		var user types.User
+		if err := ctx.ShouldBindJSON(&user); err != nil {
+			ctx.JSON(http.StatusBadRequest, messageErrorMissingUserData)
+			return
+		}
+
+		if !(id == user.ID) {
+			ctx.JSON(http.StatusUnauthorized, messageErrorUnauthorized)
+			return
+		}
+
+		// the picture is only set by uploading one, so that users can't point it at blobs that aren't theirs
+		stored, err := sh.byIDReader.ReadByID(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		user.PictureURL = stored.PictureURL
+
+		err = sh.updater.Update(ctx, user)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"user": user})
+	}
+}
+
+// UploadPicture stores the picture of a multipart form and sets its URL as the picture of the user,
+// replacing the previous one
+func (sh Handler) UploadPicture() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetString(paramKeyAuthenticatedUserID)
+
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxPictureRequestSize)
+		fileHeader, err := ctx.FormFile(formKeyPicture)
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": picture.ErrTooLarge.Error()})
+				return
+			}
+			ctx.JSON(http.StatusBadRequest, messageErrorMissingPicture)
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, messageErrorMissingPicture)
+			return
+		}
+		defer file.Close()
+
+		user, err := sh.byIDReader.ReadByID(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+
+		pic, err := sh.pictureSaver.SavePicture(ctx, id, file)
+		if err != nil {
+			ctx.JSON(pictureErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		oldPictureURL := user.PictureURL
+		user.PictureURL = pic.URL
+		err = sh.updater.Update(ctx, user)
+		if err != nil {
+			if oldPictureURL != pic.URL {
+				if err := sh.pictureDeleter.DeletePicture(ctx, id, pic.URL); err != nil {
+					_ = ctx.Error(err)
+				}
+			}
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		if oldPictureURL != pic.URL {
+			if err := sh.pictureDeleter.DeletePicture(ctx, id, oldPictureURL); err != nil {
+				_ = ctx.Error(err)
+			}
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"user": user, "thumbnailUrl": pic.ThumbnailURL})
+	}
+}
+
+// GetPicture serves a stored picture or thumbnail. Their names change with their contents, so they are cached for good.
+func (sh Handler) GetPicture() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		rc, contentType, err := sh.pictureOpener.OpenPicture(ctx, ctx.Param("name"))
+		if errors.Is(err, blob.ErrNotFound) {
+			ctx.JSON(http.StatusNotFound, messageErrorPictureNotFound)
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read picture"})
+			return
+		}
+		defer rc.Close()
+
+		ctx.DataFromReader(http.StatusOK, -1, contentType, rc, map[string]string{
+			"Cache-Control":          "public, max-age=31536000, immutable",
+			"X-Content-Type-Options": "nosniff",
+		})
+	}
+}
+
+// pictureErrorStatus tells the client what is wrong with its picture, if anything
+func pictureErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, picture.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, picture.ErrUnsupportedType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, picture.ErrInvalidImage):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// DeleteUser deletes the authenticated user, revoking all of its sessions and API keys
+func (sh Handler) DeleteUser() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetString(paramKeyAuthenticatedUserID)
+
+		user, err := sh.byIDReader.ReadByID(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		err = sh.deleter.Delete(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		status := sh.requestErasure(ctx, user)
+		if !sh.revokeAccess(ctx, id) {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("deleted user %s", id),
+			"erasure": status,
+		})
+	}
+}
+
+// requestErasure starts tracking the purge of the data of a deleted user, which each service confirms once done.
+// The user is already gone, so failures are only logged and the erasure goes on untracked.
+func (sh Handler) requestErasure(ctx *gin.Context, user types.User) erasure.Status {
+	if err := sh.pictureDeleter.DeletePicture(ctx, user.ID, user.PictureURL); err != nil {
+		_ = ctx.Error(err)
+	}
+	e := erasure.Erasure{UserID: user.ID, RequestedAt: time.Now(), Services: erasure.Services}
+	if err := sh.erasureCreator.CreateErasure(ctx, e.UserID, e.Services); err != nil {
+		_ = ctx.Error(err)
+	}
+	return e.Status()
+}
+
+// ExportUserData responds with what all services hold about the user, as JSON or, with format=zip, as a ZIP archive.
+// The other services are read with the token of the user, so they only give away what it can see.
+func (sh Handler) ExportUserData() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetString(paramKeyAuthenticatedUserID)
+		format := ctx.DefaultQuery("format", exportFormatJSON)
+		if format != exportFormatJSON && format != exportFormatZIP {
+			ctx.JSON(http.StatusBadRequest, messageErrorUnknownExportFormat)
+			return
+		}
+
+		archive, err := sh.userDataExporter.ExportUserData(ctx, ctx.GetString(paramKeyAuthenticatedToken), id)
+		var sourceErr *export.SourceError
+		if errors.As(err, &sourceErr) {
+			_ = ctx.Error(err)
+			ctx.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("failed to export data from the %s service", sourceErr.Source)})
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+
+		var buf bytes.Buffer
+		if format == exportFormatZIP {
+			if err := archive.WriteZIP(&buf); err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to write archive"})
+				return
+			}
+		}
+
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "gaef-export-"+id+"."+format))
+		if format == exportFormatZIP {
+			ctx.Data(http.StatusOK, "application/zip", buf.Bytes())
+			return
+		}
+		ctx.JSON(http.StatusOK, archive)
+	}
+}
+
+// adminUser is what admins see of a user, all but its password
+type adminUser struct {
+	ID         string `json:"id"`
+	types.User `json:"user"`
+	Verified   bool   `json:"verified"`
+	MFAEnabled bool   `json:"mfaEnabled"`
+	Role       string `json:"role"`
+	Suspended  bool   `json:"suspended"`
+}
+
+// ListUsers lists users page by page, optionally those whose name or email contain the q query param
+func (sh Handler) ListUsers() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var query struct {
+			Q     string `form:"q"`
+			Skip  int64  `form:"skip" binding:"min=0"`
+			Limit int64  `form:"limit" binding:"min=0,max=100"`
+		}
+		if err := ctx.ShouldBindQuery(&query); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "bad skip or limit"})
+			return
+		}
+		if query.Limit == 0 {
+			query.Limit = adminUsersPageSize
+		}
+
+		users, err := sh.usersReader.ReadUsers(ctx, query.Q, query.Skip, query.Limit)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read users"})
+			return
+		}
+
+		views := make([]adminUser, 0, len(users))
+		for _, u := range users {
+			views = append(views, adminUser{
+				ID:         u.ID,
+				User:       u.User,
+				Verified:   u.Verified,
+				MFAEnabled: u.MFAEnabled,
+				Role:       role(u),
+				Suspended:  u.Suspended,
+			})
+		}
+		ctx.JSON(http.StatusOK, gin.H{"users": views})
+	}
+}
+
+// SuspendUser keeps a user from logging in, ending all of its sessions and revoking all of its API keys
+func (sh Handler) SuspendUser() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+
+		err := sh.suspensionSetter.SetSuspended(ctx, id, true)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		if !sh.revokeAccess(ctx, id) {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("suspended user %s", id)})
+	}
+}
+
+func (sh Handler) UnsuspendUser() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+
+		err := sh.suspensionSetter.SetSuspended(ctx, id, false)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("unsuspended user %s", id)})
+	}
+}
+
+func (sh Handler) SetUserRole() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+
+		var json struct {
+			Role string `json:"role" binding:"required,oneof=user admin"`
+		}
+		if err := ctx.ShouldBindJSON(&json); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "role must be user or admin"})
+			return
+		}
+
+		err := sh.roleSetter.SetRole(ctx, id, json.Role)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("made user %s %s", id, json.Role)})
+	}
+}
+
+// ForceLogout ends all sessions of a user, which must log in again
+func (sh Handler) ForceLogout() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+
+		err := sh.allSessionsRevoker.RevokeSessions(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("revoked sessions of user %s", id)})
+	}
+}
+
+// AdminDeleteUser deletes any user, which is announced to the other services as if it had deleted itself,
+// revoking all of its sessions and API keys
+func (sh Handler) AdminDeleteUser() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("id")
+
+		user, err := sh.byIDReader.ReadByID(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		err = sh.deleter.Delete(ctx, id)
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorUserNotFound)
+			return
+		}
+		status := sh.requestErasure(ctx, user)
+		if !sh.revokeAccess(ctx, id) {
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("deleted user %s", id),
+			"erasure": status,
+		})
+	}
+}
+
+// AdminGetErasure tells which services already purged the data of a deleted user
+func (sh Handler) AdminGetErasure() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		e, err := sh.erasureReader.ReadErasure(ctx, ctx.Param("id"))
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, messageErrorErasureNotFound)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"erasure": e.Status()})
 	}
 }
 
-const jwtTTL = time.Hour * 24 * 7
+// access tokens are short-lived, sessions are kept alive by refreshing them
+const jwtTTL = time.Minute * 15
+const sessionTTL = time.Hour * 24 * 30
+const passwordResetTTL = time.Hour
+const verificationCodeTTL = time.Hour * 24
+const purposeEmailVerification = "email-verification"
+const purposeMFAChallenge = "mfa-challenge"
+const mfaChallengeTTL = time.Minute * 5
+const purposeOIDCLogin = "oidc-login"
+const oidcLoginTTL = time.Minute * 10
+
+// MaxTokenTTL is the longest lifetime of the tokens the handler signs, which signing keys must outlive once rotated out
+const MaxTokenTTL = verificationCodeTTL
+
+const mfaIssuer = "gaef"
+const keySetMaxAge = time.Minute * 15
+const adminUsersPageSize = 50
+const apiKeyDefaultDays = 90
+const apiKeyMaxDays = 365
+const searchPageSize = 20
+const maxPictureRequestSize = 16 << 20 // bounds the form, the picture itself is bounded by the PictureSaver
+const formKeyPicture = "picture"
+const exportFormatJSON = "json"
+const exportFormatZIP = "zip"
 const paramKeyAuthenticatedUserID = "AuthenticatedUserID"
+const paramKeyAuthenticatedSessionID = "AuthenticatedSessionID"
+const paramKeyAuthenticatedToken = "AuthenticatedToken"
 
 var (
 	messageErrorUnauthorized               = gin.H{"error": "unauthorized"}
 	messageErrorMissingAuthorizationHeader = gin.H{"error": "missing authorization header"}
+	messageErrorForbidden                  = gin.H{"error": "forbidden"}
+	messageErrorSuspended                  = gin.H{"error": "account suspended"}
 	messageErrorInvalidToken               = gin.H{"error": "invalid or expired token"}
+	messageErrorInvalidSession             = gin.H{"error": "session expired or revoked"}
+	messageErrorInvalidRefreshToken        = gin.H{"error": "invalid or expired refresh token"}
+	messageErrorSessionNotFound            = gin.H{"error": "session not found"}
+	messageErrorCreateSession              = gin.H{"error": "failed to create session"}
+	messageErrorGenerateToken              = gin.H{"error": "failed to generate token"}
 	messageErrorUserNotFound               = gin.H{"error": "user not found"}
 	messageErrorMissingUserData            = gin.H{"error": "missing user data"}
-	messageErrorMessageBroker              = gin.H{"error": "unable to send message to broker"}
+	messageErrorMissingPasswords           = gin.H{"error": "missing current or new password"}
+	messageErrorBadPassword                = gin.H{"error": "bad password"}
+	messagePasswordResetRequested          = gin.H{"message": "if the email is registered, a password reset token was sent to it"}
+	messageErrorInvalidVerificationCode    = gin.H{"error": "invalid or expired verification code"}
+	messageErrorTooManyLoginAttempts       = gin.H{"error": "too many failed login attempts, try again later"}
+	messageErrorLoginAttempts              = gin.H{"error": "failed to check login attempts"}
+	messageErrorInvalidChallenge           = gin.H{"error": "invalid or expired challenge token"}
+	messageErrorMissingMFACode             = gin.H{"error": "missing code"}
+	messageErrorInvalidMFACode             = gin.H{"error": "invalid code"}
+	messageErrorMFAEnabled                 = gin.H{"error": "two-factor authentication is already enabled"}
+	messageErrorGenerateRecoveryCodes      = gin.H{"error": "failed to generate recovery codes"}
+	messageErrorMissingPicture             = gin.H{"error": "missing picture"}
+	messageErrorPictureNotFound            = gin.H{"error": "picture not found"}
+	messageErrorUnknownExportFormat        = gin.H{"error": "format must be json or zip"}
+	messageErrorErasureNotFound            = gin.H{"error": "erasure not found"}
+	messageErrorInvalidAPIKey              = gin.H{"error": "invalid, expired or revoked api key"}
+	messageErrorInsufficientScope          = gin.H{"error": "insufficient scope"}
+	messageErrorSessionRequired            = gin.H{"error": "api keys can't be used for this, log in instead"}
+	messageErrorIdentityLinked             = gin.H{"error": "identity is linked to another user"}
+	messageErrorMissingAPIKeyData          = gin.H{"error": "missing api key name or scopes"}
+	messageErrorCreateAPIKey               = gin.H{"error": "failed to create api key"}
+	messageErrorAPIKeyNotFound             = gin.H{"error": "api key not found"}
+	messageErrorRevokeAPIKeys              = gin.H{"error": "failed to revoke api keys"}
+	messageErrorInvalidOIDCLogin           = gin.H{"error": "invalid or expired login with the identity provider"}
+	messageErrorIdentityNotLinked          = gin.H{"error": "an account with this email exists, log in and link the identity to it"}
 )
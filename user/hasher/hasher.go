@@ -1,19 +1,166 @@
 package hasher
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
-type PasswordHasherVerifier struct{}
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
 
-// New TODO use function type instead of struct
-func New() PasswordHasherVerifier {
-	return PasswordHasherVerifier{}
+// Params are the Argon2id parameters of new hashes
+type Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follow the recommendations of RFC 9106 for memory-constrained environments
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// ParamsFromEnv reads the memory (KiB), iterations, parallelism and key length from ARGON2_MEMORY, ARGON2_ITERATIONS,
+// ARGON2_PARALLELISM and ARGON2_KEY_LENGTH, taking the ones that are not set from DefaultParams
+func ParamsFromEnv(getenv func(key string) string) (Params, error) {
+	params := DefaultParams
+	for _, p := range []struct {
+		key     string
+		bitSize int
+		set     func(uint64)
+	}{
+		{"ARGON2_MEMORY", 32, func(v uint64) { params.Memory = uint32(v) }},
+		{"ARGON2_ITERATIONS", 32, func(v uint64) { params.Iterations = uint32(v) }},
+		{"ARGON2_PARALLELISM", 8, func(v uint64) { params.Parallelism = uint8(v) }},
+		{"ARGON2_KEY_LENGTH", 32, func(v uint64) { params.KeyLength = uint32(v) }},
+	} {
+		value := getenv(p.key)
+		if value == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(value, 10, p.bitSize)
+		if err != nil || v == 0 {
+			return Params{}, fmt.Errorf("%s must be a positive integer of %d bits, got %q", p.key, p.bitSize, value)
+		}
+		p.set(v)
+	}
+	return params, nil
+}
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown hash algorithm")
+	ErrMalformedHash    = errors.New("malformed hash")
+)
+
+// PasswordHasherVerifier writes Argon2id hashes in the PHC string format,
+// and verifies them as well as bcrypt hashes written before it was adopted
+type PasswordHasherVerifier struct {
+	params Params
+}
+
+func New(params Params) PasswordHasherVerifier {
+	return PasswordHasherVerifier{params: params}
 }
 
 func (phv PasswordHasherVerifier) GenerateFromPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(hash), err
+	salt := make([]byte, phv.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, phv.params.Iterations, phv.params.Memory, phv.params.Parallelism, phv.params.KeyLength)
+	return encode(phv.params, salt, key), nil
 }
 
 func (phv PasswordHasherVerifier) CompareHashAndPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	if isBcrypt(hashedPassword) {
+		return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	}
+	params, salt, key, err := decode(hashedPassword)
+	if err != nil {
+		return err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+// NeedsRehash tells if the hash was written with another algorithm or with weaker parameters than the current ones
+func (phv PasswordHasherVerifier) NeedsRehash(hashedPassword string) bool {
+	params, _, _, err := decode(hashedPassword)
+	if err != nil {
+		return true
+	}
+	return params.Memory < phv.params.Memory ||
+		params.Iterations < phv.params.Iterations ||
+		params.Parallelism < phv.params.Parallelism ||
+		params.SaltLength < phv.params.SaltLength ||
+		params.KeyLength < phv.params.KeyLength
+}
+
+func isBcrypt(hashedPassword string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hashedPassword, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// encode writes $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>, with unpadded base64
+func encode(params Params, salt, key []byte) string {
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		params.Memory,
+		params.Iterations,
+		params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decode(hashedPassword string) (Params, []byte, []byte, error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return Params{}, nil, nil, ErrMalformedHash
+	}
+	if parts[1] != "argon2id" {
+		return Params{}, nil, nil, fmt.Errorf("%w %q", ErrUnknownAlgorithm, parts[1])
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Params{}, nil, nil, fmt.Errorf("%w: unsupported version %q", ErrMalformedHash, parts[2])
+	}
+	var params Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Params{}, nil, nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	if params.Iterations == 0 || params.Parallelism == 0 || params.Memory < 8*uint32(params.Parallelism) {
+		return Params{}, nil, nil, fmt.Errorf("%w: bad parameters %q", ErrMalformedHash, parts[3])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Params{}, nil, nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Params{}, nil, nil, fmt.Errorf("%w: bad key", ErrMalformedHash)
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
 }
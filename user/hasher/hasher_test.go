@@ -0,0 +1,166 @@
+package hasher_test
+
+import (
+	"errors"
+	"github.com/gabrielseibel1/gaef/user/hasher"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters, to keep the tests fast
+var testParams = hasher.Params{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordHasherVerifier_Argon2id(t *testing.T) {
+	// prepare test setup
+	phv := hasher.New(testParams)
+
+	// run code under test
+	hash, err := phv.GenerateFromPassword("dummyPassword")
+
+	// assertions
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+	if got, want := hash, "$argon2id$v=19$m=64,t=1,p=1$"; !strings.HasPrefix(got, want) {
+		t.Errorf("got hash %s, want prefix %s", got, want)
+	}
+	if err := phv.CompareHashAndPassword(hash, "dummyPassword"); err != nil {
+		t.Errorf("CompareHashAndPassword() error = %v, want nil", err)
+	}
+	if err := phv.CompareHashAndPassword(hash, "otherPassword"); !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		t.Errorf("CompareHashAndPassword() wrong password error = %v, want %v", err, bcrypt.ErrMismatchedHashAndPassword)
+	}
+	other, _ := phv.GenerateFromPassword("dummyPassword")
+	if hash == other {
+		t.Errorf("got the same hash twice, want them salted")
+	}
+	if phv.NeedsRehash(hash) {
+		t.Errorf("NeedsRehash() = true for a hash with the current parameters, want false")
+	}
+}
+
+func TestPasswordHasherVerifier_Bcrypt(t *testing.T) {
+	// prepare test setup
+	phv := hasher.New(testParams)
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummyPassword"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run code under test and assertions
+	if err := phv.CompareHashAndPassword(string(hash), "dummyPassword"); err != nil {
+		t.Errorf("CompareHashAndPassword() error = %v, want nil", err)
+	}
+	if err := phv.CompareHashAndPassword(string(hash), "otherPassword"); err == nil {
+		t.Errorf("CompareHashAndPassword() wrong password error = nil, want error")
+	}
+	if !phv.NeedsRehash(string(hash)) {
+		t.Errorf("NeedsRehash() = false for a bcrypt hash, want true")
+	}
+}
+
+func TestPasswordHasherVerifier_NeedsRehash(t *testing.T) {
+	// prepare test setup
+	weak := hasher.New(testParams)
+	hash, err := weak.GenerateFromPassword("dummyPassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stronger := testParams
+	stronger.Iterations++
+	phv := hasher.New(stronger)
+
+	// run code under test and assertions
+	if !phv.NeedsRehash(hash) {
+		t.Errorf("NeedsRehash() = false for a hash with weaker parameters, want true")
+	}
+	if err := phv.CompareHashAndPassword(hash, "dummyPassword"); err != nil {
+		t.Errorf("CompareHashAndPassword() with weaker parameters error = %v, want nil", err)
+	}
+}
+
+func TestPasswordHasherVerifier_MalformedHash(t *testing.T) {
+	phv := hasher.New(testParams)
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$scrypt$ln=16,r=8,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=64,t=1,p=0$c2FsdHNhbHRzYWx0$aGFzaA",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdHNhbHRzYWx0$aGFzaA",
+		"$argon2id$v=19$m=64,t=1,p=1$not base64!$aGFzaA",
+	} {
+		if err := phv.CompareHashAndPassword(hash, "dummyPassword"); err == nil {
+			t.Errorf("CompareHashAndPassword(%q) error = nil, want error", hash)
+		}
+		if !phv.NeedsRehash(hash) {
+			t.Errorf("NeedsRehash(%q) = false, want true", hash)
+		}
+	}
+}
+
+func TestParamsFromEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantParams hasher.Params
+		wantErr    bool
+	}{
+		{
+			name:       "nothing set",
+			env:        map[string]string{},
+			wantParams: hasher.DefaultParams,
+		},
+		{
+			name: "all set",
+			env: map[string]string{
+				"ARGON2_MEMORY":      "19456",
+				"ARGON2_ITERATIONS":  "2",
+				"ARGON2_PARALLELISM": "1",
+				"ARGON2_KEY_LENGTH":  "64",
+			},
+			wantParams: hasher.Params{Memory: 19456, Iterations: 2, Parallelism: 1, SaltLength: hasher.DefaultParams.SaltLength, KeyLength: 64},
+		},
+		{
+			name: "some set",
+			env:  map[string]string{"ARGON2_ITERATIONS": "4"},
+			wantParams: hasher.Params{
+				Memory:      hasher.DefaultParams.Memory,
+				Iterations:  4,
+				Parallelism: hasher.DefaultParams.Parallelism,
+				SaltLength:  hasher.DefaultParams.SaltLength,
+				KeyLength:   hasher.DefaultParams.KeyLength,
+			},
+		},
+		{
+			name:    "not a number",
+			env:     map[string]string{"ARGON2_MEMORY": "64MiB"},
+			wantErr: true,
+		},
+		{
+			name:    "zero",
+			env:     map[string]string{"ARGON2_ITERATIONS": "0"},
+			wantErr: true,
+		},
+		{
+			name:    "out of range",
+			env:     map[string]string{"ARGON2_PARALLELISM": "256"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// run code under test
+			params, err := hasher.ParamsFromEnv(func(key string) string { return tt.env[key] })
+
+			// assertions
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("ParamsFromEnv() error = %v, want error %v", err, want)
+			}
+			if got, want := params, tt.wantParams; got != want {
+				t.Errorf("got params %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gabrielseibel1/gaef/auth"
+	"github.com/golang-jwt/jwt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Key is a signing key as kept in the Store
+type Key struct {
+	ID         string    `bson:"_id"`
+	PrivateKey []byte    `bson:"privateKey"` // PKCS #8, ASN.1 DER
+	CreatedAt  time.Time `bson:"createdAt"`
+}
+
+type Store interface {
+	ReadKeys(ctx context.Context) ([]Key, error)
+	CreateKey(ctx context.Context, key Key) error
+}
+
+// Ring signs tokens with its newest key and verifies them with any key that is still in its grace period.
+// A key is retired when a newer one is created, and keeps verifying tokens for gracePeriod after that,
+// so gracePeriod should be at least the lifetime of the tokens.
+type Ring struct {
+	store            Store
+	rotationInterval time.Duration
+	gracePeriod      time.Duration
+
+	mu   sync.RWMutex
+	keys []ringKey // newest first
+}
+
+type ringKey struct {
+	id        string
+	key       *rsa.PrivateKey
+	retiredAt time.Time
+}
+
+func New(store Store, rotationInterval, gracePeriod time.Duration) *Ring {
+	return &Ring{
+		store:            store,
+		rotationInterval: rotationInterval,
+		gracePeriod:      gracePeriod,
+	}
+}
+
+var (
+	ErrNoSigningKey = errors.New("no signing key")
+	ErrUnknownKey   = errors.New("unknown key")
+)
+
+const keyBits = 2048
+
+// Refresh loads the keys from the store, creating a new one if the newest is due for rotation
+func (r *Ring) Refresh(ctx context.Context) error {
+	stored, err := r.store.ReadKeys(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].CreatedAt.After(stored[j].CreatedAt) })
+
+	now := time.Now()
+	if len(stored) == 0 || now.Sub(stored[0].CreatedAt) >= r.rotationInterval {
+		key, err := generate(now)
+		if err != nil {
+			return err
+		}
+		if err := r.store.CreateKey(ctx, key); err != nil {
+			return err
+		}
+		stored = append([]Key{key}, stored...)
+	}
+
+	keys := make([]ringKey, 0, len(stored))
+	for i, k := range stored {
+		var retiredAt time.Time
+		if i > 0 {
+			retiredAt = stored[i-1].CreatedAt
+			if now.After(retiredAt.Add(r.gracePeriod)) {
+				break
+			}
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(k.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("parsing key %s: %w", k.ID, err)
+		}
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return fmt.Errorf("key %s is not an RSA key", k.ID)
+		}
+		keys = append(keys, ringKey{id: k.ID, key: rsaKey, retiredAt: retiredAt})
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.mu.Unlock()
+	return nil
+}
+
+// Run blocks refreshing the keys every interval, so that rotations by any instance are picked up by all of them
+func (r *Ring) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil {
+				log.Printf("refreshing signing keys: %v", err)
+			}
+		}
+	}
+}
+
+// Sign signs the claims with the newest key, identified by the kid header
+func (r *Ring) Sign(claims jwt.MapClaims) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.keys) == 0 {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = r.keys[0].id
+	return token.SignedString(r.keys[0].key)
+}
+
+// VerificationKey picks the public key to verify the token by its kid header
+func (r *Ring) VerificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, k := range r.keys {
+		if k.id != kid {
+			continue
+		}
+		if !k.retiredAt.IsZero() && time.Now().After(k.retiredAt.Add(r.gracePeriod)) {
+			break
+		}
+		return &k.key.PublicKey, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+}
+
+// KeySet returns the public keys that verify tokens, to be published as a JWK set
+func (r *Ring) KeySet() []auth.JWK {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	set := make([]auth.JWK, 0, len(r.keys))
+	for _, k := range r.keys {
+		set = append(set, auth.NewJWK(k.id, &k.key.PublicKey))
+	}
+	return set
+}
+
+func generate(now time.Time) (Key, error) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return Key{}, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		return Key{}, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Key{}, err
+	}
+	return Key{ID: hex.EncodeToString(id), PrivateKey: der, CreatedAt: now}, nil
+}
@@ -0,0 +1,179 @@
+package keys_test
+
+import (
+	"context"
+	"errors"
+	"github.com/gabrielseibel1/gaef/user/keys"
+	"github.com/golang-jwt/jwt"
+	"testing"
+	"time"
+)
+
+type mockStore struct {
+	keys []keys.Key
+	err  error
+}
+
+func (m *mockStore) ReadKeys(ctx context.Context) ([]keys.Key, error) {
+	return m.keys, m.err
+}
+
+func (m *mockStore) CreateKey(ctx context.Context, key keys.Key) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.keys = append(m.keys, key)
+	return nil
+}
+
+const (
+	rotationInterval = time.Hour
+	gracePeriod      = time.Minute
+)
+
+func sign(t *testing.T, r *keys.Ring) string {
+	token, err := r.Sign(jwt.MapClaims{"sub": "dummy-id", "exp": time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return token
+}
+
+func verify(r *keys.Ring, token string) error {
+	_, err := jwt.Parse(token, r.VerificationKey)
+	return err
+}
+
+func TestRing_SignAndVerify(t *testing.T) {
+	// prepare test setup
+	store := &mockStore{}
+	r := keys.New(store, rotationInterval, gracePeriod)
+
+	// run code under test
+	if _, err := r.Sign(jwt.MapClaims{}); !errors.Is(err, keys.ErrNoSigningKey) {
+		t.Fatalf("Sign() before Refresh() error = %v, want %v", err, keys.ErrNoSigningKey)
+	}
+	if err := r.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	token := sign(t, r)
+
+	// assertions
+	if got, want := len(store.keys), 1; got != want {
+		t.Fatalf("got %d stored keys, want %d", got, want)
+	}
+	if err := verify(r, token); err != nil {
+		t.Errorf("verify() error = %v, want nil", err)
+	}
+	parsed, _ := jwt.Parse(token, r.VerificationKey)
+	if got, want := parsed.Header["kid"], store.keys[0].ID; got != want {
+		t.Errorf("got kid %v, want %v", got, want)
+	}
+	if got, want := parsed.Method, jwt.SigningMethod(jwt.SigningMethodRS256); got != want {
+		t.Errorf("got signing method %v, want %v", got.Alg(), want.Alg())
+	}
+	set := r.KeySet()
+	if got, want := len(set), 1; got != want {
+		t.Fatalf("got %d keys in set, want %d", got, want)
+	}
+	if got, want := set[0].Kid, store.keys[0].ID; got != want {
+		t.Errorf("got key set kid %v, want %v", got, want)
+	}
+}
+
+func TestRing_RejectsOtherTokens(t *testing.T) {
+	// prepare test setup
+	r := keys.New(&mockStore{}, rotationInterval, gracePeriod)
+	if err := r.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	other := keys.New(&mockStore{}, rotationInterval, gracePeriod)
+	if err := other.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	hmac, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "dummy-id"}).SignedString([]byte("dummy-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// run code under test and assertions
+	if err := verify(r, sign(t, other)); err == nil {
+		t.Errorf("verify() token of unknown kid error = nil, want error")
+	}
+	if err := verify(r, hmac); err == nil {
+		t.Errorf("verify() HMAC token error = nil, want error")
+	}
+}
+
+func TestRing_Rotation(t *testing.T) {
+	// prepare test setup
+	store := &mockStore{}
+	r := keys.New(store, rotationInterval, gracePeriod)
+	if err := r.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	oldToken := sign(t, r)
+
+	// make the key due for rotation
+	store.keys[0].CreatedAt = time.Now().Add(-rotationInterval)
+
+	// run code under test
+	if err := r.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	newToken := sign(t, r)
+
+	// assertions
+	if got, want := len(store.keys), 2; got != want {
+		t.Fatalf("got %d stored keys, want %d", got, want)
+	}
+	if err := verify(r, newToken); err != nil {
+		t.Errorf("verify() new token error = %v, want nil", err)
+	}
+	if err := verify(r, oldToken); err != nil {
+		t.Errorf("verify() old token within grace period error = %v, want nil", err)
+	}
+	if got, want := len(r.KeySet()), 2; got != want {
+		t.Errorf("got %d keys in set, want %d", got, want)
+	}
+
+	// end the grace period of the old key, which was retired when the new one was created
+	store.keys[1].CreatedAt = time.Now().Add(-2 * gracePeriod)
+	store.keys[0].CreatedAt = store.keys[1].CreatedAt.Add(-time.Hour)
+	if err := r.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if err := verify(r, oldToken); err == nil {
+		t.Errorf("verify() old token after grace period error = nil, want error")
+	}
+	if err := verify(r, newToken); err != nil {
+		t.Errorf("verify() new token error = %v, want nil", err)
+	}
+	if got, want := len(r.KeySet()), 1; got != want {
+		t.Errorf("got %d keys in set, want %d", got, want)
+	}
+}
+
+func TestRing_RefreshStoreError(t *testing.T) {
+	// prepare test setup
+	dummyError := errors.New("dummy-error")
+	store := &mockStore{}
+	r := keys.New(store, rotationInterval, gracePeriod)
+	if err := r.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	store.err = dummyError
+
+	// run code under test
+	err := r.Refresh(context.TODO())
+
+	// assertions
+	if !errors.Is(err, dummyError) {
+		t.Errorf("Refresh() error = %v, want %v", err, dummyError)
+	}
+	// keys loaded before keep working
+	if err := verify(r, sign(t, r)); err != nil {
+		t.Errorf("verify() error = %v, want nil", err)
+	}
+}
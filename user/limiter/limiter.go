@@ -0,0 +1,150 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Attempts are the failed attempts made with a key, such as an email or an IP address, as kept in the Store
+type Attempts struct {
+	Key           string    `bson:"_id"`
+	Failures      int       `bson:"failures"`
+	LastFailureAt time.Time `bson:"lastFailureAt"`
+	ExpiresAt     time.Time `bson:"expiresAt"`
+}
+
+type Store interface {
+	ReadAttempts(ctx context.Context, key string) (Attempts, error)
+	// AddFailure counts a failure at now, starting the count over if the attempts have expired
+	AddFailure(ctx context.Context, key string, now, expiresAt time.Time) (Attempts, error)
+	DeleteAttempts(ctx context.Context, key string) error
+}
+
+// Limiter locks keys out after too many failed attempts.
+// Each key has freeAttempts failures before being locked out for baseDelay,
+// with the lockout doubling at each further failure up to maxDelay.
+// Failures are forgotten window after the last of them.
+type Limiter struct {
+	store        Store
+	freeAttempts int
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	window       time.Duration
+}
+
+func New(store Store, freeAttempts int, baseDelay, maxDelay, window time.Duration) *Limiter {
+	return &Limiter{
+		store:        store,
+		freeAttempts: freeAttempts,
+		baseDelay:    baseDelay,
+		maxDelay:     maxDelay,
+		window:       window,
+	}
+}
+
+// RetryAfter returns how long until any of the keys can be tried again, zero if all of them can be tried now
+func (l *Limiter) RetryAfter(ctx context.Context, keys ...string) (time.Duration, error) {
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		a, err := l.store.ReadAttempts(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if !now.Before(a.ExpiresAt) {
+			continue
+		}
+		if d := a.LastFailureAt.Add(l.lockout(a.Failures)).Sub(now); d > retryAfter {
+			retryAfter = d
+		}
+	}
+	return retryAfter, nil
+}
+
+// RecordFailure counts a failed attempt for each of the keys
+func (l *Limiter) RecordFailure(ctx context.Context, keys ...string) error {
+	now := time.Now()
+	for _, key := range keys {
+		if _, err := l.store.AddFailure(ctx, key, now, now.Add(l.window)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResetAttempts forgets the failed attempts of each of the keys
+func (l *Limiter) ResetAttempts(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		if err := l.store.DeleteAttempts(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Limiter) lockout(failures int) time.Duration {
+	if failures < l.freeAttempts {
+		return 0
+	}
+	d := l.baseDelay
+	for i := l.freeAttempts; i < failures; i++ {
+		d *= 2
+		if d >= l.maxDelay {
+			return l.maxDelay
+		}
+	}
+	return d
+}
+
+const memorySweepInterval = time.Minute
+
+// MemoryStore keeps attempts in memory, for a single instance of the service
+type MemoryStore struct {
+	mu        sync.Mutex
+	attempts  map[string]Attempts
+	nextSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: make(map[string]Attempts)}
+}
+
+func (s *MemoryStore) ReadAttempts(ctx context.Context, key string) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[key], nil
+}
+
+func (s *MemoryStore) AddFailure(ctx context.Context, key string, now, expiresAt time.Time) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// drop expired attempts now and then, so that keys that are never tried again don't pile up
+	if !now.Before(s.nextSweep) {
+		for k, a := range s.attempts {
+			if !now.Before(a.ExpiresAt) {
+				delete(s.attempts, k)
+			}
+		}
+		s.nextSweep = now.Add(memorySweepInterval)
+	}
+
+	a := s.attempts[key]
+	if !now.Before(a.ExpiresAt) {
+		a = Attempts{}
+	}
+	a.Key = key
+	a.Failures++
+	a.LastFailureAt = now
+	a.ExpiresAt = expiresAt
+	s.attempts[key] = a
+	return a, nil
+}
+
+func (s *MemoryStore) DeleteAttempts(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
@@ -0,0 +1,142 @@
+package limiter_test
+
+import (
+	"context"
+	"errors"
+	"github.com/gabrielseibel1/gaef/user/limiter"
+	"testing"
+	"time"
+)
+
+const (
+	freeAttempts = 3
+	baseDelay    = time.Minute
+	maxDelay     = 5 * time.Minute
+	window       = time.Hour
+)
+
+func retryAfter(t *testing.T, l *limiter.Limiter, keys ...string) time.Duration {
+	d, err := l.RetryAfter(context.TODO(), keys...)
+	if err != nil {
+		t.Fatalf("RetryAfter() error = %v", err)
+	}
+	return d
+}
+
+func fail(t *testing.T, l *limiter.Limiter, times int, keys ...string) {
+	for i := 0; i < times; i++ {
+		if err := l.RecordFailure(context.TODO(), keys...); err != nil {
+			t.Fatalf("RecordFailure() error = %v", err)
+		}
+	}
+}
+
+func TestLimiter_Backoff(t *testing.T) {
+	// prepare test setup
+	l := limiter.New(limiter.NewMemoryStore(), freeAttempts, baseDelay, maxDelay, window)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: baseDelay},
+		{failures: 4, want: 2 * baseDelay},
+		{failures: 5, want: 4 * baseDelay},
+		{failures: 6, want: maxDelay},
+		{failures: 20, want: maxDelay},
+	}
+	failures := 0
+	for _, tt := range tests {
+		// run code under test
+		fail(t, l, tt.failures-failures, "dummy-key")
+		failures = tt.failures
+		got := retryAfter(t, l, "dummy-key")
+
+		// assertions
+		if got > tt.want || got < tt.want-time.Second {
+			t.Errorf("after %d failures got retry after %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLimiter_Keys(t *testing.T) {
+	// prepare test setup
+	l := limiter.New(limiter.NewMemoryStore(), freeAttempts, baseDelay, maxDelay, window)
+
+	// run code under test
+	fail(t, l, freeAttempts, "email:dummy", "ip:dummy")
+	fail(t, l, 1, "email:other", "ip:dummy")
+
+	// assertions
+	if got, want := retryAfter(t, l, "email:other"), time.Duration(0); got != want {
+		t.Errorf("got retry after %v for other email, want %v", got, want)
+	}
+	if got, want := retryAfter(t, l, "email:other", "ip:dummy"), 2*baseDelay; got > want || got < want-time.Second {
+		t.Errorf("got retry after %v for other email from the same ip, want the longest lockout %v", got, want)
+	}
+
+	if err := l.ResetAttempts(context.TODO(), "email:dummy", "ip:dummy"); err != nil {
+		t.Fatalf("ResetAttempts() error = %v", err)
+	}
+	if got, want := retryAfter(t, l, "email:dummy", "ip:dummy"), time.Duration(0); got != want {
+		t.Errorf("got retry after %v after reset, want %v", got, want)
+	}
+}
+
+func TestMemoryStore_Expiry(t *testing.T) {
+	// prepare test setup
+	s := limiter.NewMemoryStore()
+	now := time.Now()
+
+	// run code under test
+	if _, err := s.AddFailure(context.TODO(), "dummy-key", now.Add(-2*window), now.Add(-window)); err != nil {
+		t.Fatalf("AddFailure() error = %v", err)
+	}
+	a, err := s.AddFailure(context.TODO(), "dummy-key", now, now.Add(window))
+
+	// assertions
+	if err != nil {
+		t.Fatalf("AddFailure() error = %v", err)
+	}
+	if got, want := a.Failures, 1; got != want {
+		t.Errorf("got %d failures after expiry, want %d", got, want)
+	}
+	if got, want := a.LastFailureAt, now; !got.Equal(want) {
+		t.Errorf("got last failure at %v, want %v", got, want)
+	}
+}
+
+type mockStore struct {
+	err error
+}
+
+func (m *mockStore) ReadAttempts(ctx context.Context, key string) (limiter.Attempts, error) {
+	return limiter.Attempts{}, m.err
+}
+
+func (m *mockStore) AddFailure(ctx context.Context, key string, now, expiresAt time.Time) (limiter.Attempts, error) {
+	return limiter.Attempts{}, m.err
+}
+
+func (m *mockStore) DeleteAttempts(ctx context.Context, key string) error {
+	return m.err
+}
+
+func TestLimiter_StoreError(t *testing.T) {
+	// prepare test setup
+	dummyError := errors.New("dummy-error")
+	l := limiter.New(&mockStore{err: dummyError}, freeAttempts, baseDelay, maxDelay, window)
+
+	// run code under test and assertions
+	if _, err := l.RetryAfter(context.TODO(), "dummy-key"); !errors.Is(err, dummyError) {
+		t.Errorf("RetryAfter() error = %v, want %v", err, dummyError)
+	}
+	if err := l.RecordFailure(context.TODO(), "dummy-key"); !errors.Is(err, dummyError) {
+		t.Errorf("RecordFailure() error = %v, want %v", err, dummyError)
+	}
+	if err := l.ResetAttempts(context.TODO(), "dummy-key"); !errors.Is(err, dummyError) {
+		t.Errorf("ResetAttempts() error = %v, want %v", err, dummyError)
+	}
+}
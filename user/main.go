@@ -4,9 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/gabrielseibel1/gaef/blob"
+	"github.com/gabrielseibel1/gaef/blob/picture"
+	encounterClient "github.com/gabrielseibel1/gaef/client/encounter"
+	encounterProposalClient "github.com/gabrielseibel1/gaef/client/encounter-proposal"
+	groupClient "github.com/gabrielseibel1/gaef/client/group"
 	"github.com/gabrielseibel1/gaef/messenger"
+	"github.com/gabrielseibel1/gaef/user/export"
 	"github.com/gabrielseibel1/gaef/user/hasher"
-	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/gabrielseibel1/gaef/user/keys"
+	"github.com/gabrielseibel1/gaef/user/limiter"
+	"github.com/gabrielseibel1/gaef/user/mail"
+	"github.com/gabrielseibel1/gaef/user/notifier"
+	"github.com/gabrielseibel1/gaef/user/oidc"
+	"github.com/gabrielseibel1/gaef/user/outbox"
 	"log"
 	"net/http"
 	"os"
@@ -30,12 +41,65 @@ type SignupHandler interface {
 }
 type LoginHandler interface {
 	Login() gin.HandlerFunc
+	CompleteMFA() gin.HandlerFunc
+}
+type SessionHandler interface {
+	RefreshSession() gin.HandlerFunc
+	Logout() gin.HandlerFunc
+	GetSessions() gin.HandlerFunc
+	RevokeSessions() gin.HandlerFunc
+}
+type APIKeyHandler interface {
+	CreateAPIKey() gin.HandlerFunc
+	GetAPIKeys() gin.HandlerFunc
+	RevokeAPIKey() gin.HandlerFunc
+	ExchangeAPIKey() gin.HandlerFunc
+}
+type OIDCHandler interface {
+	StartOIDCLogin() gin.HandlerFunc
+	CompleteOIDCLogin() gin.HandlerFunc
+	LinkOIDCIdentity() gin.HandlerFunc
+}
+type PasswordHandler interface {
+	ChangePassword() gin.HandlerFunc
+	RequestPasswordReset() gin.HandlerFunc
+	ResetPassword() gin.HandlerFunc
+}
+type VerificationHandler interface {
+	RequestVerification() gin.HandlerFunc
+	Verify() gin.HandlerFunc
+}
+type MFAHandler interface {
+	EnrollMFA() gin.HandlerFunc
+	ConfirmMFA() gin.HandlerFunc
+	RegenerateRecoveryCodes() gin.HandlerFunc
+}
+type AdminHandler interface {
+	AdminAuthMiddleware() gin.HandlerFunc
+	ListUsers() gin.HandlerFunc
+	SuspendUser() gin.HandlerFunc
+	UnsuspendUser() gin.HandlerFunc
+	SetUserRole() gin.HandlerFunc
+	ForceLogout() gin.HandlerFunc
+	AdminDeleteUser() gin.HandlerFunc
+	AdminGetErasure() gin.HandlerFunc
+}
+type PictureHandler interface {
+	UploadPicture() gin.HandlerFunc
+	GetPicture() gin.HandlerFunc
 }
 type TokenHandler interface {
 	GetIDFromToken() gin.HandlerFunc
 }
+type KeySetHandler interface {
+	GetKeySet() gin.HandlerFunc
+}
+type SearchHandler interface {
+	SearchUsers() gin.HandlerFunc
+}
 type GetHandler interface {
 	GetUserFromID() gin.HandlerFunc
+	GetUserProfile() gin.HandlerFunc
 }
 type UpdateHandler interface {
 	UpdateUser() gin.HandlerFunc
@@ -43,29 +107,69 @@ type UpdateHandler interface {
 type DeleteHandler interface {
 	DeleteUser() gin.HandlerFunc
 }
+type ExportHandler interface {
+	ExportUserData() gin.HandlerFunc
+}
 
 type handlerGenerator struct {
-	authHandler   AuthHandler
-	signupHandler SignupHandler
-	loginHandler  LoginHandler
-	tokenHandler  TokenHandler
-	getHandler    GetHandler
-	updateHandler UpdateHandler
-	deleteHandler DeleteHandler
+	authHandler         AuthHandler
+	signupHandler       SignupHandler
+	loginHandler        LoginHandler
+	sessionHandler      SessionHandler
+	apiKeyHandler       APIKeyHandler
+	oidcHandler         OIDCHandler
+	passwordHandler     PasswordHandler
+	verificationHandler VerificationHandler
+	mfaHandler          MFAHandler
+	adminHandler        AdminHandler
+	pictureHandler      PictureHandler
+	tokenHandler        TokenHandler
+	keySetHandler       KeySetHandler
+	searchHandler       SearchHandler
+	getHandler          GetHandler
+	updateHandler       UpdateHandler
+	deleteHandler       DeleteHandler
+	exportHandler       ExportHandler
+}
+
+type Notifier interface {
+	handler.PasswordResetNotifier
+	handler.VerificationNotifier
+}
+
+type OIDCProvider interface {
+	handler.OIDCAuthorizer
+	handler.OIDCExchanger
 }
 
 // implementation
 
 func main() {
 	// read environment variables
-	jwtSecret := os.Getenv("JWT_SECRET")
 	port := os.Getenv("PORT")
 	amqpURI := os.Getenv("AMQP_URI")
 	amqpExchangeUpdates := os.Getenv("AMQP_EXCHANGE_UPDATES")
 	amqpExchangeDeletes := os.Getenv("AMQP_EXCHANGE_DELETES")
+	amqpExchangeErasures := os.Getenv("AMQP_EXCHANGE_ERASURES")
+	amqpQueuePrefix := os.Getenv("AMQP_QUEUE_PREFIX")
 	dbURI := os.Getenv("MONGODB_URI")
 	dbName := os.Getenv("MONGODB_DATABASE")
 	collectionName := os.Getenv("MONGODB_COLLECTION")
+	notificationsFile := os.Getenv("NOTIFICATIONS_FILE")
+	smtpHost := os.Getenv("SMTP_HOST")
+	smtpPort := os.Getenv("SMTP_PORT")
+	smtpUsername := os.Getenv("SMTP_USERNAME")
+	smtpPassword := os.Getenv("SMTP_PASSWORD")
+	smtpFrom := os.Getenv("SMTP_FROM")
+	publicURL := os.Getenv("PUBLIC_URL")
+	picturesDir := os.Getenv("PICTURES_DIR")
+	groupServiceURL := os.Getenv("GROUP_SERVICE_URL")
+	encounterProposalServiceURL := os.Getenv("ENCOUNTER_PROPOSAL_SERVICE_URL")
+	encounterServiceURL := os.Getenv("ENCOUNTER_SERVICE_URL")
+	oidcIssuer := os.Getenv("OIDC_ISSUER")
+	oidcClientID := os.Getenv("OIDC_CLIENT_ID")
+	oidcClientSecret := os.Getenv("OIDC_CLIENT_SECRET")
+	oidcRedirectURL := os.Getenv("OIDC_REDIRECT_URL")
 
 	// connect to mongoDB
 	client, err := setupMongoDB(dbURI)
@@ -79,26 +183,202 @@ func main() {
 	}(client, context.Background())
 
 	// connect to rabbitmq
-	channel, err := setupRabbitMQ(amqpURI, amqpExchangeUpdates, amqpExchangeDeletes)
+	connection, err := setupRabbitMQ(amqpURI, amqpExchangeUpdates, amqpExchangeDeletes, amqpExchangeErasures)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// mail notifications if an SMTP server is set, write them to a file or stdout otherwise
+	var ntf Notifier
+	if smtpHost != "" {
+		ntf = notifier.NewMailNotifier(mail.NewSMTPSender(smtpHost, smtpPort, smtpUsername, smtpPassword, smtpFrom))
+	} else {
+		notifications := os.Stdout
+		if notificationsFile != "" {
+			notifications, err = os.OpenFile(notificationsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		ntf = notifier.NewLogNotifier(notifications)
+	}
+
+	// keep pictures in a directory if set, in GridFS otherwise
+	var pictureStore blob.Store = blob.NewGridFSStore(client.Database(dbName), picturesBucketName)
+	if picturesDir != "" {
+		pictureStore, err = blob.NewLocalStore(picturesDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// log in with an OpenID provider if an issuer is set
+	var idp OIDCProvider
+	if oidcIssuer != "" {
+		idp, err = oidc.NewProvider(context.Background(), oidc.Config{
+			Issuer:       oidcIssuer,
+			ClientID:     oidcClientID,
+			ClientSecret: oidcClientSecret,
+			RedirectURL:  oidcRedirectURL,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// instantiate and inject dependencies
-	msg := messenger.New(json.Marshal, amqpExchangeUpdates, amqpExchangeDeletes, channel)
+	msg := messenger.New(json.Marshal, amqpSource, amqpExchangeUpdates, amqpExchangeDeletes, amqpExchangeErasures, connection)
 	str := store.NewMongoStore(client.Database(dbName).Collection(collectionName))
-	phv := hasher.New()
-	hdl := handler.New(phv, phv, str, str, str, str, str, msg, msg, []byte(jwtSecret))
+	if err := str.CreateSearchIndex(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := str.CreateIdentityIndex(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := str.VerifyLegacyUsers(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	if err := str.IndexLegacyUsers(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	hasherParams, err := hasher.ParamsFromEnv(os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+	phv := hasher.New(hasherParams)
+	krg := keys.New(store.NewMongoKeyStore(client.Database(dbName).Collection(keysCollectionName)), keyRotationInterval, keyGracePeriod)
+	if err := krg.Refresh(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	ses := store.NewMongoSessionStore(client.Database(dbName).Collection(sessionsCollectionName))
+	if err := ses.CreateExpiryIndex(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	ats := store.NewMongoAttemptStore(client.Database(dbName).Collection(loginAttemptsCollectionName))
+	if err := ats.CreateExpiryIndex(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	lim := limiter.New(ats, loginFreeAttempts, loginBaseDelay, loginMaxDelay, loginAttemptsWindow)
+	pic := picture.New(pictureStore, publicURL+picturesPath, maxPictureSize, thumbnailSize)
+	aks := store.NewMongoAPIKeyStore(client.Database(dbName).Collection(apiKeysCollectionName))
+	ers := store.NewMongoErasureStore(client.Database(dbName).Collection(erasuresCollectionName))
+	grc := groupClient.Client{URL: groupServiceURL}
+	exp := export.New(str, ses, grc, grc, encounterProposalClient.Client{URL: encounterProposalServiceURL}, encounterClient.Client{URL: encounterServiceURL})
+	hdl := handler.New(handler.Dependencies{
+		Users: handler.Users{
+			Hasher:        phv,
+			Verifier:      phv,
+			Creator:       str,
+			ByIDReader:    str,
+			ByEmailReader: str,
+			Updater:       str,
+			Deleter:       str,
+			Searcher:      str,
+		},
+		Tokens: handler.Tokens{
+			Signer:       krg,
+			KeyReader:    krg,
+			KeySetReader: krg,
+		},
+		Sessions: handler.Sessions{
+			Creator:      ses,
+			Rotator:      ses,
+			Reader:       ses,
+			Lister:       ses,
+			Revoker:      ses,
+			AllRevoker:   ses,
+			OtherRevoker: ses,
+		},
+		Passwords: handler.Passwords{
+			SensitiveByIDReader: str,
+			Updater:             str,
+			ResetCreator:        str,
+			Resetter:            str,
+			ResetNotifier:       ntf,
+			RehashChecker:       phv,
+			Rehasher:            str,
+		},
+		Verification: handler.Verification{
+			Notifier:      ntf,
+			EmailVerifier: str,
+		},
+		Logins: handler.Logins{
+			Throttler:        lim,
+			FailureRecorder:  lim,
+			AttemptsResetter: lim,
+		},
+		MFA: handler.MFA{
+			EnrollmentCreator:     str,
+			Reader:                str,
+			Enabler:               str,
+			TOTPStepUser:          str,
+			RecoveryCodeUser:      str,
+			RecoveryCodesReplacer: str,
+		},
+		Admin: handler.Admin{
+			UsersReader:      str,
+			SuspensionSetter: str,
+			RoleSetter:       str,
+		},
+		Pictures: handler.Pictures{
+			Saver:   pic,
+			Opener:  pic,
+			Deleter: pic,
+		},
+		Privacy: handler.Privacy{
+			Exporter:       exp,
+			ErasureCreator: ers,
+			ErasureReader:  ers,
+		},
+		APIKeys: handler.APIKeys{
+			Creator:    aks,
+			Reader:     aks,
+			Lister:     aks,
+			User:       aks,
+			Revoker:    aks,
+			AllRevoker: aks,
+		},
+		OIDC: handler.OIDC{
+			Authorizer:       idp,
+			Exchanger:        idp,
+			ByIdentityReader: str,
+			IdentityLinker:   str,
+		},
+	})
+	rly := outbox.NewRelay(str, str, msg, msg, ers, outboxRelayInterval, outboxRelayMaxBackoff)
 	gen := handlerGenerator{
-		authHandler:   hdl,
-		signupHandler: hdl,
-		loginHandler:  hdl,
-		tokenHandler:  hdl,
-		getHandler:    hdl,
-		updateHandler: hdl,
-		deleteHandler: hdl,
+		authHandler:         hdl,
+		signupHandler:       hdl,
+		loginHandler:        hdl,
+		sessionHandler:      hdl,
+		apiKeyHandler:       hdl,
+		oidcHandler:         hdl,
+		passwordHandler:     hdl,
+		verificationHandler: hdl,
+		mfaHandler:          hdl,
+		adminHandler:        hdl,
+		pictureHandler:      hdl,
+		tokenHandler:        hdl,
+		keySetHandler:       hdl,
+		searchHandler:       hdl,
+		getHandler:          hdl,
+		updateHandler:       hdl,
+		deleteHandler:       hdl,
+		exportHandler:       hdl,
 	}
 
+	// send events recorded in the outbox to the broker
+	go func() { log.Fatal(rly.Run(context.Background())) }()
+
+	// track the services confirming they erased deleted users
+	consumer := messenger.NewConsumer(json.Unmarshal, amqpQueuePrefix, amqpExchangeUpdates, amqpExchangeDeletes, amqpExchangeErasures, connection)
+	go messenger.KeepConsuming(context.Background(), "user erasures", amqpReconnectDelay, amqpMaxReconnectDelay, func(ctx context.Context) error {
+		return consumer.ConsumeUserErasures(ctx, ers)
+	})
+
+	// pick up signing keys rotated by any instance
+	go func() { log.Fatal(krg.Run(context.Background(), keyRefreshInterval)) }()
+
 	r := gin.Default()
 	users := r.Group("/api/v0/users")
 	{
@@ -108,18 +388,82 @@ func main() {
 		{
 			public.POST("/", gen.signupHandler.Signup())
 			public.POST("/session", gen.loginHandler.Login())
+			public.POST("/session/mfa", gen.loginHandler.CompleteMFA())
+			public.POST("/session/refresh", gen.sessionHandler.RefreshSession())
+			public.POST("/api-keys/token", gen.apiKeyHandler.ExchangeAPIKey())
+			public.POST("/password-reset", gen.passwordHandler.RequestPasswordReset())
+			public.PUT("/password-reset", gen.passwordHandler.ResetPassword())
+			public.POST("/verification", gen.verificationHandler.Verify())
+			public.GET("/.well-known/jwks.json", gen.keySetHandler.GetKeySet())
+			public.GET("/pictures/:name", gen.pictureHandler.GetPicture())
+			if idp != nil {
+				public.GET("/oidc/authorization", gen.oidcHandler.StartOIDCLogin())
+				public.POST("/oidc/session", gen.oidcHandler.CompleteOIDCLogin())
+			}
 		}
 		auth := users.Group("", gen.authHandler.JWTAuthMiddleware())
 		{
+			auth.GET("/", gen.searchHandler.SearchUsers())
 			auth.GET("/token-validation", gen.tokenHandler.GetIDFromToken())
+			auth.DELETE("/session", gen.sessionHandler.Logout())
+			auth.GET("/sessions", gen.sessionHandler.GetSessions())
+			auth.DELETE("/sessions", gen.sessionHandler.RevokeSessions())
+			auth.POST("/api-keys", gen.apiKeyHandler.CreateAPIKey())
+			auth.GET("/api-keys", gen.apiKeyHandler.GetAPIKeys())
+			auth.DELETE("/api-keys/:keyID", gen.apiKeyHandler.RevokeAPIKey())
+			auth.GET("/profiles/:userID", gen.getHandler.GetUserProfile())
 			auth.GET("/:id", gen.getHandler.GetUserFromID())
 			auth.PUT("/:id", gen.updateHandler.UpdateUser())
+			auth.PUT("/:id/password", gen.passwordHandler.ChangePassword())
+			auth.PUT("/:id/picture", gen.pictureHandler.UploadPicture())
+			auth.POST("/:id/verification", gen.verificationHandler.RequestVerification())
+			auth.POST("/:id/mfa", gen.mfaHandler.EnrollMFA())
+			auth.PUT("/:id/mfa", gen.mfaHandler.ConfirmMFA())
+			auth.POST("/:id/mfa/recovery-codes", gen.mfaHandler.RegenerateRecoveryCodes())
 			auth.DELETE("/:id", gen.deleteHandler.DeleteUser())
+			auth.GET("/:id/export", gen.exportHandler.ExportUserData())
+			if idp != nil {
+				auth.POST("/:id/identities", gen.oidcHandler.LinkOIDCIdentity())
+			}
 		}
 	}
+	admin := r.Group("/api/v0/admin/users", gen.adminHandler.AdminAuthMiddleware())
+	{
+		admin.GET("/", gen.adminHandler.ListUsers())
+		admin.PUT("/:id/suspension", gen.adminHandler.SuspendUser())
+		admin.DELETE("/:id/suspension", gen.adminHandler.UnsuspendUser())
+		admin.PUT("/:id/role", gen.adminHandler.SetUserRole())
+		admin.DELETE("/:id/sessions", gen.adminHandler.ForceLogout())
+		admin.DELETE("/:id", gen.adminHandler.AdminDeleteUser())
+		admin.GET("/:id/erasure", gen.adminHandler.AdminGetErasure())
+	}
 	log.Fatal(r.Run(fmt.Sprintf("0.0.0.0:%s", port)))
 }
 
+const (
+	amqpSource                  = "gaef-user-service"
+	outboxRelayInterval         = time.Second
+	outboxRelayMaxBackoff       = time.Minute
+	amqpReconnectDelay          = time.Second
+	amqpMaxReconnectDelay       = time.Minute
+	keysCollectionName          = "signing-keys"
+	sessionsCollectionName      = "sessions"
+	loginAttemptsCollectionName = "login-attempts"
+	erasuresCollectionName      = "erasures"
+	apiKeysCollectionName       = "api-keys"
+	keyRefreshInterval          = time.Minute
+	keyRotationInterval         = 30 * 24 * time.Hour
+	keyGracePeriod              = handler.MaxTokenTTL // keys rotated out still verify the tokens they signed
+	loginFreeAttempts           = 5
+	loginBaseDelay              = time.Second
+	loginMaxDelay               = 15 * time.Minute
+	loginAttemptsWindow         = 24 * time.Hour
+	picturesBucketName          = "pictures"
+	picturesPath                = "/api/v0/users/pictures/"
+	maxPictureSize              = 5 << 20
+	thumbnailSize               = 128
+)
+
 func setupMongoDB(dbURI string) (*mongo.Client, error) {
 	serverAPIOptions := options.ServerAPI(options.ServerAPIVersion1)
 	clientOptions := options.Client().
@@ -140,38 +484,15 @@ func setupMongoDB(dbURI string) (*mongo.Client, error) {
 	return client, nil
 }
 
-func setupRabbitMQ(amqpURI, amqpExchangeUpdates, amqpExchangeDeletes string) (*amqp.Channel, error) {
-	connection, err := amqp.Dial(amqpURI)
-	if err != nil {
-		return nil, err
-	}
-	channel, err := connection.Channel()
-	if err != nil {
-		return nil, err
-	}
-	err = channel.ExchangeDeclare(
-		amqpExchangeUpdates,
-		amqp.ExchangeFanout,
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+func setupRabbitMQ(amqpURI string, exchanges ...string) (*messenger.Connection, error) {
+	connection, err := messenger.Dial(amqpURI)
 	if err != nil {
 		return nil, err
 	}
-	err = channel.ExchangeDeclare(
-		amqpExchangeDeletes,
-		amqp.ExchangeFanout,
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		return nil, err
+	for _, exchange := range exchanges {
+		if err := messenger.DeclareExchange(connection, exchange); err != nil {
+			return nil, err
+		}
 	}
-	return channel, nil
+	return connection, nil
 }
@@ -0,0 +1,130 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"github.com/gabrielseibel1/gaef/user/secret"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Enrollment is the TOTP second factor of a user.
+// A secret is pending until the user confirms it with a valid code, and only then becomes the Secret.
+type Enrollment struct {
+	PendingSecret      string   `bson:"pendingSecret,omitempty"`
+	Secret             string   `bson:"secret,omitempty"`
+	RecoveryCodeHashes []string `bson:"recoveryCodeHashes,omitempty"`
+	LastUsedStep       int64    `bson:"lastUsedStep"` // codes of this step and earlier can't be used again
+}
+
+// TOTP parameters (RFC 6238), the defaults understood by authenticator apps
+const (
+	digits = 6
+	period = 30 * time.Second
+	skew   = 1 // steps of clock drift accepted either way
+)
+
+const (
+	secretSize         = 20
+	recoveryCodeSize   = 5
+	recoveryCodesCount = 10
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret generates a TOTP secret, base32 encoded as authenticator apps expect it
+func NewSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// URI is the otpauth URI of the secret, to be displayed as a QR code to authenticator apps
+func URI(issuer, account, secret string) string {
+	u := url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + issuer + ":" + account,
+		RawQuery: url.Values{
+			"secret":    {secret},
+			"issuer":    {issuer},
+			"algorithm": {"SHA1"},
+			"digits":    {fmt.Sprint(digits)},
+			"period":    {fmt.Sprint(int(period.Seconds()))},
+		}.Encode(),
+	}
+	return u.String()
+}
+
+// Step is the TOTP time step that t falls in
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(period.Seconds())
+}
+
+// Code is the TOTP code of the secret for a time step
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation (RFC 4226)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod), nil
+}
+
+// Validate checks the code against the secret around t, returning the step it was valid for
+func Validate(secret, code string, t time.Time) (int64, bool) {
+	now := Step(t)
+	for step := now - skew; step <= now+skew; step++ {
+		want, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// NewRecoveryCodes generates single-use codes to sign in without the authenticator, returning them and the hashes to be stored
+func NewRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))
+		codes[i] = code[:len(code)/2] + "-" + code[len(code)/2:]
+		hashes[i] = HashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode hashes a recovery code as typed by the user, ignoring case, spaces and dashes
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return secret.Hash(code)
+}
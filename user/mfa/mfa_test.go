@@ -0,0 +1,127 @@
+package mfa_test
+
+import (
+	"encoding/base32"
+	"github.com/gabrielseibel1/gaef/user/mfa"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// the SHA1 seed of the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+	for _, tt := range tests {
+		// run code under test
+		got, err := mfa.Code(rfcSecret, mfa.Step(time.Unix(tt.unix, 0)))
+
+		// assertions
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Code() at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	// prepare test setup
+	secret, err := mfa.NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret() error = %v", err)
+	}
+	now := time.Now()
+	step := mfa.Step(now)
+	code := func(step int64) string {
+		c, err := mfa.Code(secret, step)
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: code(step), wantStep: step, wantOK: true},
+		{name: "previous step", code: code(step - 1), wantStep: step - 1, wantOK: true},
+		{name: "next step", code: code(step + 1), wantStep: step + 1, wantOK: true},
+		{name: "too old", code: code(step - 3), wantOK: false},
+		{name: "garbage", code: "12345", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// run code under test
+			gotStep, gotOK := mfa.Validate(secret, tt.code, now)
+
+			// assertions
+			if gotOK != tt.wantOK {
+				t.Fatalf("Validate() ok = %v, want %v", gotOK, tt.wantOK)
+			}
+			if gotStep != tt.wantStep {
+				t.Errorf("Validate() step = %d, want %d", gotStep, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	// run code under test
+	u, err := url.Parse(mfa.URI("gaef", "gabriel.seibel@tuta.io", "DUMMYSECRET"))
+
+	// assertions
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+	if got, want := u.Scheme+"://"+u.Host+u.Path, "otpauth://totp/gaef:gabriel.seibel@tuta.io"; got != want {
+		t.Errorf("got URI %s, want %s", got, want)
+	}
+	if got, want := u.Query().Get("secret"), "DUMMYSECRET"; got != want {
+		t.Errorf("got secret %s, want %s", got, want)
+	}
+	if got, want := u.Query().Get("issuer"), "gaef"; got != want {
+		t.Errorf("got issuer %s, want %s", got, want)
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	// run code under test
+	codes, hashes, err := mfa.NewRecoveryCodes()
+
+	// assertions
+	if err != nil {
+		t.Fatalf("NewRecoveryCodes() error = %v", err)
+	}
+	if got, want := len(codes), len(hashes); got != want {
+		t.Fatalf("got %d codes and %d hashes, want as many", got, want)
+	}
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		if seen[code] {
+			t.Errorf("got repeated code %s", code)
+		}
+		seen[code] = true
+		if got, want := mfa.HashRecoveryCode(code), hashes[i]; got != want {
+			t.Errorf("got hash %s of code %s, want %s", got, code, want)
+		}
+	}
+	if got, want := mfa.HashRecoveryCode(" ABCD-efgh "), mfa.HashRecoveryCode("abcdefgh"); got != want {
+		t.Errorf("got hash %s of code as typed, want %s", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/user/mail"
+	"io"
+	"log"
+)
+
+// LogNotifier writes notifications to a log instead of delivering them, for local runs
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+func NewLogNotifier(w io.Writer) LogNotifier {
+	return LogNotifier{logger: log.New(w, "notification: ", log.LstdFlags)}
+}
+
+func (n LogNotifier) NotifyPasswordReset(ctx context.Context, user types.User, token string) error {
+	n.logger.Printf("to %s <%s>: use the token %s to reset your password", user.Name, user.Email, token)
+	return nil
+}
+
+func (n LogNotifier) NotifyVerification(ctx context.Context, user types.User, code string) error {
+	n.logger.Printf("to %s <%s>: use the code %s to verify your email", user.Name, user.Email, code)
+	return nil
+}
+
+type MailSender interface {
+	Send(ctx context.Context, m mail.Message) error
+}
+
+// MailNotifier delivers notifications by mail
+type MailNotifier struct {
+	sender MailSender
+}
+
+func NewMailNotifier(sender MailSender) MailNotifier {
+	return MailNotifier{sender: sender}
+}
+
+func (n MailNotifier) NotifyPasswordReset(ctx context.Context, user types.User, token string) error {
+	return n.sender.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf("Hi %s,\n\nUse this token to reset your password:\n\n%s\n\nIf you didn't ask for it, ignore this message.\n", user.Name, token),
+	})
+}
+
+func (n MailNotifier) NotifyVerification(ctx context.Context, user types.User, code string) error {
+	return n.sender.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf("Hi %s,\n\nUse this code to verify your email:\n\n%s\n", user.Name, code),
+	})
+}
@@ -0,0 +1,57 @@
+package notifier_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/user/mail"
+	"github.com/gabrielseibel1/gaef/user/notifier"
+	"strings"
+	"testing"
+)
+
+var dummyUser = types.User{ID: "dummy-id", Name: "dummy-name", Email: "dummy@email.com"}
+
+func TestMailNotifier(t *testing.T) {
+	// prepare test setup
+	sender := &mail.MemorySender{}
+	n := notifier.NewMailNotifier(sender)
+
+	// run code under test
+	if err := n.NotifyVerification(context.TODO(), dummyUser, "dummy-code"); err != nil {
+		t.Fatalf("NotifyVerification() error = %v", err)
+	}
+	if err := n.NotifyPasswordReset(context.TODO(), dummyUser, "dummy-token"); err != nil {
+		t.Fatalf("NotifyPasswordReset() error = %v", err)
+	}
+
+	// assertions
+	sent := sender.Sent()
+	if got, want := len(sent), 2; got != want {
+		t.Fatalf("got %d messages sent, want %d", got, want)
+	}
+	for i, secret := range []string{"dummy-code", "dummy-token"} {
+		if got, want := sent[i].To, dummyUser.Email; got != want {
+			t.Errorf("got message %d sent to %s, want %s", i, got, want)
+		}
+		if !strings.Contains(sent[i].Body, secret) {
+			t.Errorf("got message %d body %q, want it to contain %q", i, sent[i].Body, secret)
+		}
+	}
+}
+
+func TestLogNotifier(t *testing.T) {
+	// prepare test setup
+	var buf bytes.Buffer
+	n := notifier.NewLogNotifier(&buf)
+
+	// run code under test
+	if err := n.NotifyVerification(context.TODO(), dummyUser, "dummy-code"); err != nil {
+		t.Fatalf("NotifyVerification() error = %v", err)
+	}
+
+	// assertions
+	if got := buf.String(); !strings.Contains(got, dummyUser.Email) || !strings.Contains(got, "dummy-code") {
+		t.Errorf("got log %q, want it to contain the email and the code", got)
+	}
+}
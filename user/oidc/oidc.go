@@ -0,0 +1,196 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gabrielseibel1/gaef/auth"
+	"github.com/gabrielseibel1/gaef/user/secret"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Config identifies this service as a client of an OpenID provider
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// Claims are what the provider tells about the user in its ID token
+type Claims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Nonce         string
+}
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// ErrIdentityLinked is returned when linking an identity that is already linked to another user
+var ErrIdentityLinked = errors.New("identity is linked to another user")
+
+// keySetTTL is how long the signing keys of the provider are cached, they are refetched sooner for unknown key IDs,
+// but no more than once every keySetMinRefetchInterval
+const keySetTTL = time.Hour
+const keySetMinRefetchInterval = 10 * time.Second
+
+// Provider runs the authorization code flow with PKCE against an OpenID provider
+type Provider struct {
+	config                Config
+	authorizationEndpoint string
+	tokenEndpoint         string
+	keys                  auth.KeySet
+}
+
+// NewProvider discovers the endpoints and the keys of the provider from its issuer URL
+func NewProvider(ctx context.Context, config Config) (*Provider, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery request returned status code %d", resp.StatusCode)
+	}
+
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, err
+	}
+	// the issuer must be exactly the one configured, as ID tokens are checked against it
+	if discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("discovered issuer %q, want %q", discovery.Issuer, config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("incomplete provider configuration")
+	}
+
+	return &Provider{
+		config:                config,
+		authorizationEndpoint: discovery.AuthorizationEndpoint,
+		tokenEndpoint:         discovery.TokenEndpoint,
+		keys:                  auth.NewRemoteKeySet(discovery.JWKSURI, keySetTTL, keySetMinRefetchInterval),
+	}, nil
+}
+
+// AuthorizationURL is where to send the user to log in at the provider
+func (p *Provider) AuthorizationURL(state, nonce, codeChallenge string) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(p.authorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.authorizationEndpoint + separator + q.Encode()
+}
+
+// Exchange trades the code the provider redirected the user with for its ID token, returning the verified claims.
+// The nonce is not checked here, callers must compare it with the one they sent.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (Claims, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Claims{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Claims{}, fmt.Errorf("token request returned status code %d", resp.StatusCode)
+	}
+
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return Claims{}, err
+	}
+	return p.verify(ctx, body.IDToken)
+}
+
+func (p *Provider) verify(ctx context.Context, idToken string) (Claims, error) {
+	token, err := jwt.Parse(idToken, func(token *jwt.Token) (interface{}, error) {
+		return p.keys.VerificationKey(ctx, token)
+	})
+	if err != nil || !token.Valid {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return Claims{}, ErrInvalidIDToken
+	}
+	if _, ok := claims["exp"]; !ok {
+		return Claims{}, fmt.Errorf("%w: missing expiration", ErrInvalidIDToken)
+	}
+	if !claims.VerifyIssuer(p.config.Issuer, true) {
+		return Claims{}, fmt.Errorf("%w: unexpected issuer", ErrInvalidIDToken)
+	}
+	if !claims.VerifyAudience(p.config.ClientID, true) {
+		return Claims{}, fmt.Errorf("%w: unexpected audience", ErrInvalidIDToken)
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return Claims{}, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	c := Claims{Issuer: p.config.Issuer, Subject: sub}
+	c.Email, _ = claims["email"].(string)
+	c.EmailVerified, _ = claims["email_verified"].(bool)
+	c.Name, _ = claims["name"].(string)
+	c.Nonce, _ = claims["nonce"].(string)
+	return c, nil
+}
+
+// NewPKCE generates a code verifier, to be kept until the code is exchanged, and its challenge for the authorization URL
+func NewPKCE() (string, string, error) {
+	verifier, err := secret.New(32)
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, Challenge(verifier), nil
+}
+
+// Challenge derives the S256 code challenge of a code verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
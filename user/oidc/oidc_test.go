@@ -0,0 +1,263 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"github.com/gabrielseibel1/gaef/auth"
+	"github.com/gabrielseibel1/gaef/user/oidc"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// fakeProvider is a local OpenID provider that authorizes a single user, issuing codes bound to PKCE challenges
+type fakeProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	secret   string
+
+	// codes and the challenges and nonces they were issued for
+	challenges map[string]string
+	nonces     map[string]string
+
+	// claims overrides what goes in ID tokens
+	claims jwt.MapClaims
+	// signingKey signs ID tokens instead of key if set
+	signingKey *rsa.PrivateKey
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProvider{
+		key:        key,
+		clientID:   "dummyClientID",
+		secret:     "dummyClientSecret",
+		challenges: make(map[string]string),
+		nonces:     make(map[string]string),
+		claims:     jwt.MapClaims{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string][]auth.JWK{"keys": {auth.NewJWK("dummyKid", &key.PublicKey)}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		code := r.PostFormValue("code")
+		challenge, ok := p.challenges[code]
+		if !ok || r.PostFormValue("grant_type") != "authorization_code" ||
+			r.PostFormValue("client_id") != p.clientID || r.PostFormValue("client_secret") != p.secret ||
+			oidc.Challenge(r.PostFormValue("code_verifier")) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		delete(p.challenges, code)
+
+		claims := jwt.MapClaims{
+			"iss":            p.server.URL,
+			"aud":            p.clientID,
+			"sub":            "dummySubject",
+			"email":          "dummy@email.com",
+			"email_verified": true,
+			"name":           "Dummy Name",
+			"nonce":          p.nonces[code],
+			"exp":            time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "dummyKid"
+		signingKey := p.key
+		if p.signingKey != nil {
+			signingKey = p.signingKey
+		}
+		idToken, err := token.SignedString(signingKey)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "dummyAccessToken", "token_type": "Bearer", "id_token": idToken})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize does what the user agent does: follows the authorization URL and returns the code it is redirected with
+func (p *fakeProvider) authorize(t *testing.T, authorizationURL string) string {
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if got, want := q.Get("code_challenge_method"), "S256"; got != want {
+		t.Fatalf("got code challenge method %s, want %s", got, want)
+	}
+	code := "code-for-" + q.Get("state")
+	p.challenges[code] = q.Get("code_challenge")
+	p.nonces[code] = q.Get("nonce")
+	return code
+}
+
+func (p *fakeProvider) config() oidc.Config {
+	return oidc.Config{
+		Issuer:       p.server.URL,
+		ClientID:     p.clientID,
+		ClientSecret: p.secret,
+		RedirectURL:  "https://gaef.io/oidc/callback",
+	}
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		claims        jwt.MapClaims
+		signingKey    *rsa.PrivateKey
+		wrongVerifier bool
+		wantErr       bool
+	}{
+		{
+			name: "valid id token",
+		},
+		{
+			name:          "wrong code verifier",
+			wrongVerifier: true,
+			wantErr:       true,
+		},
+		{
+			name:    "other audience",
+			claims:  jwt.MapClaims{"aud": "otherClientID"},
+			wantErr: true,
+		},
+		{
+			name:   "audiences including the client",
+			claims: jwt.MapClaims{"aud": []string{"otherClientID", "dummyClientID"}},
+		},
+		{
+			name:    "other issuer",
+			claims:  jwt.MapClaims{"iss": "https://other.io"},
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			claims:  jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()},
+			wantErr: true,
+		},
+		{
+			name:    "missing subject",
+			claims:  jwt.MapClaims{"sub": ""},
+			wantErr: true,
+		},
+		{
+			name:       "signed with another key",
+			signingKey: otherKey,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// prepare test setup
+			fake := newFakeProvider(t)
+			if tt.claims != nil {
+				fake.claims = tt.claims
+			}
+			fake.signingKey = tt.signingKey
+			p, err := oidc.NewProvider(context.Background(), fake.config())
+			if err != nil {
+				t.Fatalf("NewProvider() error = %v", err)
+			}
+			verifier, challenge, err := oidc.NewPKCE()
+			if err != nil {
+				t.Fatal(err)
+			}
+			code := fake.authorize(t, p.AuthorizationURL("dummyState", "dummyNonce", challenge))
+			if tt.wrongVerifier {
+				verifier = "wrongVerifier"
+			}
+
+			// run code under test
+			claims, err := p.Exchange(context.Background(), code, verifier)
+
+			// assertions
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exchange() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			want := oidc.Claims{
+				Issuer:        fake.server.URL,
+				Subject:       "dummySubject",
+				Email:         "dummy@email.com",
+				EmailVerified: true,
+				Name:          "Dummy Name",
+				Nonce:         "dummyNonce",
+			}
+			if claims != want {
+				t.Errorf("Exchange() = %+v, want %+v", claims, want)
+			}
+		})
+	}
+}
+
+func TestNewProvider_IssuerMismatch(t *testing.T) {
+	// prepare test setup
+	fake := newFakeProvider(t)
+	config := fake.config()
+	config.Issuer += "/"
+
+	// run code under test
+	_, err := oidc.NewProvider(context.Background(), config)
+
+	// assertions
+	if err == nil {
+		t.Fatalf("NewProvider() error = nil, want an error")
+	}
+}
+
+func TestProvider_Exchange_InvalidIDTokenError(t *testing.T) {
+	// prepare test setup
+	fake := newFakeProvider(t)
+	fake.claims = jwt.MapClaims{"aud": "otherClientID"}
+	p, err := oidc.NewProvider(context.Background(), fake.config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := fake.authorize(t, p.AuthorizationURL("dummyState", "dummyNonce", challenge))
+
+	// run code under test
+	_, err = p.Exchange(context.Background(), code, verifier)
+
+	// assertions
+	if !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("Exchange() error = %v, want %v", err, oidc.ErrInvalidIDToken)
+	}
+}
@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"context"
+	"github.com/gabrielseibel1/gaef/messenger"
+	"github.com/gabrielseibel1/gaef/types"
+	"time"
+)
+
+type EventType string
+
+const (
+	UserUpdated EventType = "user-updated"
+	UserDeleted EventType = "user-deleted"
+)
+
+// Event is a message waiting to be sent to the broker, stored together with the change that caused it
+type Event struct {
+	ID        string     `bson:"id"`
+	Type      EventType  `bson:"type"`
+	User      types.User `bson:"user"`
+	CreatedAt time.Time  `bson:"createdAt"`
+}
+
+func NewEvent(eventType EventType, user types.User) Event {
+	return Event{
+		ID:        messenger.NewEventID(),
+		Type:      eventType,
+		User:      user,
+		CreatedAt: time.Now(),
+	}
+}
+
+// dependencies
+
+type UpdateMessenger interface {
+	SendUserUpdatedMessage(ctx context.Context, eventID string, user types.User) error
+}
+type DeleteMessenger interface {
+	SendUserDeletedMessage(ctx context.Context, eventID, userID string) error
+}
+type ErasureRecorder interface {
+	RecordErasure(ctx context.Context, userID, service string) error
+}
+
+// implementation
+
+// NewRelay creates a relay of the outbox of users, which sends the events of each user in order
+func NewRelay(
+	reader messenger.PendingEventsReader[Event],
+	acknowledger messenger.EventAcknowledger[Event],
+	updateMessenger UpdateMessenger,
+	deleteMessenger DeleteMessenger,
+	erasureRecorder ErasureRecorder,
+	interval time.Duration,
+	maxBackoff time.Duration,
+) messenger.Relay[Event] {
+	key := func(event Event) string { return event.User.ID }
+	publish := func(ctx context.Context, event Event) error {
+		switch event.Type {
+		case UserUpdated:
+			return updateMessenger.SendUserUpdatedMessage(ctx, event.ID, event.User)
+		case UserDeleted:
+			if err := deleteMessenger.SendUserDeletedMessage(ctx, event.ID, event.User.ID); err != nil {
+				return err
+			}
+			// acknowledging the last event of a deleted user removes it, so its erasure is recorded
+			// right before, to be retried along with the event if recording fails
+			return erasureRecorder.RecordErasure(ctx, event.User.ID, messenger.ServiceUser)
+		}
+		return nil
+	}
+	return messenger.NewRelay[Event](reader, acknowledger, key, publish, interval, maxBackoff)
+}
@@ -0,0 +1,124 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gabrielseibel1/gaef/messenger"
+	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/user/outbox"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mockPublisher struct {
+	// receive
+	exchanges  []string
+	messageIDs []string
+
+	// return
+	errs []error
+}
+
+func (m *mockPublisher) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	m.exchanges = append(m.exchanges, exchange)
+	m.messageIDs = append(m.messageIDs, msg.MessageId)
+	if len(m.errs) == 0 {
+		return nil
+	}
+	err := m.errs[0]
+	m.errs = m.errs[1:]
+	return err
+}
+
+type mockStore struct {
+	// receive
+	acknowledged []string
+	erased       []string
+
+	// return
+	events []outbox.Event
+}
+
+func (m *mockStore) ReadPendingEvents(ctx context.Context) ([]outbox.Event, error) {
+	return m.events, nil
+}
+
+func (m *mockStore) AcknowledgeEvent(ctx context.Context, event outbox.Event) error {
+	m.acknowledged = append(m.acknowledged, event.ID)
+	return nil
+}
+
+func (m *mockStore) RecordErasure(ctx context.Context, userID, service string) error {
+	m.erased = append(m.erased, service+":"+userID)
+	return nil
+}
+
+var (
+	dummyError  = errors.New("dummy-error")
+	dummyUser1  = types.User{ID: "dummy-id-1", Name: "dummy-name-1"}
+	dummyUser2  = types.User{ID: "dummy-id-2", Name: "dummy-name-2"}
+	dummyEvents = []outbox.Event{
+		{ID: "1", Type: outbox.UserUpdated, User: dummyUser1},
+		{ID: "2", Type: outbox.UserUpdated, User: dummyUser2},
+		{ID: "3", Type: outbox.UserDeleted, User: types.User{ID: dummyUser1.ID}},
+	}
+)
+
+// the relay itself is tested in messenger, this tests the events of users are published where they belong, in order
+func TestNewRelay(t *testing.T) {
+	tests := []struct {
+		name             string
+		store            *mockStore
+		publisher        *mockPublisher
+		wantErr          bool
+		wantExchanges    []string
+		wantMessageIDs   []string
+		wantAcknowledged []string
+		wantErased       []string
+	}{
+		{
+			name:             "drain ok",
+			store:            &mockStore{events: dummyEvents},
+			publisher:        &mockPublisher{},
+			wantErr:          false,
+			wantExchanges:    []string{"updates", "updates", "deletes"},
+			wantMessageIDs:   []string{"1", "2", "3"},
+			wantAcknowledged: []string{"1", "2", "3"},
+			wantErased:       []string{"user:dummy-id-1"},
+		},
+		{
+			name:             "drain publisher error keeps events of the user in order",
+			store:            &mockStore{events: dummyEvents},
+			publisher:        &mockPublisher{errs: []error{dummyError}},
+			wantErr:          true,
+			wantExchanges:    []string{"updates", "updates"},
+			wantMessageIDs:   []string{"1", "2"},
+			wantAcknowledged: []string{"2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := messenger.New(json.Marshal, "dummy-source", "updates", "deletes", "erasures", tt.publisher)
+			r := outbox.NewRelay(tt.store, tt.store, m, m, tt.store, time.Millisecond, time.Millisecond)
+
+			if err := r.Drain(context.TODO()); (err != nil) != tt.wantErr {
+				t.Errorf("Drain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got, want := tt.publisher.exchanges, tt.wantExchanges; !reflect.DeepEqual(got, want) {
+				t.Errorf("Drain() published to %v, want %v", got, want)
+			}
+			if got, want := tt.publisher.messageIDs, tt.wantMessageIDs; !reflect.DeepEqual(got, want) {
+				t.Errorf("Drain() published message ids %v, want %v", got, want)
+			}
+			if got, want := tt.store.acknowledged, tt.wantAcknowledged; !reflect.DeepEqual(got, want) {
+				t.Errorf("Drain() acknowledged %v, want %v", got, want)
+			}
+			if got, want := tt.store.erased, tt.wantErased; !reflect.DeepEqual(got, want) {
+				t.Errorf("Drain() recorded erasures %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package search
+
+import (
+	"github.com/gabrielseibel1/gaef/types/cursor"
+	"strings"
+)
+
+// Query is a page of a search for users by their name or email
+type Query struct {
+	Text string
+	// FullText matches whole words anywhere in the name or email, ranked by relevance,
+	// rather than names and emails that start with Text
+	FullText bool
+	After    *cursor.Cursor
+	Limit    int64
+}
+
+// Prefixes are what prefix searches match the start of: the name from each of its words on, and the email, lower-cased.
+// Stored along with the user and indexed, they let prefix searches scan only the users that match.
+func Prefixes(name, email string) []string {
+	words := strings.Fields(strings.ToLower(name))
+	prefixes := make([]string, 0, len(words)+1)
+	for i := range words {
+		prefixes = append(prefixes, strings.Join(words[i:], " "))
+	}
+	if email != "" {
+		prefixes = append(prefixes, strings.ToLower(email))
+	}
+	return prefixes
+}
@@ -0,0 +1,33 @@
+package search_test
+
+import (
+	"github.com/gabrielseibel1/gaef/user/search"
+	"reflect"
+	"testing"
+)
+
+func TestPrefixes(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		want  []string
+	}{
+		{
+			name:  "Gabriel  Seibel",
+			email: "Gabriel.Seibel@tuta.io",
+			want:  []string{"gabriel seibel", "seibel", "gabriel.seibel@tuta.io"},
+		},
+		{
+			name: "Gabriel",
+			want: []string{"gabriel"},
+		},
+		{
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		if got := search.Prefixes(tt.name, tt.email); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Prefixes(%q, %q) = %q, want %q", tt.name, tt.email, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package session
+
+import (
+	"errors"
+	"github.com/gabrielseibel1/gaef/user/secret"
+	"strings"
+	"time"
+)
+
+// Session is a login of a user, kept alive by rotating its refresh token.
+// Only the hash of the current refresh token is stored.
+type Session struct {
+	ID               string    `bson:"_id" json:"id"`
+	UserID           string    `bson:"userID" json:"userID"`
+	RefreshTokenHash string    `bson:"refreshTokenHash" json:"-"`
+	UserAgent        string    `bson:"userAgent" json:"userAgent"`
+	CreatedAt        time.Time `bson:"createdAt" json:"createdAt"`
+	RefreshedAt      time.Time `bson:"refreshedAt" json:"refreshedAt"`
+	ExpiresAt        time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+var ErrMalformedRefreshToken = errors.New("malformed refresh token")
+
+// New starts a session for the user that expires after ttl unless refreshed, returning it along with its refresh token
+func New(userID, userAgent string, ttl time.Duration) (Session, string, error) {
+	id, err := secret.New(12)
+	if err != nil {
+		return Session{}, "", err
+	}
+	refreshToken, hash, err := NewRefreshToken(id)
+	if err != nil {
+		return Session{}, "", err
+	}
+	now := time.Now()
+	return Session{
+		ID:               id,
+		UserID:           userID,
+		RefreshTokenHash: hash,
+		UserAgent:        userAgent,
+		CreatedAt:        now,
+		RefreshedAt:      now,
+		ExpiresAt:        now.Add(ttl),
+	}, refreshToken, nil
+}
+
+// NewRefreshToken generates a refresh token for the session, returning it and the hash to be stored
+func NewRefreshToken(sessionID string) (string, string, error) {
+	s, err := secret.New(32)
+	if err != nil {
+		return "", "", err
+	}
+	token := sessionID + "." + s
+	return token, secret.Hash(token), nil
+}
+
+// ParseRefreshToken returns the ID of the session the refresh token belongs to and the hash of the token
+func ParseRefreshToken(token string) (string, string, error) {
+	sessionID, s, ok := strings.Cut(token, ".")
+	if !ok || sessionID == "" || s == "" {
+		return "", "", ErrMalformedRefreshToken
+	}
+	return sessionID, secret.Hash(token), nil
+}
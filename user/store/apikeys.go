@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"github.com/gabrielseibel1/gaef/user/apikey"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoAPIKeyStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAPIKeyStore(collection *mongo.Collection) *MongoAPIKeyStore {
+	return &MongoAPIKeyStore{
+		collection: collection,
+	}
+}
+
+func (as MongoAPIKeyStore) CreateAPIKey(ctx context.Context, k apikey.APIKey) error {
+	_, err := as.collection.InsertOne(ctx, k)
+	return err
+}
+
+// ReadAPIKey reads an API key that didn't expire, given its ID
+func (as MongoAPIKeyStore) ReadAPIKey(ctx context.Context, id string) (apikey.APIKey, error) {
+	res := as.collection.FindOne(ctx, bson.M{"_id": id, "expiresAt": bson.M{"$gt": time.Now()}})
+	if res.Err() != nil {
+		return apikey.APIKey{}, res.Err()
+	}
+
+	var k apikey.APIKey
+	err := res.Decode(&k)
+	return k, err
+}
+
+func (as MongoAPIKeyStore) ReadAPIKeys(ctx context.Context, userID string) ([]apikey.APIKey, error) {
+	cursor, err := as.collection.Find(
+		ctx,
+		bson.M{"userID": userID, "expiresAt": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.M{"createdAt": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]apikey.APIKey, 0)
+	err = cursor.All(ctx, &keys)
+	return keys, err
+}
+
+// UseAPIKey reads an API key that didn't expire, if the given hash is the one of the key, and records it was used
+func (as MongoAPIKeyStore) UseAPIKey(ctx context.Context, id, hash string) (apikey.APIKey, error) {
+	now := time.Now()
+	res := as.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "hash": hash, "expiresAt": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"lastUsedAt": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+	if res.Err() != nil {
+		return apikey.APIKey{}, res.Err()
+	}
+
+	var k apikey.APIKey
+	err := res.Decode(&k)
+	return k, err
+}
+
+// RevokeAPIKeys deletes all API keys of a user
+func (as MongoAPIKeyStore) RevokeAPIKeys(ctx context.Context, userID string) error {
+	_, err := as.collection.DeleteMany(ctx, bson.M{"userID": userID})
+	return err
+}
+
+func (as MongoAPIKeyStore) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	res, err := as.collection.DeleteOne(ctx, bson.M{"_id": id, "userID": userID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"github.com/gabrielseibel1/gaef/user/limiter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAttemptStore keeps failed login attempts, shared by all instances of the service.
+// Expired attempts are ignored, a TTL index on expiresAt lets mongoDB clean them up, see CreateExpiryIndex.
+type MongoAttemptStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAttemptStore(collection *mongo.Collection) *MongoAttemptStore {
+	return &MongoAttemptStore{
+		collection: collection,
+	}
+}
+
+// CreateExpiryIndex creates the TTL index by which mongoDB removes attempts once they expire, if it doesn't exist yet
+func (as MongoAttemptStore) CreateExpiryIndex(ctx context.Context) error {
+	_, err := as.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetName("login-attempt-expiry").SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (as MongoAttemptStore) ReadAttempts(ctx context.Context, key string) (limiter.Attempts, error) {
+	res := as.collection.FindOne(ctx, bson.M{"_id": key, "expiresAt": bson.M{"$gt": time.Now()}})
+	if res.Err() == mongo.ErrNoDocuments {
+		return limiter.Attempts{}, nil
+	}
+	if res.Err() != nil {
+		return limiter.Attempts{}, res.Err()
+	}
+
+	var a limiter.Attempts
+	err := res.Decode(&a)
+	return a, err
+}
+
+func (as MongoAttemptStore) AddFailure(ctx context.Context, key string, now, expiresAt time.Time) (limiter.Attempts, error) {
+	res := as.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": key},
+		bson.A{bson.M{"$set": bson.M{
+			"failures": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$expiresAt", now}},
+				bson.M{"$add": bson.A{"$failures", 1}},
+				1,
+			}},
+			"lastFailureAt": now,
+			"expiresAt":     expiresAt,
+		}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	)
+	if res.Err() != nil {
+		return limiter.Attempts{}, res.Err()
+	}
+
+	var a limiter.Attempts
+	err := res.Decode(&a)
+	return a, err
+}
+
+func (as MongoAttemptStore) DeleteAttempts(ctx context.Context, key string) error {
+	_, err := as.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/gabrielseibel1/gaef/user/erasure"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoErasureStore keeps track of the erasure of deleted users, one document per user.
+// Both requests and confirmations upsert, as confirmations may be consumed before the request is stored.
+type MongoErasureStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoErasureStore(collection *mongo.Collection) *MongoErasureStore {
+	return &MongoErasureStore{
+		collection: collection,
+	}
+}
+
+func (es MongoErasureStore) CreateErasure(ctx context.Context, userID string, services []string) error {
+	_, err := es.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"requestedAt": time.Now(), "services": services}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (es MongoErasureStore) RecordErasure(ctx context.Context, userID, service string) error {
+	// the service names a field, so it must not be able to reach others
+	if service == "" || strings.ContainsAny(service, ".$") {
+		return errors.New("invalid service name")
+	}
+	_, err := es.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"erasedAt." + service: time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (es MongoErasureStore) ReadErasure(ctx context.Context, userID string) (erasure.Erasure, error) {
+	res := es.collection.FindOne(ctx, bson.M{"_id": userID, "requestedAt": bson.M{"$exists": true}})
+	if res.Err() != nil {
+		return erasure.Erasure{}, res.Err()
+	}
+
+	var e erasure.Erasure
+	err := res.Decode(&e)
+	return e, err
+}
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"github.com/gabrielseibel1/gaef/user/keys"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoKeyStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoKeyStore(collection *mongo.Collection) *MongoKeyStore {
+	return &MongoKeyStore{
+		collection: collection,
+	}
+}
+
+func (ks MongoKeyStore) ReadKeys(ctx context.Context) ([]keys.Key, error) {
+	cursor, err := ks.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var k []keys.Key
+	err = cursor.All(ctx, &k)
+	return k, err
+}
+
+func (ks MongoKeyStore) CreateKey(ctx context.Context, key keys.Key) error {
+	_, err := ks.collection.InsertOne(ctx, key)
+	return err
+}
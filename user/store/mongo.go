@@ -4,10 +4,19 @@ import (
 	"context"
 	"errors"
 	"github.com/gabrielseibel1/gaef/types"
+	"github.com/gabrielseibel1/gaef/types/cursor"
+	"github.com/gabrielseibel1/gaef/user/mfa"
+	"github.com/gabrielseibel1/gaef/user/oidc"
+	"github.com/gabrielseibel1/gaef/user/outbox"
+	"github.com/gabrielseibel1/gaef/user/search"
+	"regexp"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type MongoStore struct {
@@ -29,7 +38,10 @@ func (ms MongoStore) Create(ctx context.Context, user types.UserWithHashedPasswo
 
 	id := res.InsertedID.(primitive.ObjectID).Hex()
 	user.User.ID = id
-	err = ms.Update(ctx, user.User)
+	_, err = ms.collection.UpdateOne(ctx, bson.M{"_id": res.InsertedID}, bson.M{"$set": bson.M{
+		"user":           user.User,
+		"searchPrefixes": search.Prefixes(user.Name, user.Email),
+	}})
 	if err != nil {
 		return "", err
 	}
@@ -43,7 +55,7 @@ func (ms MongoStore) ReadByID(ctx context.Context, id string) (types.User, error
 		return types.User{}, err
 	}
 
-	res := ms.collection.FindOne(ctx, bson.M{"_id": hexID})
+	res := ms.collection.FindOne(ctx, bson.M{"_id": hexID, "deleted": bson.M{"$ne": true}})
 	if res.Err() != nil {
 		return types.User{}, res.Err()
 	}
@@ -57,7 +69,7 @@ func (ms MongoStore) ReadByID(ctx context.Context, id string) (types.User, error
 }
 
 func (ms MongoStore) ReadSensitiveByEmail(ctx context.Context, email string) (types.UserWithHashedPassword, error) {
-	res := ms.collection.FindOne(ctx, bson.M{"user.email": email})
+	res := ms.collection.FindOne(ctx, bson.M{"user.email": email, "deleted": bson.M{"$ne": true}})
 	if res.Err() != nil {
 		return types.UserWithHashedPassword{}, res.Err()
 	}
@@ -70,13 +82,36 @@ func (ms MongoStore) ReadSensitiveByEmail(ctx context.Context, email string) (ty
 	return user, err
 }
 
+// Update sets the user, bumping its version, and records a user-updated event in the outbox, atomically.
+// Changing the email makes the user unverified again.
 func (ms MongoStore) Update(ctx context.Context, user types.User) error {
 	hexID, err := primitive.ObjectIDFromHex(user.ID)
 	if err != nil {
 		return err
 	}
 
-	res, err := ms.collection.UpdateOne(ctx, bson.M{"_id": hexID}, bson.M{"$set": bson.M{"user": user}})
+	// the event carries the new version, so that services with copies of the user can leave out older events delivered late
+	updated := bson.M{"$mergeObjects": bson.A{
+		bson.M{"$literal": user},
+		bson.M{"version": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$user.version", 0}}, 1}}},
+	}}
+	// a pipeline update, so that the old email can be compared with the new one
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "deleted": bson.M{"$ne": true}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"verified":       bson.M{"$and": bson.A{"$verified", bson.M{"$eq": bson.A{"$user.email", user.Email}}}},
+			"user":           updated,
+			"searchPrefixes": bson.M{"$literal": search.Prefixes(user.Name, user.Email)},
+			"outbox": bson.M{"$concatArrays": bson.A{
+				bson.M{"$ifNull": bson.A{"$outbox", bson.A{}}},
+				bson.A{bson.M{"$mergeObjects": bson.A{
+					bson.M{"$literal": outbox.NewEvent(outbox.UserUpdated, user)},
+					bson.M{"user": updated},
+				}}},
+			}},
+		}}}},
+	)
 	if err != nil {
 		return err
 	}
@@ -86,18 +121,570 @@ func (ms MongoStore) Update(ctx context.Context, user types.User) error {
 	return nil
 }
 
+// Delete marks the user as deleted and records a user-deleted event in the outbox, atomically.
+// The document is removed once the outbox is drained, see AcknowledgeEvent.
 func (ms MongoStore) Delete(ctx context.Context, id string) error {
 	hexID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
 
-	res, err := ms.collection.DeleteOne(ctx, bson.M{"_id": hexID})
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "deleted": bson.M{"$ne": true}},
+		bson.M{
+			"$set":  bson.M{"deleted": true},
+			"$push": bson.M{"outbox": outbox.NewEvent(outbox.UserDeleted, types.User{ID: id})},
+		},
+	)
 	if err != nil {
 		return err
 	}
-	if res.DeletedCount == 0 {
+	if res.MatchedCount == 0 {
+		return errors.New("no such user")
+	}
+	return nil
+}
+
+func (ms MongoStore) ReadSensitiveByID(ctx context.Context, id string) (types.UserWithHashedPassword, error) {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.UserWithHashedPassword{}, err
+	}
+
+	res := ms.collection.FindOne(ctx, bson.M{"_id": hexID, "deleted": bson.M{"$ne": true}})
+	if res.Err() != nil {
+		return types.UserWithHashedPassword{}, res.Err()
+	}
+
+	var user types.UserWithHashedPassword
+	err = res.Decode(&user)
+	if err != nil {
+		return types.UserWithHashedPassword{}, err
+	}
+	return user, err
+}
+
+func (ms MongoStore) UpdatePassword(ctx context.Context, id, hashedPassword string) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"hashedPassword": hashedPassword}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
 		return errors.New("no such user")
 	}
 	return nil
 }
+
+// RehashPassword replaces the hash of the password of the user, as long as the password wasn't changed meanwhile
+func (ms MongoStore) RehashPassword(ctx context.Context, id, oldHash, newHash string) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "hashedPassword": oldHash, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"hashedPassword": newHash}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("no such user with the old hash")
+	}
+	return nil
+}
+
+// CreatePasswordReset stores the hash of a reset token for the user, replacing any previous one
+func (ms MongoStore) CreatePasswordReset(ctx context.Context, id, tokenHash string, expiresAt time.Time) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"passwordReset": bson.M{"tokenHash": tokenHash, "expiresAt": expiresAt}}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("no such user")
+	}
+	return nil
+}
+
+// ResetPassword sets the password of the user with an unexpired reset token, consuming the token atomically
+// so that it can't be used twice. It returns the ID of the user.
+func (ms MongoStore) ResetPassword(ctx context.Context, tokenHash, hashedPassword string) (string, error) {
+	res := ms.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"passwordReset.tokenHash": tokenHash,
+			"passwordReset.expiresAt": bson.M{"$gt": time.Now()},
+			"deleted":                 bson.M{"$ne": true},
+		},
+		bson.M{
+			"$set":   bson.M{"hashedPassword": hashedPassword},
+			"$unset": bson.M{"passwordReset": ""},
+		},
+		options.FindOneAndUpdate().SetProjection(bson.M{"_id": 1}),
+	)
+	if res.Err() != nil {
+		return "", res.Err()
+	}
+
+	var doc struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	err := res.Decode(&doc)
+	if err != nil {
+		return "", err
+	}
+	return doc.ID.Hex(), nil
+}
+
+// VerifyEmail marks the user as verified, as long as the email is still the one that was verified
+func (ms MongoStore) VerifyEmail(ctx context.Context, id, email string) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "user.email": email, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"verified": true}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("no such user")
+	}
+	return nil
+}
+
+// CreateIdentityIndex creates the index that keeps an identity from being linked to more than one user, if it doesn't exist yet
+func (ms MongoStore) CreateIdentityIndex(ctx context.Context) error {
+	_, err := ms.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "identities.issuer", Value: 1}, {Key: "identities.subject", Value: 1}},
+		Options: options.Index().
+			SetName("user-identities").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"identities.subject": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+// VerifyLegacyUsers marks the users stored before emails were verified as verified, so that they aren't locked out.
+// Users stored since always have the field, so it is safe to run on every start.
+func (ms MongoStore) VerifyLegacyUsers(ctx context.Context) error {
+	_, err := ms.collection.UpdateMany(ctx, bson.M{"verified": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"verified": true}})
+	return err
+}
+
+// ReadSensitiveByIdentity reads the user an identity at an OpenID provider is linked to
+func (ms MongoStore) ReadSensitiveByIdentity(ctx context.Context, issuer, subject string) (types.UserWithHashedPassword, error) {
+	res := ms.collection.FindOne(ctx, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"issuer": issuer, "subject": subject}},
+		"deleted":    bson.M{"$ne": true},
+	})
+	if res.Err() != nil {
+		return types.UserWithHashedPassword{}, res.Err()
+	}
+
+	var user types.UserWithHashedPassword
+	err := res.Decode(&user)
+	if err != nil {
+		return types.UserWithHashedPassword{}, err
+	}
+	return user, err
+}
+
+// LinkIdentity lets the user log in with an identity at an OpenID provider.
+// It returns oidc.ErrIdentityLinked if another user linked the identity first, see CreateIdentityIndex.
+func (ms MongoStore) LinkIdentity(ctx context.Context, id string, identity types.Identity) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "deleted": bson.M{"$ne": true}},
+		bson.M{"$addToSet": bson.M{"identities": identity}},
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return oidc.ErrIdentityLinked
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("no such user")
+	}
+	return nil
+}
+
+// CreateSearchIndex creates the text index that full-text searches need and the index of the prefixes that prefix searches match,
+// if they don't exist yet
+func (ms MongoStore) CreateSearchIndex(ctx context.Context) error {
+	_, err := ms.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user.name", Value: "text"}, {Key: "user.email", Value: "text"}},
+			Options: options.Index().SetName("user-search"),
+		},
+		{
+			Keys:    bson.D{{Key: "searchPrefixes", Value: 1}},
+			Options: options.Index().SetName("user-search-prefixes"),
+		},
+	})
+	return err
+}
+
+// IndexLegacyUsers stores the search prefixes of the users stored before prefix searches were indexed, so that they can be found.
+// Users stored since always have them, so it is safe to run on every start.
+func (ms MongoStore) IndexLegacyUsers(ctx context.Context) error {
+	cursor, err := ms.collection.Find(
+		ctx,
+		bson.M{"searchPrefixes": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"user.name": 1, "user.email": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			User types.User         `bson:"user"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		_, err := ms.collection.UpdateOne(
+			ctx,
+			bson.M{"_id": doc.ID, "searchPrefixes": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"searchPrefixes": search.Prefixes(doc.User.Name, doc.User.Email)}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// SearchUsers reads a page of the users that match the query, along with the cursor of the next page if there is one.
+// Prefix searches match names with a word that starts with the text, or emails that start with it, regardless of case,
+// in the order users signed up. They only scan the matching entries of the index of search prefixes.
+// Full-text searches are ranked by relevance. Either way, emails are matched but not read.
+func (ms MongoStore) SearchUsers(ctx context.Context, q search.Query) ([]types.User, *cursor.Cursor, error) {
+	filter := bson.M{"deleted": bson.M{"$ne": true}, "suspended": bson.M{"$ne": true}}
+	pipeline := mongo.Pipeline{}
+	if q.FullText {
+		filter["$text"] = bson.M{"$search": q.Text}
+		pipeline = append(pipeline,
+			bson.D{{Key: "$match", Value: filter}},
+			bson.D{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+		)
+	} else {
+		// anchored and case-sensitive, so that the index bounds the scan to the prefixes that start with the text
+		filter["searchPrefixes"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.ToLower(q.Text))}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: filter}})
+	}
+
+	if q.After != nil {
+		afterID, err := primitive.ObjectIDFromHex(q.After.ID)
+		if err != nil {
+			return nil, nil, cursor.ErrMalformed
+		}
+		after := bson.M{"_id": bson.M{"$gt": afterID}}
+		if q.FullText {
+			after = bson.M{"$or": bson.A{
+				bson.M{"score": bson.M{"$lt": q.After.Score}},
+				bson.M{"score": q.After.Score, "_id": bson.M{"$gt": afterID}},
+			}}
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: after}})
+	}
+
+	sort := bson.D{{Key: "_id", Value: 1}}
+	if q.FullText {
+		sort = bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}
+	}
+	// one more than the page, to tell if there is a next one
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: sort}},
+		bson.D{{Key: "$limit", Value: q.Limit + 1}},
+		bson.D{{Key: "$project", Value: bson.M{"user.name": 1, "user.pictureUrl": 1, "score": 1}}},
+	)
+
+	results, err := ms.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, nil, err
+	}
+	var docs []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		User  types.User         `bson:"user"`
+		Score float64            `bson:"score"`
+	}
+	if err := results.All(ctx, &docs); err != nil {
+		return nil, nil, err
+	}
+
+	var next *cursor.Cursor
+	if int64(len(docs)) > q.Limit {
+		docs = docs[:q.Limit]
+		last := docs[len(docs)-1]
+		next = &cursor.Cursor{Score: last.Score, ID: last.ID.Hex()}
+	}
+	users := make([]types.User, 0, len(docs))
+	for _, doc := range docs {
+		doc.User.ID = doc.ID.Hex()
+		users = append(users, doc.User)
+	}
+	return users, next, nil
+}
+
+// ReadUsers reads a page of users in the order they signed up, those whose name or email contain query if it isn't empty
+func (ms MongoStore) ReadUsers(ctx context.Context, query string, skip, limit int64) ([]types.UserWithHashedPassword, error) {
+	filter := bson.M{"deleted": bson.M{"$ne": true}}
+	if query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+		filter["$or"] = bson.A{bson.M{"user.name": pattern}, bson.M{"user.email": pattern}}
+	}
+
+	cursor, err := ms.collection.Find(
+		ctx,
+		filter,
+		options.Find().SetSort(bson.M{"_id": 1}).SetSkip(skip).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]types.UserWithHashedPassword, 0)
+	err = cursor.All(ctx, &users)
+	return users, err
+}
+
+func (ms MongoStore) SetSuspended(ctx context.Context, id string, suspended bool) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"suspended": suspended}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("no such user")
+	}
+	return nil
+}
+
+func (ms MongoStore) SetRole(ctx context.Context, id, role string) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"role": role}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("no such user")
+	}
+	return nil
+}
+
+// CreateMFAEnrollment sets a pending TOTP secret for the user, as long as it has no second factor enabled yet
+func (ms MongoStore) CreateMFAEnrollment(ctx context.Context, id, secret string) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "mfaEnabled": bson.M{"$ne": true}, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"mfa.pendingSecret": secret}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("no such user without a second factor")
+	}
+	return nil
+}
+
+func (ms MongoStore) ReadMFA(ctx context.Context, id string) (mfa.Enrollment, error) {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return mfa.Enrollment{}, err
+	}
+
+	res := ms.collection.FindOne(
+		ctx,
+		bson.M{"_id": hexID, "deleted": bson.M{"$ne": true}},
+		options.FindOne().SetProjection(bson.M{"mfa": 1}),
+	)
+	if res.Err() != nil {
+		return mfa.Enrollment{}, res.Err()
+	}
+
+	var doc struct {
+		MFA mfa.Enrollment `bson:"mfa"`
+	}
+	err = res.Decode(&doc)
+	return doc.MFA, err
+}
+
+// EnableMFA turns the pending secret of the user into its second factor, replacing any recovery codes
+func (ms MongoStore) EnableMFA(ctx context.Context, id, secret string, recoveryCodeHashes []string, step int64) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "mfa.pendingSecret": secret, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{
+			"mfaEnabled": true,
+			"mfa":        mfa.Enrollment{Secret: secret, RecoveryCodeHashes: recoveryCodeHashes, LastUsedStep: step},
+		}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("no such pending enrollment")
+	}
+	return nil
+}
+
+// UseTOTPStep records that a code of the step was used, failing if a code of it or of a later step already was
+func (ms MongoStore) UseTOTPStep(ctx context.Context, id string, step int64) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "mfaEnabled": true, "mfa.lastUsedStep": bson.M{"$lt": step}, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"mfa.lastUsedStep": step}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("code already used")
+	}
+	return nil
+}
+
+// UseRecoveryCode consumes a recovery code of the user atomically, so that it can't be used twice
+func (ms MongoStore) UseRecoveryCode(ctx context.Context, id, codeHash string) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "mfaEnabled": true, "mfa.recoveryCodeHashes": codeHash, "deleted": bson.M{"$ne": true}},
+		bson.M{"$pull": bson.M{"mfa.recoveryCodeHashes": codeHash}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("no such recovery code")
+	}
+	return nil
+}
+
+func (ms MongoStore) ReplaceRecoveryCodes(ctx context.Context, id string, recoveryCodeHashes []string) error {
+	hexID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := ms.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": hexID, "mfaEnabled": true, "deleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"mfa.recoveryCodeHashes": recoveryCodeHashes}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("no such user with a second factor")
+	}
+	return nil
+}
+
+func (ms MongoStore) ReadPendingEvents(ctx context.Context) ([]outbox.Event, error) {
+	opts := options.Find().SetProjection(bson.M{"outbox": 1})
+	cursor, err := ms.collection.Find(ctx, bson.M{"outbox.0": bson.M{"$exists": true}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		Outbox []outbox.Event `bson:"outbox"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	var events []outbox.Event
+	for _, doc := range docs {
+		events = append(events, doc.Outbox...)
+	}
+	return events, nil
+}
+
+func (ms MongoStore) AcknowledgeEvent(ctx context.Context, event outbox.Event) error {
+	hexID, err := primitive.ObjectIDFromHex(event.User.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = ms.collection.UpdateOne(ctx, bson.M{"_id": hexID}, bson.M{"$pull": bson.M{"outbox": bson.M{"id": event.ID}}})
+	if err != nil {
+		return err
+	}
+
+	// a deleted user is only removed after all of its events are sent
+	_, err = ms.collection.DeleteOne(ctx, bson.M{"_id": hexID, "deleted": true, "outbox": bson.M{"$size": 0}})
+	return err
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"github.com/gabrielseibel1/gaef/user/session"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoSessionStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoSessionStore(collection *mongo.Collection) *MongoSessionStore {
+	return &MongoSessionStore{
+		collection: collection,
+	}
+}
+
+// CreateExpiryIndex creates the TTL index by which mongoDB removes sessions once they expire, if it doesn't exist yet.
+// Sessions are ignored from their expiry on, so the index only keeps the collection from growing.
+func (ss MongoSessionStore) CreateExpiryIndex(ctx context.Context) error {
+	_, err := ss.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetName("session-expiry").SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (ss MongoSessionStore) CreateSession(ctx context.Context, s session.Session) error {
+	_, err := ss.collection.InsertOne(ctx, s)
+	return err
+}
+
+// RotateRefreshToken replaces the refresh token of an active session, if the given one is its current token.
+// A session whose old token is presented again is revoked, as the token was likely stolen.
+func (ss MongoSessionStore) RotateRefreshToken(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) (session.Session, error) {
+	now := time.Now()
+	res := ss.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "refreshTokenHash": oldHash, "expiresAt": bson.M{"$gt": now}},
+		bson.M{"$set": bson.M{"refreshTokenHash": newHash, "refreshedAt": now, "expiresAt": expiresAt}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+	if res.Err() == mongo.ErrNoDocuments {
+		_, err := ss.collection.DeleteOne(ctx, bson.M{"_id": id, "refreshTokenHash": bson.M{"$ne": oldHash}})
+		if err != nil {
+			return session.Session{}, err
+		}
+	}
+	if res.Err() != nil {
+		return session.Session{}, res.Err()
+	}
+
+	var s session.Session
+	err := res.Decode(&s)
+	return s, err
+}
+
+func (ss MongoSessionStore) ReadSession(ctx context.Context, id string) (session.Session, error) {
+	res := ss.collection.FindOne(ctx, bson.M{"_id": id, "expiresAt": bson.M{"$gt": time.Now()}})
+	if res.Err() != nil {
+		return session.Session{}, res.Err()
+	}
+
+	var s session.Session
+	err := res.Decode(&s)
+	return s, err
+}
+
+func (ss MongoSessionStore) ReadSessions(ctx context.Context, userID string) ([]session.Session, error) {
+	cursor, err := ss.collection.Find(
+		ctx,
+		bson.M{"userID": userID, "expiresAt": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.M{"refreshedAt": -1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]session.Session, 0)
+	err = cursor.All(ctx, &sessions)
+	return sessions, err
+}
+
+func (ss MongoSessionStore) RevokeSession(ctx context.Context, userID, id string) error {
+	res, err := ss.collection.DeleteOne(ctx, bson.M{"_id": id, "userID": userID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (ss MongoSessionStore) RevokeSessions(ctx context.Context, userID string) error {
+	_, err := ss.collection.DeleteMany(ctx, bson.M{"userID": userID})
+	return err
+}
+
+// RevokeOtherSessions revokes all sessions of the user but the one to keep
+func (ss MongoSessionStore) RevokeOtherSessions(ctx context.Context, userID, keepID string) error {
+	_, err := ss.collection.DeleteMany(ctx, bson.M{"userID": userID, "_id": bson.M{"$ne": keepID}})
+	return err
+}